@@ -0,0 +1,403 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/spf13/viper"
+)
+
+var (
+	// Matches the first line of a resource, data or module block
+	blockHeader = regexp.MustCompile(`^\s*(resource|data|module)\s+"([^"]+)"(?:\s+"([^"]+)")?\s*\{`)
+	// Matches the source argument of a module block
+	moduleSource = regexp.MustCompile(`^\s*source\s*=\s*"([^"]+)"`)
+)
+
+// attributedChange is a planned change declared in a file changed on the branch.
+type attributedChange struct {
+	Address string // Resource address, e.g. module.network.aws_subnet.legacy
+	File    string // The changed file it stems from
+}
+
+// attribution splits the planned changes of a plan by whether they stem from
+// files changed on the branch, for --since-commit.
+type attribution struct {
+	Since  string             // The commit compared against
+	Branch []attributedChange // Changes declared in files changed since Since
+	Other  []string           // Addresses of the other changes, e.g. drift or upstream changes
+}
+
+// configIndex locates the blocks of a configuration directory.
+type configIndex struct {
+	Files   map[string]string // File declaring each block: "type.name", "data.type.name" or "module.name"
+	Sources map[string]string // Local source directory of each module call, by module name
+}
+
+// changedFiles lists the files changed on the branch since ref, as 'git diff
+// ref...HEAD' does from the repository root, so changes outside the current
+// directory, e.g. to a module it calls, are listed too. The files are
+// relative to the current directory, e.g. ../modules/vpc/main.tf.
+//
+// Parameters:
+//
+//	ctx - The context for the git commands.
+//	git - The GitRunner used.
+//	ref - The commit to compare against, e.g. origin/main.
+//
+// Returns:
+//
+//	[]string - The changed files, slash-separated.
+//	error - An error if ref is invalid or git fails.
+func changedFiles(ctx context.Context, git GitRunner, ref string) ([]string, error) {
+	if ref == "" || strings.HasPrefix(ref, "-") {
+		return nil, fmt.Errorf("invalid 'since-commit' (%q): not a commit", ref)
+	}
+	// The current directory, relative to the repository root, e.g. "stacks/db/"
+	prefix, err := git.Run(ctx, "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, fmt.Errorf("unable to locate the current directory in the repository: %w", err)
+	}
+	cwd := path.Clean(strings.TrimSpace(string(prefix)))
+	// --no-relative overrides diff.relative, which would hide files outside
+	out, err := git.Run(ctx, "diff", "--name-only", "--no-relative", ref+"...HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the files changed since %s: %w", ref, err)
+	}
+	var files []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		rel, relErr := filepath.Rel(cwd, line)
+		if relErr != nil {
+			return nil, fmt.Errorf("unable to list the files changed since %s: %w", ref, relErr)
+		}
+		files = append(files, filepath.ToSlash(rel))
+	}
+	Logger.Debugf("Files changed since %s: %v", ref, files)
+	return files, nil
+}
+
+// comparisonBase returns the commit the branch's changes are listed against,
+// for --skip-if-no-tf-changes and --changed-dirs-from-git: the remote branch
+// of --base, then --since-commit, then the remote branch 'baseRules' or the
+// repository's default branch give.
+//
+// Parameters:
+//
+//	ctx - The context for the git and gh commands.
+//	prBase - The branch passed with --base, normalized.
+//	rules - The rules from loadBaseRules.
+//	git - The GitRunner reading the current branch.
+//	defaultBranch - The default branch of the repository, looked up once per run.
+//
+// Returns:
+//
+//	string - The commit to compare against, e.g. origin/main.
+func comparisonBase(
+	ctx context.Context,
+	prBase string,
+	rules []baseRule,
+	git GitRunner,
+	defaultBranch *defaultBranchCache,
+) string {
+	if prBase != "" {
+		return baseRemote(ctx, git) + "/" + prBase
+	}
+	if since := viper.GetString("sinceCommit"); since != "" {
+		return since
+	}
+	branch := resolvePRBase("", currentBranch(ctx, git), rules, defaultBranch.Get(ctx))
+	return baseRemote(ctx, git) + "/" + branch
+}
+
+// defaultRemote is the remote compared against when neither 'remote' nor the
+// branch's upstream names one
+const defaultRemote = "origin"
+
+// baseRemote returns the remote whose branches comparisonBase compares
+// against: 'remote', else the remote of the current branch's upstream, else
+// defaultRemote.
+func baseRemote(ctx context.Context, git GitRunner) string {
+	if remote := viper.GetString("remote"); remote != "" {
+		return remote
+	}
+	out, err := git.Run(ctx, "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{u}")
+	if err != nil {
+		Logger.Debugf("No upstream for the current branch, comparing against %s: %v", defaultRemote, err)
+		return defaultRemote
+	}
+	// e.g. "origin/feature/vpc"
+	if remote, _, ok := strings.Cut(strings.TrimSpace(string(out)), "/"); ok && remote != "" {
+		return remote
+	}
+	return defaultRemote
+}
+
+// tfFileSuffixes are the files whose changes can change a plan, see isTFFile
+var tfFileSuffixes = []string{".tf", ".tofu", ".tfvars", ".tf.json", ".tofu.json", ".tfvars.json"}
+
+// lockFileName is the dependency lock file, whose changes upgrade providers
+const lockFileName = ".terraform.lock.hcl"
+
+// isTFFile reports whether a change to file can change a plan: a file of
+// tfFileSuffixes or the lock file.
+func isTFFile(file string) bool {
+	if path.Base(file) == lockFileName {
+		return true
+	}
+	for _, suffix := range tfFileSuffixes {
+		if strings.HasSuffix(file, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTFChanges reports whether a file that can change the plan of the
+// current directory changed on the branch since base: a Terraform or OpenTofu
+// file, see isTFFile, in the current directory or in a local module it calls.
+//
+// Parameters:
+//
+//	ctx - The context for the git commands.
+//	git - The GitRunner used.
+//	base - The commit to compare against, e.g. origin/main.
+//
+// Returns:
+//
+//	bool - Whether such a file changed.
+//	error - An error if the changed files can't be listed.
+func hasTFChanges(ctx context.Context, git GitRunner, base string) (bool, error) {
+	files, err := changedFiles(ctx, git, base)
+	if err != nil {
+		return false, err
+	}
+	modules := moduleSourceDirs(".")
+	for _, f := range files {
+		if !isTFFile(f) {
+			continue
+		}
+		if !strings.HasPrefix(f, "../") || inAnyDir(f, modules) {
+			Logger.Debugf("%s changed since %s", f, base)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// moduleSourceDirs returns the local source directories of the modules the
+// configuration in dir calls, and those they call in turn, relative to the
+// current directory like dir.
+func moduleSourceDirs(dir string) []string {
+	seen := map[string]bool{path.Clean(filepath.ToSlash(dir)): true}
+	var dirs []string
+	for queue := []string{dir}; len(queue) > 0; queue = queue[1:] {
+		idx, err := indexConfig(queue[0])
+		if err != nil {
+			Logger.Debugf("Unable to find the modules of %s: %v", queue[0], err)
+			continue
+		}
+		for _, source := range idx.Sources {
+			if source = path.Clean(source); !seen[source] {
+				seen[source] = true
+				dirs = append(dirs, source)
+				queue = append(queue, source)
+			}
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// inAnyDir reports whether file, slash-separated, is below one of dirs.
+func inAnyDir(file string, dirs []string) bool {
+	for _, dir := range dirs {
+		if dir == "." || strings.HasPrefix(file, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// indexConfig finds the resource, data and module blocks declared in the
+// .tf and .tofu files of dir. Blocks are matched by their first line, which
+// is how 'fmt' writes them.
+//
+// Parameters:
+//
+//	dir - The configuration directory.
+//
+// Returns:
+//
+//	configIndex - The blocks found, with paths joined to dir.
+//	error - Any error encountered reading the files.
+func indexConfig(dir string) (configIndex, error) {
+	idx := configIndex{Files: map[string]string{}, Sources: map[string]string{}}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return idx, fmt.Errorf("unable to read %s: %w", dir, err)
+	}
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".tf" && ext != ".tofu") {
+			continue
+		}
+		file := filepath.Join(dir, e.Name())
+		data, readErr := os.ReadFile(file) //nolint:gosec // a file of the configuration being planned
+		if readErr != nil {
+			return idx, fmt.Errorf("unable to read %s: %w", file, readErr)
+		}
+		indexFile(filepath.ToSlash(file), string(data), idx)
+	}
+	return idx, nil
+}
+
+// indexFile adds the blocks declared in one file to idx.
+func indexFile(file, content string, idx configIndex) {
+	module := "" // The module block being read, to find its source
+	depth := 0
+	for _, line := range strings.Split(content, "\n") {
+		if depth == 0 {
+			if m := blockHeader.FindStringSubmatch(line); m != nil {
+				switch m[1] {
+				case "resource":
+					idx.Files[m[2]+"."+m[3]] = file
+				case "data":
+					idx.Files["data."+m[2]+"."+m[3]] = file
+				case "module":
+					idx.Files["module."+m[2]] = file
+					module = m[2]
+				}
+			}
+		} else if depth == 1 && module != "" {
+			if m := moduleSource.FindStringSubmatch(line); m != nil && isLocalSource(m[1]) {
+				idx.Sources[module] = path.Join(path.Dir(file), m[1])
+			}
+		}
+		depth += strings.Count(line, "{") - strings.Count(line, "}")
+		if depth <= 0 {
+			depth = 0
+			module = ""
+		}
+	}
+}
+
+// isLocalSource reports whether a module source is a local directory.
+func isLocalSource(source string) bool {
+	return strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../")
+}
+
+// blockKey returns the key of the block declaring the resource at address in
+// the root module: "type.name", "data.type.name" or "module.name".
+func blockKey(address string) string {
+	if rest, ok := strings.CutPrefix(address, "module."); ok {
+		name, _, _ := strings.Cut(rest, ".")
+		name, _, _ = strings.Cut(name, "[")
+		return "module." + name
+	}
+	key, _, _ := strings.Cut(address, "[")
+	return key
+}
+
+// attributeChanges matches the planned changes of plan to the files changed
+// on the branch. A change stems from the branch when the file declaring its
+// block changed, or for a module with a local source, any file below it.
+//
+// Parameters:
+//
+//	plan - The structured plan.
+//	idx - The blocks of the planned configuration.
+//	changed - The files changed on the branch, from changedFiles.
+//	since - The commit compared against, for rendering.
+//
+// Returns:
+//
+//	*attribution - The changes split by whether they stem from the branch.
+func attributeChanges(plan *tfjson.Plan, idx configIndex, changed []string, since string) *attribution {
+	changedSet := make(map[string]bool, len(changed))
+	for _, f := range changed {
+		changedSet[f] = true
+	}
+	sortedChanged := append([]string(nil), changed...)
+	sort.Strings(sortedChanged)
+
+	a := &attribution{Since: since}
+	for _, rc := range plan.ResourceChanges {
+		if rc.Change == nil || rc.Change.Actions.NoOp() || rc.Change.Actions.Read() {
+			continue
+		}
+		key := blockKey(rc.Address)
+		file := ""
+		if declared, ok := idx.Files[key]; ok && changedSet[declared] {
+			file = declared
+		} else if source, ok := idx.Sources[strings.TrimPrefix(key, "module.")]; ok {
+			for _, f := range sortedChanged {
+				if strings.HasPrefix(f, source+"/") {
+					file = f
+					break
+				}
+			}
+		}
+		if file != "" {
+			a.Branch = append(a.Branch, attributedChange{Address: rc.Address, File: file})
+		} else {
+			a.Other = append(a.Other, rc.Address)
+		}
+	}
+	return a
+}
+
+// branchAttribution attributes the changes of the plan in dir for
+// --since-commit, or returns nil when it's not set or there's no structured
+// plan. The section is a review aid, so failing to read the configuration
+// only warns.
+func branchAttribution(dir string, plan *tfjson.Plan, changed []string, since string) *attribution {
+	if since == "" || plan == nil {
+		return nil
+	}
+	idx, err := indexConfig(dir)
+	if err != nil {
+		Logger.Warnf("Unable to attribute changes to this branch: %v", err)
+		return nil
+	}
+	return attributeChanges(plan, idx, changed, since)
+}
+
+// renderAttribution renders the body of the "Changes attributable to this
+// branch" section.
+func renderAttribution(a *attribution) string {
+	var sb strings.Builder
+	if len(a.Branch) == 0 {
+		fmt.Fprintf(&sb, "None of the planned changes stem from files changed since `%s`.\n", a.Since)
+	}
+	for _, c := range a.Branch {
+		fmt.Fprintf(&sb, "- `%s` (`%s`)\n", c.Address, c.File)
+	}
+	if len(a.Other) > 0 {
+		sb.WriteString("\nNot from files changed on this branch:\n\n")
+		for _, addr := range a.Other {
+			fmt.Fprintf(&sb, "- `%s`\n", addr)
+		}
+	}
+	return sb.String()
+}
+
+// attributionSummary returns the summary of the attribution section.
+func attributionSummary(a *attribution, dir string) string {
+	title := "Changes attributable to this branch"
+	if dir != "" {
+		title += " in " + dir
+	}
+	return fmt.Sprintf("%s (%d of %d)", title, len(a.Branch), len(a.Branch)+len(a.Other))
+}
@@ -0,0 +1,255 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangedFiles(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	diff := []string{"diff", "--name-only", "--no-relative", "origin/main...HEAD"}
+
+	t.Run("Files changed on the branch", func(t *testing.T) {
+		git := new(MockGitRunner)
+		git.On("Run", mock.Anything, []string{"rev-parse", "--show-prefix"}).Return([]byte("\n"), nil)
+		git.On("Run", mock.Anything, diff).Return([]byte("main.tf\nmodules/network/main.tf\n\n"), nil)
+
+		files, err := changedFiles(context.Background(), git, "origin/main")
+
+		require.NoError(t, err)
+		require.Equal(t, []string{"main.tf", "modules/network/main.tf"}, files)
+	})
+
+	t.Run("Files outside the current directory", func(t *testing.T) {
+		git := new(MockGitRunner)
+		git.On("Run", mock.Anything, []string{"rev-parse", "--show-prefix"}).Return([]byte("stacks/db/\n"), nil)
+		git.On("Run", mock.Anything, diff).
+			Return([]byte("stacks/db/main.tf\nmodules/network/main.tf\n.terraform.lock.hcl\n"), nil)
+
+		files, err := changedFiles(context.Background(), git, "origin/main")
+
+		require.NoError(t, err)
+		require.Equal(t, []string{"main.tf", "../../modules/network/main.tf", "../../.terraform.lock.hcl"}, files)
+	})
+
+	t.Run("Refs that look like options are rejected", func(t *testing.T) {
+		git := new(MockGitRunner)
+
+		_, err := changedFiles(context.Background(), git, "--output=x")
+
+		require.ErrorContains(t, err, "invalid 'since-commit'")
+		git.AssertNotCalled(t, "Run", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Unknown refs fail", func(t *testing.T) {
+		git := new(MockGitRunner)
+		git.On("Run", mock.Anything, []string{"rev-parse", "--show-prefix"}).Return([]byte("\n"), nil)
+		git.On("Run", mock.Anything, mock.Anything).Return(nil, errors.New("unknown revision"))
+
+		_, err := changedFiles(context.Background(), git, "nope")
+
+		require.ErrorContains(t, err, "unable to list the files changed since nope")
+	})
+}
+
+func TestComparisonBase(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	t.Cleanup(viper.Reset)
+	t.Setenv("GITHUB_HEAD_REF", "feature/vpc")
+	rules := []baseRule{{Prefix: "feature/", Base: "develop"}}
+	ctx := context.Background()
+
+	noUpstream := new(MockGitRunner)
+	noUpstream.On("Run", mock.Anything, []string{"rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{u}"}).
+		Return(nil, errors.New("git rev-parse: exit status 128: fatal: no upstream configured for branch"))
+	require.Equal(t, "origin/release", comparisonBase(ctx, "release", rules, noUpstream, newDefaultBranchCache(new(MockGhRunner))))
+
+	viper.Set("sinceCommit", "abc123")
+	require.Equal(t, "abc123", comparisonBase(ctx, "", rules, new(MockGitRunner), newDefaultBranchCache(new(MockGhRunner))))
+
+	viper.Set("sinceCommit", "")
+	gh := new(MockGhRunner)
+	gh.On("Run", mock.Anything, []string{"repo", "view", "--json", "defaultBranchRef", "--jq", ".defaultBranchRef.name"}).
+		Return([]byte("trunk\n"), nil)
+	defaultBranch := newDefaultBranchCache(gh)
+	require.Equal(t, "origin/develop", comparisonBase(ctx, "", rules, noUpstream, defaultBranch))
+	require.Equal(t, "origin/trunk", comparisonBase(ctx, "", nil, noUpstream, defaultBranch))
+	gh.AssertNumberOfCalls(t, "Run", 1)
+
+	t.Run("Remote", func(t *testing.T) {
+		upstream := new(MockGitRunner)
+		upstream.On("Run", mock.Anything, []string{"rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{u}"}).
+			Return([]byte("fork/feature/vpc\n"), nil)
+		require.Equal(t, "fork/release", comparisonBase(ctx, "release", rules, upstream, defaultBranch), "the upstream's remote")
+
+		viper.Set("remote", "upstream")
+		require.Equal(t, "upstream/release", comparisonBase(ctx, "release", rules, upstream, defaultBranch))
+	})
+}
+
+func TestBlockKey(t *testing.T) {
+	tests := map[string]string{
+		"random_password.db":                  "random_password.db",
+		"aws_instance.web[0]":                 "aws_instance.web",
+		`aws_instance.web["a.b"]`:             "aws_instance.web",
+		"data.aws_ami.ubuntu":                 "data.aws_ami.ubuntu",
+		"module.network.aws_vpc.main":         "module.network",
+		`module.network["eu"].aws_vpc.main`:   "module.network",
+		"module.app.module.db.aws_db.primary": "module.app",
+	}
+	for address, want := range tests {
+		require.Equal(t, want, blockKey(address), address)
+	}
+}
+
+func TestAttributeChanges(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	plan := loadPlanFixture(t, "changes.json")
+	t.Chdir("../testdata/attribution")
+
+	idx, err := indexConfig(".")
+	require.NoError(t, err)
+	require.Equal(t, "main.tf", idx.Files["random_password.db"])
+	require.Equal(t, "network.tf", idx.Files["module.network"])
+	require.Equal(t, "modules/network", idx.Sources["network"])
+	require.Equal(t, "modules/db", idx.Sources["db"])
+
+	t.Run("Changes declared in or below changed files", func(t *testing.T) {
+		a := attributeChanges(plan, idx, []string{"main.tf", "modules/network/main.tf"}, "origin/main")
+
+		require.Equal(t, []attributedChange{
+			{Address: "random_password.db", File: "main.tf"},
+			{Address: "module.network.aws_vpc.main", File: "modules/network/main.tf"},
+			{Address: "module.network.aws_subnet.legacy", File: "modules/network/main.tf"},
+		}, a.Branch)
+		require.Equal(t, []string{"module.db.aws_db_instance.main"}, a.Other, "no-op changes are left out")
+	})
+
+	t.Run("A changed module call", func(t *testing.T) {
+		a := attributeChanges(plan, idx, []string{"db.tf"}, "origin/main")
+
+		require.Equal(t, []attributedChange{{Address: "module.db.aws_db_instance.main", File: "db.tf"}}, a.Branch)
+		require.Len(t, a.Other, 3)
+	})
+
+	t.Run("Rendered in the Markdown", func(t *testing.T) {
+		a := attributeChanges(plan, idx, []string{"modules/network/main.tf"}, "origin/main")
+		var sb strings.Builder
+		sb.WriteString(attributionSummary(a, ""))
+		sb.WriteString("\n" + renderAttribution(a))
+
+		require.Equal(t, "Changes attributable to this branch (2 of 4)\n"+
+			"- `module.network.aws_vpc.main` (`modules/network/main.tf`)\n"+
+			"- `module.network.aws_subnet.legacy` (`modules/network/main.tf`)\n"+
+			"\nNot from files changed on this branch:\n\n"+
+			"- `random_password.db`\n"+
+			"- `module.db.aws_db_instance.main`\n", sb.String())
+	})
+
+	t.Run("Nothing changed on the branch", func(t *testing.T) {
+		a := attributeChanges(plan, idx, nil, "origin/main")
+
+		require.Empty(t, a.Branch)
+		require.Contains(t, renderAttribution(a), "None of the planned changes stem from files changed since `origin/main`.")
+		require.Equal(t, "Changes attributable to this branch in stacks/app (0 of 4)", attributionSummary(a, "stacks/app"))
+	})
+}
+
+func TestHasTFChanges(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	t.Chdir(t.TempDir())
+	// Ignore the user's git config, e.g. commit signing
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	git := &RealGitRunner{}
+	ctx := context.Background()
+	run := func(args ...string) {
+		t.Helper()
+		_, err := git.Run(ctx, args...)
+		require.NoError(t, err)
+	}
+	commit := func(name, content string) {
+		t.Helper()
+		require.NoError(t, os.MkdirAll(filepath.Dir(name), 0o700))
+		require.NoError(t, os.WriteFile(name, []byte(content), 0o600))
+		run("add", name)
+		run("commit", "-q", "-m", "Change "+name)
+	}
+	run("init", "-q", "-b", "main")
+	run("config", "user.email", "tp@example.com")
+	run("config", "user.name", "tp")
+	commit("main.tf", "resource \"random_pet\" \"name\" {}\n")
+	run("checkout", "-q", "-b", "feature")
+
+	t.Run("Unchanged", func(t *testing.T) {
+		commit("README.md", "# Infra\n")
+		commit("docs/main.tf.md", "Notes\n")
+
+		changed, err := hasTFChanges(ctx, git, "main")
+
+		require.NoError(t, err)
+		require.False(t, changed)
+	})
+
+	t.Run("Changed", func(t *testing.T) {
+		commit("envs/prod.tfvars", "region = \"eu-west-1\"\n")
+
+		changed, err := hasTFChanges(ctx, git, "main")
+
+		require.NoError(t, err)
+		require.True(t, changed)
+	})
+
+	t.Run("Modules called from outside the directory", func(t *testing.T) {
+		commit("stacks/app/main.tf", "module \"vpc\" {\n  source = \"../../modules/vpc\"\n}\n")
+		commit("modules/vpc/main.tf", "resource \"random_pet\" \"vpc\" {}\n")
+		head := func() string {
+			out, err := git.Run(ctx, "rev-parse", "HEAD")
+			require.NoError(t, err)
+			return strings.TrimSpace(string(out))
+		}
+		base := head()
+		t.Chdir(filepath.Join("stacks", "app"))
+
+		commit("../../modules/dns/main.tf", "resource \"random_pet\" \"dns\" {}\n")
+		changed, err := hasTFChanges(ctx, git, base)
+		require.NoError(t, err)
+		require.False(t, changed, "a module the directory doesn't call")
+
+		commit("../../modules/vpc/variables.tf", "variable \"cidr\" {}\n")
+		changed, err = hasTFChanges(ctx, git, base)
+		require.NoError(t, err)
+		require.True(t, changed, "a module the directory calls")
+
+		base = head()
+		commit(lockFileName, "# provider upgrade\n")
+		changed, err = hasTFChanges(ctx, git, base)
+		require.NoError(t, err)
+		require.True(t, changed, "the lock file")
+	})
+
+	t.Run("Unknown base", func(t *testing.T) {
+		_, err := hasTFChanges(ctx, git, "origin/main")
+
+		require.ErrorContains(t, err, "unable to list the files changed since origin/main")
+	})
+}
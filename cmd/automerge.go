@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// autoMergeMutation enables auto-merge on a pull request
+const autoMergeMutation = `mutation($pullRequestId: ID!, $mergeMethod: PullRequestMergeMethod!) {
+  enablePullRequestAutoMerge(input: {pullRequestId: $pullRequestId, mergeMethod: $mergeMethod}) {
+    pullRequest { number }
+  }
+}`
+
+// mergeMethods are the values of 'merge-method'
+var mergeMethods = []string{"merge", "squash", "rebase"}
+
+// errAutoMergeNotAllowed is returned when the repository doesn't allow auto-merge
+var errAutoMergeNotAllowed = errors.New("auto-merge is not allowed for this pull request")
+
+// autoMergeNotAllowedMessages are fragments of the GraphQL errors returned when
+// auto-merge can't be enabled because of the repository's settings
+var autoMergeNotAllowedMessages = []string{
+	"auto merge is not allowed",       // 'Allow auto-merge' is off in the repository settings
+	"protected branch rules not",      // The base branch has no required checks or reviews
+	"pull request is in clean status", // Nothing to wait for, the PR can be merged now
+}
+
+// defaultAutoMergeClient is the AutoMergeClient used outside of tests
+var defaultAutoMergeClient AutoMergeClient = &RealAutoMergeClient{runner: defaultGhRunner}
+
+// AutoMergeClient is an interface for enabling auto-merge on pull requests
+// This allows for dependency injection and easier testing
+type AutoMergeClient interface {
+	EnableAutoMerge(ctx context.Context, prURL, method string) error
+}
+
+// RealAutoMergeClient implements the AutoMergeClient interface with the GraphQL API through 'gh api'
+type RealAutoMergeClient struct {
+	runner GhRunner
+}
+
+// EnableAutoMerge enables auto-merge with the enablePullRequestAutoMerge mutation
+//
+// Parameters:
+//
+//	ctx - The context controlling the requests
+//	prURL - The URL of the pull request
+//	method - The GraphQL merge method, e.g. SQUASH
+//
+// Returns:
+//
+//	error - errAutoMergeNotAllowed if the repository doesn't allow it, or any other error encountered
+func (c *RealAutoMergeClient) EnableAutoMerge(ctx context.Context, prURL, method string) error {
+	out, err := c.runner.Run(ctx, "pr", "view", prURL, "--json", "id", "--jq", ".id")
+	if err != nil {
+		return fmt.Errorf("unable to look up pull request %s: %w", prURL, err)
+	}
+	id := strings.TrimSpace(string(out))
+	if id == "" {
+		return fmt.Errorf("unable to look up pull request %s: no ID returned", prURL)
+	}
+
+	_, err = c.runner.Run(
+		ctx,
+		"api", "graphql",
+		"-f", "query="+autoMergeMutation,
+		"-f", "pullRequestId="+id,
+		"-f", "mergeMethod="+method,
+	)
+	if err != nil {
+		msg := strings.ToLower(err.Error())
+		for _, fragment := range autoMergeNotAllowedMessages {
+			if strings.Contains(msg, fragment) {
+				return fmt.Errorf("%w: %w", errAutoMergeNotAllowed, err)
+			}
+		}
+		return fmt.Errorf("failed to enable auto-merge on %s: %w", prURL, err)
+	}
+	return nil
+}
+
+// parseMergeMethod validates 'merge-method' and returns its GraphQL value.
+func parseMergeMethod(method string) (string, error) {
+	method = strings.ToLower(strings.TrimSpace(method))
+	if !slices.Contains(mergeMethods, method) {
+		return "", fmt.Errorf(
+			"invalid 'merge-method' (%q): must be one of %s",
+			method,
+			strings.Join(mergeMethods, ", "),
+		)
+	}
+	return strings.ToUpper(method), nil
+}
+
+// enableAutoMerge enables auto-merge on a newly created pull request. A
+// repository that doesn't allow auto-merge only warns, the pull request
+// itself was created and is still useful.
+//
+// Parameters:
+//
+//	ctx - The context controlling the requests
+//	client - The AutoMergeClient used
+//	prURL - The URL of the pull request
+//	method - The GraphQL merge method from parseMergeMethod
+//
+// Returns:
+//
+//	error - Any error other than auto-merge not being allowed
+func enableAutoMerge(ctx context.Context, client AutoMergeClient, prURL, method string) error {
+	err := client.EnableAutoMerge(ctx, prURL, method)
+	if errors.Is(err, errAutoMergeNotAllowed) {
+		Logger.Warnf(
+			"Auto-merge wasn't enabled on %s: %v. Check that 'Allow auto-merge' is on in the repository settings.",
+			prURL,
+			err,
+		)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	Logger.Infof("Auto-merge (%s) enabled on %s", strings.ToLower(method), prURL)
+	return nil
+}
@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockAutoMergeClient is a mock implementation of AutoMergeClient
+type MockAutoMergeClient struct {
+	mock.Mock
+}
+
+func (m *MockAutoMergeClient) EnableAutoMerge(ctx context.Context, prURL, method string) error {
+	return m.Called(ctx, prURL, method).Error(0)
+}
+
+func TestRealAutoMergeClient(t *testing.T) {
+	const prURL = "https://github.com/o/r/pull/7"
+	viewArgs := []string{"pr", "view", prURL, "--json", "id", "--jq", ".id"}
+	mutationArgs := []string{
+		"api", "graphql",
+		"-f", "query=" + autoMergeMutation,
+		"-f", "pullRequestId=PR_kwDO123",
+		"-f", "mergeMethod=SQUASH",
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		runner := new(MockGhRunner)
+		runner.On("Run", mock.Anything, viewArgs).Return([]byte("PR_kwDO123\n"), nil)
+		runner.On("Run", mock.Anything, mutationArgs).Return([]byte(`{"data":{}}`), nil)
+
+		err := (&RealAutoMergeClient{runner: runner}).EnableAutoMerge(context.Background(), prURL, "SQUASH")
+
+		require.NoError(t, err)
+		runner.AssertExpectations(t)
+	})
+
+	t.Run("Not allowed on the repository", func(t *testing.T) {
+		runner := new(MockGhRunner)
+		runner.On("Run", mock.Anything, viewArgs).Return([]byte("PR_kwDO123\n"), nil)
+		runner.On("Run", mock.Anything, mutationArgs).Return(nil, errors.New(
+			"gh api: exit status 1: GraphQL: Pull request Auto merge is not allowed for this repository (enablePullRequestAutoMerge)",
+		))
+
+		err := (&RealAutoMergeClient{runner: runner}).EnableAutoMerge(context.Background(), prURL, "SQUASH")
+
+		require.ErrorIs(t, err, errAutoMergeNotAllowed)
+	})
+
+	t.Run("Other API errors", func(t *testing.T) {
+		runner := new(MockGhRunner)
+		runner.On("Run", mock.Anything, viewArgs).Return([]byte("PR_kwDO123\n"), nil)
+		runner.On("Run", mock.Anything, mutationArgs).Return(nil, errors.New("gh api: exit status 1: HTTP 502"))
+
+		err := (&RealAutoMergeClient{runner: runner}).EnableAutoMerge(context.Background(), prURL, "SQUASH")
+
+		require.ErrorContains(t, err, "failed to enable auto-merge")
+		require.NotErrorIs(t, err, errAutoMergeNotAllowed)
+	})
+}
+
+func TestEnableAutoMerge(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	const prURL = "https://github.com/o/r/pull/7"
+
+	t.Run("Success", func(t *testing.T) {
+		client := new(MockAutoMergeClient)
+		client.On("EnableAutoMerge", mock.Anything, prURL, "REBASE").Return(nil)
+
+		require.NoError(t, enableAutoMerge(context.Background(), client, prURL, "REBASE"))
+		client.AssertExpectations(t)
+	})
+
+	t.Run("Not allowed only warns", func(t *testing.T) {
+		client := new(MockAutoMergeClient)
+		client.On("EnableAutoMerge", mock.Anything, prURL, "MERGE").Return(errAutoMergeNotAllowed)
+
+		require.NoError(t, enableAutoMerge(context.Background(), client, prURL, "MERGE"))
+	})
+
+	t.Run("Other errors fail", func(t *testing.T) {
+		client := new(MockAutoMergeClient)
+		client.On("EnableAutoMerge", mock.Anything, prURL, "MERGE").Return(errors.New("HTTP 502"))
+
+		require.ErrorContains(t, enableAutoMerge(context.Background(), client, prURL, "MERGE"), "HTTP 502")
+	})
+}
+
+func TestParseMergeMethod(t *testing.T) {
+	for input, want := range map[string]string{"merge": "MERGE", "Squash": "SQUASH", " rebase ": "REBASE"} {
+		got, err := parseMergeMethod(input)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+
+	_, err := parseMergeMethod("fast-forward")
+	require.ErrorContains(t, err, "invalid 'merge-method'")
+}
@@ -0,0 +1,175 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// planSourceExts are the file suffixes whose modification invalidates a cached plan.
+var planSourceExts = []string{
+	".tf",
+	".tofu",
+	".tf.json",
+	".tofu.json",
+	".tfvars",
+	".tfvars.json",
+	".terraform.lock.hcl",
+}
+
+// planCacheKeyExt is the suffix of the file next to a cached plan recording
+// its planCacheKey.
+const planCacheKeyExt = ".cache-key"
+
+// planCacheKey identifies how a plan was made, so a plan made with another
+// binary, in another workspace or with other arguments isn't reused. The key
+// is a digest, so the values of --var aren't written to disk.
+//
+// Parameters:
+//
+//	binary - The binary that runs the plan.
+//	workspace - The workspace the plan runs in, from currentWorkspace.
+//	planArgs - The arguments of the plan from buildPlanOptions: -var, -var-file, -target and -exclude.
+//
+// Returns:
+//
+//	string - A digest of the parameters.
+func planCacheKey(binary, workspace string, planArgs []string) string {
+	fields := append([]string{binary, workspace}, planArgs...)
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// writePlanCacheKey records key next to the plan file when the plan cache is
+// enabled. A key that can't be written only costs a cache miss later.
+//
+// Parameters:
+//
+//	planPath - The path of the plan file just written.
+//	key - The planCacheKey of the plan.
+func writePlanCacheKey(planPath, key string) {
+	keyPath := planPath + planCacheKeyExt
+	if viper.GetDuration("planCacheTTL") <= 0 {
+		_ = os.Remove(keyPath) // A plan cached later must not match a stale key
+		return
+	}
+	if err := os.WriteFile(keyPath, []byte(key+"\n"), 0o600); err != nil {
+		Logger.Debugf("Unable to record the cache key of %s: %v", planPath, err)
+	}
+}
+
+// usePlanCache reports whether an existing plan file can be reused instead of
+// running a new plan, based on the 'planCacheTTL' and 'noCache' settings.
+//
+// Parameters:
+//
+//	planPath - The path of the plan file that would be reused.
+//	dir - The directory containing the configuration that was planned.
+//	key - The planCacheKey of the plan that would run.
+//
+// Returns:
+//
+//	bool - true if the cached plan is fresh and should be reused, false otherwise.
+func usePlanCache(planPath, dir, key string) bool {
+	ttl := viper.GetDuration("planCacheTTL")
+	if ttl <= 0 {
+		return false
+	}
+	if viper.GetBool("noCache") {
+		Logger.Debug("Plan cache disabled via --no-cache.")
+		return false
+	}
+	return isPlanCacheFresh(planPath, dir, key, ttl, time.Now())
+}
+
+// isPlanCacheFresh checks that planPath is younger than ttl, was made with the
+// same key and that no configuration source under dir has been modified since
+// the plan was written.
+//
+// Parameters:
+//
+//	planPath - The path of the cached plan file.
+//	dir - The directory to scan for configuration sources.
+//	key - The planCacheKey of the plan that would run.
+//	ttl - The maximum age of a reusable plan.
+//	now - The time to measure the plan's age against.
+//
+// Returns:
+//
+//	bool - true if the plan is fresh, false if it is missing, stale, of another key or any source changed.
+func isPlanCacheFresh(planPath, dir, key string, ttl time.Duration, now time.Time) bool {
+	planInfo, err := os.Stat(planPath)
+	if err != nil {
+		Logger.Debugf("Plan cache miss: cannot stat %s: %v", planPath, err)
+		return false
+	}
+	planTime := planInfo.ModTime()
+	if age := now.Sub(planTime); age > ttl {
+		Logger.Debugf("Plan cache miss: %s is %s old (ttl %s)", planPath, age, ttl)
+		return false
+	}
+	cachedKey, err := os.ReadFile(planPath + planCacheKeyExt)
+	if err != nil {
+		Logger.Debugf("Plan cache miss: no cache key for %s: %v", planPath, err)
+		return false
+	}
+	if strings.TrimSpace(string(cachedKey)) != key {
+		Logger.Debugf("Plan cache miss: %s was made with another binary, workspace or arguments", planPath)
+		return false
+	}
+
+	// errSourceChanged stops the walk at the first modified source
+	errSourceChanged := errors.New("source changed")
+	walkErr := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// Skip provider/module caches and other hidden directories
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !hasPlanSourceExt(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(planTime) {
+			Logger.Debugf("Plan cache miss: %s modified after %s", path, planPath)
+			return errSourceChanged
+		}
+		return nil
+	})
+	if walkErr != nil {
+		if !errors.Is(walkErr, errSourceChanged) {
+			Logger.Debugf("Plan cache miss: error scanning %s: %v", dir, walkErr)
+		}
+		return false
+	}
+
+	Logger.Debugf("Plan cache hit: reusing %s", planPath)
+	return true
+}
+
+// hasPlanSourceExt reports whether name ends in one of planSourceExts.
+func hasPlanSourceExt(name string) bool {
+	for _, ext := range planSourceExts {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsePlanCache(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	key := planCacheKey("terraform", defaultWorkspace, []string{"-out=plan.out"})
+
+	// writeFixture creates a plan with its cache key and a source file with the given modification times
+	writeFixture := func(t *testing.T, planAge, sourceAge time.Duration) (string, string) {
+		t.Helper()
+		dir := t.TempDir()
+		planPath := filepath.Join(dir, "plan.out")
+		sourcePath := filepath.Join(dir, "main.tf")
+		require.NoError(t, os.WriteFile(planPath, []byte("plan"), 0o600))
+		require.NoError(t, os.WriteFile(planPath+planCacheKeyExt, []byte(key+"\n"), 0o600))
+		require.NoError(t, os.WriteFile(sourcePath, []byte(`resource "null_resource" "a" {}`), 0o600))
+		now := time.Now()
+		require.NoError(t, os.Chtimes(planPath, now.Add(-planAge), now.Add(-planAge)))
+		require.NoError(t, os.Chtimes(sourcePath, now.Add(-sourceAge), now.Add(-sourceAge)))
+		return dir, planPath
+	}
+
+	setCache := func(t *testing.T, ttl time.Duration, noCache bool) {
+		t.Helper()
+		viper.Set("planCacheTTL", ttl)
+		viper.Set("noCache", noCache)
+		t.Cleanup(func() {
+			viper.Set("planCacheTTL", time.Duration(0))
+			viper.Set("noCache", false)
+		})
+	}
+
+	t.Run("Cache hit skips the plan", func(t *testing.T) {
+		dir, planPath := writeFixture(t, time.Minute, time.Hour)
+		setCache(t, 10*time.Minute, false)
+
+		require.True(t, usePlanCache(planPath, dir, key))
+	})
+
+	t.Run("Cache miss after switching workspace", func(t *testing.T) {
+		t.Setenv("TF_WORKSPACE", "")
+		dir, planPath := writeFixture(t, time.Minute, time.Hour)
+		setCache(t, 10*time.Minute, false)
+		selectWorkspace := func(workspace string) string {
+			t.Helper()
+			require.NoError(t, os.MkdirAll(filepath.Join(dir, ".terraform"), 0o750))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, ".terraform", "environment"), []byte(workspace), 0o600))
+			return planCacheKey("terraform", currentWorkspace(dir), []string{"-out=plan.out"})
+		}
+
+		// The first run plans dev
+		writePlanCacheKey(planPath, selectWorkspace("dev"))
+		require.True(t, usePlanCache(planPath, dir, selectWorkspace("dev")))
+
+		require.False(t, usePlanCache(planPath, dir, selectWorkspace("prod")))
+	})
+
+	t.Run("Cache miss with another binary", func(t *testing.T) {
+		dir, planPath := writeFixture(t, time.Minute, time.Hour)
+		setCache(t, 10*time.Minute, false)
+
+		require.False(t, usePlanCache(planPath, dir, planCacheKey("tofu", defaultWorkspace, []string{"-out=plan.out"})))
+	})
+
+	t.Run("Cache miss with other arguments", func(t *testing.T) {
+		dir, planPath := writeFixture(t, time.Minute, time.Hour)
+		setCache(t, 10*time.Minute, false)
+
+		for _, args := range [][]string{
+			{"-out=plan.out", "-var=region=us-west-2"},
+			{"-out=plan.out", "-var-file=/work/prod.tfvars"},
+			{"-out=plan.out", "-target=module.db"},
+			{"-out=plan.out", "-exclude=module.cdn"},
+		} {
+			require.False(t, usePlanCache(planPath, dir, planCacheKey("terraform", defaultWorkspace, args)), args)
+		}
+	})
+
+	t.Run("Cache miss without a cache key", func(t *testing.T) {
+		dir, planPath := writeFixture(t, time.Minute, time.Hour)
+		require.NoError(t, os.Remove(planPath+planCacheKeyExt))
+		setCache(t, 10*time.Minute, false)
+
+		require.False(t, usePlanCache(planPath, dir, key))
+	})
+
+	t.Run("Cache miss when a source changed after the plan", func(t *testing.T) {
+		dir, planPath := writeFixture(t, time.Hour, time.Minute)
+		setCache(t, 2*time.Hour, false)
+
+		require.False(t, usePlanCache(planPath, dir, key))
+	})
+
+	t.Run("Cache miss when a nested module source changed", func(t *testing.T) {
+		dir, planPath := writeFixture(t, time.Minute, time.Hour)
+		moduleDir := filepath.Join(dir, "modules", "net")
+		require.NoError(t, os.MkdirAll(moduleDir, 0o750))
+		require.NoError(t, os.WriteFile(filepath.Join(moduleDir, "main.tf"), nil, 0o600))
+		setCache(t, 10*time.Minute, false)
+
+		require.False(t, usePlanCache(planPath, dir, key))
+	})
+
+	t.Run("Cache miss when the plan is older than the ttl", func(t *testing.T) {
+		dir, planPath := writeFixture(t, time.Hour, 2*time.Hour)
+		setCache(t, 10*time.Minute, false)
+
+		require.False(t, usePlanCache(planPath, dir, key))
+	})
+
+	t.Run("Cache miss when the plan does not exist", func(t *testing.T) {
+		dir := t.TempDir()
+		setCache(t, 10*time.Minute, false)
+
+		require.False(t, usePlanCache(filepath.Join(dir, "plan.out"), dir, key))
+	})
+
+	t.Run("No cache forces a re-plan", func(t *testing.T) {
+		dir, planPath := writeFixture(t, time.Minute, time.Hour)
+		setCache(t, 10*time.Minute, true)
+
+		require.False(t, usePlanCache(planPath, dir, key))
+	})
+
+	t.Run("Caching is off without a ttl", func(t *testing.T) {
+		dir, planPath := writeFixture(t, time.Minute, time.Hour)
+		setCache(t, 0, false)
+
+		require.False(t, usePlanCache(planPath, dir, key))
+	})
+}
@@ -0,0 +1,211 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// failureCommentMarker identifies the comment --pr-comment-on-failure posts,
+	// so a later failure updates it rather than adding another
+	failureCommentMarker = "<!-- gh-tp:plan-failure%s -->"
+	// commentTimeout bounds posting the failure comment, which also runs after
+	// a deadline
+	commentTimeout = 30 * time.Second
+)
+
+// noPullRequestMessage is part of the error 'gh pr view' returns when the
+// branch has no pull request
+const noPullRequestMessage = "no pull requests found"
+
+// defaultCommentClient is the CommentClient used outside of tests
+var defaultCommentClient CommentClient = &RealCommentClient{runner: defaultGhRunner}
+
+// CommentClient is an interface for commenting on the current branch's pull request
+// This allows for dependency injection and easier testing
+type CommentClient interface {
+	CurrentPullRequest(ctx context.Context) (int, error)
+	FindComment(ctx context.Context, pr int, marker string) (int64, error)
+	CreateComment(ctx context.Context, pr int, body string) error
+	UpdateComment(ctx context.Context, id int64, body string) error
+}
+
+// RealCommentClient implements the CommentClient interface with 'gh pr view' and the REST API through 'gh api'
+type RealCommentClient struct {
+	runner GhRunner
+}
+
+// CurrentPullRequest returns the number of the open pull request for the
+// current branch, or 0 when it has none.
+func (c *RealCommentClient) CurrentPullRequest(ctx context.Context) (int, error) {
+	out, err := c.runner.Run(ctx, "pr", "view", "--json", "number,state")
+	if err != nil {
+		if strings.Contains(err.Error(), noPullRequestMessage) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("unable to look up the branch's pull request: %w", err)
+	}
+	var pr openPR
+	if err = json.Unmarshal(out, &pr); err != nil {
+		return 0, fmt.Errorf("unable to look up the branch's pull request: %w", err)
+	}
+	// 'pr view' also finds the branch's closed and merged pull requests
+	if pr.State != "OPEN" {
+		Logger.Debugf("The branch's pull request #%d is %s", pr.Number, strings.ToLower(pr.State))
+		return 0, nil
+	}
+	return pr.Number, nil
+}
+
+// FindComment returns the ID of the first comment on pr containing marker, or
+// 0 when there's none.
+func (c *RealCommentClient) FindComment(ctx context.Context, pr int, marker string) (int64, error) {
+	out, err := c.runner.Run(
+		ctx,
+		"api", "--paginate",
+		fmt.Sprintf("repos/{owner}/{repo}/issues/%d/comments", pr),
+		"--jq", fmt.Sprintf(".[] | select(.body | contains(%s)) | .id", strconv.Quote(marker)),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("unable to list the comments of pull request #%d: %w", pr, err)
+	}
+	first, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	if first == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unable to list the comments of pull request #%d: unexpected ID %q", pr, first)
+	}
+	return id, nil
+}
+
+// CreateComment adds a comment to pr
+func (c *RealCommentClient) CreateComment(ctx context.Context, pr int, body string) error {
+	_, err := c.runner.Run(
+		ctx,
+		"api", "--method", "POST",
+		fmt.Sprintf("repos/{owner}/{repo}/issues/%d/comments", pr),
+		"-f", "body="+body,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to comment on pull request #%d: %w", pr, err)
+	}
+	return nil
+}
+
+// UpdateComment replaces the body of the comment id
+func (c *RealCommentClient) UpdateComment(ctx context.Context, id int64, body string) error {
+	_, err := c.runner.Run(
+		ctx,
+		"api", "--method", "PATCH",
+		fmt.Sprintf("repos/{owner}/{repo}/issues/comments/%d", id),
+		"-f", "body="+body,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update comment %d: %w", id, err)
+	}
+	return nil
+}
+
+// failureMarker returns the marker of the failure comment, one per
+// environment so plans of several environments don't overwrite each other's.
+func failureMarker(environment string) string {
+	if environment == "" {
+		return fmt.Sprintf(failureCommentMarker, "")
+	}
+	return fmt.Sprintf(failureCommentMarker, " "+environment)
+}
+
+// failureComment renders the comment for a failed plan. The comment is
+// public, so the error is always masked, with or without --redact.
+//
+// Parameters:
+//
+//	runErr - The error the run ended with.
+//	title - The plan's title, e.g. "Terraform plan (prod)".
+//	environment - The 'environment' label, may be empty.
+//	secrets - Values masked in the error, from runSecrets.
+//	patterns - Patterns whose matches are masked in the error, from 'redactPatterns'.
+//	maxBytes - The size the comment is truncated to, from 'prBodyMaxBytes'.
+//
+// Returns:
+//
+//	string - The comment's Markdown, at most maxBytes.
+func failureComment(
+	runErr error,
+	title, environment string,
+	secrets []string,
+	patterns []*regexp.Regexp,
+	maxBytes int,
+) string {
+	msg := maskRunError(runErr, secrets, patterns)
+	// A fence longer than any backtick run in the error can't be closed by it
+	fence := "```"
+	for strings.Contains(msg, fence) {
+		fence += "`"
+	}
+
+	var b strings.Builder
+	b.WriteString(failureMarker(environment) + "\n")
+	fmt.Fprintf(&b, "### %s failed\n\n", title)
+	fmt.Fprintf(&b, "%s\n%s\n%s\n", fence, strings.TrimSpace(msg), fence)
+	return truncateMarkdown(b.String(), maxBytes)
+}
+
+// postFailureComment posts the comment for a failed plan on the current
+// branch's pull request, or updates the one a previous failure posted. A
+// branch without a pull request only logs.
+//
+// Parameters:
+//
+//	ctx - The context of the run, the comment is posted even once it's done.
+//	client - The CommentClient used.
+//	environment - The 'environment' label, may be empty.
+//	body - The comment from failureComment.
+//
+// Returns:
+//
+//	error - Any error encountered.
+func postFailureComment(ctx context.Context, client CommentClient, environment, body string) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), commentTimeout)
+	defer cancel()
+
+	pr, err := client.CurrentPullRequest(ctx)
+	if err != nil {
+		return err
+	}
+	if pr == 0 {
+		Logger.Info("The branch has no pull request, the plan failure wasn't commented")
+		return nil
+	}
+
+	id, err := client.FindComment(ctx, pr, failureMarker(environment))
+	if err != nil {
+		return err
+	}
+	if id != 0 {
+		err = client.UpdateComment(ctx, id, body)
+	} else {
+		err = client.CreateComment(ctx, pr, body)
+	}
+	if err != nil {
+		return err
+	}
+	Logger.Infof("Plan failure commented on pull request #%d", pr)
+	return nil
+}
+
+// shouldCommentFailure reports whether runErr is a failure worth commenting
+// on. An interrupt is the user stopping the run, not the plan failing.
+func shouldCommentFailure(runErr error) bool {
+	return runErr != nil && !errors.Is(runErr, ErrInterrupted)
+}
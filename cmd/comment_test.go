@@ -0,0 +1,226 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockCommentClient is a mock implementation of CommentClient
+type MockCommentClient struct {
+	mock.Mock
+}
+
+func (m *MockCommentClient) CurrentPullRequest(ctx context.Context) (int, error) {
+	called := m.Called(ctx)
+	return called.Int(0), called.Error(1)
+}
+
+func (m *MockCommentClient) FindComment(ctx context.Context, pr int, marker string) (int64, error) {
+	called := m.Called(ctx, pr, marker)
+	id, _ := called.Get(0).(int64)
+	return id, called.Error(1)
+}
+
+func (m *MockCommentClient) CreateComment(ctx context.Context, pr int, body string) error {
+	return m.Called(ctx, pr, body).Error(0)
+}
+
+func (m *MockCommentClient) UpdateComment(ctx context.Context, id int64, body string) error {
+	return m.Called(ctx, id, body).Error(0)
+}
+
+func TestRealCommentClient(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	prView := []string{"pr", "view", "--json", "number,state"}
+
+	t.Run("Current pull request", func(t *testing.T) {
+		runner := new(MockGhRunner)
+		runner.On("Run", mock.Anything, prView).Return([]byte(`{"number":42,"state":"OPEN"}`), nil)
+
+		pr, err := (&RealCommentClient{runner: runner}).CurrentPullRequest(context.Background())
+
+		require.NoError(t, err)
+		require.Equal(t, 42, pr)
+	})
+
+	t.Run("Merged or closed pull request", func(t *testing.T) {
+		for _, state := range []string{"MERGED", "CLOSED"} {
+			runner := new(MockGhRunner)
+			runner.On("Run", mock.Anything, prView).Return([]byte(`{"number":41,"state":"`+state+`"}`), nil)
+
+			pr, err := (&RealCommentClient{runner: runner}).CurrentPullRequest(context.Background())
+
+			require.NoError(t, err)
+			require.Zero(t, pr, state)
+		}
+	})
+
+	t.Run("Branch without a pull request", func(t *testing.T) {
+		runner := new(MockGhRunner)
+		runner.On("Run", mock.Anything, prView).Return(nil, errors.New(
+			`gh pr: exit status 1: no pull requests found for branch "fix"`,
+		))
+
+		pr, err := (&RealCommentClient{runner: runner}).CurrentPullRequest(context.Background())
+
+		require.NoError(t, err)
+		require.Zero(t, pr)
+	})
+
+	t.Run("Finds the first comment with the marker", func(t *testing.T) {
+		runner := new(MockGhRunner)
+		runner.On("Run", mock.Anything, []string{
+			"api", "--paginate", "repos/{owner}/{repo}/issues/42/comments",
+			"--jq", `.[] | select(.body | contains("<!-- gh-tp:plan-failure -->")) | .id`,
+		}).Return([]byte("1001\n1002\n"), nil)
+
+		id, err := (&RealCommentClient{runner: runner}).FindComment(context.Background(), 42, failureMarker(""))
+
+		require.NoError(t, err)
+		require.Equal(t, int64(1001), id)
+	})
+
+	t.Run("Creates and updates comments", func(t *testing.T) {
+		runner := new(MockGhRunner)
+		runner.On("Run", mock.Anything, []string{
+			"api", "--method", "POST", "repos/{owner}/{repo}/issues/42/comments", "-f", "body=failed",
+		}).Return([]byte(`{"id":1}`), nil)
+		runner.On("Run", mock.Anything, []string{
+			"api", "--method", "PATCH", "repos/{owner}/{repo}/issues/comments/1001", "-f", "body=failed",
+		}).Return([]byte(`{"id":1001}`), nil)
+		client := &RealCommentClient{runner: runner}
+
+		require.NoError(t, client.CreateComment(context.Background(), 42, "failed"))
+		require.NoError(t, client.UpdateComment(context.Background(), 1001, "failed"))
+		runner.AssertExpectations(t)
+	})
+}
+
+func TestFailureComment(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	t.Run("Error in a code block", func(t *testing.T) {
+		runErr := errors.New("terraform plan failed: exit status 1\n\n\x1b[31mError:\x1b[0m Invalid reference")
+
+		got := failureComment(runErr, "Terraform plan (prod)", "prod", nil, nil, defaultPRBodyMaxBytes)
+
+		require.Equal(t, "<!-- gh-tp:plan-failure prod -->\n"+
+			"### Terraform plan (prod) failed\n\n"+
+			"```\nterraform plan failed: exit status 1\n\nError: Invalid reference\n```\n", got)
+	})
+
+	t.Run("Redact patterns are masked", func(t *testing.T) {
+		runErr := errors.New(`Error: invalid token "ghp_abc123"`)
+
+		got := failureComment(
+			runErr, "Terraform plan", "", nil, []*regexp.Regexp{regexp.MustCompile(`ghp_\w+`)}, defaultPRBodyMaxBytes,
+		)
+
+		require.NotContains(t, got, "ghp_abc123")
+		require.Contains(t, got, sensitivePlaceholder)
+	})
+
+	t.Run("Backticks in the error can't close the block", func(t *testing.T) {
+		got := failureComment(errors.New("Error: ``` in a heredoc"), "Terraform plan", "", nil, nil, defaultPRBodyMaxBytes)
+
+		require.Contains(t, got, "````\nError: ``` in a heredoc\n````\n")
+	})
+
+	t.Run("Long errors are truncated", func(t *testing.T) {
+		runErr := errors.New(strings.Repeat("Error: too long\n", 10000))
+
+		got := failureComment(runErr, "Terraform plan", "", nil, nil, defaultPRBodyMaxBytes)
+		require.LessOrEqual(t, len(got), defaultPRBodyMaxBytes)
+
+		got = failureComment(runErr, "Terraform plan", "", nil, nil, 4096)
+		require.LessOrEqual(t, len(got), 4096, "'prBodyMaxBytes'")
+	})
+
+	t.Run("Secrets are masked", func(t *testing.T) {
+		runErr := errors.New(`Error: connecting with password "hunter2hunter2"`)
+
+		got := failureComment(runErr, "Terraform plan", "", []string{"hunter2hunter2"}, nil, defaultPRBodyMaxBytes)
+
+		require.NotContains(t, got, "hunter2")
+		require.Contains(t, got, sensitivePlaceholder)
+	})
+}
+
+func TestShouldCommentFailure(t *testing.T) {
+	require.False(t, shouldCommentFailure(nil))
+	require.False(t, shouldCommentFailure(fmt.Errorf("plan in network: %w", ErrInterrupted)))
+	require.True(t, shouldCommentFailure(errors.New("terraform plan failed")))
+	require.True(t, shouldCommentFailure(ErrDeadlineExceeded))
+}
+
+func TestPostFailureComment(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	body := failureComment(errors.New("terraform plan failed"), "Terraform plan", "", nil, nil, defaultPRBodyMaxBytes)
+
+	t.Run("Posts the failure comment", func(t *testing.T) {
+		client := new(MockCommentClient)
+		client.On("CurrentPullRequest", mock.Anything).Return(42, nil)
+		client.On("FindComment", mock.Anything, 42, "<!-- gh-tp:plan-failure -->").Return(int64(0), nil)
+		client.On("CreateComment", mock.Anything, 42, body).Return(nil)
+
+		require.NoError(t, postFailureComment(context.Background(), client, "", body))
+		client.AssertExpectations(t)
+	})
+
+	t.Run("Updates the previous failure comment", func(t *testing.T) {
+		client := new(MockCommentClient)
+		client.On("CurrentPullRequest", mock.Anything).Return(42, nil)
+		client.On("FindComment", mock.Anything, 42, "<!-- gh-tp:plan-failure prod -->").Return(int64(1001), nil)
+		client.On("UpdateComment", mock.Anything, int64(1001), body).Return(nil)
+
+		require.NoError(t, postFailureComment(context.Background(), client, "prod", body))
+		client.AssertExpectations(t)
+		client.AssertNotCalled(t, "CreateComment", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Posted after the run's context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		client := new(MockCommentClient)
+		client.On("CurrentPullRequest", mock.MatchedBy(func(ctx context.Context) bool { return ctx.Err() == nil })).
+			Return(42, nil)
+		client.On("FindComment", mock.Anything, 42, mock.Anything).Return(int64(0), nil)
+		client.On("CreateComment", mock.Anything, 42, body).Return(nil)
+
+		require.NoError(t, postFailureComment(ctx, client, "", body))
+		client.AssertExpectations(t)
+	})
+
+	t.Run("No pull request", func(t *testing.T) {
+		client := new(MockCommentClient)
+		client.On("CurrentPullRequest", mock.Anything).Return(0, nil)
+
+		require.NoError(t, postFailureComment(context.Background(), client, "", body))
+		client.AssertNotCalled(t, "FindComment", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Errors are returned", func(t *testing.T) {
+		client := new(MockCommentClient)
+		client.On("CurrentPullRequest", mock.Anything).Return(42, nil)
+		client.On("FindComment", mock.Anything, 42, mock.Anything).Return(int64(0), nil)
+		client.On("CreateComment", mock.Anything, 42, body).Return(errors.New("HTTP 403"))
+
+		require.EqualError(t, postFailureComment(context.Background(), client, "", body), "HTTP 403")
+	})
+}
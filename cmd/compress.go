@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// maxPlanBytes bounds the size of a decompressed plan, a small archive can
+// expand to fill memory
+const maxPlanBytes = 256 * 1024 * 1024
+
+// gzipMagic starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// isGzip reports whether a plan read from name is gzipped, by its magic bytes
+// or a .gz extension.
+func isGzip(name string, data []byte) bool {
+	return bytes.HasPrefix(data, gzipMagic) || strings.EqualFold(filepath.Ext(name), ".gz")
+}
+
+// decompressPlan decompresses a gzipped plan, as CI artifacts often are, and
+// returns any other plan as it is.
+//
+// Parameters:
+//
+//	name - Where the plan was read from, for its extension and errors.
+//	data - The plan as read.
+//	limit - The maximum size of the decompressed plan, normally maxPlanBytes.
+//
+// Returns:
+//
+//	[]byte - The plan, decompressed.
+//	error - An error if the plan isn't valid gzip or is larger than limit.
+func decompressPlan(name string, data []byte, limit int64) ([]byte, error) {
+	if !isGzip(name, data) {
+		return data, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress %s: %w", name, err)
+	}
+	defer zr.Close() //nolint:errcheck // reading from memory
+	plan, err := io.ReadAll(io.LimitReader(zr, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress %s: %w", name, err)
+	}
+	if int64(len(plan)) > limit {
+		return nil, fmt.Errorf("unable to decompress %s: larger than %d bytes", name, limit)
+	}
+	Logger.Debugf("Decompressed %s: %d bytes to %d", name, len(data), len(plan))
+	return plan, nil
+}
@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecompressPlan(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	planText, err := os.ReadFile(filepath.Join("..", "testdata", "plans", "changes.txt"))
+	require.NoError(t, err)
+	compressed, err := os.ReadFile(filepath.Join("..", "testdata", "plans", "changes.txt.gz"))
+	require.NoError(t, err)
+
+	t.Run("Gzipped plans are decompressed and rendered", func(t *testing.T) {
+		got, err := decompressPlan("stdin", compressed, maxPlanBytes)
+		require.NoError(t, err)
+		require.Equal(t, string(planText), string(got))
+
+		t.Chdir(t.TempDir())
+		mdFile, err := createMarkdown("plan.md", string(got), "terraform", markdownOptions{})
+		require.NoError(t, err)
+		md, err := os.ReadFile(mdFile)
+		require.NoError(t, err)
+		require.Contains(t, string(md), "Plan: 2 to add, 1 to change, 2 to destroy.")
+	})
+
+	t.Run("Other plans are left alone", func(t *testing.T) {
+		got, err := decompressPlan("stdin", planText, maxPlanBytes)
+		require.NoError(t, err)
+		require.Equal(t, planText, got)
+	})
+
+	t.Run("A .gz extension must be gzip", func(t *testing.T) {
+		_, err := decompressPlan("plan.txt.gz", planText, maxPlanBytes)
+		require.ErrorContains(t, err, "unable to decompress plan.txt.gz")
+	})
+
+	t.Run("The decompressed size is bounded", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, err := zw.Write([]byte(strings.Repeat("~", 2048)))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+
+		_, err = decompressPlan("stdin", buf.Bytes(), 1024)
+		require.ErrorContains(t, err, "larger than 1024 bytes")
+
+		got, err := decompressPlan("stdin", buf.Bytes(), 2048)
+		require.NoError(t, err)
+		require.Len(t, got, 2048)
+	})
+}
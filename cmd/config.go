@@ -3,6 +3,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -23,7 +24,6 @@ const ConfigName = ".tp.toml"
 // Global variables used throughout the configuration management system
 var (
 	accessible         bool                             // Flag to enable accessibility mode for UI interactions
-	localNow           string                           // Timestamp string used for backup file naming
 	title              string                           // Title for user prompt UI
 	defaultFileChecker FileChecker = &RealFileChecker{} // Default implementation of FileChecker interface
 	defaultUserPrompt  UserPrompt  = &RealUserPrompt{}  // Default implementation of UserPrompt interface
@@ -39,10 +39,26 @@ type ConfigFile struct {
 // ConfigParams contains all configurable parameters for the application
 // with validation rules and comments for documentation
 type ConfigParams struct {
-	Binary   string `toml:"binary"   comment:"binary: (type: string) The name of the binary, expect either 'tofu' or 'terraform'. Must exist on your $PATH." validate:"oneof=terraform tofu"`
-	PlanFile string `toml:"planFile" comment:"planFile: (type: string) The name of the plan file created by 'gh tp'."                                        validate:"required"`
-	MdFile   string `toml:"mdFile"   comment:"mdFile: (type: string) The name of the Markdown file created by 'gh tp'."                                      validate:"required,nefield=PlanFile"`
-	Verbose  bool   `toml:"verbose"  comment:"verbose: (type: bool) Enable Verbose Logging. Default is false."                                               validate:"boolean"`
+	Binary     string            `toml:"binary"            comment:"binary: (type: string) The name of the binary, expect either 'tofu' or 'terraform' on your $PATH, or the path of either, e.g. /opt/tools/tofu-1.8.0/tofu." validate:"binary"`
+	PlanFile   string            `toml:"planFile"          comment:"planFile: (type: string) The name of the plan file created by 'gh tp'."                                        validate:"required"`
+	MdFile     string            `toml:"mdFile"            comment:"mdFile: (type: string) The name of the Markdown file created by 'gh tp'."                                      validate:"required,nefield=PlanFile"`
+	Verbose    bool              `toml:"verbose"           comment:"verbose: (type: bool) Enable Verbose Logging. Default is false."                                               validate:"boolean"`
+	PlanEnv    map[string]string `toml:"planEnv,omitempty" comment:"planEnv: (type: table) Extra environment variables set for the plan process, e.g. AWS_PROFILE."`
+	MinVersion string            `toml:"minVersion,omitempty" comment:"minVersion: (type: string) The oldest binary version allowed to plan, e.g. 1.6.0." validate:"omitempty,semver"`
+	Markdown   *MarkdownParams   `toml:"markdown,omitempty" comment:"markdown: (type: table) Markdown rendering options."`
+}
+
+// MarkdownParams are the rendering options of the [markdown] table. Each
+// takes precedence over the top-level parameter of earlier versions, and flags
+// take precedence over both.
+type MarkdownParams struct {
+	Syntax         string `toml:"syntax,omitempty"         comment:"syntax: (type: string) The language of the plan code blocks: terraform, hcl, diff or text. Default is terraform." validate:"omitempty,oneof=terraform hcl diff text"`
+	GroupByModule  *bool  `toml:"groupByModule,omitempty"  comment:"groupByModule: (type: bool) Render one collapsible block per top-level module."`
+	ShowDrift      *bool  `toml:"showDrift,omitempty"      comment:"showDrift: (type: bool) Render resources changed outside of Terraform/OpenTofu in their own section."`
+	ShowOutputs    *bool  `toml:"showOutputs,omitempty"    comment:"showOutputs: (type: bool) Render the outputs the plan changes in their own section."`
+	IncludeJSON    *bool  `toml:"includeJson,omitempty"    comment:"includeJson: (type: bool) Include the JSON plan in a collapsible block, truncated if large."`
+	IncludeCommand *bool  `toml:"includeCommand,omitempty" comment:"includeCommand: (type: bool) Include the plan command line."`
+	Template       string `toml:"template,omitempty"       comment:"template: (type: string) Go template file rendering the whole Markdown."`
 }
 
 // genConfig marshals the configuration parameters into TOML format
@@ -144,6 +160,9 @@ func createOrOverwrite(
 	configExists = fileChecker.DoesExist(cfgFile)
 	Logger.Debugf("Using config: %s", cfgFile+ConfigName)
 	createFile, err = userPrompt.AskOverwrite(configExists)
+	if errors.Is(err, ErrInterrupted) {
+		return configExists, false, err
+	}
 	if err != nil {
 		Logger.Error(err)
 		return false, false, err
@@ -223,6 +242,10 @@ func query(configExists bool) (createFile bool, err error) {
 	// Create and run the form
 	formRunner := formRunnerFactory(title, &createFile, accessible)
 	err = formRunner.Run()
+	if isUserAbort(err) {
+		Logger.Debugf("Overwrite prompt aborted by user: %v", err)
+		return false, ErrInterrupted
+	}
 	if err != nil {
 		Logger.Error(err)
 	}
@@ -246,17 +269,21 @@ func query(configExists bool) (createFile bool, err error) {
 //	cfgFile - The path to the configuration file
 //	cfgMdFile - The name of the markdown file
 //	cfgPlanFile - The name of the plan file
+//	cfgMarkdown - The [markdown] table, nil to leave it out
 //
 // Returns:
 //
 //	error - Any error encountered during the configuration process
-func createConfig(cfgBinary, cfgFile, cfgMdFile, cfgPlanFile string) error {
+func createConfig(cfgBinary, cfgFile, cfgMdFile, cfgPlanFile string, cfgMarkdown *MarkdownParams) error {
 	// Check if config exists and ask user if they want to create/overwrite
 	configExists, createFile, err := createOrOverwrite(
 		cfgFile,
 		defaultFileChecker,
 		defaultUserPrompt,
 	)
+	if errors.Is(err, ErrInterrupted) {
+		return err
+	}
 	if err != nil {
 		Logger.Error(err)
 		return err
@@ -273,6 +300,7 @@ func createConfig(cfgBinary, cfgFile, cfgMdFile, cfgPlanFile string) error {
 		PlanFile: cfgPlanFile,
 		MdFile:   cfgMdFile,
 		Verbose:  false, // Default to non-verbose mode
+		Markdown: cfgMarkdown,
 	}
 
 	err = validateConfig(conf)
@@ -317,14 +345,8 @@ func createConfig(cfgBinary, cfgFile, cfgMdFile, cfgPlanFile string) error {
 			// When overwriting existing config, create backup first
 			Logger.Debugf("Config is: \n%s\n", string(config))
 
-			// Create timestamp for backup file name
-			// #117 This could be moved to BackupFile() I think
-			localNow = time.Now().Local().Format("200601021504")
-			existingConfigFile := configFile.Path
-			bkupConfigFile := configFile.Path + "-" + localNow
-
 			// Create backup of existing config
-			err := BackupFile(existingConfigFile, bkupConfigFile)
+			bkupConfigFile, err := newBackup(configFile.Path, time.Now())
 			if err != nil {
 				Logger.Fatal(err)
 				return err
@@ -350,6 +372,12 @@ func createConfig(cfgBinary, cfgFile, cfgMdFile, cfgPlanFile string) error {
 }
 
 func validateConfig(conf ConfigParams) error {
+	return validateParams(conf)
+}
+
+// newConfigValidator returns the validator of the config parameters, with the
+// validations their tags use registered.
+func newConfigValidator() *validator.Validate {
 	// Initialize validator with required struct validation
 	validate := validator.New(validator.WithRequiredStructEnabled())
 
@@ -357,9 +385,20 @@ func validateConfig(conf ConfigParams) error {
 	validate.RegisterTagNameFunc(func(fld reflect.StructField) string {
 		return fld.Name
 	})
+	// The binary is checked as getBinaryFromConfig does, a name or a path.
+	// RegisterValidation only fails with an empty tag or a nil function.
+	_ = validate.RegisterValidation("binary", func(fl validator.FieldLevel) bool {
+		return knownBinary(fl.Field().String())
+	})
+	return validate
+}
+
+// validateParams validates params against their struct's validate tags.
+func validateParams(params any) error {
+	validate := newConfigValidator()
 
 	// Validate the configuration against defined validation rules
-	err := validate.Struct(conf)
+	err := validate.Struct(params)
 	if err != nil {
 		var validationErrors []string
 		for _, err := range err.(validator.ValidationErrors) {
@@ -0,0 +1,460 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// backupTimeFormat is the timestamp suffix of config backups, e.g. .tp.toml-20250102150405
+const backupTimeFormat = "20060102150405"
+
+// legacyBackupTimeFormat is the minute resolution suffix of older backups,
+// e.g. .tp.toml-202501021504, still listed and restorable
+const legacyBackupTimeFormat = "200601021504"
+
+// configBackup is a timestamped backup of a config file
+type configBackup struct {
+	Path      string    // Full path to the backup file
+	Timestamp string    // Timestamp suffix, as passed to 'config restore'
+	Time      time.Time // Time parsed from the timestamp
+}
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Args:  cobra.NoArgs,
+	Short: "Manage the tp config file.",
+	Long: heredoc.Doc(`
+		Manage the .tp.toml config file tp loaded, either found in the standard
+		locations or passed with --config.`),
+}
+
+// configBackupsCmd represents the config backups command
+var configBackupsCmd = &cobra.Command{
+	Use:               "backups",
+	Args:              cobra.NoArgs,
+	ValidArgsFunction: cobra.NoFileCompletions,
+	Short:             "List the backups 'gh tp init' made of the config file.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgPath, err := resolvedConfigPath()
+		if err != nil {
+			return err
+		}
+		backups, err := listConfigBackups(cfgPath)
+		if err != nil {
+			return err
+		}
+		if len(backups) == 0 {
+			Logger.Infof("No backups found for %s", cfgPath)
+			return nil
+		}
+		for _, b := range backups {
+			fmt.Fprintf(
+				cmd.OutOrStdout(),
+				"%s\t%s\t%s\n",
+				b.Timestamp,
+				b.Time.Format("2006-01-02 15:04:05"),
+				b.Path,
+			)
+		}
+		return nil
+	},
+}
+
+// configRestoreCmd represents the config restore command
+var configRestoreCmd = &cobra.Command{
+	Use:               "restore <timestamp>",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeBackupTimestamps,
+	Short:             "Restore a backup of the config file.",
+	Long: heredoc.Doc(`
+		Restore a backup listed by 'gh tp config backups' over the current config
+		file. The current config file is backed up first.`),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgPath, err := resolvedConfigPath()
+		if err != nil {
+			return err
+		}
+		yes, err := cmd.Flags().GetBool("yes")
+		if err != nil {
+			return err
+		}
+		err = restoreConfigBackup(cfgPath, args[0], yes)
+		if errors.Is(err, ErrInterrupted) {
+			Logger.Info("Restore cancelled by user.")
+			return nil
+		}
+		return err
+	},
+}
+
+// configExportFlagsCmd represents the config export-flags command
+var configExportFlagsCmd = &cobra.Command{
+	Use:               "export-flags",
+	Args:              cobra.NoArgs,
+	ValidArgsFunction: cobra.NoFileCompletions,
+	Short:             "Print the command line equivalent to the config file.",
+	Long: heredoc.Doc(`
+		Print the 'gh tp' command line setting the parameters of the config file
+		with flags, e.g. to move a CI job from a config file to flags. Parameters
+		without a flag, like tables, are listed so they can stay in a config file.
+		Secrets, like the notifyWebhook URL, are redacted unless --show-secrets
+		is passed.`),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := resolvedConfigPath(); err != nil {
+			return err
+		}
+		flags := pflag.NewFlagSet("tp", pflag.ContinueOnError)
+		flags.AddFlagSet(rootCmd.Flags())
+		flags.AddFlagSet(rootCmd.PersistentFlags())
+		showSecrets, _ := cmd.Flags().GetBool("show-secrets")
+		flagArgs, unexported, redacted := exportFlags(flags, showSecrets)
+		if len(unexported) > 0 {
+			Logger.Warnf("These parameters have no flag, keep them in a config file: %s", strings.Join(unexported, ", "))
+		}
+		if len(redacted) > 0 {
+			Logger.Warnf(
+				"Secret values of these parameters are replaced with %s, use --show-secrets to print them: %s",
+				redactedValue,
+				strings.Join(redacted, ", "),
+			)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), formatExportedFlags(flagArgs))
+		return nil
+	},
+}
+
+// flagParams are the parameters set by a flag of another name, e.g. --dir
+// sets 'dirs'. Other flags set the parameter of their name, ignoring case and
+// dashes: --create-pr sets 'createPr'.
+var flagParams = map[string]string{
+	"dir":                "dirs",
+	"env":                "extraEnv",
+	"format":             "planFormat",
+	"target":             "targets",
+	"var":                "vars",
+	"var-file":           "varFiles",
+	"protected-resource": "protectedResources",
+	"redact-pattern":     "redactPatterns",
+	"workspace":          "planWorkspace",
+}
+
+// secretParams are the parameters whose whole value is a secret.
+var secretParams = map[string]bool{
+	"notifywebhook": true,
+}
+
+// keyValueParams are the parameters whose values are KEY=VALUE pairs, whose
+// VALUE is a secret when KEY looks like one.
+var keyValueParams = map[string]bool{
+	"extraenv": true,
+	"vars":     true,
+}
+
+// paramID is how viper and exportFlags compare parameter and flag names.
+func paramID(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "-", ""))
+}
+
+// exportFlags converts the parameters of the loaded config files to the
+// arguments of flags, sorted by parameter. The [markdown] keys of
+// markdownSettings are exported as their flag, in place of their top-level
+// parameter.
+//
+// Parameters:
+//
+//	flags - The flags of tp.
+//	showSecrets - Whether secret values are exported, instead of redactedValue.
+//
+// Returns:
+//
+//	[]string - The arguments, unquoted.
+//	[]string - The parameters in the config without a flag.
+//	[]string - The parameters whose values were redacted.
+func exportFlags(flags *pflag.FlagSet, showSecrets bool) ([]string, []string, []string) {
+	byParam := map[string]*pflag.Flag{}
+	flags.VisitAll(func(f *pflag.Flag) {
+		param := f.Name
+		if p, ok := flagParams[f.Name]; ok {
+			param = p
+		}
+		byParam[paramID(param)] = f
+	})
+	replaced := map[string]bool{}
+	for _, s := range markdownSettings {
+		key := "markdown." + s.key
+		if f := flags.Lookup(s.flag); f != nil && viper.InConfig(key) {
+			byParam[paramID(key)] = f
+			replaced[paramID(s.param)] = true
+		}
+	}
+
+	keys := viper.AllKeys()
+	sort.Strings(keys)
+	var args, unexported, redacted []string
+	for _, key := range keys {
+		if !viper.InConfig(key) || replaced[paramID(key)] {
+			continue
+		}
+		f, ok := byParam[paramID(key)]
+		if !ok {
+			unexported = append(unexported, key)
+			continue
+		}
+		masked := false
+		value := func(v string) string {
+			if showSecrets {
+				return v
+			}
+			if secretParams[paramID(key)] {
+				masked = true
+				return redactedValue
+			}
+			if k, _, ok := strings.Cut(v, "="); ok && keyValueParams[paramID(key)] && sensitiveEnvKey.MatchString(k) {
+				masked = true
+				return k + "=" + redactedValue
+			}
+			return v
+		}
+		args = append(args, flagArgs(f, key, value)...)
+		if masked {
+			redacted = append(redacted, key)
+		}
+	}
+	return args, unexported, redacted
+}
+
+// flagArgs returns the arguments setting f to the value of the parameter key,
+// each value passed through value: one per value of a repeatable flag, and
+// --flag=false for a false bool.
+func flagArgs(f *pflag.Flag, key string, value func(string) string) []string {
+	name := "--" + f.Name
+	if f.Shorthand != "" {
+		name = "-" + f.Shorthand
+	}
+	switch f.Value.Type() {
+	case "bool":
+		if viper.GetBool(key) {
+			return []string{name}
+		}
+		return []string{"--" + f.Name + "=false"}
+	case "stringArray":
+		var args []string
+		for _, v := range viper.GetStringSlice(key) {
+			args = append(args, name, value(v))
+		}
+		return args
+	case "stringSlice":
+		// Repeated slice flags append, and each is read as CSV, so a value
+		// with a comma is quoted to stay one value
+		var args []string
+		for _, v := range viper.GetStringSlice(key) {
+			args = append(args, name, csvField(value(v)))
+		}
+		return args
+	default:
+		return []string{name, value(viper.GetString(key))}
+	}
+}
+
+// csvField quotes value as a CSV field when it needs it, e.g. a value with a
+// comma or a double quote.
+func csvField(value string) string {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	// Writing to a strings.Builder doesn't fail
+	_ = w.Write([]string{value})
+	w.Flush()
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// formatExportedFlags renders the arguments of exportFlags as a command line
+// for POSIX shells.
+func formatExportedFlags(args []string) string {
+	parts := []string{"gh", "tp"}
+	for _, arg := range args {
+		parts = append(parts, shellQuote(arg))
+	}
+	return strings.Join(parts, " ")
+}
+
+// resolvedConfigPath returns the config file viper loaded.
+func resolvedConfigPath() (string, error) {
+	cfgPath := viper.ConfigFileUsed()
+	if cfgPath == "" {
+		return "", fmt.Errorf(
+			"no config file found (checked standard locations for '%s'). Use --config or run 'gh tp init'",
+			ConfigName,
+		)
+	}
+	return cfgPath, nil
+}
+
+// listConfigBackups finds the timestamped backups of cfgPath, oldest first.
+//
+// Parameters:
+//
+//	cfgPath - The path of the config file.
+//
+// Returns:
+//
+//	[]configBackup - The backups found, oldest first.
+//	error - Any error encountered searching for backups.
+func listConfigBackups(cfgPath string) ([]configBackup, error) {
+	matches, err := filepath.Glob(cfgPath + "-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for backups of %s: %w", cfgPath, err)
+	}
+
+	var backups []configBackup
+	for _, m := range matches {
+		ts := strings.TrimPrefix(m, cfgPath+"-")
+		parsed, ok := parseBackupTimestamp(ts)
+		if !ok {
+			Logger.Debugf("Skipping %s: not a timestamped backup", m)
+			continue
+		}
+		backups = append(backups, configBackup{Path: m, Timestamp: ts, Time: parsed})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].Time.Equal(backups[j].Time) {
+			return backups[i].Time.Before(backups[j].Time)
+		}
+		// Backups of the same second are numbered by newBackup: -2 before -10
+		if len(backups[i].Path) != len(backups[j].Path) {
+			return len(backups[i].Path) < len(backups[j].Path)
+		}
+		return backups[i].Path < backups[j].Path
+	})
+	return backups, nil
+}
+
+// parseBackupTimestamp parses the suffix of a backup: a timestamp in
+// backupTimeFormat or legacyBackupTimeFormat, optionally followed by the
+// number newBackup appends, e.g. 20250102150405-2.
+func parseBackupTimestamp(ts string) (time.Time, bool) {
+	stamp, n, numbered := strings.Cut(ts, "-")
+	if numbered {
+		if i, err := strconv.Atoi(n); err != nil || i < 2 {
+			return time.Time{}, false
+		}
+	}
+	for _, layout := range []string{backupTimeFormat, legacyBackupTimeFormat} {
+		if len(stamp) != len(layout) {
+			continue
+		}
+		if parsed, err := time.ParseInLocation(layout, stamp, time.Local); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// restoreConfigBackup copies the backup with the given timestamp over cfgPath
+// after confirmation. The current config is backed up first so a restore can
+// itself be undone.
+//
+// Parameters:
+//
+//	cfgPath - The path of the config file.
+//	timestamp - The timestamp of the backup to restore.
+//	skipConfirm - Whether to restore without asking.
+//
+// Returns:
+//
+//	error - ErrInterrupted if the user declines, or any error encountered restoring.
+func restoreConfigBackup(cfgPath, timestamp string, skipConfirm bool) error {
+	backups, err := listConfigBackups(cfgPath)
+	if err != nil {
+		return err
+	}
+	var backup *configBackup
+	for i := range backups {
+		if backups[i].Timestamp == timestamp {
+			backup = &backups[i]
+			break
+		}
+	}
+	if backup == nil {
+		return fmt.Errorf(
+			"no backup of %s with timestamp %q, run 'gh tp config backups' to list them",
+			cfgPath,
+			timestamp,
+		)
+	}
+
+	if !skipConfirm {
+		accessible, _ = strconv.ParseBool(os.Getenv("ACCESSIBLE"))
+		var restore bool
+		formRunner := formRunnerFactory(
+			fmt.Sprintf("Restore %s over %s?", filepath.Base(backup.Path), cfgPath),
+			&restore,
+			accessible,
+		)
+		err = formRunner.Run()
+		if isUserAbort(err) || (err == nil && !restore) {
+			return ErrInterrupted
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if doesExist(cfgPath) {
+		currentBackup, backupErr := newBackup(cfgPath, time.Now())
+		if backupErr != nil {
+			return backupErr
+		}
+		Logger.Infof("Backup file %s created", currentBackup)
+	}
+	if err = copyFile(backup.Path, cfgPath, os.O_TRUNC); err != nil {
+		return err
+	}
+	Logger.Infof("Restored %s from %s", cfgPath, backup.Path)
+	return nil
+}
+
+// completeBackupTimestamps completes 'config restore' with the known backups.
+func completeBackupTimestamps(
+	_ *cobra.Command,
+	args []string,
+	_ string,
+) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	cfgPath, err := resolvedConfigPath()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	backups, err := listConfigBackups(cfgPath)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	timestamps := make([]string, 0, len(backups))
+	for _, b := range backups {
+		timestamps = append(timestamps, b.Timestamp)
+	}
+	return timestamps, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	configRestoreCmd.Flags().BoolP("yes", "y", false, "restore without asking for confirmation")
+	configExportFlagsCmd.Flags().Bool("show-secrets", false, "print secret values, like the notifyWebhook URL, instead of redacting them")
+	configCmd.AddCommand(configBackupsCmd, configRestoreCmd, configExportFlagsCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigBackups(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	// setup creates a config file with two backups and an unrelated file
+	setup := func(t *testing.T) string {
+		t.Helper()
+		dir := t.TempDir()
+		cfgPath := filepath.Join(dir, ConfigName)
+		files := map[string]string{
+			ConfigName:                   "planFile = 'current.out'\n",
+			ConfigName + "-202501021504": "planFile = 'newer.out'\n",
+			ConfigName + "-202401021504": "planFile = 'older.out'\n",
+			ConfigName + "-notes":        "not a backup\n",
+		}
+		for name, content := range files {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+		}
+		return cfgPath
+	}
+
+	t.Run("Backups are listed oldest first", func(t *testing.T) {
+		cfgPath := setup(t)
+
+		backups, err := listConfigBackups(cfgPath)
+
+		require.NoError(t, err)
+		require.Len(t, backups, 2)
+		require.Equal(t, "202401021504", backups[0].Timestamp)
+		require.Equal(t, "202501021504", backups[1].Timestamp)
+		require.Equal(t, cfgPath+"-202501021504", backups[1].Path)
+	})
+
+	t.Run("Restore a specific backup", func(t *testing.T) {
+		cfgPath := setup(t)
+
+		require.NoError(t, restoreConfigBackup(cfgPath, "202401021504", true))
+
+		got, err := os.ReadFile(cfgPath)
+		require.NoError(t, err)
+		require.Equal(t, "planFile = 'older.out'\n", string(got))
+
+		// The replaced config was backed up
+		backups, err := listConfigBackups(cfgPath)
+		require.NoError(t, err)
+		require.Len(t, backups, 3)
+		replaced, err := os.ReadFile(backups[2].Path)
+		require.NoError(t, err)
+		require.Equal(t, "planFile = 'current.out'\n", string(replaced))
+	})
+
+	t.Run("Unknown timestamp", func(t *testing.T) {
+		cfgPath := setup(t)
+
+		err := restoreConfigBackup(cfgPath, "202301021504", true)
+
+		require.ErrorContains(t, err, `no backup of`)
+		got, readErr := os.ReadFile(cfgPath)
+		require.NoError(t, readErr)
+		require.Equal(t, "planFile = 'current.out'\n", string(got))
+	})
+
+	t.Run("Confirmation is required", func(t *testing.T) {
+		originalFactory := formRunnerFactory
+		defer func() {
+			formRunnerFactory = originalFactory
+		}()
+
+		for _, confirm := range []bool{false, true} {
+			cfgPath := setup(t)
+			formRunnerFactory = func(title string, createFile *bool, accessible bool) FormRunner {
+				require.Contains(t, title, ConfigName+"-202501021504")
+				*createFile = confirm
+				return &MockFormRunner{err: nil}
+			}
+
+			err := restoreConfigBackup(cfgPath, "202501021504", false)
+
+			got, readErr := os.ReadFile(cfgPath)
+			require.NoError(t, readErr)
+			if confirm {
+				require.NoError(t, err)
+				require.Equal(t, "planFile = 'newer.out'\n", string(got))
+			} else {
+				require.ErrorIs(t, err, ErrInterrupted)
+				require.Equal(t, "planFile = 'current.out'\n", string(got))
+			}
+		}
+	})
+}
+
+func TestExportFlags(t *testing.T) {
+	// The flags of tp, bound to their parameters
+	newFlags := func() *pflag.FlagSet {
+		flags := pflag.NewFlagSet("tp", pflag.ContinueOnError)
+		persistent := pflag.NewFlagSet("tp", pflag.ContinueOnError)
+		defineFlags(flags, persistent)
+		flags.AddFlagSet(persistent)
+		return flags
+	}
+	loadConfig(t, `binary = 'terraform'
+planFile = 'plan.out'
+mdFile = 'plan.md'
+showDrift = false
+dirs = ['stacks/net', 'stacks/app']
+formats = ['github', 'plain,text']
+deadline = '20m'
+confirmDestroyCount = 5
+prTitle = "Rotate the team's keys"
+mdTemplate = 'replaced.tmpl'
+extraEnv = ['AWS_REGION=us-east-1', 'DB_PASSWORD=hunter2']
+notifyWebhook = 'https://hooks.example.com/T000/B000/XXXX'
+
+[markdown]
+template = '.github/plan.md.tmpl'
+
+[baseRules]
+"feature/" = 'develop'
+`)
+
+	args, unexported, redacted := exportFlags(newFlags(), false)
+
+	require.Equal(t, []string{"baserules.feature/"}, unexported)
+	require.Equal(t, []string{"extraenv", "notifywebhook"}, redacted)
+	require.Equal(
+		t,
+		"gh tp -b terraform --confirm-destroy-count 5 --deadline 20m --dir stacks/net --dir stacks/app "+
+			"--env AWS_REGION=us-east-1 --env 'DB_PASSWORD=<redacted>' "+
+			`--formats github --formats '"plain,text"' --md-template .github/plan.md.tmpl -m plan.md `+
+			"--notify-webhook '<redacted>' -o plan.out "+
+			`--pr-title 'Rotate the team'\''s keys' --show-drift=false`,
+		formatExportedFlags(args),
+	)
+
+	// With --show-secrets, the flags resolve to the same parameters as the
+	// config file, with [markdown] applied
+	args, _, redacted = exportFlags(newFlags(), true)
+	require.Empty(t, redacted)
+	resolved := func() []any {
+		return []any{
+			viper.GetString("binary"),
+			viper.GetString("planFile"),
+			viper.GetString("mdFile"),
+			viper.GetBool("showDrift"),
+			viper.GetStringSlice("dirs"),
+			viper.GetStringSlice("formats"),
+			viper.GetDuration("deadline"),
+			viper.GetInt("confirmDestroyCount"),
+			viper.GetString("prTitle"),
+			viper.GetString("mdTemplate"),
+			viper.GetStringSlice("extraEnv"),
+			viper.GetString("notifyWebhook"),
+		}
+	}
+	viper.Set("mdTemplate", viper.Get("markdown.template"))
+	want := resolved()
+	viper.Reset()
+	flags := pflag.NewFlagSet("tp", pflag.ContinueOnError)
+	persistent := pflag.NewFlagSet("tp", pflag.ContinueOnError)
+	defineFlags(flags, persistent)
+	bindFlags(flags, persistent)
+	flags.AddFlagSet(persistent)
+	require.NoError(t, flags.Parse(args))
+	require.Equal(t, want, resolved())
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/charmbracelet/log"
 	"github.com/go-playground/validator/v10"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
@@ -34,7 +35,7 @@ func TestGenConfig(t *testing.T) {
 }
 
 func TestCreateConfig_ValidationPlanAndMdAreNotTheSame(t *testing.T) {
-	validate := validator.New(validator.WithRequiredStructEnabled())
+	validate := newConfigValidator()
 
 	testCases := []struct {
 		name      string
@@ -100,7 +101,7 @@ func TestCreateConfig_ValidationPlanAndMdAreNotTheSame(t *testing.T) {
 }
 
 func TestCreateConfig_ValidationPlanFileRequired(t *testing.T) {
-	validate := validator.New(validator.WithRequiredStructEnabled())
+	validate := newConfigValidator()
 
 	testCases := []struct {
 		name      string
@@ -161,7 +162,7 @@ func TestCreateConfig_ValidationPlanFileRequired(t *testing.T) {
 }
 
 func TestCreateConfig_ValidationMdFileRequired(t *testing.T) {
-	validate := validator.New(validator.WithRequiredStructEnabled())
+	validate := newConfigValidator()
 
 	testCases := []struct {
 		name      string
@@ -222,7 +223,7 @@ func TestCreateConfig_ValidationMdFileRequired(t *testing.T) {
 }
 
 func TestCreateConfig_ValidationExpectedBinary(t *testing.T) {
-	validate := validator.New(validator.WithRequiredStructEnabled())
+	validate := newConfigValidator()
 
 	testCases := []struct {
 		name      string
@@ -252,6 +253,20 @@ func TestCreateConfig_ValidationExpectedBinary(t *testing.T) {
 			mdFile:    "fukd.md",
 			expectErr: true,
 		},
+		{
+			name:      "Path to OpenTofu",
+			binary:    "/opt/tools/tofu-1.8.0/tofu",
+			planFile:  "plan.out",
+			mdFile:    "plan.md",
+			expectErr: false,
+		},
+		{
+			name:      "Path to another binary",
+			binary:    "/usr/local/bin/fukd",
+			planFile:  "plan.out",
+			mdFile:    "plan.md",
+			expectErr: true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -276,16 +291,16 @@ func TestCreateConfig_ValidationExpectedBinary(t *testing.T) {
 					validationErrs, ok := err.(validator.ValidationErrors)
 					require.True(t, ok, "Should be validator.ValidationErrors")
 
-					// check if any validation error is for the oneof constraint
+					// check if any validation error is for the binary constraint
 					found := false
 
 					for _, valErr := range validationErrs {
-						if valErr.Tag() == "oneof" {
+						if valErr.Tag() == "binary" {
 							found = true
 							break
 						}
 					}
-					require.True(t, found, "Should have 'oneof' validation error")
+					require.True(t, found, "Should have 'binary' validation error")
 				} else {
 					require.NoError(t, err, "Should not return an error when tofu or terraform is the binary")
 				}
@@ -294,9 +309,29 @@ func TestCreateConfig_ValidationExpectedBinary(t *testing.T) {
 	}
 }
 
+func TestCreateConfig_ValidationMinVersion(t *testing.T) {
+	validate := newConfigValidator()
+
+	for minVersion, expectErr := range map[string]bool{"": false, "1.6.0": false, "latest": true} {
+		t.Run(minVersion, func(t *testing.T) {
+			conf := ConfigParams{Binary: "terraform", PlanFile: "plan.out", MdFile: "plan.md", MinVersion: minVersion}
+
+			err := validate.Struct(conf)
+
+			if !expectErr {
+				require.NoError(t, err)
+				return
+			}
+			var validationErrs validator.ValidationErrors
+			require.ErrorAs(t, err, &validationErrs)
+			require.Equal(t, "semver", validationErrs[0].Tag())
+		})
+	}
+}
+
 func TestCreateConfig_ValidationVerboseIsABool(t *testing.T) {
 	// Setup validation
-	validate := validator.New(validator.WithRequiredStructEnabled())
+	validate := newConfigValidator()
 
 	// Test validation through direct tag validation
 	// This allows us to test the constraint without fighting Go's type system
@@ -458,7 +493,7 @@ func TestCreateConfig(t *testing.T) {
 		mockUserPrompt.On("AskOverwrite", false).Return(true, nil)
 
 		// Call the function
-		err := createConfig(cfgBinary, cfgFile, cfgMdFile, cfgPlanFile)
+		err := createConfig(cfgBinary, cfgFile, cfgMdFile, cfgPlanFile, nil)
 
 		// Debug - print actual calls
 		// t.Logf("Mock file checker calls: %v", mockFileChecker.Calls)
@@ -470,3 +505,158 @@ func TestCreateConfig(t *testing.T) {
 		mockUserPrompt.AssertExpectations(t)
 	})
 }
+
+func TestMarkdownChoices(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	t.Run("Defaults leave out the table", func(t *testing.T) {
+		require.Nil(t, defaultMarkdownChoices().params())
+
+		data, err := genConfig(ConfigParams{Binary: "terraform", PlanFile: "plan.out", MdFile: "plan.md"})
+		require.NoError(t, err)
+		require.NotContains(t, string(data), "[markdown]")
+	})
+
+	t.Run("Form values round-trip through the config file", func(t *testing.T) {
+		choices := defaultMarkdownChoices()
+		choices.Syntax = string(SyntaxHighlightDiff)
+		choices.ShowDrift = false
+		choices.IncludeJSON = true
+		conf := ConfigParams{
+			Binary:   "tofu",
+			PlanFile: "plan.out",
+			MdFile:   "plan.md",
+			Markdown: choices.params(),
+		}
+		require.NoError(t, validateConfig(conf))
+
+		data, err := genConfig(conf)
+		require.NoError(t, err)
+		require.Contains(t, string(data), "[markdown]")
+		require.NotContains(t, string(data), "groupByModule", "defaults aren't written")
+
+		loadConfig(t, string(data))
+		params, err := loadMarkdownParams()
+		require.NoError(t, err)
+		require.Equal(t, *conf.Markdown, params)
+		require.Equal(t, "diff", params.Syntax)
+		require.False(t, *params.ShowDrift)
+		require.True(t, *params.IncludeJSON)
+		require.Nil(t, params.GroupByModule)
+	})
+}
+
+func TestReadConfigFiles(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+	base := write("base.toml", `binary = "terraform"
+planFile = "plan.out"
+mdFile = "plan.md"
+
+[markdown]
+syntax = "diff"
+showOutputs = true
+`)
+	override := write("override.toml", `binary = "tofu"
+
+[markdown]
+syntax = "hcl"
+`)
+
+	t.Run("Later files win", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+
+		require.NoError(t, readConfigFiles([]string{base, override}))
+
+		require.Equal(t, "tofu", viper.GetString("binary"))
+		require.Equal(t, "plan.out", viper.GetString("planFile"))
+		require.Equal(t, "hcl", viper.GetString("markdown.syntax"))
+		require.True(t, viper.GetBool("markdown.showOutputs"))
+	})
+
+	t.Run("Single file", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+
+		require.NoError(t, readConfigFiles([]string{base}))
+
+		require.Equal(t, "terraform", viper.GetString("binary"))
+		require.Equal(t, base, viper.ConfigFileUsed())
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		missing := filepath.Join(dir, "missing.toml")
+
+		err := readConfigFiles([]string{base, missing})
+
+		require.EqualError(t, err, `config file "`+missing+`" specified via --config not found`)
+	})
+
+	t.Run("Directory", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+
+		require.ErrorContains(t, readConfigFiles([]string{dir}), "is a directory")
+	})
+
+	t.Run("File that doesn't parse", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		invalid := write("invalid.toml", "binary = \n")
+
+		require.ErrorContains(t, readConfigFiles([]string{base, invalid}), `invalid config file "`+invalid+`"`)
+	})
+}
+
+func TestWriteExampleTF(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	t.Run("Created in an empty directory", func(t *testing.T) {
+		dir := t.TempDir()
+
+		created, err := writeExampleTF(dir)
+
+		require.NoError(t, err)
+		require.True(t, created)
+		got, err := os.ReadFile(filepath.Join(dir, exampleTFName)) //nolint:gosec // test file
+		require.NoError(t, err)
+		require.Equal(t, exampleTF, string(got))
+		require.Contains(t, string(got), `resource "terraform_data" "example"`)
+	})
+
+	t.Run("Existing main.tf is kept", func(t *testing.T) {
+		dir := t.TempDir()
+		mainTF := filepath.Join(dir, exampleTFName)
+		require.NoError(t, os.WriteFile(mainTF, []byte("# mine\n"), 0o600))
+
+		created, err := writeExampleTF(dir)
+
+		require.NoError(t, err)
+		require.False(t, created)
+		got, err := os.ReadFile(mainTF) //nolint:gosec // test file
+		require.NoError(t, err)
+		require.Equal(t, "# mine\n", string(got))
+	})
+
+	t.Run("Not added to an existing configuration", func(t *testing.T) {
+		for _, file := range []string{"network.tf", "network.tofu"} {
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, file), nil, 0o600))
+
+			created, err := writeExampleTF(dir)
+
+			require.NoError(t, err)
+			require.False(t, created)
+			require.NoFileExists(t, filepath.Join(dir, exampleTFName))
+		}
+	})
+}
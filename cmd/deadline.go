@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrDeadlineExceeded indicates that the run took longer than --deadline.
+var ErrDeadlineExceeded = errors.New("deadline exceeded")
+
+// runContext returns the root context of a run. With a positive deadline it's
+// cancelled once the deadline passes, with ErrDeadlineExceeded as its cause.
+//
+// Parameters:
+//
+//	parent - The parent context.
+//	deadline - The maximum duration of the run, 0 for no limit.
+//
+// Returns:
+//
+//	context.Context - The context to pass to every plan, show and API call.
+//	context.CancelFunc - Releases the context's resources.
+func runContext(parent context.Context, deadline time.Duration) (context.Context, context.CancelFunc) {
+	if deadline <= 0 {
+		return context.WithCancel(parent)
+	}
+	Logger.Debugf("Run deadline is %s", deadline)
+	return context.WithTimeoutCause(parent, deadline, fmt.Errorf("%w after %s", ErrDeadlineExceeded, deadline))
+}
+
+// deadlineError replaces err with the deadline error when the run's deadline
+// has passed, since whatever failed only did so because it was cancelled.
+func deadlineError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if cause := context.Cause(ctx); errors.Is(cause, ErrDeadlineExceeded) {
+		Logger.Debugf("Cancelled by the deadline: %v", err)
+		return cause
+	}
+	return err
+}
@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunContext(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	t.Run("No deadline", func(t *testing.T) {
+		ctx, cancel := runContext(context.Background(), 0)
+		defer cancel()
+
+		_, ok := ctx.Deadline()
+		require.False(t, ok)
+		require.NoError(t, ctx.Err())
+	})
+
+	t.Run("Expired deadline has a clear cause", func(t *testing.T) {
+		ctx, cancel := runContext(context.Background(), time.Millisecond)
+		defer cancel()
+		<-ctx.Done()
+
+		err := deadlineError(ctx, errors.New("terraform plan failed: context deadline exceeded"))
+
+		require.ErrorIs(t, err, ErrDeadlineExceeded)
+		require.EqualError(t, err, "deadline exceeded after 1ms")
+	})
+
+	t.Run("Errors before the deadline are kept", func(t *testing.T) {
+		ctx, cancel := runContext(context.Background(), time.Hour)
+		defer cancel()
+		planErr := errors.New("terraform plan failed")
+
+		require.Equal(t, planErr, deadlineError(ctx, planErr))
+		require.NoError(t, deadlineError(ctx, nil))
+	})
+}
+
+func TestRunPlansDeadline(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	ctx, cancel := runContext(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := runPlans(ctx, []string{"a", "b", "c"}, 2, func(ctx context.Context, _ string) (planResult, error) {
+		// Blocks like a long plan until the cancellation reaches it
+		select {
+		case <-ctx.Done():
+			return planResult{}, ctx.Err()
+		case <-time.After(time.Minute):
+			return planResult{}, nil
+		}
+	})
+
+	require.ErrorIs(t, err, ErrDeadlineExceeded)
+	require.Less(t, time.Since(start), 10*time.Second)
+}
@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// loadConfirmDestroyCount reads and validates 'confirmDestroyCount', the
+// number of destroyed resources above which the pull request needs a
+// confirmation. 0, the default, never asks.
+func loadConfirmDestroyCount() (int, error) {
+	threshold := viper.GetInt("confirmDestroyCount")
+	if threshold < 0 {
+		return 0, fmt.Errorf("invalid 'confirmDestroyCount' (%d): must not be negative", threshold)
+	}
+	return threshold, nil
+}
+
+// destroyedResources returns the addresses of the resources the plans
+// destroy, replacements included, prefixed by their directory when it isn't
+// the current one, and the directories of the plans without a structured
+// plan, whose destroyed resources are unknown.
+func destroyedResources(results []planResult) ([]string, []string) {
+	var destroyed, unknown []string
+	for _, result := range results {
+		if result.JSON == nil {
+			unknown = append(unknown, planResultDir(result.Dir))
+			continue
+		}
+		for _, rc := range result.JSON.ResourceChanges {
+			if rc.Change == nil || !(rc.Change.Actions.Delete() || rc.Change.Actions.Replace()) {
+				continue
+			}
+			address := rc.Address
+			if result.Dir != "" && result.Dir != "." {
+				address = filepath.ToSlash(result.Dir) + ": " + address
+			}
+			destroyed = append(destroyed, address)
+		}
+	}
+	return destroyed, unknown
+}
+
+// planResultDir returns dir for display, "." for the current directory.
+func planResultDir(dir string) string {
+	if dir == "" {
+		return "."
+	}
+	return filepath.ToSlash(dir)
+}
+
+// confirmDestroy lists the resources the plans destroy and asks whether to go
+// on with the pull request when there are more than threshold of them, or
+// when a plan has no structured plan to count them in.
+//
+// Parameters:
+//
+//	results - The plans tp ran
+//	threshold - The limit from loadConfirmDestroyCount, 0 never asks
+//	skipConfirm - Whether --yes was passed
+//
+// Returns:
+//
+//	error - ErrInterrupted if the user declined, or any error encountered asking
+func confirmDestroy(results []planResult, threshold int, skipConfirm bool) error {
+	if threshold == 0 {
+		return nil
+	}
+	destroyed, unknown := destroyedResources(results)
+	if len(destroyed) <= threshold && len(unknown) == 0 {
+		Logger.Debugf("The plan destroys %d resources, within 'confirmDestroyCount' (%d)", len(destroyed), threshold)
+		return nil
+	}
+
+	title := fmt.Sprintf("Destroy %d resources and proceed with the pull request?", len(destroyed))
+	if len(destroyed) > threshold {
+		Logger.Warnf("The plan destroys %d resources, more than 'confirmDestroyCount' (%d):", len(destroyed), threshold)
+		for _, address := range destroyed {
+			Logger.Warnf("  - %s", address)
+		}
+	}
+	if len(unknown) > 0 {
+		Logger.Warnf(
+			"Unable to count the resources destroyed in %s, no structured plan to check 'confirmDestroyCount' (%d) against.",
+			strings.Join(unknown, ", "),
+			threshold,
+		)
+		title = "Proceed with the pull request without knowing how many resources are destroyed?"
+	}
+	if skipConfirm {
+		Logger.Info("Proceeding with --yes.")
+		return nil
+	}
+
+	accessible, _ := strconv.ParseBool(os.Getenv("ACCESSIBLE"))
+	var proceed bool
+	formRunner := formRunnerFactory(title, &proceed, accessible)
+	err := formRunner.Run()
+	if isUserAbort(err) || (err == nil && !proceed) {
+		return ErrInterrupted
+	}
+	if err != nil {
+		return fmt.Errorf("unable to confirm the destroyed resources, pass --yes to proceed: %w", err)
+	}
+	return nil
+}
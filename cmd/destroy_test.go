@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfirmDestroyCount(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	threshold, err := loadConfirmDestroyCount()
+	require.NoError(t, err)
+	require.Zero(t, threshold, "off by default")
+
+	viper.Set("confirmDestroyCount", 10)
+	threshold, err = loadConfirmDestroyCount()
+	require.NoError(t, err)
+	require.Equal(t, 10, threshold)
+
+	viper.Set("confirmDestroyCount", -1)
+	_, err = loadConfirmDestroyCount()
+	require.ErrorContains(t, err, "must not be negative")
+}
+
+func TestDestroyedResources(t *testing.T) {
+	plan := loadPlanFixture(t, "changes.json")
+
+	destroyed, unknown := destroyedResources([]planResult{{Dir: ".", JSON: plan}})
+	require.Equal(
+		t,
+		[]string{"module.network.aws_subnet.legacy", "module.db.aws_db_instance.main"},
+		destroyed,
+		"replacements are destroyed too",
+	)
+	require.Empty(t, unknown)
+
+	destroyed, unknown = destroyedResources([]planResult{{Dir: "stacks/net", JSON: plan}, {Dir: "stacks/app"}})
+	require.Equal(
+		t,
+		[]string{"stacks/net: module.network.aws_subnet.legacy", "stacks/net: module.db.aws_db_instance.main"},
+		destroyed,
+	)
+	require.Equal(t, []string{"stacks/app"}, unknown, "without a structured plan, what is destroyed is unknown")
+
+	destroyed, unknown = destroyedResources([]planResult{{Dir: ".", JSON: loadPlanFixture(t, "no-changes.json")}})
+	require.Empty(t, destroyed)
+	require.Empty(t, unknown)
+}
+
+func TestConfirmDestroy(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	results := []planResult{{Dir: ".", JSON: loadPlanFixture(t, "changes.json")}}
+	originalFactory := formRunnerFactory
+	t.Cleanup(func() {
+		formRunnerFactory = originalFactory
+	})
+	prompted := false
+	wantTitle := "Destroy 2 resources and proceed with the pull request?"
+	answer := func(proceed bool, err error) {
+		prompted = false
+		formRunnerFactory = func(title string, createFile *bool, accessible bool) FormRunner {
+			prompted = true
+			require.Equal(t, wantTitle, title)
+			*createFile = proceed
+			return &MockFormRunner{err: err}
+		}
+	}
+
+	t.Run("Below the threshold", func(t *testing.T) {
+		answer(false, nil)
+
+		require.NoError(t, confirmDestroy(results, 2, false))
+		require.False(t, prompted)
+	})
+
+	t.Run("Off", func(t *testing.T) {
+		answer(false, nil)
+
+		require.NoError(t, confirmDestroy(results, 0, false))
+		require.False(t, prompted)
+	})
+
+	t.Run("Above the threshold", func(t *testing.T) {
+		answer(true, nil)
+		require.NoError(t, confirmDestroy(results, 1, false))
+		require.True(t, prompted)
+
+		answer(false, nil)
+		require.ErrorIs(t, confirmDestroy(results, 1, false), ErrInterrupted)
+		require.True(t, prompted)
+	})
+
+	t.Run("Yes", func(t *testing.T) {
+		answer(false, nil)
+
+		require.NoError(t, confirmDestroy(results, 1, true))
+		require.False(t, prompted)
+	})
+
+	t.Run("No structured plan", func(t *testing.T) {
+		wantTitle = "Proceed with the pull request without knowing how many resources are destroyed?"
+		t.Cleanup(func() {
+			wantTitle = "Destroy 2 resources and proceed with the pull request?"
+		})
+		unstructured := []planResult{{Dir: "stacks/app"}}
+
+		answer(false, nil)
+		require.ErrorIs(t, confirmDestroy(unstructured, 10, false), ErrInterrupted)
+		require.True(t, prompted, "a threshold can't be checked without a structured plan")
+
+		answer(false, nil)
+		require.NoError(t, confirmDestroy(unstructured, 10, true))
+		require.False(t, prompted)
+	})
+
+	t.Run("No terminal", func(t *testing.T) {
+		answer(false, errors.New("could not open a new TTY"))
+
+		err := confirmDestroy(results, 1, false)
+
+		require.ErrorContains(t, err, "pass --yes to proceed")
+		require.NotErrorIs(t, err, ErrInterrupted)
+	})
+}
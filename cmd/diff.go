@@ -0,0 +1,204 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	md "github.com/nao1215/markdown"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+)
+
+// diffContextLines is the number of unchanged lines shown around each change
+const diffContextLines = 3
+
+// planFileMagic starts every saved plan file, which is a zip archive
+var planFileMagic = []byte("PK\x03\x04")
+
+// codeFence matches the opening or closing line of a fenced code block
+var codeFence = regexp.MustCompile("^(`{3,})[A-Za-z0-9_-]*$")
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff <old> <new>",
+	Args:  cobra.ExactArgs(2), //nolint:mnd
+	Short: "Render a unified diff of two plans as Markdown.",
+	Long: heredoc.Doc(`
+		Render a unified diff of two plans as Markdown, to review how a plan
+		changed. Each plan can be a plan file, the Markdown 'gh tp' created or
+		the plan's text output, which may be gzipped. Plan files are read with
+		'show', in the directory containing them.`),
+	Example: heredoc.Doc(`
+		gh tp diff main.md plan.md
+		gh tp diff old.out plan.out --out plan-diff.md`),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		oldText, err := readPlanInput(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+		newText, err := readPlanInput(cmd.Context(), args[1])
+		if err != nil {
+			return err
+		}
+		doc, err := renderPlanDiff(args[0], args[1], oldText, newText)
+		if err != nil {
+			return err
+		}
+
+		diffOut, _ := cmd.Flags().GetString("out")
+		if diffOut == "" {
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), doc)
+			return err
+		}
+		out, err := validateFilePath(diffOut)
+		if err != nil {
+			return fmt.Errorf("invalid 'out' (%q): %w", diffOut, err)
+		}
+		if err = os.WriteFile(out, []byte(doc+"\n"), defaultFileMode); err != nil {
+			return fmt.Errorf("failed to write %s: %w", out, err)
+		}
+		return existsOrCreated([]tpFile{{out, "Markdown"}})
+	},
+}
+
+// readPlanInput reads the plan text of a 'gh tp diff' input.
+//
+// Parameters:
+//
+//	ctx - The context for showing a plan file.
+//	path - A plan file, a Markdown file created by 'gh tp' or plan text.
+//
+// Returns:
+//
+//	string - The plan text, without ANSI escape sequences.
+//	error - An error if the file can't be read or shown.
+func readPlanInput(ctx context.Context, path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read %q: %w", path, err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("unable to read %q: not a regular file", path)
+	}
+	data, err := os.ReadFile(path) //nolint:gosec // explicitly provided by the user
+	if err != nil {
+		return "", fmt.Errorf("unable to read %q: %w", path, err)
+	}
+
+	compressed := isGzip(path, data)
+	if data, err = decompressPlan(path, data, maxPlanBytes); err != nil {
+		return "", err
+	}
+	name := path
+	if compressed {
+		name = strings.TrimSuffix(path, filepath.Ext(path))
+	}
+
+	var text string
+	switch {
+	case bytes.HasPrefix(data, planFileMagic) && compressed:
+		return "", fmt.Errorf("unable to read %q: decompress plan files before diffing them", path)
+	case bytes.HasPrefix(data, planFileMagic):
+		text, err = showPlanFile(ctx, path)
+		if err != nil {
+			return "", err
+		}
+	case strings.EqualFold(filepath.Ext(name), ".md"):
+		text = extractCodeBlocks(string(data))
+		if text == "" {
+			return "", fmt.Errorf("no plan found in %q: expected Markdown created by 'gh tp'", path)
+		}
+	default:
+		text = string(data)
+	}
+	return normalizePlanText(ansiEscape.ReplaceAllString(text, "")), nil
+}
+
+// showPlanFile reads a saved plan file as text with the configured binary.
+func showPlanFile(ctx context.Context, path string) (string, error) {
+	bin, err := determineBinary(nil)
+	if err != nil {
+		return "", err
+	}
+	tf, err := tfexec.NewTerraform(filepath.Dir(path), bin)
+	if err != nil {
+		return "", fmt.Errorf("tfexec init failed: %w", err)
+	}
+	return showPlan(ctx, tf, filepath.Base(path))
+}
+
+// extractCodeBlocks returns the contents of the fenced code blocks of a
+// Markdown document, in order. 'gh tp' renders the plan in code blocks, one
+// per module with --group-by-module.
+func extractCodeBlocks(doc string) string {
+	var blocks []string
+	var block []string
+	fence := ""
+	for line := range strings.SplitSeq(strings.ReplaceAll(doc, "\r\n", "\n"), "\n") {
+		m := codeFence.FindStringSubmatch(strings.TrimSpace(line))
+		switch {
+		case fence == "" && m != nil:
+			fence = m[1]
+			block = nil
+		case fence != "" && strings.TrimSpace(line) == fence:
+			blocks = append(blocks, strings.Join(block, "\n"))
+			fence = ""
+		case fence != "":
+			block = append(block, line)
+		}
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// renderPlanDiff renders the unified diff of two plans as Markdown.
+//
+// Parameters:
+//
+//	oldName, newName - The names of the plans, for the diff header.
+//	oldText, newText - The plan text of each.
+//
+// Returns:
+//
+//	string - The Markdown document.
+//	error - Any error encountered rendering the diff.
+func renderPlanDiff(oldName, newName, oldText, newText string) (string, error) {
+	var sb strings.Builder
+	doc := md.NewMarkdown(&sb)
+	if oldText == newText {
+		doc.PlainTextf("No differences between `%s` and `%s`.", oldName, newName)
+		return doc.String(), nil
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(oldText + "\n"),
+		B:        difflib.SplitLines(newText + "\n"),
+		FromFile: oldName,
+		ToFile:   newName,
+		Context:  diffContextLines,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to diff %s and %s: %w", oldName, newName, err)
+	}
+
+	var block strings.Builder
+	err = md.NewMarkdown(&block).CodeBlocks(md.SyntaxHighlight(SyntaxHighlightDiff), strings.TrimRight(diff, "\n")).Build()
+	if err != nil {
+		return "", fmt.Errorf("markdown generation failed (code block): %w", err)
+	}
+	doc.Details(fmt.Sprintf("Plan diff: %s → %s", oldName, newName), "\n"+block.String()+"\n")
+	return doc.String(), nil
+}
+
+func init() {
+	diffCmd.Flags().String("out", "", "write the Markdown to this file instead of stdout.")
+	rootCmd.AddCommand(diffCmd)
+}
@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderPlanDiff(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	oldPath := filepath.Join("..", "testdata", "plans", "changes.txt")
+	newPath := filepath.Join("..", "testdata", "plans", "changes-updated.txt")
+
+	oldText, err := readPlanInput(context.Background(), oldPath)
+	require.NoError(t, err)
+	newText, err := readPlanInput(context.Background(), newPath)
+	require.NoError(t, err)
+
+	t.Run("Fixtures", func(t *testing.T) {
+		got, err := renderPlanDiff("changes.txt", "changes-updated.txt", oldText, newText)
+		require.NoError(t, err)
+
+		require.Contains(t, got, "<summary>Plan diff: changes.txt → changes-updated.txt</summary>")
+		require.Contains(t, got, "```diff\n--- changes.txt\n+++ changes-updated.txt\n@@ ")
+		require.Contains(t, got, "\n-  # module.network.aws_subnet.legacy will be destroyed\n")
+		require.Contains(t, got, "\n-Plan: 2 to add, 1 to change, 2 to destroy.\n+Plan: 2 to add, 1 to change, 1 to destroy.\n")
+		require.Contains(t, got, "\n+  + vpc_id = \"vpc-0abc\"\n")
+		// Unchanged resources stay out of the diff beyond the context lines
+		require.NotContains(t, got, "random_password.db will be created")
+	})
+
+	t.Run("Identical plans", func(t *testing.T) {
+		got, err := renderPlanDiff("a.md", "b.md", oldText, oldText)
+		require.NoError(t, err)
+
+		require.Equal(t, "No differences between `a.md` and `b.md`.", got)
+	})
+
+	t.Run("Markdown created by tp compares as its plan", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		mdFile, err := createMarkdown("plan.md", "\x1b[1m"+oldText+"\x1b[0m", "terraform", markdownOptions{})
+		require.NoError(t, err)
+
+		fromMd, err := readPlanInput(context.Background(), mdFile)
+		require.NoError(t, err)
+		require.Equal(t, oldText, fromMd)
+	})
+}
+
+func TestReadPlanInput(t *testing.T) {
+	dir := t.TempDir()
+	notPlan := filepath.Join(dir, "notes.md")
+	require.NoError(t, os.WriteFile(notPlan, []byte("# Notes\n\nNo plan here.\n"), 0o600))
+
+	testCases := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{"Missing", filepath.Join(dir, "missing.out"), "no such file"},
+		{"Directory", dir, "not a regular file"},
+		{"Markdown without a plan", notPlan, "no plan found"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := readPlanInput(context.Background(), tc.path)
+
+			require.ErrorContains(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestReadPlanInputGzip(t *testing.T) {
+	planText, err := os.ReadFile(filepath.Join("..", "testdata", "plans", "changes.txt"))
+	require.NoError(t, err)
+
+	got, err := readPlanInput(context.Background(), filepath.Join("..", "testdata", "plans", "changes.txt.gz"))
+
+	require.NoError(t, err)
+	require.Equal(t, normalizePlanText(string(planText)), got)
+}
+
+func TestExtractCodeBlocks(t *testing.T) {
+	doc := strings.Join([]string{
+		"> [!NOTE]",
+		"<details><summary>root</summary>",
+		"",
+		"```terraform",
+		"  + resource \"a\" \"b\" {}",
+		"```",
+		"</details>",
+		"````terraform",
+		"```",
+		"````",
+	}, "\n")
+
+	require.Equal(t, "  + resource \"a\" \"b\" {}\n\n```", extractCodeBlocks(doc))
+}
@@ -0,0 +1,281 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/viper"
+)
+
+// Regex for environment variable names whose values must never be logged
+var sensitiveEnvKey = regexp.MustCompile(
+	`(?i)(SECRET|TOKEN|PASSWORD|PASSWD|CREDENTIAL|PRIVATE|AUTH|(^|_)KEY$|_KEY_)`,
+)
+
+// redactedValue replaces sensitive values in log output
+const redactedValue = "<redacted>"
+
+// minSecretLength is the shortest secret-looking variable value masked in
+// text, shorter ones like "1" would mask unrelated text
+const minSecretLength = 8
+
+// buildPlanEnv collects the extra environment variables for the plan process
+// from the 'planEnv' config table and the repeatable --env flag. Flag values
+// override config values for the same key, and --data-dir sets TF_DATA_DIR.
+//
+// Returns:
+//
+//	map[string]string - The extra variables, or an empty map if none are configured.
+//	error - Any error encountered reading the config or parsing a flag value.
+func buildPlanEnv() (map[string]string, error) {
+	env, err := configPlanEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, assignment := range viper.GetStringSlice("extraEnv") {
+		key, value, found := strings.Cut(assignment, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid --env %q: expected KEY=VALUE", assignment)
+		}
+		env[key] = value
+	}
+
+	// --data-dir relocates .terraform, otherwise an inherited TF_DATA_DIR is kept
+	if dataDir := viper.GetString("dataDir"); dataDir != "" {
+		env["TF_DATA_DIR"] = dataDir
+	}
+
+	if prohibited := tfexec.ProhibitedEnv(env); len(prohibited) > 0 {
+		sort.Strings(prohibited)
+		return nil, fmt.Errorf(
+			"cannot set %s via planEnv or --env: it is managed by tp",
+			strings.Join(prohibited, ", "),
+		)
+	}
+
+	return env, nil
+}
+
+// configPlanEnv reads the 'planEnv' table from the loaded config file.
+// Environment variable names are case sensitive, so the table is read with
+// configTable rather than from viper.
+func configPlanEnv() (map[string]string, error) {
+	return configTable("planEnv")
+}
+
+// configTable reads a table of strings from the loaded config files. Viper
+// lowercases nested keys, so tables whose keys are case sensitive are decoded
+// from the files directly. With several --config files the tables are merged
+// in order, a later file overriding the keys it sets.
+//
+// Parameters:
+//
+//	key - The name of the table, e.g. "planEnv".
+//
+// Returns:
+//
+//	map[string]string - The table, or an empty map if it isn't configured.
+//	error - Any error encountered reading or parsing a config file.
+func configTable(key string) (map[string]string, error) {
+	table := map[string]string{}
+	if !viper.IsSet(key) {
+		return table, nil
+	}
+
+	for _, configPath := range configFilesUsed() {
+		data, err := os.ReadFile(configPath) //nolint:gosec // path is a config file viper loaded
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s from %s: %w", key, configPath, err)
+		}
+		var raw map[string]any
+		if err = toml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse %s in %s: %w", key, configPath, err)
+		}
+		value, set := raw[key]
+		if !set {
+			continue // Another file sets the table
+		}
+		values, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid %s in %s: expected a table", key, configPath)
+		}
+		for k, v := range values {
+			s, isString := v.(string)
+			if !isString {
+				return nil, fmt.Errorf("invalid %s.%s in %s: expected a string", key, k, configPath)
+			}
+			table[k] = s
+		}
+	}
+	return table, nil
+}
+
+// configFilesUsed returns the config files viper loaded, in the order they
+// were merged: the --config files, or the single file viper found itself.
+//
+// Returns:
+//
+//	[]string - The config files, empty if none was loaded.
+func configFilesUsed() []string {
+	used := viper.ConfigFileUsed()
+	if used == "" {
+		return nil
+	}
+	// readConfigFiles leaves viper pointing at the last file it merged
+	if n := len(layeredConfigFiles); n > 0 && layeredConfigFiles[n-1] == used {
+		return layeredConfigFiles
+	}
+	return []string{used}
+}
+
+// planProcessEnv returns the environment of the plan process: the inherited
+// environment with the extra variables from buildPlanEnv merged over it.
+//
+// Parameters:
+//
+//	environ - The inherited environment as KEY=VALUE, os.Environ() outside of tests.
+//	env - The extra variables, which override inherited ones.
+//
+// Returns:
+//
+//	map[string]string - The environment of the plan process.
+func planProcessEnv(environ []string, env map[string]string) map[string]string {
+	merged := make(map[string]string, len(environ)+len(env))
+	for _, assignment := range environ {
+		if key, value, found := strings.Cut(assignment, "="); found {
+			merged[key] = value
+		}
+	}
+
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		merged[k] = env[k]
+		Logger.Debugf("Plan environment: %s=%s", k, redactEnvValue(k, env[k]))
+	}
+	return merged
+}
+
+// envSetter is the subset of *tfexec.Terraform used to set the plan environment.
+type envSetter interface {
+	SetEnv(env map[string]string) error
+}
+
+// applyPlanEnv gives tf, and only tf, the environment of the plan process, so
+// the extra variables reach neither gh, git, nor the plans of other stacks.
+//
+// tf.SetEnv rejects the variables tfexec would rather take as options, like
+// the TF_VAR_* many users already export. It keeps the map it accepted and
+// copies it for every command, so those are put back once the map is set, as
+// they were when tfexec passed our own environment through.
+//
+// Parameters:
+//
+//	tf - The binary the plan runs with, normally a *tfexec.Terraform.
+//	env - The environment from planProcessEnv.
+//
+// Returns:
+//
+//	error - Any error encountered setting the environment, or nil on success.
+func applyPlanEnv(tf envSetter, env map[string]string) error {
+	managed := make(map[string]string)
+	for _, k := range tfexec.ProhibitedEnv(env) {
+		managed[k] = env[k]
+		delete(env, k)
+	}
+	if err := tf.SetEnv(env); err != nil {
+		return fmt.Errorf("failed to set the plan environment: %w", err)
+	}
+	maps.Copy(env, managed)
+	return nil
+}
+
+// runSecrets returns the values of the secret-looking variables of the plan
+// process, to mask in what the run posts publicly.
+func runSecrets() []string {
+	// A failed --env or 'planEnv' still leaves the inherited secrets to mask
+	planEnv, _ := buildPlanEnv()
+	return sensitiveEnvValues(os.Environ(), planEnv)
+}
+
+// sensitiveEnvValues returns the values of the secret-looking variables of
+// the plan process, from the inherited environ and the extra env, to mask in
+// text posted publicly.
+func sensitiveEnvValues(environ []string, env map[string]string) []string {
+	var values []string
+	add := func(key, value string) {
+		if sensitiveEnvKey.MatchString(key) && len(value) >= minSecretLength && !slices.Contains(values, value) {
+			values = append(values, value)
+		}
+	}
+	for _, kv := range environ {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			add(key, value)
+		}
+	}
+	for key, value := range env {
+		add(key, value)
+	}
+	return values
+}
+
+// redactEnvValue returns value, or a placeholder if key looks like it holds a secret.
+func redactEnvValue(key, value string) string {
+	if sensitiveEnvKey.MatchString(key) {
+		return redactedValue
+	}
+	return value
+}
+
+// dumpPlanEnv writes the environment the plan process would run with, for
+// --dump-plan-env: the inherited variables, then those gh-tp adds from
+// 'planEnv', --env and --data-dir. Secret-looking values are redacted.
+//
+// Parameters:
+//
+//	w - Where the environment is written, stdout outside of tests.
+//	environ - The inherited environment as KEY=VALUE, os.Environ() outside of tests.
+//	env - The variables from buildPlanEnv, which override inherited ones.
+//
+// Returns:
+//
+//	error - Any error encountered writing.
+func dumpPlanEnv(w io.Writer, environ []string, env map[string]string) error {
+	var b strings.Builder
+	b.WriteString("# Inherited\n")
+	inherited := slices.Clone(environ)
+	sort.Strings(inherited)
+	for _, assignment := range inherited {
+		key, value, _ := strings.Cut(assignment, "=")
+		if _, overridden := env[key]; overridden {
+			continue
+		}
+		fmt.Fprintf(&b, "%s=%s\n", key, redactEnvValue(key, value))
+	}
+	b.WriteString("# Added by planEnv, --env and --data-dir\n")
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s=%s\n", key, redactEnvValue(key, env[key]))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,219 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildPlanEnv(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	t.Run("Config table keeps key case and flags override it", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		cfg := filepath.Join(t.TempDir(), ".tp.toml")
+		require.NoError(t, os.WriteFile(cfg, []byte(`planFile = 'plan.out'
+mdFile = 'plan.md'
+
+[planEnv]
+AWS_PROFILE = 'dev'
+http_proxy = 'http://proxy:3128'
+`), 0o600))
+		viper.SetConfigFile(cfg)
+		require.NoError(t, viper.ReadInConfig())
+		viper.Set("extraEnv", []string{"AWS_PROFILE=prod", "EXTRA=a=b"})
+
+		env, err := buildPlanEnv()
+
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{
+			"AWS_PROFILE": "prod",
+			"http_proxy":  "http://proxy:3128",
+			"EXTRA":       "a=b",
+		}, env)
+	})
+
+	t.Run("Tables of layered config files are merged", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		dir := t.TempDir()
+		base := filepath.Join(dir, "base.toml")
+		require.NoError(t, os.WriteFile(base, []byte(`planFile = 'plan.out'
+mdFile = 'plan.md'
+
+[planEnv]
+AWS_PROFILE = 'dev'
+AWS_REGION = 'us-east-1'
+`), 0o600))
+		noTable := filepath.Join(dir, "no-table.toml")
+		require.NoError(t, os.WriteFile(noTable, []byte("binary = 'tofu'\n"), 0o600))
+		override := filepath.Join(dir, "override.toml")
+		require.NoError(t, os.WriteFile(override, []byte(`[planEnv]
+AWS_PROFILE = 'prod'
+`), 0o600))
+
+		require.NoError(t, readConfigFiles([]string{base, noTable}))
+		env, err := buildPlanEnv()
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"AWS_PROFILE": "dev", "AWS_REGION": "us-east-1"}, env)
+
+		require.NoError(t, readConfigFiles([]string{base, noTable, override}))
+		env, err = buildPlanEnv()
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"AWS_PROFILE": "prod", "AWS_REGION": "us-east-1"}, env)
+	})
+
+	t.Run("--data-dir sets TF_DATA_DIR", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		viper.Set("extraEnv", []string{"TF_DATA_DIR=/tmp/ignored"})
+		viper.Set("dataDir", "/var/cache/tf")
+
+		env, err := buildPlanEnv()
+
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"TF_DATA_DIR": "/var/cache/tf"}, env)
+	})
+
+	t.Run("Malformed flag value", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		viper.Set("extraEnv", []string{"NOEQUALS"})
+
+		_, err := buildPlanEnv()
+
+		require.ErrorContains(t, err, "expected KEY=VALUE")
+	})
+
+	t.Run("Variables managed by tp are rejected", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		viper.Set("extraEnv", []string{"TF_VAR_region=us-east-1"})
+
+		_, err := buildPlanEnv()
+
+		require.ErrorContains(t, err, "TF_VAR_region")
+	})
+}
+
+// fakeEnvSetter rejects the variables tfexec does and keeps the map it was given.
+type fakeEnvSetter struct {
+	env map[string]string
+}
+
+func (f *fakeEnvSetter) SetEnv(env map[string]string) error {
+	if prohibited := tfexec.ProhibitedEnv(env); len(prohibited) > 0 {
+		return fmt.Errorf("manual setting of env var %q detected", prohibited[0])
+	}
+	f.env = env
+	return nil
+}
+
+func TestPlanProcessEnv(t *testing.T) {
+	originalLogger := Logger
+	defer func() {
+		Logger = originalLogger
+	}()
+	var buf bytes.Buffer
+	Logger = log.NewWithOptions(&buf, log.Options{Level: log.DebugLevel})
+
+	env := planProcessEnv(
+		[]string{"PATH=/usr/bin", "TP_TEST_PROFILE=dev", "EMPTY="},
+		map[string]string{
+			"TP_TEST_PROFILE":      "prod",
+			"TP_TEST_SECRET_TOKEN": "hunter2",
+		},
+	)
+
+	require.Equal(t, map[string]string{
+		"PATH":                 "/usr/bin",
+		"TP_TEST_PROFILE":      "prod",
+		"TP_TEST_SECRET_TOKEN": "hunter2",
+		"EMPTY":                "",
+	}, env)
+	require.Contains(t, buf.String(), "TP_TEST_PROFILE=prod")
+	require.Contains(t, buf.String(), "TP_TEST_SECRET_TOKEN="+redactedValue)
+	require.NotContains(t, buf.String(), "hunter2")
+}
+
+func TestApplyPlanEnv(t *testing.T) {
+	t.Setenv("TP_TEST_PROFILE", "")
+	tf := &fakeEnvSetter{}
+
+	err := applyPlanEnv(tf, map[string]string{
+		"TP_TEST_PROFILE": "dev",
+		"TF_VAR_region":   "us-east-1",
+		"TF_WORKSPACE":    "prod",
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"TP_TEST_PROFILE": "dev",
+		"TF_VAR_region":   "us-east-1",
+		"TF_WORKSPACE":    "prod",
+	}, tf.env, "inherited variables tfexec rejects are kept")
+	require.Empty(t, os.Getenv("TP_TEST_PROFILE"), "our own environment is untouched")
+}
+
+func TestRedactEnvValue(t *testing.T) {
+	testCases := []struct {
+		key  string
+		want string
+	}{
+		{key: "AWS_PROFILE", want: "value"},
+		{key: "AWS_SECRET_ACCESS_KEY", want: redactedValue},
+		{key: "GOOGLE_CREDENTIALS", want: redactedValue},
+		{key: "GITHUB_TOKEN", want: redactedValue},
+		{key: "ARM_CLIENT_SECRET", want: redactedValue},
+		{key: "DB_PASSWORD", want: redactedValue},
+		{key: "REGION", want: "value"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.key, func(t *testing.T) {
+			require.Equal(t, tc.want, redactEnvValue(tc.key, "value"))
+		})
+	}
+}
+
+func TestSensitiveEnvValues(t *testing.T) {
+	got := sensitiveEnvValues(
+		[]string{"GITHUB_TOKEN=ghp_abcdefgh", "AWS_REGION=us-east-1", "AUTH_ENABLED=1", "DB_PASSWORD=hunter2hunter2"},
+		map[string]string{"DB_PASSWORD": "hunter2hunter2", "TF_VAR_api_key": "k3y-k3y-k3y", "TF_LOG": "DEBUG"},
+	)
+
+	require.ElementsMatch(t, []string{"ghp_abcdefgh", "hunter2hunter2", "k3y-k3y-k3y"}, got, "short values are kept")
+}
+
+func TestDumpPlanEnv(t *testing.T) {
+	environ := []string{
+		"PATH=/usr/bin",
+		"GITHUB_TOKEN=ghp_abc123",
+		"AWS_PROFILE=dev",
+	}
+	env := map[string]string{
+		"AWS_PROFILE":    "prod",
+		"TF_DATA_DIR":    "/var/cache/tf",
+		"AWS_SECRET_KEY": "hunter2",
+		"DB_PASSWORD":    "hunter3",
+	}
+	var buf bytes.Buffer
+
+	require.NoError(t, dumpPlanEnv(&buf, environ, env))
+
+	require.Equal(t, `# Inherited
+GITHUB_TOKEN=<redacted>
+PATH=/usr/bin
+# Added by planEnv, --env and --data-dir
+AWS_PROFILE=prod
+AWS_SECRET_KEY=<redacted>
+DB_PASSWORD=<redacted>
+TF_DATA_DIR=/var/cache/tf
+`, buf.String())
+}
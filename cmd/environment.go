@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// maxEnvironmentLength bounds 'environment' so its label fits GitHub's 50
+// character limit
+const maxEnvironmentLength = 46
+
+// environmentLabelPrefix prefixes the environment in the pull request label
+const environmentLabelPrefix = "env:"
+
+// environmentName matches a simple identifier, e.g. prod or eu-west.staging
+var environmentName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// validateEnvironment checks the 'environment' label, empty when unset.
+func validateEnvironment(environment string) error {
+	if environment == "" {
+		return nil
+	}
+	if !environmentName.MatchString(environment) {
+		return fmt.Errorf(
+			"invalid 'environment' %q: use letters, digits, '-', '_' and '.', starting with a letter or digit",
+			environment,
+		)
+	}
+	if len(environment) > maxEnvironmentLength {
+		return fmt.Errorf("invalid 'environment' %q: longer than %d characters", environment, maxEnvironmentLength)
+	}
+	return nil
+}
+
+// environmentTitle labels title with the environment, e.g.
+// "Terraform plan (prod)", or returns title when environment is empty.
+func environmentTitle(title, environment string) string {
+	if environment == "" {
+		return title
+	}
+	return fmt.Sprintf("%s (%s)", title, environment)
+}
+
+// environmentLabels returns the pull request labels for the environment, e.g.
+// "env:prod", or none when environment is empty.
+func environmentLabels(environment string) []string {
+	if environment == "" {
+		return nil
+	}
+	return []string{environmentLabelPrefix + environment}
+}
@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateEnvironment(t *testing.T) {
+	for _, valid := range []string{"", "prod", "staging", "eu-west.dev_2"} {
+		require.NoError(t, validateEnvironment(valid), valid)
+	}
+	for _, invalid := range []string{"prod env", "-prod", "prod/eu", "prød", "`prod`"} {
+		require.ErrorContains(t, validateEnvironment(invalid), "invalid 'environment'", invalid)
+	}
+	require.ErrorContains(t, validateEnvironment(strings.Repeat("a", 47)), "longer than 46 characters")
+}
+
+func TestEnvironmentLabels(t *testing.T) {
+	require.Equal(t, []string{"env:prod"}, environmentLabels("prod"))
+	require.Empty(t, environmentLabels(""))
+	for _, label := range environmentLabels(strings.Repeat("a", maxEnvironmentLength)) {
+		require.LessOrEqual(t, len(label), 50, "GitHub's label limit")
+	}
+}
+
+func TestCreateMarkdownEnvironment(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	t.Chdir(t.TempDir())
+	planStr := "Plan: 1 to add, 0 to change, 0 to destroy."
+
+	render := func(t *testing.T, opts markdownOptions) string {
+		t.Helper()
+		mdFile, err := createMarkdown("plan.md", planStr, "tofu", opts)
+		require.NoError(t, err)
+		got, err := os.ReadFile(mdFile)
+		require.NoError(t, err)
+		return string(got)
+	}
+
+	t.Run("Environment in the title", func(t *testing.T) {
+		got := render(t, markdownOptions{Environment: "prod"})
+
+		require.Contains(t, got, "<details><summary>OpenTofu plan (prod)</summary>")
+	})
+
+	t.Run("Environment in each section's title", func(t *testing.T) {
+		got := render(t, markdownOptions{
+			Environment: "staging",
+			Sections:    []planSection{{Dir: "infra/net", Text: planStr}, {Dir: "infra/db", Text: planStr}},
+		})
+
+		require.Contains(t, got, "<details><summary>OpenTofu plan (staging): infra/net</summary>")
+		require.Contains(t, got, "<details><summary>OpenTofu plan (staging): infra/db</summary>")
+	})
+
+	t.Run("No environment", func(t *testing.T) {
+		got := render(t, markdownOptions{})
+
+		require.Contains(t, got, "<details><summary>OpenTofu plan</summary>")
+	})
+}
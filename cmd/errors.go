@@ -7,12 +7,43 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/charmbracelet/huh"
 	"github.com/spf13/viper"
 )
 
 // ErrInterrupted indicates that the operation was cancelled by the user (e.g., Ctrl+C).
 var ErrInterrupted = errors.New("operation interrupted by user")
 
+// userAbortMessages are fragments of the errors huh has returned over time when
+// a user leaves a form, used as a fallback for errors not wrapping a sentinel.
+var userAbortMessages = []string{"user aborted", "canceled", "cancelled", "quit"}
+
+// isUserAbort reports whether err indicates the user left a prompt (Ctrl+C/esc)
+// rather than the prompt failing.
+//
+// Parameters:
+//
+//	err - The error returned from running a form.
+//
+// Returns:
+//
+//	bool - true if the user aborted the prompt, false otherwise.
+func isUserAbort(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, huh.ErrUserAborted) || errors.Is(err, ErrInterrupted) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, fragment := range userAbortMessages {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
 // buildNoBinaryFoundError constructs the error message when no binary is found.
 func buildNoBinaryFoundError() error {
 	configPath := viper.ConfigFileUsed()
@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsUserAbort(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil error", err: nil, want: false},
+		{name: "huh user aborted", err: huh.ErrUserAborted, want: true},
+		{
+			name: "wrapped huh user aborted",
+			err:  fmt.Errorf("running form: %w", huh.ErrUserAborted),
+			want: true,
+		},
+		{name: "package interrupted", err: ErrInterrupted, want: true},
+		{name: "legacy canceled message", err: errors.New("program was canceled"), want: true},
+		{name: "legacy quit message", err: errors.New("user quit"), want: true},
+		{name: "huh timeout", err: huh.ErrTimeout, want: false},
+		{name: "unrelated error", err: errors.New("permission denied"), want: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, isUserAbort(tc.err))
+		})
+	}
+}
+
+func TestQueryUserAbort(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	originalFactory := formRunnerFactory
+	defer func() {
+		formRunnerFactory = originalFactory
+	}()
+
+	formRunnerFactory = func(title string, createFile *bool, accessible bool) FormRunner {
+		return &MockFormRunner{createFilePtr: createFile, userSelection: true, err: huh.ErrUserAborted}
+	}
+
+	createFile, err := query(true)
+
+	require.ErrorIs(t, err, ErrInterrupted)
+	require.False(t, createFile)
+}
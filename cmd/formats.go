@@ -0,0 +1,283 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Output formats for --formats
+const (
+	formatGitHub = "github" // GitHub Flavored Markdown with <details> blocks, the mdFile
+	formatPlain  = "plain"  // Plain text for systems that don't render Markdown
+)
+
+// outputFormats are the supported output formats, in the order they're written
+var outputFormats = []string{formatGitHub, formatPlain}
+
+// parseFormats validates the formats passed with --formats.
+//
+// Parameters:
+//
+//	raw - The formats as given, e.g. ["github", "plain"].
+//
+// Returns:
+//
+//	[]string - The formats without duplicates, in outputFormats order.
+//	error - An error naming the first unknown format, or if none is given.
+func parseFormats(raw []string) ([]string, error) {
+	var formats []string
+	for _, f := range raw {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if !slices.Contains(outputFormats, f) {
+			return nil, fmt.Errorf(
+				"invalid 'formats' value %q: must be one of %s",
+				f,
+				strings.Join(outputFormats, ", "),
+			)
+		}
+		if !slices.Contains(formats, f) {
+			formats = append(formats, f)
+		}
+	}
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("invalid 'formats': at least one of %s is required", strings.Join(outputFormats, ", "))
+	}
+	slices.SortFunc(formats, func(a, b string) int {
+		return slices.Index(outputFormats, a) - slices.Index(outputFormats, b)
+	})
+	return formats, nil
+}
+
+// formatPath returns the file a format is written to: the mdFile for github,
+// and the mdFile with a .txt extension for plain, e.g. plan.md and plan.txt.
+func formatPath(mdFile, format string) string {
+	if format == formatPlain {
+		return strings.TrimSuffix(mdFile, filepath.Ext(mdFile)) + ".txt"
+	}
+	return mdFile
+}
+
+// output is a file createOutputs writes, rendered before any is written.
+type output struct {
+	Path    string
+	Purpose string // Reported by existsOrCreated, e.g. "Markdown"
+	Content string
+}
+
+// createOutputs writes the plan in each of the formats, and to opts.Stdout
+// when set. Every output is rendered first, and the files already written are
+// removed if a later one fails, so a run never leaves some formats updated and
+// others missing.
+//
+// Parameters:
+//
+//	formats - The formats from parseFormats.
+//	mdParam - The validated mdFile.
+//	planStr - The human-readable plan output.
+//	binaryName - The binary used, for titles.
+//	opts - The rendering options, shared by all formats.
+//
+// Returns:
+//
+//	string - The Markdown file, or "" if github isn't one of the formats.
+//	[]tpFile - The files written, for existsOrCreated, none if any failed.
+//	error - Any error encountered rendering or writing an output.
+func createOutputs(
+	formats []string,
+	mdParam, planStr, binaryName string,
+	opts markdownOptions,
+) (string, []tpFile, error) {
+	mdFile := ""
+	var markdown string
+	var outputs []output
+	var files []tpFile
+	for _, format := range formats {
+		path := formatPath(mdParam, format)
+		var validated, content, purpose string
+		var err error
+		switch format {
+		case formatGitHub:
+			validated, content, err = renderMarkdown(path, planStr, binaryName, opts)
+			mdFile, markdown, purpose = validated, content, "Markdown"
+		case formatPlain:
+			validated, content, err = renderPlainText(path, planStr, binaryName, opts)
+			purpose = "Plain Text"
+		}
+		if err != nil {
+			return mdFile, nil, err
+		}
+		// An empty plan renders nothing, but existsOrCreated still reports it
+		files = append(files, tpFile{validated, purpose})
+		if content != "" {
+			outputs = append(outputs, output{Path: validated, Purpose: purpose, Content: content})
+		}
+	}
+
+	if err := writeOutputs(outputs, opts.FileMode); err != nil {
+		return mdFile, nil, err
+	}
+	if opts.Stdout != nil && markdown != "" {
+		if _, err := io.WriteString(opts.Stdout, markdown); err != nil {
+			return mdFile, files, fmt.Errorf("failed to write the Markdown to stdout: %w", err)
+		}
+	}
+	return mdFile, files, nil
+}
+
+// writeOutputs writes every output, or none. Each is first written to a
+// temporary file next to it, and the temporary files replace the outputs only
+// once all were written, so a failed write leaves the previous files as they
+// were.
+func writeOutputs(outputs []output, mode os.FileMode) error {
+	temps := make([]string, 0, len(outputs))
+	removeTemps := func() {
+		for _, tmp := range temps {
+			if rmErr := os.Remove(tmp); rmErr != nil && !errors.Is(rmErr, fs.ErrNotExist) {
+				Logger.Warnf("Unable to remove %s after a failed write: %v", tmp, rmErr)
+			}
+		}
+	}
+	for _, out := range outputs {
+		tmp, err := writeOutputTemp(out.Path, out.Content, mode)
+		if err != nil {
+			removeTemps()
+			return fmt.Errorf("failed to write %s file: %w", strings.ToLower(out.Purpose), err)
+		}
+		temps = append(temps, tmp)
+	}
+	// A directory in the way would only fail once some outputs were replaced
+	for _, out := range outputs {
+		if info, err := os.Stat(out.Path); err == nil && info.IsDir() {
+			removeTemps()
+			return fmt.Errorf("failed to write %s file: %s is a directory", strings.ToLower(out.Purpose), out.Path)
+		}
+	}
+	for i, out := range outputs {
+		if err := os.Rename(temps[i], out.Path); err != nil {
+			removeTemps()
+			return fmt.Errorf("failed to write %s file: %w", strings.ToLower(out.Purpose), err)
+		}
+		Logger.Debugf("Successfully wrote %s to %s", out.Purpose, out.Path)
+	}
+	return nil
+}
+
+// writeOutputTemp writes content to a temporary file with mode, defaultFileMode
+// if zero, in the directory of path, for writeOutputs to rename to path.
+func writeOutputTemp(path, content string, mode os.FileMode) (string, error) {
+	if mode == 0 {
+		mode = defaultFileMode
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	tmp := f.Name()
+	_, err = f.WriteString(content)
+	if err == nil {
+		// Unlike WriteFile, the umask doesn't apply to Chmod
+		err = f.Chmod(mode)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	return tmp, nil
+}
+
+// writeOutput writes content to path with mode, defaultFileMode if zero.
+func writeOutput(path, content string, mode os.FileMode) error {
+	if mode == 0 {
+		mode = defaultFileMode
+	}
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		return err
+	}
+	// WriteFile applies the umask and keeps the mode of an existing file
+	if err := os.Chmod(path, mode); err != nil {
+		return fmt.Errorf("failed to set permissions on %s: %w", path, err)
+	}
+	return nil
+}
+
+// createPlainText writes the plan as plain text: the notes and command, then
+// each plan under an underlined title. Redaction applies as in the Markdown.
+//
+// Parameters:
+//
+//	path - The file to write.
+//	planStr - The human-readable plan output.
+//	binaryName - The binary used, for the title.
+//	opts - The rendering options.
+//
+// Returns:
+//
+//	string - The validated path written.
+//	error - Any error encountered validating or writing the file.
+func createPlainText(path, planStr, binaryName string, opts markdownOptions) (string, error) {
+	validated, text, err := renderPlainText(path, planStr, binaryName, opts)
+	if err != nil || text == "" {
+		return validated, err
+	}
+	if err = writeOutput(validated, text, opts.FileMode); err != nil {
+		return validated, fmt.Errorf("failed to write plain text file: %w", err)
+	}
+	Logger.Debugf("Successfully wrote plain text to %s", validated)
+	return validated, nil
+}
+
+// renderPlainText renders the plain text createPlainText writes, without
+// writing it.
+//
+// Returns:
+//
+//	string - The validated path.
+//	string - The plain text, empty when the plan output is empty and nothing is to be written.
+//	error - Any error encountered validating the path.
+func renderPlainText(path, planStr, binaryName string, opts markdownOptions) (string, string, error) {
+	validated, err := validateOutputName(path)
+	if err != nil {
+		return path, "", err
+	}
+	if len(planStr) == 0 && len(opts.Sections) == 0 {
+		Logger.Debugf("Plan output is empty. Skipping plain text file creation for %q.", validated)
+		return validated, "", nil
+	}
+
+	var sb strings.Builder
+	for _, note := range opts.Notes {
+		sb.WriteString("Note: " + note + "\n")
+	}
+	if opts.Command != "" {
+		sb.WriteString("Plan command: " + opts.Command + "\n")
+	}
+	if sb.Len() > 0 {
+		sb.WriteString("\n")
+	}
+
+	title := environmentTitle(planTitle(binaryName), opts.Environment)
+	for i, section := range planSections(planStr, opts) {
+		text := sectionText(section, opts)
+		heading := title
+		if section.Dir != "" {
+			heading = fmt.Sprintf("%s: %s", title, section.Dir)
+		}
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(heading + "\n" + strings.Repeat("=", len(heading)) + "\n\n")
+		sb.WriteString(strings.TrimRight(text, "\n") + "\n")
+	}
+	return validated, sb.String(), nil
+}
@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     []string
+		want    []string
+		wantErr string
+	}{
+		{name: "Default", raw: []string{"github"}, want: []string{"github"}},
+		{name: "Both in order", raw: []string{"plain", " GitHub "}, want: []string{"github", "plain"}},
+		{name: "Duplicates", raw: []string{"plain", "plain"}, want: []string{"plain"}},
+		{name: "Unknown", raw: []string{"github", "html"}, wantErr: `invalid 'formats' value "html": must be one of github, plain`},
+		{name: "Empty", raw: nil, wantErr: "invalid 'formats': at least one of github, plain is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFormats(tt.raw)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFormatPath(t *testing.T) {
+	require.Equal(t, "plan.md", formatPath("plan.md", formatGitHub))
+	require.Equal(t, "plan.txt", formatPath("plan.md", formatPlain))
+	require.Equal(t, "plan.txt", formatPath("plan", formatPlain))
+}
+
+func TestCreateOutputs(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	t.Chdir(t.TempDir())
+	planText := "Terraform will perform the following actions:\n\nPlan: 1 to add, 0 to change, 0 to destroy.\n"
+
+	mdFile, files, err := createOutputs(
+		[]string{formatGitHub, formatPlain},
+		"plan.md",
+		planText,
+		"terraform",
+		markdownOptions{Command: "terraform plan -out=plan.out"},
+	)
+	require.NoError(t, err)
+	require.Equal(t, "plan.md", mdFile)
+	require.Equal(t, []tpFile{{"plan.md", "Markdown"}, {"plan.txt", "Plain Text"}}, files)
+
+	md, err := os.ReadFile("plan.md")
+	require.NoError(t, err)
+	require.Contains(t, string(md), "<details><summary>Terraform plan</summary>")
+
+	plain, err := os.ReadFile("plan.txt")
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		"Plan command: terraform plan -out=plan.out\n\nTerraform plan\n==============\n\n"+planText,
+		string(plain),
+	)
+	require.NotContains(t, string(plain), "<details>")
+
+	t.Run("Plain only", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		mdFile, files, err := createOutputs([]string{formatPlain}, "plan.md", planText, "tofu", markdownOptions{
+			Sections: []planSection{{Dir: "network", Text: "No changes."}, {Dir: "db", Text: planText}},
+		})
+		require.NoError(t, err)
+		require.Empty(t, mdFile)
+		require.Equal(t, []tpFile{{"plan.txt", "Plain Text"}}, files)
+		require.NoFileExists(t, "plan.md")
+
+		plain, err := os.ReadFile("plan.txt")
+		require.NoError(t, err)
+		require.Equal(
+			t,
+			"OpenTofu plan: network\n======================\n\nNo changes.\n\nOpenTofu plan: db\n=================\n\n"+planText,
+			string(plain),
+		)
+	})
+	t.Run("Also printed to stdout", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		var stdout bytes.Buffer
+
+		_, _, err := createOutputs([]string{formatGitHub}, "plan.md", planText, "terraform", markdownOptions{
+			Stdout: &stdout,
+		})
+
+		require.NoError(t, err)
+		md, err := os.ReadFile("plan.md")
+		require.NoError(t, err)
+		require.Equal(t, string(md), stdout.String())
+	})
+
+	t.Run("A failed output leaves the previous files", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		require.NoError(t, os.WriteFile("plan.md", []byte("previous plan"), 0o600))
+		// plan.txt can't be written over a directory
+		require.NoError(t, os.Mkdir("plan.txt", 0o750))
+		var stdout bytes.Buffer
+
+		_, files, err := createOutputs(
+			[]string{formatGitHub, formatPlain},
+			"plan.md",
+			planText,
+			"terraform",
+			markdownOptions{Stdout: &stdout},
+		)
+
+		require.ErrorContains(t, err, "failed to write plain text file")
+		require.Empty(t, files)
+		md, err := os.ReadFile("plan.md")
+		require.NoError(t, err)
+		require.Equal(t, "previous plan", string(md))
+		require.Empty(t, stdout.String())
+		entries, err := os.ReadDir(".")
+		require.NoError(t, err)
+		require.Len(t, entries, 2, "no temporary file is left behind")
+	})
+
+	t.Run("Outputs replace previous files with the mode", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		require.NoError(t, os.WriteFile("plan.md", []byte("previous plan"), 0o600))
+
+		_, _, err := createOutputs([]string{formatGitHub}, "plan.md", planText, "terraform", markdownOptions{
+			FileMode: 0o644,
+		})
+
+		require.NoError(t, err)
+		info, err := os.Stat("plan.md")
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+		md, err := os.ReadFile("plan.md")
+		require.NoError(t, err)
+		require.NotEqual(t, "previous plan", string(md))
+	})
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/cli/safeexec"
+)
+
+// realGhRunner runs gh for every client, so --gh-config-dir applies to all of them
+var realGhRunner = &RealGhRunner{}
+
+// defaultGhRunner is the GhRunner used outside of tests
+var defaultGhRunner GhRunner = realGhRunner
+
+// GhRunner is an interface for running GitHub CLI commands
+// This allows for dependency injection and easier testing
+type GhRunner interface {
+	Run(ctx context.Context, args ...string) ([]byte, error)
+}
+
+// RealGhRunner implements the GhRunner interface by running the 'gh' binary
+type RealGhRunner struct {
+	// ConfigDir is passed to gh as GH_CONFIG_DIR when set, selecting the
+	// account gh authenticates as. Otherwise gh inherits our environment.
+	ConfigDir string
+}
+
+// Run executes 'gh' with the given arguments
+//
+// Parameters:
+//
+//	ctx - The context controlling the command's lifetime
+//	args - The arguments passed to 'gh'
+//
+// Returns:
+//
+//	[]byte - The command's standard output
+//	error - An error including the command's standard error if it failed
+func (r *RealGhRunner) Run(ctx context.Context, args ...string) ([]byte, error) {
+	ghPath, err := safeexec.LookPath("gh")
+	if err != nil {
+		return nil, fmt.Errorf("'gh' not found in PATH: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := r.command(ctx, ghPath, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	Logger.Debugf("Running gh %s", strings.Join(args, " "))
+	if err = cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return stdout.Bytes(), fmt.Errorf("gh %s: %w: %s", args[0], err, msg)
+		}
+		return stdout.Bytes(), fmt.Errorf("gh %s: %w", args[0], err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// command returns the gh command to run, with ConfigDir in its environment.
+func (r *RealGhRunner) command(ctx context.Context, ghPath string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, ghPath, args...)
+	if r.ConfigDir != "" {
+		cmd.Env = append(os.Environ(), "GH_CONFIG_DIR="+r.ConfigDir)
+	}
+	return cmd
+}
+
+// useGhConfigDir makes every gh command use dir as its config directory, for
+// --gh-config-dir.
+//
+// Parameters:
+//
+//	runner - The runner to configure, realGhRunner outside of tests.
+//	dir - The gh config directory, e.g. ~/.config/gh-work.
+//
+// Returns:
+//
+//	error - An error if dir isn't a directory.
+func useGhConfigDir(runner *RealGhRunner, dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("invalid 'gh-config-dir' (%q): %w", dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("invalid 'gh-config-dir' (%q): not a directory", dir)
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("invalid 'gh-config-dir' (%q): %w", dir, err)
+	}
+	for _, name := range []string{"GH_TOKEN", "GITHUB_TOKEN"} {
+		if os.Getenv(name) != "" {
+			Logger.Warnf("%s is set and takes precedence over the account in 'gh-config-dir' %s", name, abs)
+			break
+		}
+	}
+	Logger.Debugf("Using gh config directory: %s", abs)
+	runner.ConfigDir = abs
+	return nil
+}
@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUseGhConfigDir(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	t.Run("Commands get the config dir", func(t *testing.T) {
+		dir := t.TempDir()
+		runner := &RealGhRunner{}
+
+		require.NoError(t, useGhConfigDir(runner, dir))
+
+		cmd := runner.command(context.Background(), "gh", "pr", "create")
+		require.Equal(t, dir, runner.ConfigDir)
+		require.Contains(t, cmd.Env, "GH_CONFIG_DIR="+dir)
+		require.Equal(t, "GH_CONFIG_DIR="+dir, cmd.Env[len(cmd.Env)-1], "overrides an inherited GH_CONFIG_DIR")
+	})
+
+	t.Run("Relative dirs are made absolute", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		require.NoError(t, os.Mkdir("gh-work", 0o700))
+		runner := &RealGhRunner{}
+
+		require.NoError(t, useGhConfigDir(runner, "gh-work"))
+
+		require.True(t, filepath.IsAbs(runner.ConfigDir))
+	})
+
+	t.Run("Without a config dir gh inherits the environment", func(t *testing.T) {
+		cmd := (&RealGhRunner{}).command(context.Background(), "gh", "pr", "create")
+
+		require.Nil(t, cmd.Env)
+	})
+
+	t.Run("Missing dir", func(t *testing.T) {
+		runner := &RealGhRunner{}
+
+		err := useGhConfigDir(runner, filepath.Join(t.TempDir(), "missing"))
+
+		require.ErrorContains(t, err, "invalid 'gh-config-dir'")
+		require.Empty(t, runner.ConfigDir)
+	})
+
+	t.Run("Not a directory", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "hosts.yml")
+		require.NoError(t, os.WriteFile(file, nil, 0o600))
+
+		err := useGhConfigDir(&RealGhRunner{}, file)
+
+		require.ErrorContains(t, err, "not a directory")
+	})
+
+	t.Run("Default clients share the runner", func(t *testing.T) {
+		gist, ok := defaultGistClient.(*RealGistClient)
+		require.True(t, ok)
+		require.Same(t, realGhRunner, gist.runner)
+		require.Same(t, realGhRunner, defaultGhRunner)
+	})
+}
@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultGistClient is the GistClient used outside of tests
+var defaultGistClient GistClient = &RealGistClient{runner: defaultGhRunner}
+
+// GistClient is an interface for uploading files as gists
+// This allows for dependency injection and easier testing
+type GistClient interface {
+	CreateGist(ctx context.Context, path, description string) (url string, err error)
+}
+
+// RealGistClient implements the GistClient interface with 'gh gist create'
+type RealGistClient struct {
+	runner GhRunner
+}
+
+// CreateGist uploads a file as a secret gist
+//
+// Parameters:
+//
+//	ctx - The context controlling the upload
+//	path - The file to upload
+//	description - The gist's description
+//
+// Returns:
+//
+//	string - The URL of the created gist
+//	error - Any error encountered creating the gist
+func (c *RealGistClient) CreateGist(ctx context.Context, path, description string) (string, error) {
+	out, err := c.runner.Run(ctx, "gist", "create", "--desc", description, path)
+	if err != nil {
+		return "", err
+	}
+	// gh prints the URL of the new gist as the last line of its output
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return "", errors.New("gh gist create did not return a URL")
+	}
+	return fields[len(fields)-1], nil
+}
+
+// attachPlan uploads the binary plan file as a gist so reviewers can download
+// the exact plan. Gists only hold text, so the plan is base64 encoded first.
+//
+// Parameters:
+//
+//	ctx - The context controlling the upload
+//	client - The GistClient used to upload the plan
+//	planPath - The path of the binary plan file
+//
+// Returns:
+//
+//	string - A note referencing the gist, for the Markdown body
+//	string - The URL of the gist, for the truncation notice
+//	error - Any error encountered reading, encoding or uploading the plan
+func attachPlan(ctx context.Context, client GistClient, planPath string) (string, string, error) {
+	data, err := os.ReadFile(planPath) //nolint:gosec // planPath is validated by the caller
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read plan file %s: %w", planPath, err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "gh-tp-gist-")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer func() {
+		if rmErr := os.RemoveAll(tmpDir); rmErr != nil {
+			Logger.Debugf("Failed to remove %s: %v", tmpDir, rmErr)
+		}
+	}()
+
+	encodedName := filepath.Base(planPath) + ".b64"
+	encodedPath := filepath.Join(tmpDir, encodedName)
+	encoded := base64.StdEncoding.EncodeToString(data)
+	if err = os.WriteFile(encodedPath, []byte(encoded+"\n"), 0o600); err != nil {
+		return "", "", fmt.Errorf("failed to write encoded plan: %w", err)
+	}
+
+	url, err := client.CreateGist(
+		ctx,
+		encodedPath,
+		fmt.Sprintf("Plan file %s created by gh-tp", filepath.Base(planPath)),
+	)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to upload plan file as a gist: %w", err)
+	}
+	Logger.Debugf("Uploaded plan file %s to %s", planPath, url)
+
+	return fmt.Sprintf(
+		"The plan file is attached as a [gist](%s). Download `%s` and run `base64 -d %s > %s` to inspect it.",
+		url,
+		encodedName,
+		encodedName,
+		filepath.Base(planPath),
+	), url, nil
+}
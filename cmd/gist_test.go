@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockGistClient struct {
+	mock.Mock
+}
+
+func (m *MockGistClient) CreateGist(ctx context.Context, path, description string) (string, error) {
+	args := m.Called(ctx, path, description)
+	return args.String(0), args.Error(1)
+}
+
+type MockGhRunner struct {
+	mock.Mock
+}
+
+func (m *MockGhRunner) Run(ctx context.Context, args ...string) ([]byte, error) {
+	called := m.Called(ctx, args)
+	out, _ := called.Get(0).([]byte)
+	return out, called.Error(1)
+}
+
+func TestAttachPlan(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	dir := t.TempDir()
+	planPath := filepath.Join(dir, "plan.out")
+	planBytes := []byte{0x50, 0x4b, 0x03, 0x04, 0x00, 0xff}
+	require.NoError(t, os.WriteFile(planPath, planBytes, 0o600))
+	gistURL := "https://gist.github.com/octocat/0123456789abcdef"
+
+	t.Run("Gist URL is inserted into the Markdown", func(t *testing.T) {
+		client := new(MockGistClient)
+		client.On("CreateGist", mock.Anything, mock.Anything, "Plan file plan.out created by gh-tp").
+			Run(func(args mock.Arguments) {
+				// The uploaded file is the base64 encoded plan
+				uploaded, err := os.ReadFile(args.String(1))
+				require.NoError(t, err)
+				require.Equal(t, "plan.out.b64", filepath.Base(args.String(1)))
+				require.Equal(t, base64.StdEncoding.EncodeToString(planBytes)+"\n", string(uploaded))
+			}).
+			Return(gistURL, nil)
+
+		note, url, err := attachPlan(context.Background(), client, planPath)
+		require.NoError(t, err)
+		require.Equal(t, gistURL, url)
+		client.AssertExpectations(t)
+
+		t.Chdir(t.TempDir())
+		mdFile, err := createMarkdown("plan.md", "No changes.", "terraform", markdownOptions{
+			Notes: []string{note},
+		})
+		require.NoError(t, err)
+		got, err := os.ReadFile(mdFile)
+		require.NoError(t, err)
+		require.Contains(t, string(got), "[gist]("+gistURL+")")
+		require.Contains(t, string(got), "base64 -d plan.out.b64 > plan.out")
+	})
+
+	t.Run("Upload failures are returned", func(t *testing.T) {
+		client := new(MockGistClient)
+		client.On("CreateGist", mock.Anything, mock.Anything, mock.Anything).
+			Return("", errors.New("HTTP 401"))
+
+		_, _, err := attachPlan(context.Background(), client, planPath)
+
+		require.ErrorContains(t, err, "HTTP 401")
+	})
+
+	t.Run("Missing plan file", func(t *testing.T) {
+		_, _, err := attachPlan(context.Background(), new(MockGistClient), filepath.Join(dir, "nope"))
+
+		require.ErrorContains(t, err, "failed to read plan file")
+	})
+}
+
+func TestRealGistClientCreateGist(t *testing.T) {
+	runner := new(MockGhRunner)
+	runner.On("Run", mock.Anything, []string{"gist", "create", "--desc", "desc", "plan.out.b64"}).
+		Return([]byte("- Creating gist plan.out.b64\n✓ Created secret gist plan.out.b64\nhttps://gist.github.com/abc\n"), nil)
+
+	url, err := (&RealGistClient{runner: runner}).CreateGist(context.Background(), "plan.out.b64", "desc")
+
+	require.NoError(t, err)
+	require.Equal(t, "https://gist.github.com/abc", url)
+	runner.AssertExpectations(t)
+}
@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/cli/safeexec"
+)
+
+// defaultGitRunner is the GitRunner used outside of tests
+var defaultGitRunner GitRunner = &RealGitRunner{}
+
+// GitRunner is an interface for running git commands
+// This allows for dependency injection and easier testing
+type GitRunner interface {
+	Run(ctx context.Context, args ...string) ([]byte, error)
+}
+
+// RealGitRunner implements the GitRunner interface by running the 'git' binary
+type RealGitRunner struct{}
+
+// Run executes 'git' with the given arguments in the current directory
+//
+// Parameters:
+//
+//	ctx - The context controlling the command's lifetime
+//	args - The arguments passed to 'git'
+//
+// Returns:
+//
+//	[]byte - The command's standard output
+//	error - An error including the command's standard error if it failed
+func (r *RealGitRunner) Run(ctx context.Context, args ...string) ([]byte, error) {
+	gitPath, err := safeexec.LookPath("git")
+	if err != nil {
+		return nil, fmt.Errorf("'git' not found in PATH: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, gitPath, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	Logger.Debugf("Running git %s", strings.Join(args, " "))
+	if err = cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return stdout.Bytes(), fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+		}
+		return stdout.Bytes(), fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// currentBranch returns the branch being planned: GITHUB_HEAD_REF in a
+// pull_request workflow, whose checkout is detached, otherwise the checked
+// out branch, or "" when it can't be told. It is normalized like the other
+// branch names, so it compares with baseRules prefixes as written.
+func currentBranch(ctx context.Context, git GitRunner) string {
+	branch := os.Getenv("GITHUB_HEAD_REF")
+	if branch == "" {
+		out, err := git.Run(ctx, "rev-parse", "--abbrev-ref", "HEAD")
+		if err != nil {
+			return ""
+		}
+		branch = string(out)
+	}
+	normalized, err := normalizeBranchName(branch)
+	if err != nil {
+		Logger.Debugf("Unable to tell the current branch: %v", err)
+		return ""
+	}
+	if normalized == "HEAD" {
+		return ""
+	}
+	return normalized
+}
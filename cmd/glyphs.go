@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// glyphs mark whether a file was created in existsOrCreated's report.
+type glyphs struct {
+	Success string
+	Failure string
+}
+
+var (
+	// unicodeGlyphs are the default glyphs
+	unicodeGlyphs = glyphs{Success: "✔", Failure: "✕"}
+	// asciiGlyphs render in any terminal and font, used for --ascii
+	asciiGlyphs = glyphs{Success: "[OK]", Failure: "[FAIL]"}
+)
+
+// statusGlyphs returns the glyphs for the report: the ASCII ones with
+// --ascii, 'successGlyph' and 'failureGlyph' when configured, and otherwise
+// the Unicode ones if the terminal supports them.
+//
+// Parameters:
+//
+//	getenv - Reads the environment, os.Getenv outside of tests.
+//	goos - The operating system, runtime.GOOS outside of tests.
+//
+// Returns:
+//
+//	glyphs - The glyphs to use.
+func statusGlyphs(getenv func(string) string, goos string) glyphs {
+	if viper.GetBool("ascii") {
+		return asciiGlyphs
+	}
+	success, failure := viper.GetString("successGlyph"), viper.GetString("failureGlyph")
+	if success != "" || failure != "" {
+		g := unicodeGlyphs
+		if success != "" {
+			g.Success = success
+		}
+		if failure != "" {
+			g.Failure = failure
+		}
+		return g
+	}
+	if !supportsUnicode(getenv, goos) {
+		Logger.Debug("The terminal may not render Unicode, using ASCII glyphs")
+		return asciiGlyphs
+	}
+	return unicodeGlyphs
+}
+
+// supportsUnicode guesses whether the terminal renders Unicode. The Linux
+// virtual console and a non UTF-8 locale don't; neither does the legacy
+// Windows console, unlike Windows Terminal and VS Code's. An unset locale, as
+// in most CI runners, is assumed to render it.
+func supportsUnicode(getenv func(string) string, goos string) bool {
+	if getenv("TERM") == "linux" {
+		return false
+	}
+	if goos == "windows" {
+		return getenv("WT_SESSION") != "" || getenv("TERM_PROGRAM") == "vscode"
+	}
+	// The first variable set decides, as for setlocale
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if locale := strings.ToLower(getenv(name)); locale != "" {
+			return strings.Contains(locale, "utf-8") || strings.Contains(locale, "utf8")
+		}
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/fatih/color"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+// envOf returns a getenv reading from env.
+func envOf(env map[string]string) func(string) string {
+	return func(name string) string { return env[name] }
+}
+
+func TestSupportsUnicode(t *testing.T) {
+	testCases := []struct {
+		name string
+		env  map[string]string
+		goos string
+		want bool
+	}{
+		{name: "UTF-8 locale", env: map[string]string{"LANG": "en_US.UTF-8"}, goos: "linux", want: true},
+		{name: "utf8 spelling", env: map[string]string{"LC_CTYPE": "C.utf8"}, goos: "darwin", want: true},
+		{name: "No locale", env: map[string]string{}, goos: "linux", want: true},
+		{name: "C locale", env: map[string]string{"LANG": "C"}, goos: "linux", want: false},
+		{
+			name: "LC_ALL takes precedence",
+			env:  map[string]string{"LC_ALL": "POSIX", "LANG": "en_US.UTF-8"},
+			goos: "linux",
+			want: false,
+		},
+		{name: "Linux console", env: map[string]string{"TERM": "linux", "LANG": "en_US.UTF-8"}, goos: "linux", want: false},
+		{name: "Legacy Windows console", env: map[string]string{}, goos: "windows", want: false},
+		{name: "Windows Terminal", env: map[string]string{"WT_SESSION": "1"}, goos: "windows", want: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, supportsUnicode(envOf(tc.env), tc.goos))
+		})
+	}
+}
+
+func TestStatusGlyphs(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	utf8 := envOf(map[string]string{"LANG": "en_US.UTF-8"})
+
+	t.Run("Unicode by default", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+
+		require.Equal(t, unicodeGlyphs, statusGlyphs(utf8, "linux"))
+	})
+
+	t.Run("ASCII without Unicode support", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+
+		require.Equal(t, asciiGlyphs, statusGlyphs(envOf(map[string]string{"LANG": "C"}), "linux"))
+	})
+
+	t.Run("Configured glyphs", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		viper.Set("successGlyph", "OK")
+
+		require.Equal(t, glyphs{Success: "OK", Failure: "✕"}, statusGlyphs(utf8, "linux"))
+	})
+
+	t.Run("--ascii overrides configured glyphs", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		viper.Set("successGlyph", "🎉")
+		viper.Set("ascii", true)
+
+		require.Equal(t, asciiGlyphs, statusGlyphs(utf8, "linux"))
+	})
+}
+
+func TestExistsOrCreatedASCII(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	t.Cleanup(viper.Reset)
+	viper.Set("ascii", true)
+	originalOutput := color.Output
+	defer func() {
+		color.Output = originalOutput
+	}()
+	var buf bytes.Buffer
+	color.Output = &buf
+	created, err := os.CreateTemp(t.TempDir(), "plan.md")
+	require.NoError(t, err)
+	require.NoError(t, created.Close())
+
+	err = existsOrCreated([]tpFile{
+		{Name: created.Name(), Purpose: "Markdown"},
+		{Name: "missing.out", Purpose: "Plan"},
+	})
+
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), "[OK]  Markdown Created...\n[FAIL]  Plan Failed to Create\n")
+}
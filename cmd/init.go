@@ -4,9 +4,11 @@ package cmd
 
 import (
 	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"strconv"
-	"strings"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/charmbracelet/bubbles/key"
@@ -52,6 +54,8 @@ var initCmd = &cobra.Command{
 			Logger.Debugf("Invalid ACCESSIBLE value, defaulting to false: %v", err)
 		}
 		configFile := ConfigFile{}
+		customizeMarkdown := false
+		mdChoices := defaultMarkdownChoices()
 
 		form := huh.NewForm(
 			huh.NewGroup(
@@ -70,7 +74,19 @@ var initCmd = &cobra.Command{
 							"Home Directory: "+homeDir+"/"+ConfigName,
 							homeDir+"/"+ConfigName,
 						),
-					).Value(&configFile.Path),
+					).Value(&configFile.Path).
+					Validate(
+						func(path string) error {
+							if err := checkWritable(filepath.Dir(path)); err != nil {
+								//lint:ignore ST1005 User-facing error message. I want pretty.
+								return fmt.Errorf( //nolint:staticcheck
+									"Cannot save your config here, choose another location: %w",
+									err,
+								)
+							}
+							return nil
+						},
+					),
 
 				// It could make sense some day to do a `gh tp init --binary`
 				huh.NewSelect[string]().
@@ -131,7 +147,43 @@ var initCmd = &cobra.Command{
 							return nil
 						},
 					),
+
+				huh.NewConfirm().
+					Title("Do you want to choose how the Markdown is rendered?").
+					Description("The defaults can be changed later in the [markdown] table.").
+					Value(&customizeMarkdown),
 			),
+
+			huh.NewGroup(
+				huh.NewSelect[string]().
+					Title("Which language should the plan code blocks be highlighted as?").
+					Options(
+						huh.NewOption("Terraform", string(SyntaxHighlightTerraform)).Selected(true),
+						huh.NewOption("HCL", string(SyntaxHighlightHCL)),
+						huh.NewOption("Diff (colors added and removed lines)", string(SyntaxHighlightDiff)),
+						huh.NewOption("Plain text", string(SyntaxHighlightText)),
+					).Value(&mdChoices.Syntax),
+
+				huh.NewConfirm().
+					Title("Render one collapsible block per top-level module?").
+					Value(&mdChoices.GroupByModule),
+
+				huh.NewConfirm().
+					Title("List resources changed outside of Terraform/OpenTofu in their own section?").
+					Value(&mdChoices.ShowDrift),
+
+				huh.NewConfirm().
+					Title("List the outputs the plan changes in their own section?").
+					Value(&mdChoices.ShowOutputs),
+
+				huh.NewConfirm().
+					Title("Include the JSON plan in a collapsible block?").
+					Value(&mdChoices.IncludeJSON),
+
+				huh.NewConfirm().
+					Title("Include the plan command line so reviewers can reproduce the plan?").
+					Value(&mdChoices.IncludeCommand),
+			).WithHideFunc(func() bool { return !customizeMarkdown }),
 		).WithTheme(huh.ThemeBase16()).
 			// Just in case https://raw.githubusercontent.com/charmbracelet/huh/refs/tags/v0.6.0/keymap.go
 			// https://github.com/charmbracelet/huh/issues/73
@@ -254,9 +306,8 @@ var initCmd = &cobra.Command{
 
 		err = form.Run()
 		if err != nil {
-			// Check for user cancellation (check actual error strings used by huh)
-			if strings.Contains(err.Error(), "canceled") || strings.Contains(err.Error(), "quit") {
-				Logger.Error("Configuration cancelled by user.")
+			if isUserAbort(err) {
+				Logger.Info("Configuration cancelled by user.")
 				return // Exit without error code
 			}
 
@@ -270,13 +321,128 @@ var initCmd = &cobra.Command{
 			configFile.Path,
 			configFile.Params.MdFile,
 			configFile.Params.PlanFile,
+			mdChoices.params(),
 		)
+		if isUserAbort(err) {
+			Logger.Info("Configuration cancelled by user.")
+			return
+		}
 		if err != nil {
 			Logger.Fatal(err)
 		}
+
+		if example, _ := cmd.Flags().GetBool("example"); example {
+			created, err := writeExampleTF(cwd)
+			if err != nil {
+				Logger.Fatal(err)
+			}
+			if created {
+				Logger.Infof(
+					"Example %s created, run '%s init' then 'gh tp' to try a plan",
+					exampleTFName,
+					configFile.Params.Binary,
+				)
+			}
+		}
 	},
 }
 
+// exampleTFName is the file 'gh tp init --example' writes
+const exampleTFName = "main.tf"
+
+// exampleTF is a minimal configuration to try a plan with. terraform_data is
+// built in, so it needs no provider or credentials.
+var exampleTF = heredoc.Doc(`
+	# Created by 'gh tp init --example' to try tp. Replace it with your own configuration.
+	resource "terraform_data" "example" {
+	  input = "Hello from gh tp"
+	}
+
+	output "example" {
+	  value = terraform_data.example.output
+	}
+`)
+
+// writeExampleTF writes exampleTF to dir so newcomers can try a plan. It's
+// only written to a directory without .tf or .tofu files, so it never
+// overwrites a file or adds a resource to an existing configuration.
+//
+// Parameters:
+//
+//	dir - The directory to write main.tf to.
+//
+// Returns:
+//
+//	bool - Whether the example was written.
+//	error - Any error encountered writing it.
+func writeExampleTF(dir string) (bool, error) {
+	if checkFilesByExtension(dir, []string{".tf", ".tofu"}) {
+		Logger.Infof("%s already has .tf or .tofu files, the example wasn't created", dir)
+		return false, nil
+	}
+	path := filepath.Join(dir, exampleTFName)
+	// O_EXCL guards against a main.tf directory or a file created meanwhile
+	//nolint:gosec // a source file, readable like the rest of the project
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		Logger.Infof("%s already exists, the example wasn't created", path)
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to create %s: %w", path, err)
+	}
+	if _, err = f.WriteString(exampleTF); err != nil {
+		_ = f.Close()
+		return false, fmt.Errorf("unable to write %s: %w", path, err)
+	}
+	if err = f.Close(); err != nil {
+		return false, fmt.Errorf("unable to write %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// markdownChoices are the Markdown rendering options asked by 'gh tp init'.
+type markdownChoices struct {
+	Syntax         string
+	GroupByModule  bool
+	ShowDrift      bool
+	ShowOutputs    bool
+	IncludeJSON    bool
+	IncludeCommand bool
+}
+
+// defaultMarkdownChoices returns the choices matching the flags' defaults.
+func defaultMarkdownChoices() markdownChoices {
+	return markdownChoices{Syntax: string(SyntaxHighlightTerraform), ShowDrift: true}
+}
+
+// params returns the [markdown] table holding the choices that differ from
+// the defaults, or nil when none do so the config file stays minimal.
+func (c markdownChoices) params() *MarkdownParams {
+	defaults := defaultMarkdownChoices()
+	if c == defaults {
+		return nil
+	}
+	params := &MarkdownParams{}
+	if c.Syntax != defaults.Syntax {
+		params.Syntax = c.Syntax
+	}
+	choice := func(value, defaultValue bool) *bool {
+		if value == defaultValue {
+			return nil
+		}
+		return &value
+	}
+	params.GroupByModule = choice(c.GroupByModule, defaults.GroupByModule)
+	params.ShowDrift = choice(c.ShowDrift, defaults.ShowDrift)
+	params.ShowOutputs = choice(c.ShowOutputs, defaults.ShowOutputs)
+	params.IncludeJSON = choice(c.IncludeJSON, defaults.IncludeJSON)
+	params.IncludeCommand = choice(c.IncludeCommand, defaults.IncludeCommand)
+	return params
+}
+
 func init() {
+	initCmd.Flags().
+		Bool("example", false, "also create an example main.tf to try a plan with, in a directory without .tf or .tofu files.")
 	rootCmd.AddCommand(initCmd)
 }
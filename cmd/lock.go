@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// stateLockCreatedLayout is how Terraform and OpenTofu print when a lock was taken
+const stateLockCreatedLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+var (
+	// stateLockHeader starts the error reported when the state is locked
+	stateLockHeader = regexp.MustCompile(`Error acquiring the state lock`)
+	// stateLockField matches a line of the "Lock Info:" block, e.g. "  Who: alice@host"
+	stateLockField = regexp.MustCompile(`(?m)^\s*(ID|Path|Operation|Who|Created):[ \t]*(.*?)\s*$`)
+)
+
+// stateLock is who holds the state lock a plan failed to acquire, from the
+// "Lock Info:" block of the error.
+type stateLock struct {
+	ID        string // Lock ID, what 'force-unlock' takes
+	Path      string // The locked state
+	Operation string // The operation holding the lock, e.g. OperationTypeApply
+	Who       string // The user and host holding the lock, e.g. alice@build-7
+	Created   string // When the lock was taken
+}
+
+// StateLockError is returned when a plan fails because another operation
+// holds the state lock.
+type StateLockError struct {
+	Lock stateLock
+	Err  error // The plan's error
+}
+
+func (e *StateLockError) Error() string {
+	l := e.Lock
+	msg := "state locked"
+	if l.Who != "" {
+		msg += " by " + l.Who
+	}
+	if l.Created != "" {
+		msg += " since " + l.Created
+	}
+	var details []string
+	if l.Operation != "" {
+		details = append(details, "operation "+strings.TrimPrefix(l.Operation, "OperationType"))
+	}
+	if l.ID != "" {
+		details = append(details, "lock ID "+l.ID)
+	}
+	if len(details) > 0 {
+		msg += "; " + strings.Join(details, ", ")
+	}
+	msg += ". Re-run the plan once it's released"
+	if l.ID != "" {
+		msg += fmt.Sprintf(", or run 'force-unlock %s' if the lock is stale", l.ID)
+	}
+	return msg
+}
+
+func (e *StateLockError) Unwrap() error {
+	return e.Err
+}
+
+// parseStateLock reads the lock info from the error of a plan that failed to
+// acquire the state lock.
+//
+// Parameters:
+//
+//	msg - The plan's error, including the binary's stderr.
+//
+// Returns:
+//
+//	stateLock - Who holds the lock. Created is formatted to the minute in UTC when it parses.
+//	bool - Whether msg is a state lock error.
+func parseStateLock(msg string) (stateLock, bool) {
+	var lock stateLock
+	loc := stateLockHeader.FindStringIndex(msg)
+	if loc == nil {
+		return lock, false
+	}
+	info := msg[loc[1]:]
+	if i := strings.Index(info, "Lock Info:"); i >= 0 {
+		info = info[i:]
+	} else {
+		// Nothing past the header says who holds the lock
+		return lock, true
+	}
+	for _, m := range stateLockField.FindAllStringSubmatch(info, -1) {
+		switch m[1] {
+		case "ID":
+			lock.ID = m[2]
+		case "Path":
+			lock.Path = m[2]
+		case "Operation":
+			lock.Operation = m[2]
+		case "Who":
+			lock.Who = m[2]
+		case "Created":
+			lock.Created = m[2]
+			if created, err := time.Parse(stateLockCreatedLayout, m[2]); err == nil {
+				lock.Created = created.UTC().Format("2006-01-02 15:04 MST")
+			}
+		}
+	}
+	return lock, true
+}
+
+// explainLockError returns a StateLockError when err is a plan failing to
+// acquire the state lock, and err otherwise.
+func explainLockError(err error) error {
+	if err == nil {
+		return nil
+	}
+	lock, ok := parseStateLock(err.Error())
+	if !ok {
+		return err
+	}
+	Logger.Debugf("State lock held: %+v", lock)
+	return &StateLockError{Lock: lock, Err: err}
+}
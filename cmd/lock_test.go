@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainLockError(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	fixture, err := os.ReadFile(filepath.Join("..", "testdata", "lock", "plan-error.txt"))
+	require.NoError(t, err)
+	planErr := errors.New(string(fixture))
+
+	t.Run("Lock info is parsed", func(t *testing.T) {
+		lock, ok := parseStateLock(planErr.Error())
+
+		require.True(t, ok)
+		require.Equal(t, stateLock{
+			ID:        "8c3f9c2e-5b1d-4f0a-9a47-6d2b1e0c7f31",
+			Path:      "acme-tfstate/network/terraform.tfstate",
+			Operation: "OperationTypePlan",
+			Who:       "alice@build-7",
+			Created:   "2025-01-02 10:02 UTC",
+		}, lock)
+	})
+
+	t.Run("Friendly message", func(t *testing.T) {
+		err := explainLockError(planErr)
+
+		var lockErr *StateLockError
+		require.ErrorAs(t, err, &lockErr)
+		require.EqualError(t, err, "state locked by alice@build-7 since 2025-01-02 10:02 UTC; "+
+			"operation Plan, lock ID 8c3f9c2e-5b1d-4f0a-9a47-6d2b1e0c7f31. Re-run the plan once it's released, "+
+			"or run 'force-unlock 8c3f9c2e-5b1d-4f0a-9a47-6d2b1e0c7f31' if the lock is stale")
+		require.ErrorIs(t, err, planErr)
+	})
+
+	t.Run("Lock error without lock info", func(t *testing.T) {
+		err := explainLockError(errors.New("exit status 1\n\nError: Error acquiring the state lock\n\nError message: timeout"))
+
+		require.EqualError(t, err, "state locked. Re-run the plan once it's released")
+	})
+
+	t.Run("Other errors are unchanged", func(t *testing.T) {
+		err := errors.New("exit status 1\n\nError: Invalid reference")
+
+		require.Equal(t, err, explainLockError(err))
+		require.NoError(t, explainLockError(nil))
+	})
+}
@@ -3,10 +3,17 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"regexp"
+	"sort"
 	"strings"
+	"text/template"
+	"unicode/utf8"
 
+	tfjson "github.com/hashicorp/terraform-json"
 	md "github.com/nao1215/markdown"
 )
 
@@ -18,8 +25,224 @@ const (
 	// SyntaxHighlightTerraform is the syntax highlighting identifier for
 	// Terraform/OpenTofu code.
 	SyntaxHighlightTerraform SyntaxHighlight = "terraform"
+	// SyntaxHighlightHCL highlights the plan as HCL.
+	SyntaxHighlightHCL SyntaxHighlight = "hcl"
+	// SyntaxHighlightDiff colors added and removed lines, by their leading + and -.
+	SyntaxHighlightDiff SyntaxHighlight = "diff"
+	// SyntaxHighlightText disables highlighting.
+	SyntaxHighlightText SyntaxHighlight = "text"
 )
 
+// markdownOptions holds optional content rendered alongside the plan output.
+type markdownOptions struct {
+	// Notes are rendered as a GitHub note above the plan details.
+	Notes []string
+	// Plan is the structured plan, nil when only the plan text is available.
+	Plan *tfjson.Plan
+	// ShowDrift renders resources changed outside of Terraform/OpenTofu in their own section.
+	ShowDrift bool
+	// ShowOutputs renders the outputs the plan changes in their own section.
+	ShowOutputs bool
+	// IncludeJSON renders the structured plan in a collapsible block after the plan.
+	IncludeJSON bool
+	// GroupByModule renders one collapsible block per top-level module.
+	GroupByModule bool
+	// Redact masks sensitive values in the plan output before rendering.
+	Redact bool
+	// RedactPatterns are additional patterns masked when Redact is set.
+	RedactPatterns []*regexp.Regexp
+	// Command is the plan command line, rendered so reviewers can reproduce the plan.
+	Command string
+	// BodyBase is existing Markdown the plan is appended to or inserted into.
+	BodyBase string
+	// FileMode is the permission mode of the Markdown file, defaultFileMode if zero.
+	FileMode os.FileMode
+	// Stdout also receives the Markdown once createOutputs has written every file, for --md-stdout.
+	Stdout io.Writer
+	// Sections are the plans of several directories, rendered instead of planStr and Plan.
+	Sections []planSection
+	// Deterministic leaves out volatile content so identical plans render byte-identical Markdown.
+	Deterministic bool
+	// Template renders the whole Markdown instead of the built-in layout, when set.
+	Template *template.Template
+	// Syntax is the language of the plan code blocks, SyntaxHighlightTerraform if empty.
+	Syntax SyntaxHighlight
+	// Attribution lists the changes stemming from the branch's edits, rendered when set.
+	Attribution *attribution
+	// BinaryVersion overrides the version of the binary that made the plan,
+	// recorded in binaryVersionMarker.
+	BinaryVersion string
+	// RawWhitespace writes the Markdown as rendered, skipping normalizeMarkdown.
+	RawWhitespace bool
+	// Environment labels the plan's title, e.g. "Terraform plan (prod)", when set.
+	Environment string
+	// Workspace is the workspace selected with --workspace, rendered above the plan when set.
+	Workspace string
+	// Icons prefixes each resource of the plan output with the icon of its action, see addActionIcons.
+	Icons bool
+}
+
+// syntax returns the language of the plan code blocks.
+func (o markdownOptions) syntax() SyntaxHighlight {
+	if o.Syntax == "" {
+		return SyntaxHighlightTerraform
+	}
+	return o.Syntax
+}
+
+// planSection is the plan of one directory in a multi-directory run.
+type planSection struct {
+	Dir     string       // Directory of the plan, empty for a single plan
+	Text    string       // Human-readable plan output
+	Plan    *tfjson.Plan // Structured plan, may be nil
+	Command string       // Command line of the plan, rendered when set
+
+	Attribution *attribution // Changes stemming from the branch's edits, rendered when set
+}
+
+// sectionPlans returns the structured plan of each section, nil when unknown.
+func sectionPlans(sections []planSection) []*tfjson.Plan {
+	plans := make([]*tfjson.Plan, 0, len(sections))
+	for _, section := range sections {
+		plans = append(plans, section.Plan)
+	}
+	return plans
+}
+
+// planSections returns the plans to render: opts.Sections, or planStr and
+// opts.Plan for a single plan.
+func planSections(planStr string, opts markdownOptions) []planSection {
+	if len(opts.Sections) > 0 {
+		return opts.Sections
+	}
+	return []planSection{{Text: planStr, Plan: opts.Plan, Attribution: opts.Attribution}}
+}
+
+// normalizePlanText removes differences in plan output that don't change the
+// plan: Windows line endings, trailing spaces and trailing blank lines.
+func normalizePlanText(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+// normalizeMarkdown gives a document consistent whitespace, as Markdown linters
+// expect: Unix line endings, no trailing spaces, no trailing blank lines and a
+// single final newline. Outside code blocks, a hard line break made of trailing
+// spaces is kept as a backslash, which renders the same.
+func normalizeMarkdown(doc string) string {
+	lines := strings.Split(strings.ReplaceAll(doc, "\r\n", "\n"), "\n")
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimRight(line, " \t")
+		if m := codeFence.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			switch {
+			case fence == "":
+				fence = m[1]
+			case strings.TrimSpace(line) == fence:
+				fence = ""
+			}
+		} else if fence == "" && strings.HasSuffix(line, "  ") && strings.TrimSpace(trimmed) != "" &&
+			i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+			trimmed += "\\"
+		}
+		lines[i] = trimmed
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n") + "\n"
+}
+
+// sectionText returns the plan output of section as rendered: normalized with
+// Deterministic and redacted with Redact.
+func sectionText(section planSection, opts markdownOptions) string {
+	text := section.Text
+	if opts.Deterministic {
+		text = normalizePlanText(text)
+	}
+	if opts.Redact {
+		text = redactPlan(text, section.Plan, opts.RedactPatterns)
+	}
+	return text
+}
+
+// renderPlanSection renders one plan: a summary of its changes, its drift, then its output in a
+// <details> block, or one block per module with GroupByModule.
+//
+// Parameters:
+//
+//	doc - The Markdown document being built.
+//	section - The plan to render.
+//	title - The summary title of the plan, e.g. "Terraform plan".
+//	opts - The options controlling redaction, drift and grouping.
+//
+// Returns:
+//
+//	error - Any error encountered generating the code block.
+func renderPlanSection(doc *md.Markdown, section planSection, title string, opts markdownOptions) error {
+	text := sectionText(section, opts)
+	driftTitle := "Detected Drift"
+	if section.Dir != "" {
+		title = fmt.Sprintf("%s: %s", title, section.Dir)
+		driftTitle = fmt.Sprintf("Detected Drift in %s", section.Dir)
+	}
+
+	if section.Command != "" {
+		doc.PlainTextf("Plan command in `%s`: `%s`", section.Dir, section.Command).PlainText("")
+	}
+	if section.Plan != nil {
+		// Surfaced for reviewers skimming past the collapsed plan, the text
+		// alone isn't counted
+		doc.PlainTextf("📋 %s", countChanges(section.Plan).summary()).PlainText("")
+	}
+	if opts.ShowDrift {
+		if drift := planDrift(section.Plan); len(drift) > 0 {
+			doc.Details(driftSummary(driftTitle, drift), "\n"+renderDrift(drift)+"\n").PlainText("")
+		}
+	}
+	if opts.ShowOutputs {
+		if outputs := planOutputChanges(section.Plan); len(outputs) > 0 {
+			doc.Details(outputsSummary(outputs, section.Dir), "\n"+renderOutputs(outputs)+"\n").PlainText("")
+		}
+	}
+	if section.Attribution != nil {
+		doc.Details(
+			attributionSummary(section.Attribution, section.Dir),
+			"\n"+renderAttribution(section.Attribution),
+		).PlainText("")
+	}
+
+	if opts.Icons && section.Plan == nil {
+		Logger.Warnf("'icons' needs a structured plan, rendering the plan of %s without icons.", planResultDir(section.Dir))
+	}
+	if opts.GroupByModule {
+		grouped, err := renderModuleGroups(text, title, section.Plan, opts.syntax())
+		if err == nil {
+			if opts.Icons {
+				grouped = addActionIcons(grouped, section.Plan)
+			}
+			doc.PlainText(grouped)
+			return nil
+		}
+		// Grouping is a presentation nicety, fall back to the single block
+		Logger.Warnf("Unable to group plan by module, using a single block: %v", err)
+	}
+
+	if opts.Icons {
+		text = addActionIcons(text, section.Plan)
+	}
+	var sbPlan strings.Builder
+	err := md.NewMarkdown(&sbPlan).CodeBlocks(
+		md.SyntaxHighlight(opts.syntax()), text,
+	).Build()
+	if err != nil {
+		return fmt.Errorf("markdown generation failed (code block): %w", err)
+	}
+	doc.Details(title, "\n"+sbPlan.String()+"\n")
+	return nil
+}
+
 // createMarkdown generates a GitHub Flavored Markdown document containing the
 // Terraform/OpenTofu plan output.
 //
@@ -28,113 +251,448 @@ const (
 //	mdParam - The desired filename for the markdown document. MUST be a base filename without directory separators and using only allowed characters.
 //	planStr - The human-readable plan output from createPlan() or stdin.
 //	binaryName - The name of the binary used ("terraform" or "tofu") for the title.
+//	opts - Optional content rendered alongside the plan output.
 //
 // Returns:
 //
 //	string - The validated filename used.
 //	error - Any error encountered during markdown generation or validation, or nil on success.
-func createMarkdown(mdParam, planStr, binaryName string) (string, error) {
-	// Use local variables
-	var sbPlanBuilder strings.Builder
-
+func createMarkdown(mdParam, planStr, binaryName string, opts markdownOptions) (string, error) {
 	Logger.Debugf(
 		"createMarkdown called for binary: %s, output file parameter: %q",
 		binaryName,
 		mdParam,
 	)
+	validatedFilename, body, err := renderMarkdown(mdParam, planStr, binaryName, opts)
+	if err != nil || body == "" {
+		return validatedFilename, err
+	}
+	if err = writeOutput(validatedFilename, body, opts.FileMode); err != nil {
+		Logger.Errorf("Failed to write markdown file '%s': %v", validatedFilename, err)
+		return validatedFilename, fmt.Errorf("failed to write markdown file: %w", err)
+	}
+	Logger.Debugf("Successfully wrote markdown content to %s", validatedFilename)
+	return validatedFilename, nil
+}
 
+// renderMarkdown renders the Markdown createMarkdown writes, without writing
+// it, so several outputs can be rendered before any is written.
+//
+// Parameters:
+//
+//	mdParam - The desired filename for the markdown document, as for createMarkdown.
+//	planStr - The human-readable plan output from createPlan() or stdin.
+//	binaryName - The name of the binary used ("terraform" or "tofu") for the title.
+//	opts - Optional content rendered alongside the plan output.
+//
+// Returns:
+//
+//	string - The validated filename.
+//	string - The Markdown, empty when the plan output is empty and nothing is to be written.
+//	error - Any error encountered during markdown generation or validation, or nil on success.
+func renderMarkdown(mdParam, planStr, binaryName string, opts markdownOptions) (string, string, error) {
 	// If we reach here, validatedFilename is considered safe and is just the filename.
-	validatedFilename, err := validateFilePath(mdParam)
+	validatedFilename, err := validateOutputName(mdParam)
 	if err != nil {
-		return mdParam, err
+		return mdParam, "", err
 	}
 
-	if len(planStr) == 0 {
+	if len(planStr) == 0 && len(opts.Sections) == 0 {
 		Logger.Debugf(
 			"Plan output is empty. Skipping Markdown file creation for %q.",
 			validatedFilename,
 		)
 		// Return the validated path, indicating it wasn't processed, and no error.
-		return validatedFilename, nil
-	}
-
-	// Prepare Markdown Content
-	codeBlockMarkdown := md.NewMarkdown(&sbPlanBuilder)
-	err = codeBlockMarkdown.CodeBlocks(
-		md.SyntaxHighlight(SyntaxHighlightTerraform), planStr,
-	).Build()
-	if err != nil {
-		Logger.Errorf("Internal error generating markdown code block: %v", err)
-		return validatedFilename, fmt.Errorf("markdown generation failed (code block): %w", err)
+		return validatedFilename, "", nil
 	}
-	sbPlan := sbPlanBuilder.String()
 
-	title := ""
-	switch strings.ToLower(binaryName) {
-	case "tofu":
-		title = "OpenTofu plan"
-	case "terraform":
-		title = "Terraform plan"
-	default:
-		title = "Plan Details"
+	title := planTitle(binaryName)
+	if title == defaultPlanTitle {
 		Logger.Warnf("Unknown binary name '%s', using default markdown title.", binaryName)
 	}
+	title = environmentTitle(title, opts.Environment)
 	Logger.Debugf("Markdown details title: %s", title)
 
-	Logger.Debugf("Attempting to create/write markdown file: %s", validatedFilename)
-
-	// Use the validatedFilename directly - it's just the filename for the current dir.
-	planMdFile, err := os.Create( //nolint:gosec // validateFilename is sanitized by validateFilePath
-		validatedFilename,
-	)
-	if err != nil {
-		Logger.Errorf("Failed to create markdown file '%s': %v", validatedFilename, err)
-		return validatedFilename, fmt.Errorf(
-			"failed to create markdown file %s: %w",
-			validatedFilename,
-			err,
-		)
+	// Build the final markdown
+	var sbBody strings.Builder
+	finalMarkdown := md.NewMarkdown(&sbBody)
+	if len(opts.Notes) > 0 {
+		finalMarkdown.Note(strings.Join(opts.Notes, "  \n> ")).PlainText("")
+	}
+	if opts.Command != "" {
+		finalMarkdown.PlainTextf("Plan command: `%s`", opts.Command).PlainText("")
+	}
+	if opts.Workspace != "" {
+		finalMarkdown.PlainTextf("Workspace: `%s`", opts.Workspace).PlainText("")
 	}
-	defer func() {
-		if closeErr := planMdFile.Close(); closeErr != nil {
-			Logger.Errorf("Error closing markdown file '%s': %v", validatedFilename, closeErr)
-		} else {
-			Logger.Debugf("Closed markdown file: %s", validatedFilename)
+	sections := planSections(planStr, opts)
+	for i, section := range sections {
+		if i > 0 {
+			finalMarkdown.PlainText("")
 		}
-	}()
-
-	// Build final markdown directly into the file handle
-	finalMarkdown := md.NewMarkdown(planMdFile)
-	buildErr := finalMarkdown.Details(title, "\n"+sbPlan+"\n").Build()
+		if err = renderPlanSection(finalMarkdown, section, title, opts); err != nil {
+			Logger.Errorf("Internal error generating markdown code block: %v", err)
+			return validatedFilename, "", err
+		}
+		if opts.IncludeJSON {
+			if err = renderPlanJSON(finalMarkdown, section, opts); err != nil {
+				return validatedFilename, "", err
+			}
+		}
+	}
+	if version := reportedVersion(opts.BinaryVersion, sectionPlans(sections)...); version != "" {
+		finalMarkdown.PlainText("").PlainTextf(binaryVersionMarker, binaryKind(binaryName), version)
+	}
+	buildErr := finalMarkdown.Build()
 	if buildErr != nil {
 		Logger.Errorf(
 			"Failed to write <details> block to markdown file '%s': %v",
 			validatedFilename,
 			buildErr,
 		)
-		return validatedFilename, fmt.Errorf(
+		return validatedFilename, "", fmt.Errorf(
 			"failed to write markdown content to %s: %w",
 			validatedFilename,
 			buildErr,
 		)
 	}
+	body := sbBody.String()
+	if opts.Template != nil {
+		body, err = renderMarkdownTemplate(opts.Template, newTemplateData(planStr, binaryName, body, opts))
+		if err != nil {
+			return validatedFilename, "", err
+		}
+	}
+	if opts.BodyBase != "" {
+		body = composeBody(opts.BodyBase, body)
+	}
+
+	if opts.RawWhitespace {
+		// Write body with a final newline to mdFile
+		body += "\n"
+	} else {
+		body = normalizeMarkdown(body)
+	}
+	return validatedFilename, body, nil
+}
+
+// defaultPlanTitle is the title of plans from an unknown binary
+const defaultPlanTitle = "Plan Details"
+
+// planTitle returns the title of a plan made with binaryName, e.g. "OpenTofu plan".
+func planTitle(binaryName string) string {
+	switch strings.ToLower(binaryKind(binaryName)) {
+	case "tofu":
+		return "OpenTofu plan"
+	case "terraform":
+		return "Terraform plan"
+	default:
+		return defaultPlanTitle
+	}
+}
+
+// driftSummary returns the summary of the drift section.
+func driftSummary(title string, drift []driftedResource) string {
+	noun := "resources"
+	if len(drift) == 1 {
+		noun = "resource"
+	}
+	return fmt.Sprintf("%s (%d %s)", title, len(drift), noun)
+}
+
+// renderDrift renders drifted resources as a Markdown list.
+func renderDrift(drift []driftedResource) string {
+	var sb strings.Builder
+	sb.WriteString("Changed outside of Terraform/OpenTofu since the last apply:\n\n")
+	for _, d := range drift {
+		fmt.Fprintf(&sb, "- `%s` was %s", d.Address, d.Change)
+		if len(d.Attributes) > 0 {
+			sb.WriteString(": `" + strings.Join(d.Attributes, "`, `") + "`")
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// outputsSummary returns the summary of the outputs section, e.g. "2 outputs changed".
+func outputsSummary(outputs []outputChange, dir string) string {
+	noun := "outputs"
+	if len(outputs) == 1 {
+		noun = "output"
+	}
+	summary := fmt.Sprintf("%d %s changed", len(outputs), noun)
+	if dir != "" {
+		summary += " in " + dir
+	}
+	return summary
+}
+
+// renderOutputs renders changed outputs as a Markdown list.
+func renderOutputs(outputs []outputChange) string {
+	var sb strings.Builder
+	for _, o := range outputs {
+		fmt.Fprintf(&sb, "- `%s` %s\n", o.Name, o.Change)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// binaryVersionMarker records the binary and version behind the Markdown,
+// without rendering anything in the pull request.
+const binaryVersionMarker = "<!-- gh-tp:binary-version %s %s -->"
+
+// planBodyMarker marks where the plan is inserted into a --pr-body-file body.
+const planBodyMarker = "<!-- gh-tp:plan -->"
+
+// composeBody inserts the plan Markdown into base at the first planBodyMarker,
+// or appends it after a blank line if base has no marker.
+func composeBody(base, planMd string) string {
+	if strings.Contains(base, planBodyMarker) {
+		return strings.Replace(base, planBodyMarker, planMd, 1)
+	}
+	return strings.TrimRight(base, "\n") + "\n\n" + planMd
+}
 
-	// Add final newline to mdFile
-	_, err = planMdFile.WriteString("\n")
+// readBodyFile reads the file passed to --pr-body-file.
+//
+// Parameters:
+//
+//	path - The path of the body file. Unlike planFile and mdFile, it may be in another directory.
+//
+// Returns:
+//
+//	string - The file's content.
+//	error - An error if the file is missing, not a regular file or not valid UTF-8.
+func readBodyFile(path string) (string, error) {
+	info, err := os.Stat(path)
 	if err != nil {
-		Logger.Errorf(
-			"Failed to write final newline to markdown file '%s': %v",
-			validatedFilename,
-			err,
-		)
-		return validatedFilename, fmt.Errorf(
-			"failed write final newline to %s: %w",
-			validatedFilename,
-			err,
+		return "", fmt.Errorf("invalid 'pr-body-file' (%q): %w", path, err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("invalid 'pr-body-file' (%q): not a regular file", path)
+	}
+	data, err := os.ReadFile(path) //nolint:gosec // explicitly provided by the user
+	if err != nil {
+		return "", fmt.Errorf("invalid 'pr-body-file' (%q): %w", path, err)
+	}
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("invalid 'pr-body-file' (%q): file is not valid UTF-8", path)
+	}
+	return string(data), nil
+}
+
+// rootModuleGroup is the group name used for resources outside any module.
+const rootModuleGroup = "root module"
+
+var (
+	// Matches the header line of a resource block, e.g. "  # module.a.null_resource.b will be created"
+	resourceBlockHeader = regexp.MustCompile(`^  # ([^\s(]+\.[^\s]+) `)
+	// Matches the line introducing the planned actions for Terraform and OpenTofu
+	plannedActionsMarker = regexp.MustCompile(`will perform the following actions:\s*$`)
+	// Matches the first line after the resource blocks
+	planFooterStart = regexp.MustCompile(`^(Plan: |Changes to Outputs:)`)
+	// Matches the top-level module call in an address, ignoring instance keys
+	topLevelModule = regexp.MustCompile(`^(module\.[^.\[]+)`)
+)
+
+// Icons of --icons, by the action of the resource
+const (
+	iconCreate  = "➕"
+	iconUpdate  = "🔄"
+	iconDelete  = "➖"
+	iconReplace = "♻️"
+)
+
+// actionIcon returns the icon of a resource's actions, "" for reads and no-ops.
+func actionIcon(actions tfjson.Actions) string {
+	switch {
+	case actions.Replace():
+		return iconReplace
+	case actions.Create():
+		return iconCreate
+	case actions.Update():
+		return iconUpdate
+	case actions.Delete():
+		return iconDelete
+	default:
+		return ""
+	}
+}
+
+// addActionIcons prefixes the header line of each resource in the plan
+// output with the icon of its action in the structured plan, e.g.
+// "  # 🔄 aws_vpc.main will be updated in-place". Only the planned actions are
+// marked when the text has them, not the resources that drifted before them.
+//
+// Parameters:
+//
+//	text - The plan output, or the Markdown of renderModuleGroups.
+//	plan - The structured plan giving each resource's actions, the text is returned as is when nil.
+//
+// Returns:
+//
+//	string - The text with the icons.
+func addActionIcons(text string, plan *tfjson.Plan) string {
+	if plan == nil {
+		return text
+	}
+	icons := make(map[string]string, len(plan.ResourceChanges))
+	for _, rc := range plan.ResourceChanges {
+		if rc.Change != nil {
+			icons[rc.Address] = actionIcon(rc.Change.Actions)
+		}
+	}
+
+	lines := strings.Split(text, "\n")
+	start := 0
+	for i, line := range lines {
+		if plannedActionsMarker.MatchString(line) {
+			start = i + 1
+			break
+		}
+	}
+	for i := start; i < len(lines); i++ {
+		m := resourceBlockHeader.FindStringSubmatch(lines[i])
+		if m == nil || icons[m[1]] == "" {
+			continue
+		}
+		lines[i] = "  # " + icons[m[1]] + " " + strings.TrimPrefix(lines[i], "  # ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// planModuleGroup is the plan text for the resources of one top-level module.
+type planModuleGroup struct {
+	Name   string
+	Blocks []string
+}
+
+// renderModuleGroups splits the plan text into per-resource blocks and renders
+// one <details> element per top-level module, root module first. The text
+// before the resource blocks, e.g. drift detected outside of Terraform, and
+// the text after them are each rendered as a separate code block.
+//
+// Parameters:
+//
+//	planStr - The human-readable plan output.
+//	title - The summary title of the plan, e.g. "Terraform plan".
+//	plan - The structured plan used to map resources to modules, may be nil.
+//	syntax - The language of the code blocks.
+//
+// Returns:
+//
+//	string - The rendered Markdown.
+//	error - An error if the plan text could not be split into resource blocks.
+func renderModuleGroups(planStr, title string, plan *tfjson.Plan, syntax SyntaxHighlight) (string, error) {
+	lines := strings.Split(planStr, "\n")
+
+	start := -1
+	for i, line := range lines {
+		if plannedActionsMarker.MatchString(line) {
+			start = i + 1
+			break
+		}
+	}
+	if start < 0 {
+		return "", errors.New("no planned actions found in plan output")
+	}
+
+	// Map addresses to their module from the structured plan when available
+	moduleOf := map[string]string{}
+	if plan != nil {
+		for _, rc := range plan.ResourceChanges {
+			moduleOf[rc.Address] = rc.ModuleAddress
+		}
+	}
+	groupName := func(address string) string {
+		moduleAddress, ok := moduleOf[address]
+		if !ok {
+			moduleAddress = address
+		}
+		if m := topLevelModule.FindStringSubmatch(moduleAddress); m != nil {
+			return m[1]
+		}
+		return rootModuleGroup
+	}
+
+	groups := map[string]*planModuleGroup{}
+	var footer []string
+	var current []string
+	var currentGroup string
+	flush := func() {
+		if currentGroup == "" || len(current) == 0 {
+			return
+		}
+		g, ok := groups[currentGroup]
+		if !ok {
+			g = &planModuleGroup{Name: currentGroup}
+			groups[currentGroup] = g
+		}
+		g.Blocks = append(g.Blocks, strings.TrimRight(strings.Join(current, "\n"), "\n "))
+		current = nil
+	}
+
+	for i := start; i < len(lines); i++ {
+		line := lines[i]
+		if planFooterStart.MatchString(line) {
+			flush()
+			footer = lines[i:]
+			break
+		}
+		if m := resourceBlockHeader.FindStringSubmatch(line); m != nil {
+			flush()
+			currentGroup = groupName(m[1])
+		}
+		if currentGroup != "" {
+			current = append(current, line)
+		}
+	}
+	flush()
+
+	if len(groups) == 0 {
+		return "", errors.New("no resource blocks found in plan output")
+	}
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		if name != rootModuleGroup {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if _, ok := groups[rootModuleGroup]; ok {
+		names = append([]string{rootModuleGroup}, names...)
+	}
+
+	var sb strings.Builder
+	doc := md.NewMarkdown(&sb)
+	if headerText := strings.TrimSpace(strings.Join(lines[:start], "\n")); headerText != "" {
+		doc.CodeBlocks(md.SyntaxHighlight(syntax), headerText).PlainText("")
+	}
+	for i, name := range names {
+		g := groups[name]
+		noun := "resources"
+		if len(g.Blocks) == 1 {
+			noun = "resource"
+		}
+		var block strings.Builder
+		err := md.NewMarkdown(&block).CodeBlocks(
+			md.SyntaxHighlight(syntax), strings.Join(g.Blocks, "\n\n"),
+		).Build()
+		if err != nil {
+			return "", fmt.Errorf("markdown generation failed (module %s): %w", name, err)
+		}
+		if i > 0 {
+			doc.PlainText("")
+		}
+		doc.Details(
+			fmt.Sprintf("%s: %s (%d %s)", title, name, len(g.Blocks), noun),
+			"\n"+block.String()+"\n",
 		)
 	}
 
-	Logger.Debugf("Successfully wrote markdown content to %s", validatedFilename)
-	// Return the validatedFilename used and nil error on success
-	return validatedFilename, nil
+	if footerText := strings.TrimSpace(strings.Join(footer, "\n")); footerText != "" {
+		doc.PlainText("").CodeBlocks(md.SyntaxHighlight(syntax), footerText)
+	}
+
+	return doc.String(), nil
 }
@@ -3,12 +3,18 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+	"text/template"
 
 	"github.com/charmbracelet/log"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_createMarkdown(t *testing.T) {
@@ -27,6 +33,7 @@ func Test_createMarkdown(t *testing.T) {
 		mdParam    string
 		planStr    string
 		binaryName string
+		opts       markdownOptions
 	}
 	tests := []struct {
 		name        string
@@ -75,6 +82,24 @@ func Test_createMarkdown(t *testing.T) {
 				"</details>",
 			},
 		},
+		{
+			name: "with notes",
+			args: args{
+				mdParam:    "notes_plan.md",
+				planStr:    "+ resource \"test\"",
+				binaryName: "terraform",
+				opts: markdownOptions{
+					Notes: []string{"Configuration was generated to `generated.tf`."},
+				},
+			},
+			wantPath: "notes_plan.md",
+			wantErr:  false,
+			wantContent: []string{
+				"> [!NOTE]",
+				"> Configuration was generated to `generated.tf`.",
+				"<details><summary>Terraform plan</summary>",
+			},
+		},
 		// --- Validation Failure Cases ---
 		{
 			name: "invalid filename - contains slash",
@@ -146,7 +171,12 @@ func Test_createMarkdown(t *testing.T) {
 		t.Cleanup(func() { os.Chdir(cwd) })
 
 		t.Run(tt.name, func(t *testing.T) {
-			gotPath, err := createMarkdown(tt.args.mdParam, tt.args.planStr, tt.args.binaryName)
+			gotPath, err := createMarkdown(
+				tt.args.mdParam,
+				tt.args.planStr,
+				tt.args.binaryName,
+				tt.args.opts,
+			)
 
 			// 1. Check error status
 			if (err != nil) != tt.wantErr {
@@ -208,3 +238,601 @@ func Test_createMarkdown(t *testing.T) {
 		})
 	}
 }
+
+func Test_renderModuleGroups(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	planText, err := os.ReadFile(filepath.Join("..", "testdata", "plans", "changes.txt"))
+	require.NoError(t, err)
+
+	t.Run("Groups resources by top-level module", func(t *testing.T) {
+		got, err := renderModuleGroups(
+			string(planText), "Terraform plan", loadPlanFixture(t, "changes.json"), SyntaxHighlightTerraform,
+		)
+		require.NoError(t, err)
+
+		rootIdx := strings.Index(got, "<summary>Terraform plan: root module (1 resource)</summary>")
+		dbIdx := strings.Index(got, "<summary>Terraform plan: module.db (1 resource)</summary>")
+		netIdx := strings.Index(got, "<summary>Terraform plan: module.network (2 resources)</summary>")
+		require.NotEqual(t, -1, rootIdx, got)
+		require.NotEqual(t, -1, dbIdx, got)
+		require.NotEqual(t, -1, netIdx, got)
+		require.Less(t, rootIdx, dbIdx, "root module should come first")
+		require.Less(t, dbIdx, netIdx, "modules should be sorted")
+
+		// Each resource lands in its module's block
+		require.Contains(t, got[rootIdx:dbIdx], "# random_password.db will be created")
+		require.Contains(t, got[dbIdx:netIdx], "# module.db.aws_db_instance.main must be replaced")
+		require.Contains(t, got[netIdx:], "# module.network.aws_subnet.legacy will be destroyed")
+		require.Contains(t, got[netIdx:], "# module.network.aws_vpc.main will be updated in-place")
+
+		// Drift reported before the planned actions leads, outside the groups
+		driftIdx := strings.Index(got, "aws_security_group.web has changed")
+		require.NotEqual(t, -1, driftIdx, got)
+		require.Less(t, driftIdx, rootIdx)
+		require.Less(t, strings.Index(got, "Terraform will perform the following actions:"), rootIdx)
+
+		// The plan summary is kept after the groups
+		require.Contains(t, got[netIdx:], "Plan: 2 to add, 1 to change, 2 to destroy.")
+	})
+
+	t.Run("Groups from addresses without a structured plan", func(t *testing.T) {
+		got, err := renderModuleGroups(string(planText), "OpenTofu plan", nil, SyntaxHighlightTerraform)
+		require.NoError(t, err)
+
+		require.Contains(t, got, "<summary>OpenTofu plan: module.network (2 resources)</summary>")
+	})
+
+	t.Run("No planned actions", func(t *testing.T) {
+		_, err := renderModuleGroups(
+			"No changes. Your infrastructure matches the configuration.", "Terraform plan", nil, SyntaxHighlightTerraform,
+		)
+
+		require.Error(t, err)
+	})
+}
+
+func TestAddActionIcons(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	planText, err := os.ReadFile(filepath.Join("..", "testdata", "plans", "changes.txt"))
+	require.NoError(t, err)
+	plan := loadPlanFixture(t, "changes.json")
+
+	got := addActionIcons(string(planText), plan)
+
+	for _, want := range []string{
+		"  # ➕ random_password.db will be created",
+		"  # 🔄 module.network.aws_vpc.main will be updated in-place",
+		"  # ➖ module.network.aws_subnet.legacy will be destroyed",
+		"  # ♻️ module.db.aws_db_instance.main must be replaced",
+		"  # aws_security_group.web has changed",
+	} {
+		require.Contains(t, got, want+"\n")
+	}
+	require.Equal(t, string(planText), addActionIcons(string(planText), nil), "no icons without the structured plan")
+
+	t.Run("Rendered with --icons", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		for _, group := range []bool{false, true} {
+			mdFile, err := createMarkdown("plan.md", string(planText), "terraform", markdownOptions{
+				Plan:          plan,
+				Icons:         true,
+				GroupByModule: group,
+			})
+			require.NoError(t, err)
+			content, err := os.ReadFile(mdFile)
+			require.NoError(t, err)
+			require.Contains(t, string(content), "  # ♻️ module.db.aws_db_instance.main must be replaced", "grouped: %t", group)
+		}
+
+		mdFile, err := createMarkdown("plan.md", string(planText), "terraform", markdownOptions{Plan: plan})
+		require.NoError(t, err)
+		content, err := os.ReadFile(mdFile)
+		require.NoError(t, err)
+		require.NotContains(t, string(content), iconReplace, "the raw output is the default")
+	})
+
+	t.Run("Warns without a structured plan", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		var buf bytes.Buffer
+		Logger.SetOutput(&buf)
+		t.Cleanup(func() { Logger.SetOutput(os.Stderr) })
+
+		mdFile, err := createMarkdown("plan.md", string(planText), "terraform", markdownOptions{Icons: true})
+		require.NoError(t, err)
+
+		content, err := os.ReadFile(mdFile)
+		require.NoError(t, err)
+		require.NotContains(t, string(content), iconReplace)
+		require.Contains(t, buf.String(), "'icons' needs a structured plan")
+	})
+}
+
+func TestCreateMarkdownBodyBase(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	t.Chdir(t.TempDir())
+
+	testCases := []struct {
+		name       string
+		base       string
+		wantPrefix string
+		wantSuffix string
+	}{
+		{
+			name:       "Plan is appended to the body",
+			base:       "## Summary\n\nBumps the VPC module.\n\n",
+			wantPrefix: "## Summary\n\nBumps the VPC module.\n\n<details><summary>Terraform plan</summary>",
+			wantSuffix: "</details>\n",
+		},
+		{
+			name:       "Plan is inserted at the marker",
+			base:       "## Summary\n\n" + planBodyMarker + "\n\n## Checklist\n",
+			wantPrefix: "## Summary\n\n<details><summary>Terraform plan</summary>",
+			wantSuffix: "</details>\n\n## Checklist\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bodyFile := filepath.Join(t.TempDir(), "body.md")
+			require.NoError(t, os.WriteFile(bodyFile, []byte(tc.base), 0o600))
+			base, err := readBodyFile(bodyFile)
+			require.NoError(t, err)
+
+			mdFile, err := createMarkdown("plan.md", "No changes.", "terraform", markdownOptions{
+				BodyBase: base,
+			})
+			require.NoError(t, err)
+
+			got, err := os.ReadFile(mdFile)
+			require.NoError(t, err)
+			require.True(t, strings.HasPrefix(string(got), tc.wantPrefix), string(got))
+			require.True(t, strings.HasSuffix(string(got), tc.wantSuffix), string(got))
+			require.Equal(t, 1, strings.Count(string(got), "No changes."))
+		})
+	}
+}
+
+func TestReadBodyFile(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.md")
+	require.NoError(t, os.WriteFile(invalid, []byte{0xff, 0xfe, 'h', 'i'}, 0o600))
+
+	_, err := readBodyFile(filepath.Join(dir, "missing.md"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = readBodyFile(dir)
+	require.ErrorContains(t, err, "not a regular file")
+
+	_, err = readBodyFile(invalid)
+	require.ErrorContains(t, err, "not valid UTF-8")
+}
+
+func TestCreateMarkdownCommand(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	t.Chdir(t.TempDir())
+
+	mdFile, err := createMarkdown("plan.md", "No changes.", "terraform", markdownOptions{
+		Command: "terraform plan -out=plan.out",
+	})
+	require.NoError(t, err)
+
+	got, err := os.ReadFile(mdFile)
+	require.NoError(t, err)
+	require.True(
+		t,
+		strings.HasPrefix(string(got), "Plan command: `terraform plan -out=plan.out`\n\n<details>"),
+		string(got),
+	)
+}
+
+func TestCreateMarkdownWorkspace(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	t.Chdir(t.TempDir())
+
+	mdFile, err := createMarkdown("plan.md", "No changes.", "terraform", markdownOptions{
+		Command:   "terraform plan -out=plan.out",
+		Workspace: "prod",
+	})
+	require.NoError(t, err)
+
+	got, err := os.ReadFile(mdFile)
+	require.NoError(t, err)
+	require.True(
+		t,
+		strings.HasPrefix(
+			string(got),
+			"Plan command: `terraform plan -out=plan.out`\n\nWorkspace: `prod`\n\n<details>",
+		),
+		string(got),
+	)
+}
+
+func TestCreateMarkdownChangeSummary(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	plan := loadPlanFixture(t, "changes.json")
+	t.Chdir(t.TempDir())
+
+	mdFile, err := createMarkdown("plan.md", "Plan: 2 to add, 1 to change, 2 to destroy.", "terraform", markdownOptions{
+		Plan:      plan,
+		ShowDrift: true,
+	})
+	require.NoError(t, err)
+	got, err := os.ReadFile(mdFile)
+	require.NoError(t, err)
+	require.True(
+		t,
+		strings.HasPrefix(string(got), "📋 2 to add, 1 to change, 2 to destroy\n\n<details><summary>Detected Drift"),
+		string(got),
+	)
+
+	t.Run("Imports are counted", func(t *testing.T) {
+		require.Equal(t, "1 to add, 0 to change, 0 to destroy, 1 to import",
+			changeCounts{Add: 1, Import: 1}.summary())
+	})
+
+	t.Run("No summary without the structured plan", func(t *testing.T) {
+		mdFile, err := createMarkdown("text.md", "Plan: 2 to add, 1 to change, 2 to destroy.", "terraform", markdownOptions{})
+		require.NoError(t, err)
+		got, err := os.ReadFile(mdFile)
+		require.NoError(t, err)
+		require.NotContains(t, string(got), "📋")
+	})
+}
+
+func TestCreateMarkdownDrift(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	// Fixtures are relative to the package directory, load them before changing it
+	plans := map[string]*tfjson.Plan{
+		"drift.json":      loadPlanFixture(t, "drift.json"),
+		"no-changes.json": loadPlanFixture(t, "no-changes.json"),
+	}
+	t.Chdir(t.TempDir())
+
+	render := func(t *testing.T, fixture string, showDrift bool) string {
+		t.Helper()
+		mdFile, err := createMarkdown("plan.md", "No changes.", "terraform", markdownOptions{
+			Plan:      plans[fixture],
+			ShowDrift: showDrift,
+		})
+		require.NoError(t, err)
+		got, err := os.ReadFile(mdFile)
+		require.NoError(t, err)
+		return string(got)
+	}
+
+	t.Run("Drift is rendered before the plan", func(t *testing.T) {
+		got := render(t, "drift.json", true)
+
+		driftIdx := strings.Index(got, "<details><summary>Detected Drift (2 resources)</summary>")
+		planIdx := strings.Index(got, "<details><summary>Terraform plan</summary>")
+		require.NotEqual(t, -1, driftIdx, got)
+		require.Less(t, driftIdx, planIdx)
+		require.Contains(t, got, "- `aws_db_instance.main` was updated: `instance_class`, `password`, `tags`")
+		require.Contains(t, got, "- `module.network.aws_subnet.legacy` was deleted")
+		require.NotContains(t, got, "db-password", "drift must not expose values")
+	})
+
+	t.Run("No section without drift", func(t *testing.T) {
+		require.NotContains(t, render(t, "no-changes.json", true), "Detected Drift")
+	})
+
+	t.Run("Drift can be hidden", func(t *testing.T) {
+		require.NotContains(t, render(t, "drift.json", false), "Detected Drift")
+	})
+}
+
+func TestCreateMarkdownOutputs(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	plans := map[string]*tfjson.Plan{
+		"changes.json":    loadPlanFixture(t, "changes.json"),
+		"no-changes.json": loadPlanFixture(t, "no-changes.json"),
+	}
+	t.Chdir(t.TempDir())
+
+	render := func(t *testing.T, fixture string, showOutputs bool) string {
+		t.Helper()
+		mdFile, err := createMarkdown("plan.md", "No changes.", "terraform", markdownOptions{
+			Plan:        plans[fixture],
+			ShowOutputs: showOutputs,
+		})
+		require.NoError(t, err)
+		got, err := os.ReadFile(mdFile)
+		require.NoError(t, err)
+		return string(got)
+	}
+
+	t.Run("Changed outputs are listed before the plan", func(t *testing.T) {
+		got := render(t, "changes.json", true)
+
+		outputsIdx := strings.Index(got, "<details><summary>3 outputs changed</summary>")
+		planIdx := strings.Index(got, "<details><summary>Terraform plan</summary>")
+		require.NotEqual(t, -1, outputsIdx, got)
+		require.Less(t, outputsIdx, planIdx)
+		require.Contains(t, got, "- `db_endpoint` changed\n- `legacy_subnet_id` removed\n- `vpc_id` added\n")
+		require.NotContains(t, got, "`region`", "unchanged outputs are left out")
+		require.NotContains(t, got, "db-new.internal", "outputs must not expose values")
+	})
+
+	t.Run("No section without output changes", func(t *testing.T) {
+		require.NotContains(t, render(t, "no-changes.json", true), "outputs changed")
+	})
+
+	t.Run("Outputs are hidden by default", func(t *testing.T) {
+		require.NotContains(t, render(t, "changes.json", false), "outputs changed")
+	})
+}
+
+func TestCreateMarkdownJSON(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	plan := loadPlanFixture(t, "changes.json")
+	t.Chdir(t.TempDir())
+
+	render := func(t *testing.T, opts markdownOptions) string {
+		t.Helper()
+		mdFile, err := createMarkdown("plan.md", "No changes.", "terraform", opts)
+		require.NoError(t, err)
+		got, err := os.ReadFile(mdFile)
+		require.NoError(t, err)
+		return string(got)
+	}
+	jsonBlock := func(t *testing.T, doc string) string {
+		t.Helper()
+		_, block, found := strings.Cut(doc, "<details><summary>Raw JSON plan</summary>\n\n```json\n")
+		require.True(t, found, doc)
+		block, _, found = strings.Cut(block, "\n```")
+		require.True(t, found, doc)
+		return block
+	}
+
+	t.Run("The JSON plan follows the plan and is valid JSON", func(t *testing.T) {
+		got := render(t, markdownOptions{Plan: plan, IncludeJSON: true})
+
+		planIdx := strings.Index(got, "<details><summary>Terraform plan</summary>")
+		jsonIdx := strings.Index(got, "<details><summary>Raw JSON plan</summary>")
+		require.NotEqual(t, -1, planIdx, got)
+		require.Less(t, planIdx, jsonIdx)
+		var decoded tfjson.Plan
+		require.NoError(t, json.Unmarshal([]byte(jsonBlock(t, got)), &decoded))
+		require.Len(t, decoded.ResourceChanges, len(plan.ResourceChanges))
+	})
+
+	t.Run("Sensitive values are masked with redact", func(t *testing.T) {
+		got := render(t, markdownOptions{Plan: plan, IncludeJSON: true, Redact: true})
+
+		block := jsonBlock(t, got)
+		require.NotContains(t, block, "new-db-password")
+		require.Contains(t, block, `"(sensitive value)"`)
+		require.True(t, json.Valid([]byte(block)))
+	})
+
+	t.Run("Sensitive values are masked without redact", func(t *testing.T) {
+		got := render(t, markdownOptions{
+			Plan:           plan,
+			IncludeJSON:    true,
+			RedactPatterns: []*regexp.Regexp{regexp.MustCompile(`main-vpc`)},
+		})
+
+		block := jsonBlock(t, got)
+		require.NotContains(t, block, "new-db-password")
+		require.Contains(t, block, `"(sensitive value)"`)
+		require.Contains(t, block, "main-vpc", "the patterns need redact")
+		require.True(t, json.Valid([]byte(block)))
+	})
+
+	t.Run("Large plans are truncated", func(t *testing.T) {
+		text, err := planJSONText(plan, markdownOptions{})
+		require.NoError(t, err)
+
+		cut, truncated := truncatePlanJSON(text, 200)
+
+		require.True(t, truncated)
+		require.LessOrEqual(t, len(cut), 200)
+		require.True(t, strings.HasPrefix(text, cut+"\n"))
+		_, truncated = truncatePlanJSON(text, len(text))
+		require.False(t, truncated)
+	})
+
+	t.Run("Not included by default", func(t *testing.T) {
+		require.NotContains(t, render(t, markdownOptions{Plan: plan}), "Raw JSON plan")
+	})
+
+	t.Run("Nothing without a structured plan", func(t *testing.T) {
+		require.NotContains(t, render(t, markdownOptions{IncludeJSON: true}), "Raw JSON plan")
+	})
+}
+
+func TestWritePlanJSON(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	plan := loadPlanFixture(t, "changes.json")
+	dir := t.TempDir()
+
+	t.Run("Format", func(t *testing.T) {
+		for raw, want := range map[string]string{"": planFormatText, "text": planFormatText, " JSON ": planFormatJSON} {
+			got, err := parsePlanFormat(raw)
+			require.NoError(t, err)
+			require.Equal(t, want, got)
+		}
+		_, err := parsePlanFormat("yaml")
+		require.EqualError(t, err, `invalid 'format' "yaml": must be text or json`)
+		require.Equal(t, "plan.json", planJSONPath("plan.out"))
+		require.Equal(t, "tfplan.json", planJSONPath("tfplan"))
+	})
+
+	t.Run("The structured plan is valid JSON", func(t *testing.T) {
+		path := filepath.Join(dir, "plan.json")
+
+		require.NoError(t, writePlanJSON(path, plan, markdownOptions{Redact: true, FileMode: 0o600}))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		var decoded tfjson.Plan
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		require.Len(t, decoded.ResourceChanges, len(plan.ResourceChanges))
+		require.NotContains(t, string(data), "new-db-password")
+	})
+
+	t.Run("Without a structured plan", func(t *testing.T) {
+		err := writePlanJSON(filepath.Join(dir, "missing.json"), nil, markdownOptions{})
+
+		require.ErrorContains(t, err, "the structured plan could not be read")
+		require.NoFileExists(t, filepath.Join(dir, "missing.json"))
+	})
+}
+
+func TestCreateMarkdownSections(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	drift := loadPlanFixture(t, "drift.json")
+	t.Chdir(t.TempDir())
+
+	mdFile, err := createMarkdown("plan.md", "", "tofu", markdownOptions{
+		ShowDrift: true,
+		Sections: []planSection{
+			{Dir: "network", Text: "Plan: 1 to add, 0 to change, 0 to destroy."},
+			{Dir: "database", Text: "No changes.", Plan: drift, Command: "tofu plan -out=plan.out"},
+		},
+	})
+	require.NoError(t, err)
+	content, err := os.ReadFile(mdFile)
+	require.NoError(t, err)
+	got := string(content)
+
+	networkIdx := strings.Index(got, "<details><summary>OpenTofu plan: network</summary>")
+	databaseIdx := strings.Index(got, "<details><summary>OpenTofu plan: database</summary>")
+	require.NotEqual(t, -1, networkIdx, got)
+	require.Less(t, networkIdx, databaseIdx, "sections keep the order of the directories")
+	require.Contains(t, got, "Plan command in `database`: `tofu plan -out=plan.out`")
+	require.Contains(t, got, "<details><summary>Detected Drift in database (2 resources)</summary>")
+	require.Equal(t, 1, strings.Count(got, "Detected Drift"), "drift is only shown for its directory")
+}
+
+func TestCreateMarkdownDeterministic(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	plan := loadPlanFixture(t, "changes.json")
+	planText, err := os.ReadFile(filepath.Join("..", "testdata", "plans", "changes.txt"))
+	require.NoError(t, err)
+	t.Chdir(t.TempDir())
+
+	render := func(t *testing.T, text string) []byte {
+		t.Helper()
+		mdFile, err := createMarkdown("plan.md", text, "terraform", markdownOptions{
+			Plan:          plan,
+			ShowDrift:     true,
+			GroupByModule: true,
+			Redact:        true,
+			Deterministic: true,
+		})
+		require.NoError(t, err)
+		got, err := os.ReadFile(mdFile)
+		require.NoError(t, err)
+		return got
+	}
+
+	first := render(t, string(planText))
+	require.Equal(t, first, render(t, string(planText)), "the same plan renders identically")
+
+	// Line endings and trailing whitespace from another platform don't matter either
+	crlf := strings.ReplaceAll(string(planText), "\n", "  \r\n")
+	require.Equal(t, first, render(t, crlf))
+}
+
+func TestNormalizeMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{
+			name: "Exactly one trailing newline",
+			doc:  "</details>\n\n\n",
+			want: "</details>\n",
+		},
+		{
+			name: "A missing newline is added",
+			doc:  "</details>",
+			want: "</details>\n",
+		},
+		{
+			name: "Trailing spaces and Windows line endings are removed",
+			doc:  "## Summary \t\r\n\r\nBumps the VPC module. \r\n",
+			want: "## Summary\n\nBumps the VPC module.\n",
+		},
+		{
+			name: "Hard line breaks are kept as backslashes",
+			doc:  "> First note  \n> Second note  \n\nText",
+			want: "> First note\\\n> Second note\n\nText\n",
+		},
+		{
+			name: "Code blocks are only trimmed",
+			doc:  "```terraform\n  + name = \"web\"  \n  }\n```\n",
+			want: "```terraform\n  + name = \"web\"\n  }\n```\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, normalizeMarkdown(tt.doc))
+		})
+	}
+}
+
+func TestCreateMarkdownTrailingNewline(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	t.Chdir(t.TempDir())
+	tmpl, err := template.New("padded").Parse("{{ .Summary }}   \n\n\n\n")
+	require.NoError(t, err)
+
+	for name, opts := range map[string]markdownOptions{
+		"Built-in layout": {Notes: []string{"First", "Second"}},
+		"Template":        {Template: tmpl},
+		"Body file":       {BodyBase: "## Summary\n\n" + planBodyMarker + "\n\n\n"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mdFile, err := createMarkdown("plan.md", "No changes. Your infrastructure matches.  \n", "terraform", opts)
+			require.NoError(t, err)
+
+			got, err := os.ReadFile(mdFile)
+			require.NoError(t, err)
+			require.True(t, strings.HasSuffix(string(got), "\n"), string(got))
+			require.False(t, strings.HasSuffix(string(got), "\n\n"), string(got))
+			require.NotRegexp(t, `(?m)[ \t]$`, string(got))
+		})
+	}
+
+	t.Run("Raw whitespace is written as rendered", func(t *testing.T) {
+		mdFile, err := createMarkdown("plan.md", "No changes.", "terraform", markdownOptions{
+			Template:      tmpl,
+			RawWhitespace: true,
+		})
+		require.NoError(t, err)
+
+		got, err := os.ReadFile(mdFile)
+		require.NoError(t, err)
+		require.Equal(t, "No changes.   \n", string(got))
+	})
+}
@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// markdownSettings maps the [markdown] keys to the top-level parameters and
+// flags they replace
+var markdownSettings = []struct{ key, param, flag string }{
+	{"groupByModule", "groupByModule", "group-by-module"},
+	{"showDrift", "showDrift", "show-drift"},
+	{"showOutputs", "showOutputs", "show-outputs"},
+	{"includeJson", "includeJson", "include-json"},
+	{"includeCommand", "includeCommand", "include-command"},
+	{"template", "mdTemplate", "md-template"},
+}
+
+// loadMarkdownParams reads and validates the [markdown] table. Unknown keys
+// are an error, so a typo doesn't silently leave an option off.
+func loadMarkdownParams() (MarkdownParams, error) {
+	var params MarkdownParams
+	if !viper.IsSet("markdown") {
+		return params, nil
+	}
+	err := viper.UnmarshalKey("markdown", &params, func(c *mapstructure.DecoderConfig) {
+		c.ErrorUnused = true
+	})
+	if err != nil {
+		return params, fmt.Errorf("invalid [markdown] config: %w", err)
+	}
+	if err = validateParams(params); err != nil {
+		return params, fmt.Errorf("invalid [markdown] config: %w", err)
+	}
+	return params, nil
+}
+
+// applyMarkdownConfig loads the [markdown] table and sets the top-level
+// parameters from it, unless their flag was passed, so the rest of the run
+// reads them as before.
+//
+// Parameters:
+//
+//	cmd - The command whose flags take precedence.
+//
+// Returns:
+//
+//	MarkdownParams - The [markdown] table, for the options without a top-level parameter.
+//	error - An error if the table is invalid.
+func applyMarkdownConfig(cmd *cobra.Command) (MarkdownParams, error) {
+	params, err := loadMarkdownParams()
+	if err != nil {
+		return params, err
+	}
+	for _, s := range markdownSettings {
+		key := "markdown." + s.key
+		if !viper.IsSet(key) {
+			continue
+		}
+		if cmd.Flags().Changed(s.flag) {
+			Logger.Debugf("Ignoring %s from the config, --%s was given", key, s.flag)
+			continue
+		}
+		if viper.InConfig(s.param) {
+			Logger.Warnf("Both %s and %s are set in the config, using %s", s.param, key, key)
+		}
+		Logger.Debugf("Using %s from the config: %v", key, viper.Get(key))
+		viper.Set(s.param, viper.Get(key))
+	}
+	return params, nil
+}
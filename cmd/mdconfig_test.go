@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+// newMarkdownFlagsCmd returns a command with the flags [markdown] replaces,
+// bound like the root command's.
+func newMarkdownFlagsCmd(t *testing.T) *cobra.Command {
+	t.Helper()
+	cmd := &cobra.Command{}
+	cmd.Flags().Bool("group-by-module", false, "")
+	cmd.Flags().Bool("show-drift", false, "")
+	cmd.Flags().Bool("show-outputs", false, "")
+	cmd.Flags().Bool("include-json", false, "")
+	cmd.Flags().Bool("include-command", false, "")
+	cmd.Flags().String("md-template", "", "")
+	for _, s := range markdownSettings {
+		require.NoError(t, viper.BindPFlag(s.param, cmd.Flags().Lookup(s.flag)))
+	}
+	return cmd
+}
+
+func TestApplyMarkdownConfig(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	t.Run("Nested values drive the renderer", func(t *testing.T) {
+		plan := loadPlanFixture(t, "changes.json")
+		planText, err := os.ReadFile(filepath.Join("..", "testdata", "plans", "changes.txt"))
+		require.NoError(t, err)
+		loadConfig(t, `groupByModule = false
+
+[markdown]
+syntax = "diff"
+groupByModule = true
+`)
+		cmd := newMarkdownFlagsCmd(t)
+		t.Chdir(t.TempDir())
+
+		params, err := applyMarkdownConfig(cmd)
+		require.NoError(t, err)
+		require.True(t, viper.GetBool("groupByModule"), "[markdown] takes precedence over the top-level key")
+
+		mdFile, err := createMarkdown("plan.md", string(planText), "terraform", markdownOptions{
+			Plan:          plan,
+			GroupByModule: viper.GetBool("groupByModule"),
+			Syntax:        SyntaxHighlight(params.Syntax),
+		})
+		require.NoError(t, err)
+		got, err := os.ReadFile(mdFile)
+		require.NoError(t, err)
+		require.Contains(t, string(got), "<summary>Terraform plan: module.network (2 resources)</summary>")
+		require.Contains(t, string(got), "```diff\n")
+		require.NotContains(t, string(got), "```terraform")
+	})
+
+	t.Run("Top-level keys still work", func(t *testing.T) {
+		loadConfig(t, "showDrift = true\n")
+		cmd := newMarkdownFlagsCmd(t)
+
+		params, err := applyMarkdownConfig(cmd)
+
+		require.NoError(t, err)
+		require.True(t, viper.GetBool("showDrift"))
+		require.Equal(t, MarkdownParams{}, params)
+	})
+
+	t.Run("Flags take precedence", func(t *testing.T) {
+		loadConfig(t, "[markdown]\nincludeCommand = true\ntemplate = 'pr.tmpl'\n")
+		cmd := newMarkdownFlagsCmd(t)
+		require.NoError(t, cmd.Flags().Set("include-command", "false"))
+
+		_, err := applyMarkdownConfig(cmd)
+
+		require.NoError(t, err)
+		require.False(t, viper.GetBool("includeCommand"))
+		require.Equal(t, "pr.tmpl", viper.GetString("mdTemplate"))
+	})
+
+	testCases := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"Unknown syntax", "[markdown]\nsyntax = 'yaml'\n", "Field: Syntax, Error: oneof"},
+		{"Unknown key", "[markdown]\ngroupByModules = true\n", "groupbymodules"},
+		{"Wrong type", "[markdown]\nshowDrift = 'sometimes'\n", "ShowDrift"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			loadConfig(t, tc.config)
+
+			_, err := applyMarkdownConfig(newMarkdownFlagsCmd(t))
+
+			require.ErrorContains(t, err, "invalid [markdown] config")
+			require.ErrorContains(t, err, tc.wantErr)
+		})
+	}
+}
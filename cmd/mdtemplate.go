@@ -0,0 +1,170 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxTemplateBytes bounds the size of a --md-template file
+	maxTemplateBytes = 64 * 1024
+	// maxTemplateOutputBytes bounds the Markdown a template renders, a runaway
+	// template shouldn't fill the disk
+	maxTemplateOutputBytes = 4 * 1024 * 1024
+)
+
+// errTemplateOutputTooLarge is returned when a template renders more than maxTemplateOutputBytes
+var errTemplateOutputTooLarge = fmt.Errorf("template output exceeds %d bytes", maxTemplateOutputBytes)
+
+// planSummaryLine matches the summary line of the plan output
+var planSummaryLine = regexp.MustCompile(`(?m)^(?:Plan: .+|No changes\..*)$`)
+
+// markdownTemplateData is what a --md-template is executed with.
+type markdownTemplateData struct {
+	Plan        string            // The plan output, redacted and normalized like the built-in Markdown
+	Summary     string            // The "Plan:" line, e.g. "Plan: 2 to add, 1 to change, 2 to destroy."
+	Binary      string            // The binary used, "terraform" or "tofu"
+	Version     string            // The binary's version, --binary-version if set. Empty when unknown
+	Workspace   string            // The workspace of the plan
+	Environment string            // The 'environment' label, empty when unset
+	Warnings    []string          // The notes the built-in Markdown renders above the plan
+	Date        time.Time         // When the Markdown was rendered, in UTC. Zero with --deterministic
+	Sections    []templateSection // The plan of each directory with --dir, one entry otherwise
+	Details     string            // The plan as the built-in Markdown renders it
+}
+
+// templateSection is the plan of one directory, for templates.
+type templateSection struct {
+	Dir     string // Directory of the plan, empty for a single plan
+	Plan    string // The plan output
+	Summary string // The "Plan:" line
+}
+
+// loadMarkdownTemplate reads and compiles a --md-template, so that a broken
+// template fails the run before planning.
+//
+// Parameters:
+//
+//	path - The path of the template file.
+//
+// Returns:
+//
+//	*template.Template - The compiled template.
+//	error - An error if the file can't be read or doesn't compile.
+func loadMarkdownTemplate(path string) (*template.Template, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'md-template' (%q): %w", path, err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("invalid 'md-template' (%q): not a regular file", path)
+	}
+	if info.Size() > maxTemplateBytes {
+		return nil, fmt.Errorf("invalid 'md-template' (%q): larger than %d bytes", path, maxTemplateBytes)
+	}
+	data, err := os.ReadFile(path) //nolint:gosec // explicitly provided by the user
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'md-template' (%q): %w", path, err)
+	}
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("invalid 'md-template' (%q): file is not valid UTF-8", path)
+	}
+	// A misspelled field is an error rather than an empty string
+	tmpl, err := template.New(filepath.Base(path)).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'md-template' (%q): %w", path, err)
+	}
+	Logger.Debugf("Using Markdown template: %s", path)
+	return tmpl, nil
+}
+
+// newTemplateData gathers what the template is executed with.
+//
+// Parameters:
+//
+//	planStr - The human-readable plan output.
+//	binaryName - The binary used.
+//	details - The plan rendered by the built-in renderer.
+//	opts - The rendering options.
+//
+// Returns:
+//
+//	markdownTemplateData - The template's data.
+func newTemplateData(planStr, binaryName, details string, opts markdownOptions) markdownTemplateData {
+	data := markdownTemplateData{
+		Binary:      binaryKind(binaryName),
+		Version:     reportedVersion(opts.BinaryVersion, sectionPlans(planSections(planStr, opts))...),
+		Workspace:   currentWorkspace("."),
+		Environment: opts.Environment,
+		Warnings:    opts.Notes,
+		Details:     details,
+	}
+	if !opts.Deterministic {
+		data.Date = time.Now().UTC()
+	}
+
+	texts := []string{}
+	for _, section := range planSections(planStr, opts) {
+		text := sectionText(section, opts)
+		data.Sections = append(data.Sections, templateSection{
+			Dir:     section.Dir,
+			Plan:    text,
+			Summary: planSummaryLine.FindString(text),
+		})
+		texts = append(texts, text)
+	}
+	data.Plan = strings.Join(texts, "\n\n")
+	if len(data.Sections) == 1 {
+		data.Summary = data.Sections[0].Summary
+	}
+	return data
+}
+
+// renderMarkdownTemplate executes tmpl, stopping once it has rendered
+// maxTemplateOutputBytes.
+//
+// Parameters:
+//
+//	tmpl - The compiled template.
+//	data - The template's data.
+//
+// Returns:
+//
+//	string - The rendered Markdown.
+//	error - Any error executing the template, including too much or no output.
+func renderMarkdownTemplate(tmpl *template.Template, data markdownTemplateData) (string, error) {
+	w := &limitedBuilder{limit: maxTemplateOutputBytes}
+	if err := tmpl.Execute(w, data); err != nil {
+		if errors.Is(err, errTemplateOutputTooLarge) {
+			return "", fmt.Errorf("'md-template' %s: %w", tmpl.Name(), errTemplateOutputTooLarge)
+		}
+		return "", fmt.Errorf("'md-template' %s failed: %w", tmpl.Name(), err)
+	}
+	out := strings.TrimRight(w.String(), "\n")
+	if strings.TrimSpace(out) == "" {
+		return "", fmt.Errorf("'md-template' %s rendered an empty document", tmpl.Name())
+	}
+	return out, nil
+}
+
+// limitedBuilder is a strings.Builder that refuses writes past limit.
+type limitedBuilder struct {
+	strings.Builder
+	limit int
+}
+
+func (b *limitedBuilder) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, errTemplateOutputTooLarge
+	}
+	return b.Builder.Write(p)
+}
@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMarkdownTemplate(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	dir := t.TempDir()
+	write := func(t *testing.T, name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	t.Run("Valid template", func(t *testing.T) {
+		tmpl, err := loadMarkdownTemplate(write(t, "valid.tmpl", "## {{ .Summary }}\n"))
+
+		require.NoError(t, err)
+		require.Equal(t, "valid.tmpl", tmpl.Name())
+	})
+
+	testCases := []struct {
+		name    string
+		path    func(t *testing.T) string
+		wantErr string
+	}{
+		{"Missing file", func(*testing.T) string { return filepath.Join(dir, "missing.tmpl") }, "no such file"},
+		{"Directory", func(*testing.T) string { return dir }, "not a regular file"},
+		{"Does not compile", func(t *testing.T) string { return write(t, "broken.tmpl", "{{ .Plan ") }, "unclosed action"},
+		{"Not UTF-8", func(t *testing.T) string { return write(t, "binary.tmpl", "\xff\xfe") }, "not valid UTF-8"},
+		{
+			"Too large",
+			func(t *testing.T) string { return write(t, "large.tmpl", strings.Repeat("a", maxTemplateBytes+1)) },
+			"larger than",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := loadMarkdownTemplate(tc.path(t))
+
+			require.ErrorContains(t, err, "invalid 'md-template'")
+			require.ErrorContains(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestRenderMarkdownTemplate(t *testing.T) {
+	data := markdownTemplateData{
+		Plan:      "  + resource \"aws_instance\" \"web\" {}\n\nPlan: 1 to add, 0 to change, 0 to destroy.",
+		Summary:   "Plan: 1 to add, 0 to change, 0 to destroy.",
+		Binary:    "tofu",
+		Workspace: "staging",
+		Warnings:  []string{"Excluded from this plan: `aws_s3_bucket.logs`."},
+		Date:      time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC),
+		Sections:  []templateSection{{Plan: "...", Summary: "Plan: 1 to add, 0 to change, 0 to destroy."}},
+	}
+
+	t.Run("Custom template", func(t *testing.T) {
+		tmpl := template.Must(template.New("custom").Option("missingkey=error").Parse(
+			"## {{ .Binary }} plan for `{{ .Workspace }}` ({{ .Date.Format \"2006-01-02\" }})\n\n" +
+				"{{ range .Warnings }}- {{ . }}\n{{ end }}\n**{{ .Summary }}**\n\n```\n{{ .Plan }}\n```\n",
+		))
+
+		got, err := renderMarkdownTemplate(tmpl, data)
+
+		require.NoError(t, err)
+		require.Equal(t, "## tofu plan for `staging` (2026-10-15)\n\n"+
+			"- Excluded from this plan: `aws_s3_bucket.logs`.\n\n"+
+			"**Plan: 1 to add, 0 to change, 0 to destroy.**\n\n"+
+			"```\n  + resource \"aws_instance\" \"web\" {}\n\nPlan: 1 to add, 0 to change, 0 to destroy.\n```", got)
+	})
+
+	t.Run("Unknown field", func(t *testing.T) {
+		tmpl := template.Must(template.New("typo").Parse("{{ .Summery }}"))
+
+		_, err := renderMarkdownTemplate(tmpl, data)
+
+		require.ErrorContains(t, err, "'md-template' typo failed")
+	})
+
+	t.Run("Empty output", func(t *testing.T) {
+		tmpl := template.Must(template.New("empty").Parse("{{ if false }}x{{ end }}\n"))
+
+		_, err := renderMarkdownTemplate(tmpl, data)
+
+		require.ErrorContains(t, err, "rendered an empty document")
+	})
+
+	t.Run("Output is bounded", func(t *testing.T) {
+		tmpl := template.Must(template.New("runaway").Parse("{{ .Plan }}{{ .Plan }}"))
+		large := data
+		large.Plan = strings.Repeat("x", maxTemplateOutputBytes/2+1)
+
+		_, err := renderMarkdownTemplate(tmpl, large)
+
+		require.ErrorIs(t, err, errTemplateOutputTooLarge)
+	})
+}
+
+func TestCreateMarkdownTemplate(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	plan := loadPlanFixture(t, "changes.json")
+	planText, err := os.ReadFile(filepath.Join("..", "testdata", "plans", "changes.txt"))
+	require.NoError(t, err)
+	t.Chdir(t.TempDir())
+	t.Setenv("TF_WORKSPACE", "prod")
+
+	tmpl := template.Must(template.New("custom").Option("missingkey=error").Parse(
+		"# {{ .Binary }} / {{ .Workspace }}\n\n{{ .Summary }}\n\n{{ .Details }}\n",
+	))
+	mdFile, err := createMarkdown("plan.md", string(planText), "terraform", markdownOptions{
+		Plan:          plan,
+		Deterministic: true,
+		Template:      tmpl,
+	})
+	require.NoError(t, err)
+
+	got, err := os.ReadFile(mdFile)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(got),
+		"# terraform / prod\n\nPlan: 2 to add, 1 to change, 2 to destroy.\n\n📋 2 to add, 1 to change, 2 to destroy\n\n<details><summary>Terraform plan</summary>",
+	), string(got))
+}
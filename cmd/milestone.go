@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// errMilestoneNotFound is returned when 'milestone' names no open milestone
+var errMilestoneNotFound = errors.New("milestone not found")
+
+// defaultMilestoneClient is the MilestoneClient used outside of tests
+var defaultMilestoneClient MilestoneClient = &RealMilestoneClient{runner: defaultGhRunner}
+
+// MilestoneClient is an interface for looking up milestones and setting them on pull requests
+// This allows for dependency injection and easier testing
+type MilestoneClient interface {
+	MilestoneTitle(ctx context.Context, number int) (string, error)
+	OpenMilestones(ctx context.Context) ([]string, error)
+	SetMilestone(ctx context.Context, prURL, title string) error
+}
+
+// RealMilestoneClient implements the MilestoneClient interface with 'gh api' and 'gh pr edit'
+type RealMilestoneClient struct {
+	runner GhRunner
+}
+
+// MilestoneTitle returns the title of the repository's milestone with the given number.
+func (c *RealMilestoneClient) MilestoneTitle(ctx context.Context, number int) (string, error) {
+	out, err := c.runner.Run(ctx, "api", fmt.Sprintf("repos/{owner}/{repo}/milestones/%d", number), "--jq", ".title")
+	if err != nil {
+		if strings.Contains(err.Error(), "HTTP 404") {
+			return "", fmt.Errorf("%w: no milestone #%d", errMilestoneNotFound, number)
+		}
+		return "", fmt.Errorf("unable to look up milestone #%d: %w", number, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// OpenMilestones returns the titles of the repository's open milestones.
+func (c *RealMilestoneClient) OpenMilestones(ctx context.Context) ([]string, error) {
+	out, err := c.runner.Run(
+		ctx,
+		"api", "--paginate",
+		"repos/{owner}/{repo}/milestones?state=open&per_page=100",
+		"--jq", ".[].title",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list milestones: %w", err)
+	}
+	var titles []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			titles = append(titles, line)
+		}
+	}
+	return titles, nil
+}
+
+// SetMilestone sets the milestone with the given title on a pull request.
+func (c *RealMilestoneClient) SetMilestone(ctx context.Context, prURL, title string) error {
+	if _, err := c.runner.Run(ctx, "pr", "edit", prURL, "--milestone", title); err != nil {
+		return fmt.Errorf("unable to set milestone %q on %s: %w", title, prURL, err)
+	}
+	return nil
+}
+
+// resolveMilestone returns the title of the milestone 'milestone' refers to,
+// by number, e.g. 7, or by title, e.g. "Q3 networking".
+//
+// Parameters:
+//
+//	ctx - The context controlling the requests
+//	client - The MilestoneClient used
+//	ref - The milestone number or title
+//
+// Returns:
+//
+//	string - The milestone's title
+//	error - errMilestoneNotFound naming the open milestones, or any other error encountered
+func resolveMilestone(ctx context.Context, client MilestoneClient, ref string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	if number, err := strconv.Atoi(strings.TrimPrefix(ref, "#")); err == nil && number > 0 {
+		return client.MilestoneTitle(ctx, number)
+	}
+	titles, err := client.OpenMilestones(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, title := range titles {
+		if title == ref {
+			return title, nil
+		}
+	}
+	for _, title := range titles {
+		if strings.EqualFold(title, ref) {
+			return title, nil
+		}
+	}
+	if len(titles) == 0 {
+		return "", fmt.Errorf("%w: %q, the repository has no open milestones", errMilestoneNotFound, ref)
+	}
+	return "", fmt.Errorf(
+		"%w: %q, open milestones are %s",
+		errMilestoneNotFound,
+		ref,
+		strings.Join(titles, ", "),
+	)
+}
+
+// setMilestone sets the milestone 'milestone' refers to on a newly created
+// pull request.
+//
+// Parameters:
+//
+//	ctx - The context controlling the requests
+//	client - The MilestoneClient used
+//	prURL - The URL of the pull request
+//	ref - The milestone number or title
+//
+// Returns:
+//
+//	error - Any error encountered resolving or setting the milestone
+func setMilestone(ctx context.Context, client MilestoneClient, prURL, ref string) error {
+	title, err := resolveMilestone(ctx, client, ref)
+	if err != nil {
+		return err
+	}
+	if err = client.SetMilestone(ctx, prURL, title); err != nil {
+		return err
+	}
+	Logger.Infof("Milestone %q set on %s", title, prURL)
+	return nil
+}
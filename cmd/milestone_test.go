@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockMilestoneClient is a mock implementation of MilestoneClient
+type MockMilestoneClient struct {
+	mock.Mock
+}
+
+func (m *MockMilestoneClient) MilestoneTitle(ctx context.Context, number int) (string, error) {
+	called := m.Called(ctx, number)
+	return called.String(0), called.Error(1)
+}
+
+func (m *MockMilestoneClient) OpenMilestones(ctx context.Context) ([]string, error) {
+	called := m.Called(ctx)
+	titles, _ := called.Get(0).([]string)
+	return titles, called.Error(1)
+}
+
+func (m *MockMilestoneClient) SetMilestone(ctx context.Context, prURL, title string) error {
+	return m.Called(ctx, prURL, title).Error(0)
+}
+
+func TestSetMilestone(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	const prURL = "https://github.com/o/r/pull/7"
+
+	t.Run("By number", func(t *testing.T) {
+		client := new(MockMilestoneClient)
+		client.On("MilestoneTitle", mock.Anything, 3).Return("Q3 networking", nil)
+		client.On("SetMilestone", mock.Anything, prURL, "Q3 networking").Return(nil)
+
+		require.NoError(t, setMilestone(context.Background(), client, prURL, "#3"))
+
+		client.AssertExpectations(t)
+		client.AssertNotCalled(t, "OpenMilestones", mock.Anything)
+	})
+
+	t.Run("By title", func(t *testing.T) {
+		client := new(MockMilestoneClient)
+		client.On("OpenMilestones", mock.Anything).Return([]string{"Q2 cleanup", "Q3 networking"}, nil)
+		client.On("SetMilestone", mock.Anything, prURL, "Q3 networking").Return(nil)
+
+		require.NoError(t, setMilestone(context.Background(), client, prURL, "q3 Networking"))
+
+		client.AssertExpectations(t)
+	})
+
+	t.Run("Unknown title", func(t *testing.T) {
+		client := new(MockMilestoneClient)
+		client.On("OpenMilestones", mock.Anything).Return([]string{"Q2 cleanup", "Q3 networking"}, nil)
+
+		err := setMilestone(context.Background(), client, prURL, "Q4")
+
+		require.ErrorIs(t, err, errMilestoneNotFound)
+		require.ErrorContains(t, err, `"Q4", open milestones are Q2 cleanup, Q3 networking`)
+		client.AssertNotCalled(t, "SetMilestone", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestRealMilestoneClient(t *testing.T) {
+	t.Run("Unknown number", func(t *testing.T) {
+		runner := new(MockGhRunner)
+		runner.On("Run", mock.Anything, []string{"api", "repos/{owner}/{repo}/milestones/9", "--jq", ".title"}).
+			Return(nil, errors.New("gh api: exit status 1: Not Found (HTTP 404)"))
+
+		_, err := (&RealMilestoneClient{runner: runner}).MilestoneTitle(context.Background(), 9)
+
+		require.ErrorIs(t, err, errMilestoneNotFound)
+	})
+
+	t.Run("Open milestones", func(t *testing.T) {
+		runner := new(MockGhRunner)
+		runner.On("Run", mock.Anything, []string{
+			"api", "--paginate", "repos/{owner}/{repo}/milestones?state=open&per_page=100", "--jq", ".[].title",
+		}).Return([]byte("Q2 cleanup\nQ3 networking\n"), nil)
+
+		titles, err := (&RealMilestoneClient{runner: runner}).OpenMilestones(context.Background())
+
+		require.NoError(t, err)
+		require.Equal(t, []string{"Q2 cleanup", "Q3 networking"}, titles)
+	})
+}
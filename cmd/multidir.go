@@ -0,0 +1,339 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// maxDefaultConcurrency caps the default number of parallel plans, as each
+// plan also refreshes state against the providers' APIs
+const maxDefaultConcurrency = 4
+
+// defaultConcurrency returns the default for --concurrency.
+func defaultConcurrency() int {
+	return min(runtime.NumCPU(), maxDefaultConcurrency)
+}
+
+// planFunc plans one directory, createPlan outside of tests.
+type planFunc func(ctx context.Context, dir string) (planResult, error)
+
+// planConcurrency reads and validates the 'concurrency' parameter.
+func planConcurrency() (int, error) {
+	n := viper.GetInt("concurrency")
+	if n < 1 {
+		return 0, fmt.Errorf("invalid 'concurrency' (%d): must be at least 1", n)
+	}
+	return n, nil
+}
+
+// defaultIgnorePatterns are never discovered as plan directories: provider
+// and module caches, VCS metadata, and modules and examples that aren't
+// planned on their own
+var defaultIgnorePatterns = []string{".terraform", ".git", "modules", "examples"}
+
+// ignorePatterns returns defaultIgnorePatterns followed by the 'ignore'
+// patterns, which must be valid globs.
+func ignorePatterns() ([]string, error) {
+	patterns := slices.Clone(defaultIgnorePatterns)
+	for _, p := range viper.GetStringSlice("ignore") {
+		p = filepath.ToSlash(filepath.Clean(p))
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid 'ignore' pattern %q: %w", p, err)
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// ignoredDir returns the pattern matching rel, the slash-separated path of a
+// directory relative to the discovery root. A pattern matches the directory's
+// name, e.g. "modules", or its whole path, e.g. "stacks/legacy" or "envs/*".
+func ignoredDir(rel string, patterns []string) (string, bool) {
+	name := path.Base(rel)
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return p, true
+		}
+		if ok, _ := path.Match(p, rel); ok {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// discoverPlanDirs finds the directories under root containing .tf or .tofu
+// files, for --discover. Ignored directories are skipped with everything
+// below them.
+//
+// Parameters:
+//
+//	root - The directory to search.
+//	ignore - The patterns from ignorePatterns.
+//
+// Returns:
+//
+//	[]string - The directories found, in lexical order.
+//	error - Any error encountered walking root.
+func discoverPlanDirs(root string, ignore []string) ([]string, error) {
+	var dirs []string
+	err := filepath.WalkDir(root, func(dir string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if dir != root {
+			rel, relErr := filepath.Rel(root, dir)
+			if relErr != nil {
+				return relErr
+			}
+			if pattern, ok := ignoredDir(filepath.ToSlash(rel), ignore); ok {
+				Logger.Debugf("Skipping %s, it matches the ignore pattern %q", dir, pattern)
+				return fs.SkipDir
+			}
+		}
+		if checkFilesByExtension(dir, []string{".tf", ".tofu"}) {
+			dirs = append(dirs, dir)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover plan directories under %s: %w", root, err)
+	}
+	Logger.Debugf("Discovered %d plan directories: %v", len(dirs), dirs)
+	return dirs, nil
+}
+
+// changedStacks returns the stacks owning a changed file, for
+// --changed-dirs-from-git: the deepest stack directory containing it, or the
+// stacks calling the local module it's in. The Terraform files of the
+// current directory in no stack, e.g. a root .tfvars or the lock file, are
+// returned apart: they can change any stack. Files outside of the current
+// directory that no stack calls are ignored.
+//
+// Parameters:
+//
+//	files - The changed files from changedFiles, slash-separated.
+//	stacks - The stack directories, relative to the current directory.
+//
+// Returns:
+//
+//	[]string - The changed stacks, in the order of stacks.
+//	[]string - The changed Terraform files in no stack, see isTFFile.
+func changedStacks(files, stacks []string) ([]string, []string) {
+	modules := make(map[string][]string, len(stacks))
+	for _, stack := range stacks {
+		modules[stack] = moduleSourceDirs(stack)
+	}
+	changed := make(map[string]bool, len(stacks))
+	var unowned []string
+	for _, file := range files {
+		owner, depth := "", -1
+		for _, stack := range stacks {
+			dir, d := filepath.ToSlash(filepath.Clean(stack)), 0
+			if dir != "." {
+				if !strings.HasPrefix(file, dir+"/") {
+					continue
+				}
+				d = strings.Count(dir, "/") + 1
+			} else if strings.HasPrefix(file, "../") {
+				continue
+			}
+			if d > depth {
+				owner, depth = stack, d
+			}
+		}
+		if owner != "" {
+			changed[owner] = true
+			continue
+		}
+		callers := 0
+		for _, stack := range stacks {
+			if inAnyDir(file, modules[stack]) {
+				changed[stack] = true
+				callers++
+			}
+		}
+		switch {
+		case callers > 0:
+		case isTFFile(file) && !strings.HasPrefix(file, "../"):
+			unowned = append(unowned, file)
+		default:
+			Logger.Debugf("%s changed but is in no stack", file)
+		}
+	}
+
+	var dirs []string
+	for _, stack := range stacks {
+		if changed[stack] {
+			dirs = append(dirs, stack)
+		}
+	}
+	return dirs, unowned
+}
+
+// configuredStacks returns the 'stacks' directories of the config file, which
+// are planned when no directory is passed with --dir or --discover. Each
+// must exist, a stack removed from the repository but not from the config
+// shouldn't silently go unplanned.
+func configuredStacks() ([]string, error) {
+	stacks := viper.GetStringSlice("stacks")
+	for _, stack := range stacks {
+		info, err := os.Stat(stack)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'stacks' entry %q: %w", stack, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("invalid 'stacks' entry %q: not a directory", stack)
+		}
+	}
+	if len(stacks) > 0 {
+		Logger.Debugf("Planning the configured stacks: %v", stacks)
+	}
+	return stacks, nil
+}
+
+// validatePlanDirs checks the directories passed with --dir before any plan
+// starts: each must be a directory containing .tf or .tofu files, must not be
+// the filesystem root or home directory, and must be listed only once.
+//
+// Parameters:
+//
+//	dirs - The directories to plan.
+//	allowDangerous - Whether --allow-dangerous-dir was passed.
+//
+// Returns:
+//
+//	[]string - The cleaned directories, in the order given.
+//	error - An error describing the first invalid directory.
+func validatePlanDirs(dirs []string, allowDangerous bool) ([]string, error) {
+	if viper.GetString("generateConfigOut") != "" {
+		return nil, errors.New("'generate-config-out' can't be used with several directories")
+	}
+	if viper.GetBool("attachPlan") {
+		return nil, errors.New("'attach-plan' can't be used with several directories")
+	}
+
+	homeDir, _ := os.UserHomeDir()
+	seen := make(map[string]bool, len(dirs))
+	cleaned := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		dir = filepath.Clean(dir)
+		if seen[dir] {
+			return nil, fmt.Errorf("invalid 'dir' (%q): listed more than once", dir)
+		}
+		seen[dir] = true
+
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'dir' (%q): %w", dir, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("invalid 'dir' (%q): not a directory", dir)
+		}
+		if !allowDangerous {
+			abs, absErr := filepath.Abs(dir)
+			if absErr != nil {
+				return nil, fmt.Errorf("invalid 'dir' (%q): %w", dir, absErr)
+			}
+			if err = checkDangerousDir(abs, homeDir); err != nil {
+				return nil, err
+			}
+		}
+		if !checkFilesByExtension(dir, []string{".tf", ".tofu"}) {
+			return nil, fmt.Errorf("invalid 'dir' (%q): no .tf or .tofu files found", dir)
+		}
+		cleaned = append(cleaned, dir)
+	}
+	return cleaned, nil
+}
+
+// runPlans plans each directory with at most concurrency plans running at
+// once. The first failure cancels the plans still running and no new plans
+// start once ctx is done.
+//
+// Parameters:
+//
+//	ctx - The context for all plans, cancelled on interrupt.
+//	dirs - The directories to plan.
+//	concurrency - The maximum number of plans running at once.
+//	plan - The function planning one directory.
+//
+// Returns:
+//
+//	[]planResult - The results in the order of dirs.
+//	error - The first error encountered, ErrInterrupted if ctx was cancelled, or
+//	  the deadline error if the run's deadline passed.
+func runPlans(ctx context.Context, dirs []string, concurrency int, plan planFunc) ([]planResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]planResult, len(dirs))
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	sem := make(chan struct{}, concurrency)
+	for i, dir := range dirs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			Logger.Debugf("Planning %s...", dir)
+			result, err := plan(ctx, dir)
+			if err != nil {
+				if !errors.Is(err, ErrInterrupted) {
+					err = fmt.Errorf("plan in %s failed: %w", dir, err)
+				}
+				fail(err)
+				return
+			}
+			result.Dir = dir
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	// A plan cancelled by the deadline fails, report the deadline instead
+	if cause := context.Cause(ctx); errors.Is(cause, ErrDeadlineExceeded) {
+		return nil, cause
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if ctx.Err() != nil {
+		return nil, ErrInterrupted
+	}
+	return results, nil
+}
@@ -0,0 +1,356 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunPlans(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	dirs := []string{"a", "b", "c", "d", "e", "f", "g"}
+
+	t.Run("Concurrency is bounded and results keep their order", func(t *testing.T) {
+		var running, peak atomic.Int32
+		var mu sync.Mutex
+		planned := map[string]bool{}
+		plan := func(_ context.Context, dir string) (planResult, error) {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			planned[dir] = true
+			mu.Unlock()
+			return planResult{Text: "plan of " + dir}, nil
+		}
+
+		results, err := runPlans(context.Background(), dirs, 2, plan)
+		require.NoError(t, err)
+		require.LessOrEqual(t, peak.Load(), int32(2))
+		require.Len(t, planned, len(dirs))
+		for i, dir := range dirs {
+			require.Equal(t, dir, results[i].Dir)
+			require.Equal(t, "plan of "+dir, results[i].Text)
+		}
+	})
+
+	t.Run("First failure cancels the remaining plans", func(t *testing.T) {
+		var started atomic.Int32
+		plan := func(ctx context.Context, dir string) (planResult, error) {
+			started.Add(1)
+			if dir == "a" {
+				return planResult{}, errors.New("boom")
+			}
+			<-ctx.Done()
+			return planResult{}, ctx.Err()
+		}
+
+		_, err := runPlans(context.Background(), dirs, 2, plan)
+		require.ErrorContains(t, err, "plan in a failed: boom")
+		require.Less(t, started.Load(), int32(len(dirs)), "no new plans start after a failure")
+	})
+
+	t.Run("Interrupt stops new plans", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var started atomic.Int32
+		plan := func(ctx context.Context, _ string) (planResult, error) {
+			if started.Add(1) == 1 {
+				cancel()
+			}
+			<-ctx.Done()
+			return planResult{}, ErrInterrupted
+		}
+
+		_, err := runPlans(ctx, dirs, 1, plan)
+		require.ErrorIs(t, err, ErrInterrupted)
+		require.Equal(t, int32(1), started.Load())
+	})
+}
+
+func TestPlanConcurrency(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	tests := []struct {
+		value   int
+		wantErr bool
+	}{
+		{1, false},
+		{8, false},
+		{0, true},
+		{-2, true},
+	}
+	for _, tt := range tests {
+		viper.Set("concurrency", tt.value)
+		n, err := planConcurrency()
+		if tt.wantErr {
+			require.ErrorContains(t, err, "must be at least 1")
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, tt.value, n)
+	}
+	require.GreaterOrEqual(t, defaultConcurrency(), 1)
+	require.LessOrEqual(t, defaultConcurrency(), maxDefaultConcurrency)
+}
+
+func TestValidatePlanDirs(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	t.Cleanup(viper.Reset)
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.MkdirAll("network", 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join("network", "main.tf"), nil, 0o600))
+	require.NoError(t, os.MkdirAll("empty", 0o750))
+	require.NoError(t, os.WriteFile("file.tf", nil, 0o600))
+
+	dirs, err := validatePlanDirs([]string{"network/", "."}, false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"network", "."}, dirs)
+
+	tests := []struct {
+		name string
+		dirs []string
+		want string
+	}{
+		{"Missing", []string{"missing"}, "no such file or directory"},
+		{"File", []string{"file.tf"}, "not a directory"},
+		{"No configuration", []string{"empty"}, "no .tf or .tofu files found"},
+		{"Duplicate", []string{"network", "./network"}, "listed more than once"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := validatePlanDirs(tt.dirs, false)
+			require.ErrorContains(t, err, tt.want)
+		})
+	}
+
+	t.Run("Incompatible options", func(t *testing.T) {
+		viper.Set("attachPlan", true)
+		defer viper.Set("attachPlan", false)
+		_, err := validatePlanDirs([]string{"network"}, false)
+		require.ErrorContains(t, err, "'attach-plan' can't be used with several directories")
+	})
+}
+
+func TestDiscoverPlanDirs(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	root := t.TempDir()
+	for _, file := range []string{
+		"stacks/network/main.tf",
+		"stacks/app/main.tofu",
+		"stacks/app/.terraform/modules/vpc/main.tf",
+		"stacks/legacy/main.tf",
+		"modules/vpc/main.tf",
+		"examples/basic/main.tf",
+		".git/hooks/main.tf",
+		"docs/README.md",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(file))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+		require.NoError(t, os.WriteFile(path, nil, 0o600))
+	}
+
+	t.Run("Defaults skip caches, modules and examples", func(t *testing.T) {
+		dirs, err := discoverPlanDirs(root, defaultIgnorePatterns)
+
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			filepath.Join(root, "stacks", "app"),
+			filepath.Join(root, "stacks", "legacy"),
+			filepath.Join(root, "stacks", "network"),
+		}, dirs)
+	})
+
+	t.Run("Ignore patterns match names and paths", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		viper.Set("ignore", []string{"stacks/legacy", "netw*"})
+		patterns, err := ignorePatterns()
+		require.NoError(t, err)
+
+		dirs, err := discoverPlanDirs(root, patterns)
+
+		require.NoError(t, err)
+		require.Equal(t, []string{filepath.Join(root, "stacks", "app")}, dirs)
+	})
+
+	t.Run("Invalid pattern", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		viper.Set("ignore", []string{"stacks/["})
+
+		_, err := ignorePatterns()
+
+		require.ErrorContains(t, err, "invalid 'ignore' pattern")
+	})
+}
+
+func TestChangedStacks(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	stacks := []string{"stacks/network", "stacks/network/peering", "stacks/db", "stacks/dns"}
+
+	changed, unowned := changedStacks([]string{
+		"stacks/db/main.tf",
+		"stacks/network/peering/main.tf",
+		"stacks/network/variables.tf",
+		"stacks/db/README.md",
+		"stacks/dnsx/main.tf",
+		"README.md",
+	}, stacks)
+	require.Equal(
+		t,
+		[]string{"stacks/network", "stacks/network/peering", "stacks/db"},
+		changed,
+		"the deepest stack owns a file, in the order of the stacks",
+	)
+	require.Equal(t, []string{"stacks/dnsx/main.tf"}, unowned)
+
+	changed, unowned = changedStacks(
+		[]string{"modules/vpc/main.tf", "prod.tfvars", ".terraform.lock.hcl", "README.md"},
+		stacks,
+	)
+	require.Empty(t, changed)
+	require.Equal(
+		t,
+		[]string{"modules/vpc/main.tf", "prod.tfvars", ".terraform.lock.hcl"},
+		unowned,
+		"shared Terraform files can change any stack",
+	)
+
+	changed, unowned = changedStacks([]string{"README.md", "modules/vpc/main.tf"}, []string{".", "stacks/db"})
+	require.Equal(t, []string{"."}, changed, "the root stack owns the files of no other")
+	require.Empty(t, unowned)
+
+	t.Run("Stacks calling a changed module", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		for _, dir := range []string{"stacks/network", "stacks/db"} {
+			require.NoError(t, os.MkdirAll(dir, 0o750))
+		}
+		require.NoError(t, os.WriteFile(
+			filepath.Join("stacks", "network", "main.tf"),
+			[]byte("module \"vpc\" {\n  source = \"../../modules/vpc\"\n}\n"),
+			0o600,
+		))
+		require.NoError(t, os.WriteFile(filepath.Join("stacks", "db", "main.tf"), nil, 0o600))
+
+		changed, unowned := changedStacks(
+			[]string{"modules/vpc/main.tf", "../shared/main.tf"},
+			[]string{"stacks/network", "stacks/db"},
+		)
+
+		require.Equal(t, []string{"stacks/network"}, changed)
+		require.Empty(t, unowned, "files outside of the current directory no stack calls are ignored")
+	})
+
+	t.Run("Only the changed stacks are planned", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		for _, dir := range []string{"stacks/network", "stacks/db", "stacks/dns", "modules/vpc"} {
+			require.NoError(t, os.MkdirAll(dir, 0o750))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), nil, 0o600))
+		}
+		git := new(MockGitRunner)
+		git.On("Run", mock.Anything, []string{"rev-parse", "--show-prefix"}).Return([]byte("\n"), nil)
+		git.On("Run", mock.Anything, []string{"diff", "--name-only", "--no-relative", "origin/main...HEAD"}).
+			Return([]byte("stacks/dns/records.tf\nstacks/network/main.tf\nREADME.md\n"), nil)
+
+		patterns, err := ignorePatterns()
+		require.NoError(t, err)
+		discovered, err := discoverPlanDirs(".", patterns)
+		require.NoError(t, err)
+		files, err := changedFiles(context.Background(), git, "origin/main")
+		require.NoError(t, err)
+		changed, unowned := changedStacks(files, discovered)
+		require.Empty(t, unowned)
+		dirs, err := validatePlanDirs(changed, false)
+		require.NoError(t, err)
+
+		var planned []string
+		var mu sync.Mutex
+		_, err = runPlans(context.Background(), dirs, 1, func(_ context.Context, dir string) (planResult, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			planned = append(planned, dir)
+			return planResult{}, nil
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, []string{filepath.Join("stacks", "dns"), filepath.Join("stacks", "network")}, planned)
+	})
+}
+
+func TestConfiguredStacks(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	t.Chdir(t.TempDir())
+	for _, dir := range []string{"infra/net", "infra/db"} {
+		require.NoError(t, os.MkdirAll(dir, 0o750))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), nil, 0o600))
+	}
+	require.NoError(t, os.WriteFile("main.tf", nil, 0o600))
+
+	t.Run("Stacks drive the multi-directory run", func(t *testing.T) {
+		loadConfig(t, "stacks = [\"infra/net\", \"infra/db\"]\n")
+		stacks, err := configuredStacks()
+		require.NoError(t, err)
+		dirs, err := validatePlanDirs(stacks, false)
+		require.NoError(t, err)
+
+		var planned []string
+		var mu sync.Mutex
+		_, err = runPlans(context.Background(), dirs, 1, func(_ context.Context, dir string) (planResult, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			planned = append(planned, dir)
+			return planResult{}, nil
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, []string{filepath.Join("infra", "net"), filepath.Join("infra", "db")}, planned)
+	})
+
+	t.Run("No stacks", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		stacks, err := configuredStacks()
+		require.NoError(t, err)
+		require.Empty(t, stacks)
+	})
+
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"Missing", "stacks = [\"infra/net\", \"infra/app\"]\n", "invalid 'stacks' entry \"infra/app\""},
+		{"File", "stacks = [\"main.tf\"]\n", "not a directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loadConfig(t, tt.config)
+			_, err := configuredStacks()
+			require.ErrorContains(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// notifyTimeout bounds the webhook request so a slow receiver can't stall CI
+	notifyTimeout = 10 * time.Second
+	// maxNotifyPayloadBytes bounds the JSON sent to the webhook
+	maxNotifyPayloadBytes = 8 * 1024
+	// maxNotifyFieldLength bounds each free-form string in the payload
+	maxNotifyFieldLength = 256
+)
+
+// notifyPayload is the JSON posted to --notify-webhook. Text makes it usable
+// as-is with Slack incoming webhooks, which ignore the other fields.
+type notifyPayload struct {
+	Text    string        `json:"text"`
+	Repo    string        `json:"repo,omitempty"`
+	Branch  string        `json:"branch,omitempty"`
+	Changes *changeCounts `json:"changes,omitempty"`
+	PRURL   string        `json:"pr_url,omitempty"`
+}
+
+// validateWebhookURL checks that raw is an absolute https URL.
+func validateWebhookURL(raw string) error {
+	// Don't echo the URL, webhook URLs usually embed a secret
+	u, err := url.Parse(raw)
+	if err != nil {
+		return errors.New("invalid 'notify-webhook': not a valid URL")
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return errors.New("invalid 'notify-webhook': must be an https:// URL")
+	}
+	return nil
+}
+
+// newNotifyPayload gathers what the notification reports. The repository and
+// branch are best-effort: they come from the GitHub Actions environment when
+// set, otherwise from git and gh, and are left out when unavailable.
+//
+// Parameters:
+//
+//	ctx - The context for the git and gh commands.
+//	git - The GitRunner used to read the branch.
+//	gh - The GhRunner used to read the repository.
+//	changes - The change counts, nil when the plan wasn't structured.
+//	prURL - The URL of the pull request, if one was created.
+//
+// Returns:
+//
+//	notifyPayload - The payload to send.
+func newNotifyPayload(
+	ctx context.Context,
+	git GitRunner,
+	gh GhRunner,
+	changes *changeCounts,
+	prURL string,
+) notifyPayload {
+	repo := os.Getenv("GITHUB_REPOSITORY")
+	if repo == "" {
+		if out, err := gh.Run(ctx, "repo", "view", "--json", "nameWithOwner", "--jq", ".nameWithOwner"); err == nil {
+			repo = strings.TrimSpace(string(out))
+		}
+	}
+	branch := currentBranch(ctx, git)
+
+	p := notifyPayload{
+		Repo:    truncateField(repo),
+		Branch:  truncateField(branch),
+		Changes: changes,
+		PRURL:   truncateField(prURL),
+	}
+	p.Text = notifyText(p)
+	return p
+}
+
+// notifyText renders the human-readable message of the payload.
+func notifyText(p notifyPayload) string {
+	var sb strings.Builder
+	sb.WriteString("tp: plan")
+	if p.Repo != "" {
+		sb.WriteString(" for " + p.Repo)
+	}
+	if p.Branch != "" {
+		sb.WriteString(" on " + p.Branch)
+	}
+	if p.Changes != nil {
+		fmt.Fprintf(
+			&sb,
+			": %d to add, %d to change, %d to destroy",
+			p.Changes.Add,
+			p.Changes.Change,
+			p.Changes.Destroy,
+		)
+		if p.Changes.Import > 0 {
+			fmt.Fprintf(&sb, ", %d to import", p.Changes.Import)
+		}
+	}
+	if p.PRURL != "" {
+		sb.WriteString(" " + p.PRURL)
+	}
+	return sb.String()
+}
+
+// truncateField shortens s to maxNotifyFieldLength bytes at a rune boundary.
+func truncateField(s string) string {
+	if len(s) <= maxNotifyFieldLength {
+		return s
+	}
+	cut := maxNotifyFieldLength
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut]
+}
+
+// sendNotification posts the payload to the webhook.
+//
+// Parameters:
+//
+//	ctx - The context for the request, bounded by notifyTimeout.
+//	client - The HTTP client, http.DefaultClient if nil.
+//	webhook - The validated webhook URL.
+//	payload - The payload to send.
+//
+// Returns:
+//
+//	error - Any error encountered, including a non-2xx response.
+func sendNotification(ctx context.Context, client *http.Client, webhook string, payload notifyPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+	if len(body) > maxNotifyPayloadBytes {
+		return fmt.Errorf("notification payload is %d bytes, over the %d byte limit", len(body), maxNotifyPayloadBytes)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return errors.New("failed to create notification request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// The error embeds the URL, which usually contains a secret
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notification webhook returned %s", resp.Status)
+	}
+	Logger.Debug("Notification sent.")
+	return nil
+}
@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateWebhookURL(t *testing.T) {
+	require.NoError(t, validateWebhookURL("https://hooks.slack.com/services/T000/B000/XXXX"))
+	for _, raw := range []string{"http://example.com/hook", "hooks.slack.com/services", "https://", "ftp://example.com", "://bad"} {
+		err := validateWebhookURL(raw)
+		require.Error(t, err, raw)
+		require.NotContains(t, err.Error(), "example.com", "the URL must not be echoed")
+	}
+}
+
+func TestSendNotification(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	t.Setenv("GITHUB_REPOSITORY", "")
+	t.Setenv("GITHUB_HEAD_REF", "")
+
+	git := new(MockGitRunner)
+	git.On("Run", mock.Anything, []string{"rev-parse", "--abbrev-ref", "HEAD"}).
+		Return([]byte("feature/vpc\n"), nil)
+	gh := new(MockGhRunner)
+	gh.On("Run", mock.Anything, []string{"repo", "view", "--json", "nameWithOwner", "--jq", ".nameWithOwner"}).
+		Return([]byte("octo/infra\n"), nil)
+	changes := countChanges(loadPlanFixture(t, "changes.json"))
+	payload := newNotifyPayload(context.Background(), git, gh, &changes, "https://github.com/octo/infra/pull/7")
+
+	var got map[string]any
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(body, &got))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	require.NoError(t, sendNotification(context.Background(), server.Client(), server.URL+"/hook", payload))
+	require.Equal(t, map[string]any{
+		"text":   "tp: plan for octo/infra on feature/vpc: 2 to add, 1 to change, 2 to destroy https://github.com/octo/infra/pull/7",
+		"repo":   "octo/infra",
+		"branch": "feature/vpc",
+		"changes": map[string]any{
+			"add": float64(2), "change": float64(1), "destroy": float64(2), "import": float64(0),
+		},
+		"pr_url": "https://github.com/octo/infra/pull/7",
+	}, got)
+	git.AssertExpectations(t)
+	gh.AssertExpectations(t)
+}
+
+func TestSendNotificationErrors(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	t.Setenv("GITHUB_REPOSITORY", "octo/infra")
+	t.Setenv("GITHUB_HEAD_REF", "main")
+
+	t.Run("Environment is used without git or gh", func(t *testing.T) {
+		git, gh := new(MockGitRunner), new(MockGhRunner)
+		payload := newNotifyPayload(context.Background(), git, gh, nil, "")
+		require.Equal(t, notifyPayload{Text: "tp: plan for octo/infra on main", Repo: "octo/infra", Branch: "main"}, payload)
+		git.AssertNotCalled(t, "Run")
+		gh.AssertNotCalled(t, "Run")
+	})
+
+	t.Run("Best-effort fields are left out", func(t *testing.T) {
+		t.Setenv("GITHUB_REPOSITORY", "")
+		t.Setenv("GITHUB_HEAD_REF", "")
+		git, gh := new(MockGitRunner), new(MockGhRunner)
+		git.On("Run", mock.Anything, mock.Anything).Return(nil, errors.New("not a git repository"))
+		gh.On("Run", mock.Anything, mock.Anything).Return(nil, errors.New("no remote"))
+		payload := newNotifyPayload(context.Background(), git, gh, nil, "")
+		require.Equal(t, notifyPayload{Text: "tp: plan"}, payload)
+	})
+
+	t.Run("Non-2xx response", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		}))
+		defer server.Close()
+		err := sendNotification(context.Background(), server.Client(), server.URL, notifyPayload{Text: "tp: plan"})
+		require.ErrorContains(t, err, "403 Forbidden")
+	})
+
+	t.Run("Connection errors don't leak the URL", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.NotFoundHandler())
+		webhook := server.URL + "/services/SECRET"
+		server.Close()
+		err := sendNotification(context.Background(), server.Client(), webhook, notifyPayload{Text: "tp: plan"})
+		require.Error(t, err)
+		require.NotContains(t, err.Error(), "SECRET")
+	})
+
+	t.Run("Fields are bounded", func(t *testing.T) {
+		long := strings.Repeat("é", maxNotifyFieldLength)
+		require.LessOrEqual(t, len(truncateField(long)), maxNotifyFieldLength)
+		require.True(t, strings.HasPrefix(long, truncateField(long)))
+	})
+}
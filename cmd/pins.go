@@ -0,0 +1,191 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// Matches an exact version such as 1.5.7 or 1.6.0-rc1, as opposed to
+// constraints like "latest" or "latest:^1.5" that tfenv also accepts
+var exactVersion = regexp.MustCompile(`^v?\d+\.\d+\.\d+(-[0-9A-Za-z.]+)?$`)
+
+// versionPin is a binary version pinned by a version manager file.
+type versionPin struct {
+	Binary  string // "terraform" or "tofu"
+	Version string // The pinned version, empty when not an exact version
+	Source  string // The file the pin was read from
+}
+
+// pinFiles are the version manager files we read and the binary they pin.
+// .tool-versions is asdf's, and can pin either binary.
+var pinFiles = []struct{ name, binary string }{
+	{".opentofu-version", "tofu"},       // tofuenv
+	{".terraform-version", "terraform"}, // tfenv
+	{".tool-versions", ""},              // asdf
+}
+
+// readVersionPins reads the version manager files in dir, or in its nearest
+// ancestor containing any, the way tfenv and asdf search for them. Reading is
+// best-effort: unreadable or malformed files are skipped.
+//
+// Parameters:
+//
+//	dir - The directory the plan runs in.
+//
+// Returns:
+//
+//	[]versionPin - The pins found, in the order of pinFiles, or nil.
+func readVersionPins(dir string) []versionPin {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+	for {
+		var pins []versionPin
+		for _, pf := range pinFiles {
+			path := filepath.Join(abs, pf.name)
+			data, readErr := os.ReadFile(path) //nolint:gosec // fixed file names in the plan's directory tree
+			if readErr != nil {
+				continue
+			}
+			if pf.binary != "" {
+				pins = append(pins, parseVersionFile(path, pf.binary, string(data))...)
+			} else {
+				pins = append(pins, parseToolVersions(path, string(data))...)
+			}
+		}
+		if len(pins) > 0 {
+			return pins
+		}
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return nil
+		}
+		abs = parent
+	}
+}
+
+// parseVersionFile parses a tfenv or tofuenv file, whose first line is the version.
+func parseVersionFile(path, binaryName, content string) []versionPin {
+	line, _, _ := strings.Cut(strings.TrimSpace(content), "\n")
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+	Logger.Debugf("%s pins %s %s", path, binaryName, line)
+	return []versionPin{{Binary: binaryName, Version: pinnedVersion(line), Source: path}}
+}
+
+// parseToolVersions parses an asdf .tool-versions file, e.g.
+//
+//	terraform 1.5.7
+//	opentofu 1.6.2 # comment
+//
+// Only the terraform and opentofu plugins are read. When a line lists several
+// versions, asdf uses the first.
+func parseToolVersions(path, content string) []versionPin {
+	var pins []versionPin
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 { //nolint:mnd
+			continue
+		}
+		var binaryName string
+		switch fields[0] {
+		case "terraform":
+			binaryName = "terraform"
+		case "opentofu", "tofu":
+			binaryName = "tofu"
+		default:
+			continue
+		}
+		Logger.Debugf("%s pins %s %s", path, binaryName, fields[1])
+		pins = append(pins, versionPin{Binary: binaryName, Version: pinnedVersion(fields[1]), Source: path})
+	}
+	return pins
+}
+
+// pinnedVersion returns v without a leading "v" if it is an exact version, or "".
+func pinnedVersion(v string) string {
+	if !exactVersion.MatchString(v) {
+		return ""
+	}
+	return strings.TrimPrefix(v, "v")
+}
+
+// parseBinaryVersion validates 'binaryVersion', the version reported in the
+// Markdown instead of the one that made the plan.
+func parseBinaryVersion(v string) (string, error) {
+	reported := pinnedVersion(strings.TrimSpace(v))
+	if reported == "" {
+		return "", fmt.Errorf("invalid 'binary-version' (%q): expected a version such as 1.9.5", v)
+	}
+	return reported, nil
+}
+
+// reportedVersion returns the binary version reported in the Markdown: the
+// 'binaryVersion' override if set, or the version that made the first of
+// plans, which may be nil.
+func reportedVersion(override string, plans ...*tfjson.Plan) string {
+	if override != "" {
+		return override
+	}
+	for _, plan := range plans {
+		if plan != nil && plan.TerraformVersion != "" {
+			return strings.TrimPrefix(plan.TerraformVersion, "v")
+		}
+	}
+	return ""
+}
+
+// pinnedBinary returns the binary the pins agree on, or "" when there are no
+// pins or they name both binaries.
+func pinnedBinary(pins []versionPin) string {
+	binaryName := ""
+	for _, p := range pins {
+		if binaryName != "" && p.Binary != binaryName {
+			return ""
+		}
+		binaryName = p.Binary
+	}
+	return binaryName
+}
+
+// pinnedVersionWarning compares the installed version of binaryName with its
+// pins.
+//
+// Parameters:
+//
+//	binaryName - The binary used for the plan, "terraform" or "tofu".
+//	installed - The version the binary reports.
+//	pins - The pins from readVersionPins.
+//
+// Returns:
+//
+//	string - A warning naming the pin file if a pinned version differs, or "".
+func pinnedVersionWarning(binaryName, installed string, pins []versionPin) string {
+	installed = strings.TrimPrefix(installed, "v")
+	for _, p := range pins {
+		if p.Binary != binaryName || p.Version == "" || p.Version == installed {
+			continue
+		}
+		return fmt.Sprintf(
+			"%s pins %s %s, but %s is installed. The plan may differ from one made with the pinned version.",
+			filepath.Base(p.Source),
+			binaryName,
+			p.Version,
+			installed,
+		)
+	}
+	return ""
+}
@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadVersionPins(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	fixtures := filepath.Join("..", "testdata", "versions")
+
+	tests := []struct {
+		name       string
+		dir        string
+		wantPins   []versionPin
+		wantBinary string
+	}{
+		{
+			name:       "asdf opentofu",
+			dir:        "asdf-tofu",
+			wantPins:   []versionPin{{Binary: "tofu", Version: "1.8.5"}},
+			wantBinary: "tofu",
+		},
+		{
+			name: "asdf both binaries",
+			dir:  "asdf-both",
+			wantPins: []versionPin{
+				{Binary: "terraform", Version: "1.9.8"},
+				{Binary: "tofu", Version: "1.8.5"},
+			},
+			wantBinary: "",
+		},
+		{
+			name:       "tfenv",
+			dir:        "tfenv",
+			wantPins:   []versionPin{{Binary: "terraform", Version: "1.5.7"}},
+			wantBinary: "terraform",
+		},
+		{
+			name:       "tfenv in a parent directory",
+			dir:        filepath.Join("tfenv", "modules", "network"),
+			wantPins:   []versionPin{{Binary: "terraform", Version: "1.5.7"}},
+			wantBinary: "terraform",
+		},
+		{
+			name:       "constraint is not an exact version",
+			dir:        "constraint",
+			wantPins:   []versionPin{{Binary: "terraform", Version: ""}},
+			wantBinary: "terraform",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pins := readVersionPins(filepath.Join(fixtures, tt.dir))
+			require.Len(t, pins, len(tt.wantPins))
+			for i, want := range tt.wantPins {
+				require.Equal(t, want.Binary, pins[i].Binary)
+				require.Equal(t, want.Version, pins[i].Version)
+				require.NotEmpty(t, pins[i].Source)
+			}
+			require.Equal(t, tt.wantBinary, pinnedBinary(pins))
+		})
+	}
+
+	t.Run("No pins", func(t *testing.T) {
+		require.Empty(t, readVersionPins(t.TempDir()))
+		require.Empty(t, pinnedBinary(nil))
+	})
+}
+
+func TestPinnedVersionWarning(t *testing.T) {
+	pins := []versionPin{
+		{Binary: "terraform", Version: "1.9.8", Source: "/repo/.tool-versions"},
+		{Binary: "tofu", Version: "", Source: "/repo/.opentofu-version"},
+	}
+
+	require.Empty(t, pinnedVersionWarning("terraform", "1.9.8", pins))
+	require.Empty(t, pinnedVersionWarning("terraform", "v1.9.8", pins))
+	require.Empty(t, pinnedVersionWarning("tofu", "1.8.5", pins), "constraints are not checked")
+	require.Equal(
+		t,
+		".tool-versions pins terraform 1.9.8, but 1.10.0 is installed. The plan may differ from one made with the pinned version.",
+		pinnedVersionWarning("terraform", "1.10.0", pins),
+	)
+}
+
+func TestReportedVersion(t *testing.T) {
+	plan := loadPlanFixture(t, "changes.json")
+
+	t.Run("The override replaces the detected version", func(t *testing.T) {
+		v, err := parseBinaryVersion("v1.5.7")
+		require.NoError(t, err)
+
+		require.Equal(t, "1.5.7", reportedVersion(v, plan))
+		require.Equal(t, plan.TerraformVersion, reportedVersion("", nil, plan))
+		require.Empty(t, reportedVersion("", nil))
+	})
+
+	t.Run("Versions must be exact", func(t *testing.T) {
+		for _, v := range []string{"latest", "1.5", "~> 1.5.0", ""} {
+			_, err := parseBinaryVersion(v)
+			require.ErrorContains(t, err, "invalid 'binary-version'", v)
+		}
+	})
+
+	t.Run("The override is recorded in the Markdown metadata", func(t *testing.T) {
+		if Logger == nil {
+			Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+		}
+		t.Chdir(t.TempDir())
+
+		mdFile, err := createMarkdown("plan.md", "No changes.", "tofu", markdownOptions{
+			Plan:          plan,
+			BinaryVersion: "1.5.7",
+		})
+		require.NoError(t, err)
+
+		got, err := os.ReadFile(mdFile)
+		require.NoError(t, err)
+		require.Contains(t, string(got), "\n<!-- gh-tp:binary-version tofu 1.5.7 -->\n")
+		require.NotContains(t, string(got), plan.TerraformVersion)
+
+		data := newTemplateData("No changes.", "tofu", "", markdownOptions{Plan: plan, BinaryVersion: "1.5.7"})
+		require.Equal(t, "1.5.7", data.Version)
+	})
+
+	t.Run("Without an override the detected version is recorded", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+
+		mdFile, err := createMarkdown("plan.md", "No changes.", "tofu", markdownOptions{Plan: plan})
+		require.NoError(t, err)
+
+		got, err := os.ReadFile(mdFile)
+		require.NoError(t, err)
+		require.Contains(t, string(got), "\n<!-- gh-tp:binary-version tofu "+plan.TerraformVersion+" -->\n")
+
+		mdFile, err = createMarkdown("plan.md", "No changes.", "tofu", markdownOptions{})
+		require.NoError(t, err)
+
+		got, err = os.ReadFile(mdFile)
+		require.NoError(t, err)
+		require.NotContains(t, string(got), "gh-tp:binary-version", "no version is known without a structured plan")
+
+		data := newTemplateData("No changes.", "tofu", "", markdownOptions{Plan: plan})
+		require.Equal(t, plan.TerraformVersion, data.Version)
+	})
+}
@@ -0,0 +1,203 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// planHasChanges reports whether the structured plan would change any
+// resource or output. Data source reads and no-op resources are not changes,
+// but importing an existing resource is.
+//
+// Parameters:
+//
+//	plan - The structured plan from 'show -json'.
+//
+// Returns:
+//
+//	bool - true if applying the plan would change infrastructure or outputs.
+func planHasChanges(plan *tfjson.Plan) bool {
+	for _, rc := range plan.ResourceChanges {
+		if rc.Change == nil {
+			continue
+		}
+		actions := rc.Change.Actions
+		if actions.Read() {
+			continue
+		}
+		if actions.NoOp() && rc.Change.Importing == nil {
+			continue
+		}
+		return true
+	}
+
+	for _, oc := range plan.OutputChanges {
+		if oc != nil && !oc.Actions.NoOp() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// changeCounts are the resource changes of a plan, as in the "Plan:" line.
+type changeCounts struct {
+	Add     int `json:"add"`
+	Change  int `json:"change"`
+	Destroy int `json:"destroy"`
+	Import  int `json:"import"`
+}
+
+// countChanges counts the resource changes of a structured plan the way the
+// "Plan:" line does: a replacement is one add and one destroy.
+func countChanges(plan *tfjson.Plan) changeCounts {
+	var c changeCounts
+	for _, rc := range plan.ResourceChanges {
+		if rc.Change == nil {
+			continue
+		}
+		if rc.Change.Importing != nil {
+			c.Import++
+		}
+		actions := rc.Change.Actions
+		switch {
+		case actions.Replace():
+			c.Add++
+			c.Destroy++
+		case actions.Create():
+			c.Add++
+		case actions.Update():
+			c.Change++
+		case actions.Delete():
+			c.Destroy++
+		}
+	}
+	return c
+}
+
+// plus returns the sum of c and o.
+func (c changeCounts) plus(o changeCounts) changeCounts {
+	return changeCounts{
+		Add:     c.Add + o.Add,
+		Change:  c.Change + o.Change,
+		Destroy: c.Destroy + o.Destroy,
+		Import:  c.Import + o.Import,
+	}
+}
+
+// summary returns the counts as the "Plan:" line words them, e.g. "3 to add,
+// 1 to change, 0 to destroy", with the imports when there are any.
+func (c changeCounts) summary() string {
+	s := fmt.Sprintf("%d to add, %d to change, %d to destroy", c.Add, c.Change, c.Destroy)
+	if c.Import > 0 {
+		s += fmt.Sprintf(", %d to import", c.Import)
+	}
+	return s
+}
+
+// driftedResource is a resource changed outside of Terraform/OpenTofu.
+type driftedResource struct {
+	Address    string   // Address of the resource
+	Change     string   // How it changed: "updated" or "deleted"
+	Attributes []string // Top-level attributes that changed, for updates
+}
+
+// planDrift lists the resources the structured plan reports as changed
+// outside of Terraform/OpenTofu since the last apply. Only attribute names are
+// kept, so sensitive values are never exposed.
+//
+// Parameters:
+//
+//	plan - The structured plan from 'show -json'.
+//
+// Returns:
+//
+//	[]driftedResource - The drifted resources, in plan order.
+func planDrift(plan *tfjson.Plan) []driftedResource {
+	if plan == nil {
+		return nil
+	}
+
+	var drift []driftedResource
+	for _, rc := range plan.ResourceDrift {
+		if rc.Change == nil || rc.Change.Actions.NoOp() || rc.Change.Actions.Read() {
+			continue
+		}
+		d := driftedResource{Address: rc.Address, Change: "updated"}
+		if rc.Change.Actions.Delete() {
+			d.Change = "deleted"
+		} else {
+			d.Attributes = changedAttributes(rc.Change.Before, rc.Change.After)
+		}
+		drift = append(drift, d)
+	}
+	return drift
+}
+
+// outputChange is an output the plan changes.
+type outputChange struct {
+	Name   string // Name of the output
+	Change string // How it changes: "added", "changed" or "removed"
+}
+
+// planOutputChanges lists the outputs the structured plan changes, by name.
+// Only names are kept, so sensitive values are never exposed.
+//
+// Parameters:
+//
+//	plan - The structured plan, or nil.
+//
+// Returns:
+//
+//	[]outputChange - The changed outputs sorted by name, or nil.
+func planOutputChanges(plan *tfjson.Plan) []outputChange {
+	if plan == nil {
+		return nil
+	}
+	names := make([]string, 0, len(plan.OutputChanges))
+	for name := range plan.OutputChanges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var outputs []outputChange
+	for _, name := range names {
+		c := plan.OutputChanges[name]
+		if c == nil || c.Actions.NoOp() || c.Actions.Read() {
+			continue
+		}
+		o := outputChange{Name: name, Change: "changed"}
+		switch {
+		case c.Actions.Create():
+			o.Change = "added"
+		case c.Actions.Delete():
+			o.Change = "removed"
+		}
+		outputs = append(outputs, o)
+	}
+	return outputs
+}
+
+// changedAttributes returns the sorted top-level keys whose values differ.
+func changedAttributes(before, after any) []string {
+	b, _ := before.(map[string]any)
+	a, _ := after.(map[string]any)
+	var changed []string
+	for k, bv := range b {
+		if av, ok := a[k]; !ok || !reflect.DeepEqual(av, bv) {
+			changed = append(changed, k)
+		}
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			changed = append(changed, k)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
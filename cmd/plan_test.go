@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/require"
+)
+
+// loadPlanFixture reads a structured plan from testdata/plans
+func loadPlanFixture(t *testing.T, name string) *tfjson.Plan {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "testdata", "plans", name))
+	require.NoError(t, err)
+	var plan tfjson.Plan
+	require.NoError(t, plan.UnmarshalJSON(data))
+	return &plan
+}
+
+func TestPlanHasChanges(t *testing.T) {
+	t.Run("No changes skips the PR", func(t *testing.T) {
+		plan := loadPlanFixture(t, "no-changes.json")
+
+		require.False(t, planHasChanges(plan))
+	})
+
+	t.Run("Has changes proceeds", func(t *testing.T) {
+		plan := loadPlanFixture(t, "changes.json")
+
+		require.True(t, planHasChanges(plan))
+	})
+
+	t.Run("Output change only is a change", func(t *testing.T) {
+		plan := loadPlanFixture(t, "no-changes.json")
+		plan.OutputChanges["example_id"].Actions = tfjson.Actions{tfjson.ActionUpdate}
+
+		require.True(t, planHasChanges(plan))
+	})
+
+	t.Run("Import is a change", func(t *testing.T) {
+		plan := loadPlanFixture(t, "no-changes.json")
+		plan.ResourceChanges[0].Change.Importing = &tfjson.Importing{ID: "1234567890"}
+
+		require.True(t, planHasChanges(plan))
+	})
+}
+
+func TestPlanDrift(t *testing.T) {
+	t.Run("Updated and deleted resources", func(t *testing.T) {
+		drift := planDrift(loadPlanFixture(t, "drift.json"))
+
+		require.Equal(t, []driftedResource{
+			{
+				Address:    "aws_db_instance.main",
+				Change:     "updated",
+				Attributes: []string{"instance_class", "password", "tags"},
+			},
+			{Address: "module.network.aws_subnet.legacy", Change: "deleted"},
+		}, drift)
+	})
+
+	t.Run("No drift", func(t *testing.T) {
+		require.Empty(t, planDrift(loadPlanFixture(t, "no-changes.json")))
+		require.Empty(t, planDrift(nil))
+	})
+}
+
+func TestCountChanges(t *testing.T) {
+	changes := countChanges(loadPlanFixture(t, "changes.json"))
+	require.Equal(t, changeCounts{Add: 2, Change: 1, Destroy: 2}, changes, "a replacement is an add and a destroy")
+
+	noChanges := loadPlanFixture(t, "no-changes.json")
+	require.Equal(t, changeCounts{}, countChanges(noChanges))
+
+	noChanges.ResourceChanges[0].Change.Importing = &tfjson.Importing{ID: "1234567890"}
+	require.Equal(t, changeCounts{Import: 1}, countChanges(noChanges))
+
+	require.Equal(t, changeCounts{Add: 2, Change: 1, Destroy: 2, Import: 1}, changes.plus(countChanges(noChanges)))
+}
@@ -0,0 +1,219 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	md "github.com/nao1215/markdown"
+)
+
+// maxPlanJSONBytes bounds the JSON plan embedded with --include-json. A pull
+// request body holds at most 65536 characters, so the JSON gets half of it and
+// the plan text the rest.
+const maxPlanJSONBytes = 32 * 1024
+
+// Formats of the plan artifact for --format
+const (
+	planFormatText = "text" // Only the plan file, the default
+	planFormatJSON = "json" // Also the structured plan, see planJSONPath
+)
+
+// parsePlanFormat validates 'planFormat', empty meaning planFormatText.
+func parsePlanFormat(raw string) (string, error) {
+	switch f := strings.ToLower(strings.TrimSpace(raw)); f {
+	case "", planFormatText:
+		return planFormatText, nil
+	case planFormatJSON:
+		return planFormatJSON, nil
+	default:
+		return "", fmt.Errorf("invalid 'format' %q: must be %s or %s", raw, planFormatText, planFormatJSON)
+	}
+}
+
+// planJSONPath returns the file --format json writes the structured plan to:
+// the planFile with a .json extension, e.g. plan.json for plan.out. The plan
+// file itself stays a saved plan, so it can still be applied.
+func planJSONPath(planFile string) string {
+	return strings.TrimSuffix(planFile, filepath.Ext(planFile)) + ".json"
+}
+
+// writePlanJSON writes the structured plan for --format json, redacted like
+// --include-json.
+//
+// Parameters:
+//
+//	path - The file to write, from planJSONPath.
+//	plan - The structured plan, nil when it couldn't be read.
+//	opts - The rendering options, for Redact and Deterministic.
+//
+// Returns:
+//
+//	error - An error if the plan is nil or couldn't be written.
+func writePlanJSON(path string, plan *tfjson.Plan, opts markdownOptions) error {
+	if plan == nil {
+		return fmt.Errorf("unable to write %s: the structured plan could not be read with 'show -json'", path)
+	}
+	text, err := planJSONText(plan, opts)
+	if err != nil {
+		return err
+	}
+	if err = writeOutput(path, text+"\n", opts.FileMode); err != nil {
+		return fmt.Errorf("failed to write plan JSON file %s: %w", path, err)
+	}
+	Logger.Debugf("Wrote %d bytes of plan JSON to %s", len(text)+1, path)
+	return nil
+}
+
+// planJSONText pretty-prints the structured plan. The values the plan marks as
+// sensitive and the values of sensitive variables are always replaced with
+// "(sensitive value)", keeping the JSON valid, as Terraform masks them in its
+// text plan. With redact, the user patterns are then masked as in the plan
+// text.
+//
+// Parameters:
+//
+//	plan - The structured plan.
+//	opts - The rendering options, for Redact and Deterministic.
+//
+// Returns:
+//
+//	string - The indented JSON.
+//	error - Any error encountered marshalling the plan.
+func planJSONText(plan *tfjson.Plan, opts markdownOptions) (string, error) {
+	p := *plan
+	if opts.Deterministic {
+		p.Timestamp = ""
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(&p); err != nil {
+		return "", fmt.Errorf("failed to marshal the JSON plan: %w", err)
+	}
+	text := strings.TrimRight(buf.String(), "\n")
+
+	values := append(sensitivePlanValues(plan), sensitiveVariableValues(plan)...)
+	// Replace longer values first so a value containing another is fully masked
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	placeholder := jsonString(sensitivePlaceholder)
+	var pairs []string
+	for _, v := range values {
+		pairs = append(pairs, jsonString(v), placeholder)
+	}
+	if len(pairs) > 0 {
+		text = strings.NewReplacer(pairs...).Replace(text)
+	}
+	if !opts.Redact {
+		return text, nil
+	}
+	for _, re := range opts.RedactPatterns {
+		text = re.ReplaceAllLiteralString(text, sensitivePlaceholder)
+	}
+	return text, nil
+}
+
+// sensitiveVariableValues collects the string values of the input variables
+// the configuration declares sensitive. Their values are in the JSON plan but
+// not marked there.
+func sensitiveVariableValues(plan *tfjson.Plan) []string {
+	if plan.Config == nil || plan.Config.RootModule == nil {
+		return nil
+	}
+	seen := map[string]struct{}{}
+	for name, v := range plan.Config.RootModule.Variables {
+		if pv, ok := plan.Variables[name]; ok && v != nil && v.Sensitive && pv != nil {
+			collectStrings(pv.Value, seen)
+		}
+	}
+	values := make([]string, 0, len(seen))
+	for v := range seen {
+		values = append(values, v)
+	}
+	return values
+}
+
+// jsonString encodes s as a JSON string the way planJSONText does.
+func jsonString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s) // Encoding a string can't fail
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+// truncatePlanJSON shortens text to at most maxBytes, cutting at a line
+// boundary, and reports whether it was cut.
+func truncatePlanJSON(text string, maxBytes int) (string, bool) {
+	if len(text) <= maxBytes {
+		return text, false
+	}
+	cut := text[:maxBytes]
+	for !utf8.ValidString(cut) {
+		cut = cut[:len(cut)-1]
+	}
+	if i := strings.LastIndex(cut, "\n"); i >= 0 {
+		cut = cut[:i]
+	}
+	return cut, true
+}
+
+// renderPlanJSON renders the structured plan of section in a collapsible "Raw
+// JSON plan" block for --include-json. Plans larger than maxPlanJSONBytes are
+// truncated, with a note saying so. Nothing is rendered without a structured
+// plan.
+//
+// Parameters:
+//
+//	doc - The Markdown document being built.
+//	section - The plan to render.
+//	opts - The rendering options.
+//
+// Returns:
+//
+//	error - Any error encountered marshalling the plan or building the code block.
+func renderPlanJSON(doc *md.Markdown, section planSection, opts markdownOptions) error {
+	if section.Plan == nil {
+		Logger.Debugf("No structured plan to include as JSON%s", inDir(section.Dir))
+		return nil
+	}
+	text, err := planJSONText(section.Plan, opts)
+	if err != nil {
+		return err
+	}
+	size := len(text)
+	text, truncated := truncatePlanJSON(text, maxPlanJSONBytes)
+
+	var sb strings.Builder
+	err = md.NewMarkdown(&sb).CodeBlocks(md.SyntaxHighlight("json"), text).Build()
+	if err != nil {
+		return fmt.Errorf("markdown generation failed (JSON code block): %w", err)
+	}
+	body := "\n" + sb.String() + "\n"
+	if truncated {
+		Logger.Warnf("JSON plan%s truncated to %d of %d bytes", inDir(section.Dir), len(text), size)
+		body += fmt.Sprintf(
+			"\n_Truncated to %d of %d bytes. Run `show -json` on the plan file for the full plan._\n",
+			len(text),
+			size,
+		)
+	}
+	doc.PlainText("").Details("Raw JSON plan"+inDir(section.Dir), body)
+	return nil
+}
+
+// inDir returns " in dir" for the titles of a multi-directory section, or ""
+func inDir(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	return " in " + dir
+}
@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// planURLTimeout bounds downloading the plan from --plan-url
+	planURLTimeout = 2 * time.Minute
+	// maxPlanURLBytes bounds the plan downloaded from --plan-url, before decompression
+	maxPlanURLBytes = 64 * 1024 * 1024
+)
+
+// validatePlanURL checks that raw is an absolute https URL.
+func validatePlanURL(raw string) error {
+	// Don't echo the URL, artifact URLs are often pre-signed
+	u, err := url.Parse(raw)
+	if err != nil {
+		return errors.New("invalid 'plan-url': not a valid URL")
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return errors.New("invalid 'plan-url': must be an https:// URL")
+	}
+	return nil
+}
+
+// fetchPlanURL downloads the plan output from an artifact store.
+//
+// Parameters:
+//
+//	ctx - The context for the request, bounded by planURLTimeout.
+//	client - The HTTP client, http.DefaultClient if nil.
+//	planURL - The validated URL of the plan output.
+//
+// Returns:
+//
+//	[]byte - The plan output as downloaded, possibly gzip compressed.
+//	error - Any error encountered, including a non-200 response, a redirect to http or a plan over maxPlanURLBytes.
+func fetchPlanURL(ctx context.Context, client *http.Client, planURL string) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	// A copy, the caller's client keeps following redirects as it did
+	httpsOnly := *client
+	httpsOnly.CheckRedirect = checkPlanRedirect
+	client = &httpsOnly
+
+	ctx, cancel := context.WithTimeout(ctx, planURLTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, planURL, nil)
+	if err != nil {
+		return nil, errors.New("failed to create the plan request")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// The error embeds the URL, which may be pre-signed
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("failed to download the plan: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download the plan: unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPlanURLBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download the plan: %w", err)
+	}
+	if len(data) > maxPlanURLBytes {
+		return nil, fmt.Errorf("plan from 'plan-url' is larger than %d bytes", maxPlanURLBytes)
+	}
+	Logger.Debugf("Downloaded %d bytes from 'plan-url'", len(data))
+	return data, nil
+}
+
+// maxPlanURLRedirects is how many redirects fetchPlanURL follows, as many as
+// http.Client does by default
+const maxPlanURLRedirects = 10
+
+// checkPlanRedirect is the CheckRedirect of fetchPlanURL: a plan requested
+// over https is only followed to https URLs, so it can't be downgraded to
+// http. Artifact stores commonly redirect to another host, e.g. to blob
+// storage, so the host may change.
+func checkPlanRedirect(req *http.Request, via []*http.Request) error {
+	if req.URL.Scheme != "https" {
+		// Don't echo the URL, it may be pre-signed
+		return fmt.Errorf("refusing a redirect of 'plan-url' to a %s:// URL", req.URL.Scheme)
+	}
+	if len(via) >= maxPlanURLRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxPlanURLRedirects)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatePlanURL(t *testing.T) {
+	require.NoError(t, validatePlanURL("https://artifacts.example.com/plans/plan.txt?sig=secret"))
+	for _, invalid := range []string{
+		"http://artifacts.example.com/plan.txt",
+		"file:///tmp/plan.txt",
+		"https:///plan.txt",
+		"plan.txt",
+		"://",
+	} {
+		err := validatePlanURL(invalid)
+		require.Error(t, err, invalid)
+		require.NotContains(t, err.Error(), "plan.txt", "the URL isn't echoed")
+	}
+}
+
+func TestFetchPlanURL(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	planText, err := os.ReadFile(filepath.Join("..", "testdata", "plans", "changes.txt"))
+	require.NoError(t, err)
+	compressed, err := os.ReadFile(filepath.Join("..", "testdata", "plans", "changes.txt.gz"))
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/plans/changes.txt", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(planText)
+	})
+	mux.HandleFunc("/plans/changes.txt.gz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(compressed)
+	})
+	mux.HandleFunc("/plans/huge.txt", func(w http.ResponseWriter, r *http.Request) {
+		chunk := []byte(strings.Repeat("#", 1024*1024))
+		for range maxPlanURLBytes/len(chunk) + 1 {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+	})
+	plain := httptest.NewServer(mux)
+	t.Cleanup(plain.Close)
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+	mux.HandleFunc("/redirect/https", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, server.URL+"/plans/changes.txt", http.StatusFound)
+	})
+	mux.HandleFunc("/redirect/http", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, plain.URL+"/plans/changes.txt?sig=secret", http.StatusFound)
+	})
+	ctx := context.Background()
+
+	t.Run("Plan text is downloaded", func(t *testing.T) {
+		planURL := server.URL + "/plans/changes.txt"
+		require.NoError(t, validatePlanURL(planURL))
+
+		got, err := fetchPlanURL(ctx, server.Client(), planURL)
+
+		require.NoError(t, err)
+		require.Equal(t, string(planText), string(got))
+	})
+
+	t.Run("Renders like stdin", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		content, err := fetchPlanURL(ctx, server.Client(), server.URL+"/plans/changes.txt.gz")
+		require.NoError(t, err)
+		content, err = decompressPlan("plan-url", content, maxPlanBytes)
+		require.NoError(t, err)
+		plan, err := inputPlan(string(content), "plan-url", false)
+		require.NoError(t, err)
+
+		mdFile, err := createMarkdown("plan.md", plan, "terraform", markdownOptions{})
+		require.NoError(t, err)
+		got, err := os.ReadFile(mdFile)
+		require.NoError(t, err)
+		require.Contains(t, string(got), "<details><summary>Terraform plan</summary>")
+		require.Contains(t, string(got), "Plan: ")
+	})
+
+	t.Run("Redirects to https are followed", func(t *testing.T) {
+		got, err := fetchPlanURL(ctx, server.Client(), server.URL+"/redirect/https")
+
+		require.NoError(t, err)
+		require.Equal(t, string(planText), string(got))
+	})
+
+	t.Run("Redirects to http are refused", func(t *testing.T) {
+		_, err := fetchPlanURL(ctx, server.Client(), server.URL+"/redirect/http?sig=secret")
+
+		require.ErrorContains(t, err, "refusing a redirect of 'plan-url' to a http:// URL")
+		require.NotContains(t, err.Error(), "secret")
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		_, err := fetchPlanURL(ctx, server.Client(), server.URL+"/plans/missing.txt?sig=secret")
+
+		require.EqualError(t, err, "failed to download the plan: unexpected status 404 Not Found")
+	})
+
+	t.Run("Larger than the limit", func(t *testing.T) {
+		_, err := fetchPlanURL(ctx, server.Client(), server.URL+"/plans/huge.txt")
+
+		require.ErrorContains(t, err, "larger than")
+	})
+
+	t.Run("Connection errors don't echo the URL", func(t *testing.T) {
+		_, err := fetchPlanURL(ctx, server.Client(), "https://127.0.0.1:1/plan.txt?sig=secret")
+
+		require.ErrorContains(t, err, "failed to download the plan")
+		require.NotContains(t, err.Error(), "secret")
+	})
+
+	t.Run("Empty plans need allow empty", func(t *testing.T) {
+		_, err := inputPlan("", "plan-url", false)
+		require.EqualError(t, err, "received empty plan from plan-url")
+
+		plan, err := inputPlan("", "plan-url", true)
+		require.NoError(t, err)
+		require.Equal(t, noChangesPlan, plan)
+	})
+}
@@ -0,0 +1,570 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// fallbackBaseBranch is the pull request base used when nothing else applies
+	fallbackBaseBranch = "main"
+	// maxPRTitleLength is the longest pull request title GitHub accepts, in characters
+	maxPRTitleLength = 256
+	// defaultPRBodyMaxBytes is the largest pull request body GitHub accepts,
+	// the default of 'prBodyMaxBytes'
+	defaultPRBodyMaxBytes = 65536
+)
+
+// Matches characters and sequences git doesn't allow in branch names, see
+// git-check-ref-format(1)
+var invalidBranchName = regexp.MustCompile(
+	`[\x00-\x20\x7f~^:?*\[\\]|\.\.|@\{|//|^-|^/|/$|\.$|(^|/)\.|\.lock(/|$)`,
+)
+
+const (
+	// planStartMarker starts the plan in a pull request body tp created, so
+	// --update replaces only the plan
+	planStartMarker = "<!-- gh-tp:start -->"
+	// planEndMarker ends the plan started by planStartMarker
+	planEndMarker = "<!-- gh-tp:end -->"
+)
+
+var (
+	// errPRExists is returned when the branch already has an open pull request
+	errPRExists = errors.New("a pull request already exists for the branch")
+	// errNoPR is returned by --update when the branch has no open pull request
+	errNoPR = errors.New("the branch has no open pull request")
+)
+
+// defaultPRClient is the PRClient used outside of tests
+var defaultPRClient PRClient = &RealPRClient{runner: defaultGhRunner}
+
+// PRClient is an interface for creating and updating pull requests
+// This allows for dependency injection and easier testing
+type PRClient interface {
+	CreatePR(ctx context.Context, pr newPR) (url string, err error)
+	CurrentPR(ctx context.Context) (openPR, error)
+	UpdatePRBody(ctx context.Context, number int, body string) error
+}
+
+// openPR is the open pull request of the current branch, for --update.
+type openPR struct {
+	Number int    `json:"number"` // 0 when the branch has none
+	URL    string `json:"url"`
+	Body   string `json:"body"`
+	State  string `json:"state"`
+}
+
+// newPR is the pull request --create-pr opens for the current branch.
+type newPR struct {
+	Title     string   // Title, from resolvePRTitle
+	Base      string   // Base branch, from resolvePRBase
+	BodyFile  string   // Markdown file of the body, at most 'prBodyMaxBytes'
+	Draft     bool     // Whether to open it as a draft
+	Labels    []string // Labels, e.g. from environmentLabels
+	Reviewers []string // Reviewers to request, users or org/team
+}
+
+// labelExistsMessage is part of the error 'gh label create' returns when the
+// repository already has the label
+const labelExistsMessage = "already exists"
+
+// RealPRClient implements the PRClient interface with 'gh pr create'
+type RealPRClient struct {
+	runner GhRunner
+}
+
+// CreatePR opens a pull request for the current branch
+//
+// Parameters:
+//
+//	ctx - The context controlling the command
+//	pr - The pull request to open
+//
+// Returns:
+//
+//	string - The URL of the pull request, or of the existing one with errPRExists
+//	error - errPRExists if the branch already has one, or any other error encountered
+func (c *RealPRClient) CreatePR(ctx context.Context, pr newPR) (string, error) {
+	args := []string{"pr", "create", "--title", pr.Title, "--body-file", pr.BodyFile, "--base", pr.Base}
+	if pr.Draft {
+		args = append(args, "--draft")
+	}
+	for _, label := range c.ensureLabels(ctx, pr.Labels) {
+		args = append(args, "--label", label)
+	}
+	for _, reviewer := range pr.Reviewers {
+		args = append(args, "--reviewer", reviewer)
+	}
+	out, err := c.runner.Run(ctx, args...)
+	if err != nil {
+		// gh names the existing pull request's URL on the last line
+		if msg := err.Error(); strings.Contains(msg, "already exists") {
+			return lastURL(msg), fmt.Errorf("%w: %w", errPRExists, err)
+		}
+		return "", fmt.Errorf("failed to create the pull request: %w", err)
+	}
+	// gh prints the URL of the new pull request as the last line of its output
+	url := lastURL(string(out))
+	if url == "" {
+		return "", errors.New("gh pr create did not return a URL")
+	}
+	return url, nil
+}
+
+// ensureLabels creates the labels the repository doesn't have yet, since 'gh
+// pr create' fails on a missing label, and returns those it can add. A label
+// it can't create, e.g. without the permission to, is left out with a
+// warning rather than failing the pull request.
+func (c *RealPRClient) ensureLabels(ctx context.Context, labels []string) []string {
+	var existing []string
+	for _, label := range labels {
+		_, err := c.runner.Run(ctx, "label", "create", label, "--description", "Planned by gh tp")
+		switch {
+		case err == nil:
+			Logger.Debugf("Created the label %s", label)
+		case strings.Contains(err.Error(), labelExistsMessage):
+		default:
+			Logger.Warnf("Unable to create the label %s, opening the pull request without it: %v", label, err)
+			continue
+		}
+		existing = append(existing, label)
+	}
+	return existing
+}
+
+// CurrentPR returns the open pull request of the current branch, with a zero
+// Number when it has none.
+func (c *RealPRClient) CurrentPR(ctx context.Context) (openPR, error) {
+	out, err := c.runner.Run(ctx, "pr", "view", "--json", "number,url,body,state")
+	if err != nil {
+		if strings.Contains(err.Error(), noPullRequestMessage) {
+			return openPR{}, nil
+		}
+		return openPR{}, fmt.Errorf("unable to look up the branch's pull request: %w", err)
+	}
+	var pr openPR
+	if err = json.Unmarshal(out, &pr); err != nil {
+		return openPR{}, fmt.Errorf("unable to look up the branch's pull request: %w", err)
+	}
+	// 'pr view' also finds the branch's closed and merged pull requests
+	if pr.State != "OPEN" {
+		Logger.Debugf("The branch's pull request %s is %s", pr.URL, strings.ToLower(pr.State))
+		return openPR{}, nil
+	}
+	return pr, nil
+}
+
+// UpdatePRBody replaces the body of pull request number. The body is read
+// from a file, it can be larger than the command line allows.
+func (c *RealPRClient) UpdatePRBody(ctx context.Context, number int, body string) error {
+	bodyFile, cleanup, err := writePRBody(body)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	_, err = c.runner.Run(
+		ctx,
+		"api", "--method", "PATCH",
+		fmt.Sprintf("repos/{owner}/{repo}/pulls/%d", number),
+		"-F", "body=@"+bodyFile,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update the body of pull request #%d: %w", number, err)
+	}
+	return nil
+}
+
+// lastURL returns the last word of s that is a URL, or "".
+func lastURL(s string) string {
+	fields := strings.Fields(s)
+	for i := len(fields) - 1; i >= 0; i-- {
+		if strings.HasPrefix(fields[i], "https://") || strings.HasPrefix(fields[i], "http://") {
+			return fields[i]
+		}
+	}
+	return ""
+}
+
+// createPR opens the pull request with --create-pr. A branch that already has
+// one is an error suggesting --update, rather than gh's.
+//
+// Parameters:
+//
+//	ctx - The context controlling the command
+//	client - The PRClient used
+//	pr - The pull request to open
+//
+// Returns:
+//
+//	string - The URL of the new pull request
+//	error - errPRExists naming the existing pull request, or any other error encountered
+func createPR(ctx context.Context, client PRClient, pr newPR) (string, error) {
+	url, err := client.CreatePR(ctx, pr)
+	if errors.Is(err, errPRExists) {
+		existing := "the branch's pull request"
+		if url != "" {
+			existing = url
+		}
+		return "", fmt.Errorf("%w: pass --update to update the plan in %s instead", errPRExists, existing)
+	}
+	if err != nil {
+		return "", err
+	}
+	Logger.Infof("Pull request created: %s", url)
+	return url, nil
+}
+
+// maxPRUpdateAttempts is how many times updatePR writes the body when other
+// runs keep changing it
+const maxPRUpdateAttempts = 3
+
+// updatePR replaces the plan in the body of the branch's open pull request
+// with --update, keeping the rest of the body.
+//
+// Runs updating the same pull request at once, e.g. one per prGroupKey
+// section, each read the body before writing it, so one may drop what the
+// other wrote. The body is read again after it's written, and the plan
+// spliced in again while it's missing, up to maxPRUpdateAttempts times.
+//
+// Parameters:
+//
+//	ctx - The context controlling the commands
+//	client - The PRClient used
+//	planBody - Returns the plan for the current body, see spliceBody
+//
+// Returns:
+//
+//	string - The URL of the pull request
+//	error - errNoPR if the branch has no open pull request, or any other error encountered
+func updatePR(ctx context.Context, client PRClient, planBody func(current string) string) (string, error) {
+	pr, err := client.CurrentPR(ctx)
+	if err != nil {
+		return "", err
+	}
+	if pr.Number == 0 {
+		return "", errNoPR
+	}
+	url := pr.URL
+	for attempt := 1; ; attempt++ {
+		body := planBody(pr.Body)
+		if body == pr.Body {
+			break
+		}
+		if attempt > maxPRUpdateAttempts {
+			Logger.Warnf("The body of %s kept changing while tp updated it, its plan may be out of date", url)
+			break
+		}
+		if err = client.UpdatePRBody(ctx, pr.Number, body); err != nil {
+			return "", err
+		}
+		if pr, err = client.CurrentPR(ctx); err != nil {
+			return "", err
+		}
+		if pr.Number == 0 {
+			// Closed in the meantime, the body was updated nonetheless
+			break
+		}
+	}
+	Logger.Infof("Pull request updated: %s", url)
+	return url, nil
+}
+
+// baseRule maps branches starting with Prefix to the Base branch.
+type baseRule struct {
+	Prefix string
+	Base   string
+}
+
+// loadBaseRules reads and validates the 'baseRules' table, which maps branch
+// prefixes to the base branch of their pull requests, e.g.
+//
+//	[baseRules]
+//	"feature/" = "develop"
+//	"hotfix/*" = "main"
+//
+// A trailing "*" is accepted for readability and ignored.
+//
+// Returns:
+//
+//	[]baseRule - The rules, longest prefix first.
+//	error - An error describing the first invalid rule.
+func loadBaseRules() ([]baseRule, error) {
+	table, err := configTable("baseRules")
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]baseRule, 0, len(table))
+	for prefix, base := range table {
+		trimmed := strings.TrimSuffix(prefix, "*")
+		if trimmed == "" {
+			return nil, fmt.Errorf("invalid baseRules prefix %q: must not be empty", prefix)
+		}
+		normalized, normErr := normalizeBranchName(base)
+		if normErr != nil {
+			return nil, fmt.Errorf("invalid baseRules base for prefix %q: %w", prefix, normErr)
+		}
+		rules = append(rules, baseRule{Prefix: trimmed, Base: normalized})
+	}
+
+	// The most specific prefix wins
+	sort.Slice(rules, func(i, j int) bool {
+		if len(rules[i].Prefix) != len(rules[j].Prefix) {
+			return len(rules[i].Prefix) > len(rules[j].Prefix)
+		}
+		return rules[i].Prefix < rules[j].Prefix
+	})
+	return rules, nil
+}
+
+// defaultBranchCache looks up the repository's default branch once per run.
+type defaultBranchCache struct {
+	gh     GhRunner
+	once   sync.Once
+	branch string
+}
+
+// newDefaultBranchCache creates a defaultBranchCache using gh for the lookup.
+func newDefaultBranchCache(gh GhRunner) *defaultBranchCache {
+	return &defaultBranchCache{gh: gh}
+}
+
+// Get returns the default branch from the repository's defaultBranchRef,
+// asking the API only on the first call. If the lookup fails, it warns and
+// returns fallbackBaseBranch.
+func (c *defaultBranchCache) Get(ctx context.Context) string {
+	c.once.Do(func() {
+		c.branch = fallbackBaseBranch
+		out, err := c.gh.Run(ctx, "repo", "view", "--json", "defaultBranchRef", "--jq", ".defaultBranchRef.name")
+		if err != nil {
+			Logger.Warnf("Unable to look up the default branch, using %q: %v", fallbackBaseBranch, err)
+			return
+		}
+		branch, err := normalizeBranchName(string(out))
+		if err != nil {
+			Logger.Warnf("Unexpected default branch, using %q: %v", fallbackBaseBranch, err)
+			return
+		}
+		Logger.Debugf("Repository default branch: %s", branch)
+		c.branch = branch
+	})
+	return c.branch
+}
+
+// resolvePRBase picks the base branch of a pull request from the head branch.
+//
+// Parameters:
+//
+//	explicit - The base passed with --base, which always wins when set.
+//	branch - The head branch of the pull request.
+//	rules - The rules from loadBaseRules, longest prefix first.
+//	fallback - The base used when no rule matches, normally from defaultBranchCache.
+//
+// Returns:
+//
+//	string - The base branch.
+func resolvePRBase(explicit, branch string, rules []baseRule, fallback string) string {
+	if explicit != "" {
+		return explicit
+	}
+	for _, r := range rules {
+		if strings.HasPrefix(branch, r.Prefix) {
+			Logger.Debugf("Branch %q matches baseRules prefix %q, using base %q", branch, r.Prefix, r.Base)
+			return r.Base
+		}
+	}
+	if fallback == "" {
+		return fallbackBaseBranch
+	}
+	return fallback
+}
+
+// normalizeBranchName cleans up a branch name given for the base or head of a
+// pull request: surrounding whitespace and a "refs/heads/" prefix, as copied
+// from git output or CI variables, are removed before it is validated.
+//
+// Parameters:
+//
+//	name - The branch name as given.
+//
+// Returns:
+//
+//	string - The normalized branch name.
+//	error - An error naming the branch if it is not a valid git branch name.
+func normalizeBranchName(name string) (string, error) {
+	normalized := strings.TrimPrefix(strings.TrimSpace(name), "refs/heads/")
+	if !validBranchName(normalized) {
+		return "", fmt.Errorf("%q is not a valid branch name", name)
+	}
+	return normalized, nil
+}
+
+// validBranchName reports whether name is usable as a git branch name.
+func validBranchName(name string) bool {
+	return name != "" && name != "@" && !invalidBranchName.MatchString(name)
+}
+
+// resolvePRTitle picks the title of the pull request.
+//
+// Parameters:
+//
+//	ctx - The context for the git command.
+//	explicit - The title passed with --pr-title, which always wins when set.
+//	fromCommit - Whether to use the subject of the latest commit.
+//	git - The GitRunner used to read the commit.
+//	fallback - The title used otherwise, or when the commit can't be read.
+//
+// Returns:
+//
+//	string - The title, on one line and at most maxPRTitleLength characters.
+func resolvePRTitle(ctx context.Context, explicit string, fromCommit bool, git GitRunner, fallback string) string {
+	title := explicit
+	if title == "" && fromCommit {
+		out, err := git.Run(ctx, "log", "-1", "--format=%s")
+		if err != nil {
+			// Not a git repository, or no commits yet
+			Logger.Warnf("Unable to read the latest commit subject, using %q as the title: %v", fallback, err)
+		} else {
+			title = string(out)
+		}
+	}
+	title = sanitizePRTitle(title)
+	if title == "" {
+		title = sanitizePRTitle(fallback)
+	}
+	return title
+}
+
+// sanitizePRTitle joins the lines of title and truncates it to
+// maxPRTitleLength characters, ending with an ellipsis when truncated.
+func sanitizePRTitle(title string) string {
+	lines := strings.FieldsFunc(title, func(r rune) bool { return r == '\n' || r == '\r' })
+	parts := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			parts = append(parts, line)
+		}
+	}
+	title = strings.Join(parts, " ")
+	if utf8.RuneCountInString(title) <= maxPRTitleLength {
+		return title
+	}
+	runes := []rune(title)
+	return strings.TrimSpace(string(runes[:maxPRTitleLength-1])) + "…"
+}
+
+// loadPRBodyMaxBytes reads and validates 'prBodyMaxBytes', the size the pull
+// request body is truncated to. GitHub's limit is the default, other
+// destinations can set theirs.
+func loadPRBodyMaxBytes() (int, error) {
+	if !viper.IsSet("prBodyMaxBytes") {
+		return defaultPRBodyMaxBytes, nil
+	}
+	maxBytes := viper.GetInt("prBodyMaxBytes")
+	if maxBytes <= 0 {
+		return 0, fmt.Errorf("invalid 'prBodyMaxBytes' (%d): must be positive", maxBytes)
+	}
+	return maxBytes, nil
+}
+
+// truncatePRBody shortens the pull request body to maxBytes with
+// truncateMarkdownNotice, so it's accepted by the destination, ending it with
+// notice from truncationNotice.
+func truncatePRBody(body string, maxBytes int, notice string) string {
+	truncated := truncateMarkdownNotice(body, maxBytes, notice)
+	if len(truncated) < len(body) {
+		Logger.Warnf("Pull request body truncated from %d to %d bytes to fit 'prBodyMaxBytes'", len(body), len(truncated))
+	}
+	return truncated
+}
+
+// planBlock returns the plan between planStartMarker and planEndMarker in
+// body, or "" when body has none.
+func planBlock(body string) string {
+	_, rest, ok := strings.Cut(body, planStartMarker)
+	if !ok {
+		return ""
+	}
+	block, _, ok := strings.Cut(rest, planEndMarker)
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(block)
+}
+
+// spliceBody puts the plan into a pull request body between planStartMarker
+// and planEndMarker: in place of the plan tp put there before, or after the
+// rest of the body the first time. The plan is truncated so the whole body
+// fits in maxBytes, keeping the markers.
+//
+// Parameters:
+//
+//	current - The current body, empty for a new pull request.
+//	planMd - The plan Markdown.
+//	maxBytes - The limit from loadPRBodyMaxBytes.
+//	notice - The notice ending a truncated plan, from truncationNotice.
+//
+// Returns:
+//
+//	string - The body with the plan.
+func spliceBody(current, planMd string, maxBytes int, notice string) string {
+	before, after := splitBody(current)
+	plan := truncatePRBody(strings.TrimSpace(planMd), planRoom(current, maxBytes), notice)
+	return before + planStartMarker + "\n" + plan + "\n" + planEndMarker + after
+}
+
+// splitBody returns the parts of a pull request body before and after the
+// plan spliceBody puts in it.
+func splitBody(current string) (string, string) {
+	before, after := strings.TrimRight(current, "\n"), ""
+	if i := strings.Index(current, planStartMarker); i >= 0 {
+		if j := strings.Index(current[i:], planEndMarker); j >= 0 {
+			before, after = current[:i], current[i+j+len(planEndMarker):]
+		}
+	} else if before != "" {
+		before += "\n\n"
+	}
+	return before, after
+}
+
+// planRoom returns the size the plan can have in current so spliceBody's
+// body fits in maxBytes.
+func planRoom(current string, maxBytes int) int {
+	before, after := splitBody(current)
+	return maxBytes - len(before) - len(after) - len(planStartMarker) - len(planEndMarker) - 2
+}
+
+// writePRBody writes the pull request body to a file for 'gh pr create
+// --body-file', in a temporary directory removed by the returned cleanup.
+func writePRBody(body string) (string, func(), error) {
+	noop := func() {}
+	tmpDir, err := os.MkdirTemp("", "gh-tp-pr-")
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	cleanup := func() {
+		if rmErr := os.RemoveAll(tmpDir); rmErr != nil {
+			Logger.Debugf("Failed to remove %s: %v", tmpDir, rmErr)
+		}
+	}
+	bodyPath := filepath.Join(tmpDir, "body.md")
+	if err = os.WriteFile(bodyPath, []byte(body), 0o600); err != nil {
+		cleanup()
+		return "", noop, fmt.Errorf("failed to write the pull request body: %w", err)
+	}
+	return bodyPath, cleanup, nil
+}
@@ -0,0 +1,692 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockGitRunner is a mock implementation of GitRunner
+type MockGitRunner struct {
+	mock.Mock
+}
+
+func (m *MockGitRunner) Run(ctx context.Context, args ...string) ([]byte, error) {
+	called := m.Called(ctx, args)
+	out, _ := called.Get(0).([]byte)
+	return out, called.Error(1)
+}
+
+// MockPRClient is a mock implementation of PRClient
+type MockPRClient struct {
+	mock.Mock
+}
+
+func (m *MockPRClient) CreatePR(ctx context.Context, pr newPR) (string, error) {
+	called := m.Called(ctx, pr)
+	return called.String(0), called.Error(1)
+}
+
+func (m *MockPRClient) CurrentPR(ctx context.Context) (openPR, error) {
+	called := m.Called(ctx)
+	pr, _ := called.Get(0).(openPR)
+	return pr, called.Error(1)
+}
+
+func (m *MockPRClient) UpdatePRBody(ctx context.Context, number int, body string) error {
+	return m.Called(ctx, number, body).Error(0)
+}
+
+// loadConfig reads content as the config file for the rest of the test.
+func loadConfig(t *testing.T, content string) {
+	t.Helper()
+	t.Cleanup(viper.Reset)
+	cfg := filepath.Join(t.TempDir(), ConfigName)
+	require.NoError(t, os.WriteFile(cfg, []byte(content), 0o600))
+	viper.SetConfigFile(cfg)
+	require.NoError(t, viper.ReadInConfig())
+}
+
+func TestLoadBaseRules(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	t.Run("Rules keep their case and sort longest prefix first", func(t *testing.T) {
+		loadConfig(t, `[baseRules]
+"feature/" = "develop"
+"hotfix/*" = "main"
+"feature/Release-" = "release"
+`)
+
+		rules, err := loadBaseRules()
+
+		require.NoError(t, err)
+		require.Equal(t, []baseRule{
+			{Prefix: "feature/Release-", Base: "release"},
+			{Prefix: "feature/", Base: "develop"},
+			{Prefix: "hotfix/", Base: "main"},
+		}, rules)
+	})
+
+	t.Run("No rules configured", func(t *testing.T) {
+		loadConfig(t, "planFile = 'plan.out'\n")
+
+		rules, err := loadBaseRules()
+
+		require.NoError(t, err)
+		require.Empty(t, rules)
+	})
+
+	testCases := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{name: "Empty prefix", config: `"*" = "main"`, wantErr: "must not be empty"},
+		{name: "Invalid base", config: `"feature/" = "dev..elop"`, wantErr: "not a valid branch name"},
+		{name: "Base with spaces", config: `"feature/" = "my base"`, wantErr: "not a valid branch name"},
+		{name: "Non-string base", config: `"feature/" = 1`, wantErr: "expected a string"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			loadConfig(t, "[baseRules]\n"+tc.config+"\n")
+
+			_, err := loadBaseRules()
+
+			require.ErrorContains(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestResolvePRBase(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	rules := []baseRule{
+		{Prefix: "feature/release-", Base: "release"},
+		{Prefix: "feature/", Base: "develop"},
+		{Prefix: "hotfix/", Base: "main"},
+	}
+
+	testCases := []struct {
+		name     string
+		explicit string
+		branch   string
+		fallback string
+		want     string
+	}{
+		{name: "Matching prefix", branch: "feature/vpc", fallback: "trunk", want: "develop"},
+		{name: "Longest prefix wins", branch: "feature/release-2", fallback: "trunk", want: "release"},
+		{name: "Hotfix", branch: "hotfix/cve", fallback: "trunk", want: "main"},
+		{name: "No match uses the default branch", branch: "chore/deps", fallback: "trunk", want: "trunk"},
+		{name: "No match without a default branch", branch: "chore/deps", want: "main"},
+		{name: "Explicit base wins", explicit: "staging", branch: "feature/vpc", want: "staging"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, resolvePRBase(tc.explicit, tc.branch, rules, tc.fallback))
+		})
+	}
+}
+
+func TestResolvePRTitle(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	logArgs := []string{"log", "-1", "--format=%s"}
+	long := strings.Repeat("a", maxPRTitleLength+10)
+
+	tests := []struct {
+		name       string
+		explicit   string
+		fromCommit bool
+		subject    string
+		gitErr     error
+		want       string
+	}{
+		{"Fallback", "", false, "", nil, "Terraform plan"},
+		{"Explicit wins", "Bump the VPC", true, "", nil, "Bump the VPC"},
+		{"Commit subject", "", true, "Add the network module\n", nil, "Add the network module"},
+		{"Newlines are joined", "", true, "Add the\r\nnetwork module\n", nil, "Add the network module"},
+		{"Not a git repository", "", true, "", errors.New("fatal: not a git repository"), "Terraform plan"},
+		{"Empty subject", "", true, "\n", nil, "Terraform plan"},
+		{"Truncated", "", true, long, nil, strings.Repeat("a", maxPRTitleLength-1) + "…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			git := new(MockGitRunner)
+			if tt.explicit == "" && tt.fromCommit {
+				git.On("Run", mock.Anything, logArgs).Return([]byte(tt.subject), tt.gitErr)
+			}
+			got := resolvePRTitle(context.Background(), tt.explicit, tt.fromCommit, git, "Terraform plan")
+			require.Equal(t, tt.want, got)
+			git.AssertExpectations(t)
+		})
+	}
+}
+
+func TestResolvePRTitleFromRepository(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found in PATH")
+	}
+	t.Chdir(t.TempDir())
+
+	// Outside a repository the fallback is used
+	require.Equal(t, "OpenTofu plan", resolvePRTitle(context.Background(), "", true, &RealGitRunner{}, "OpenTofu plan"))
+
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=tp", "-c", "user.email=tp@example.com", "commit", "-q", "--allow-empty", "-m", "Add the network module", "-m", "Body text"},
+	} {
+		out, err := exec.Command("git", args...).CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	require.Equal(
+		t,
+		"Add the network module",
+		resolvePRTitle(context.Background(), "", true, &RealGitRunner{}, "OpenTofu plan"),
+	)
+}
+
+func TestNormalizeBranchName(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "Plain", input: "main", want: "main"},
+		{name: "Nested", input: "feature/vpc-peering", want: "feature/vpc-peering"},
+		{name: "Ref prefix", input: "refs/heads/release/1.2", want: "release/1.2"},
+		{name: "Whitespace", input: "  develop\n", want: "develop"},
+		{name: "Prefix and whitespace", input: " refs/heads/main ", want: "main"},
+		{name: "Empty", input: "  ", wantErr: true},
+		{name: "Only the prefix", input: "refs/heads/", wantErr: true},
+		{name: "Space inside", input: "my branch", wantErr: true},
+		{name: "Double dot", input: "feature..x", wantErr: true},
+		{name: "Reflog syntax", input: "main@{1}", wantErr: true},
+		{name: "Invalid characters", input: "feat~1^:x?*[", wantErr: true},
+		{name: "Control character", input: "feat\x07", wantErr: true},
+		{name: "Leading dash", input: "-main", wantErr: true},
+		{name: "Component starting with a dot", input: "feature/.hidden", wantErr: true},
+		{name: "Lock component", input: "feature.lock/x", wantErr: true},
+		{name: "Trailing slash", input: "feature/", wantErr: true},
+		{name: "At sign", input: "@", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeBranchName(tt.input)
+			if tt.wantErr {
+				require.ErrorContains(t, err, "is not a valid branch name")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDefaultBranchCache(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	viewArgs := []string{"repo", "view", "--json", "defaultBranchRef", "--jq", ".defaultBranchRef.name"}
+
+	t.Run("Non-main default is used as the base", func(t *testing.T) {
+		gh := new(MockGhRunner)
+		gh.On("Run", mock.Anything, viewArgs).Return([]byte("trunk\n"), nil).Once()
+		cache := newDefaultBranchCache(gh)
+
+		require.Equal(t, "trunk", resolvePRBase("", "chore/deps", nil, cache.Get(context.Background())))
+		require.Equal(t, "trunk", cache.Get(context.Background()), "the lookup is cached")
+		gh.AssertExpectations(t)
+	})
+
+	t.Run("API failure falls back to main", func(t *testing.T) {
+		gh := new(MockGhRunner)
+		gh.On("Run", mock.Anything, viewArgs).Return(nil, errors.New("HTTP 404")).Once()
+		cache := newDefaultBranchCache(gh)
+
+		require.Equal(t, fallbackBaseBranch, cache.Get(context.Background()))
+		require.Equal(t, fallbackBaseBranch, cache.Get(context.Background()))
+		gh.AssertExpectations(t)
+	})
+
+	t.Run("Invalid response falls back to main", func(t *testing.T) {
+		gh := new(MockGhRunner)
+		gh.On("Run", mock.Anything, viewArgs).Return([]byte("\n"), nil)
+
+		require.Equal(t, fallbackBaseBranch, newDefaultBranchCache(gh).Get(context.Background()))
+	})
+}
+
+func TestLoadPRBodyMaxBytes(t *testing.T) {
+	t.Run("GitHub's limit by default", func(t *testing.T) {
+		loadConfig(t, "")
+
+		got, err := loadPRBodyMaxBytes()
+
+		require.NoError(t, err)
+		require.Equal(t, defaultPRBodyMaxBytes, got)
+	})
+
+	t.Run("Override", func(t *testing.T) {
+		loadConfig(t, "prBodyMaxBytes = 1000000\n")
+
+		got, err := loadPRBodyMaxBytes()
+
+		require.NoError(t, err)
+		require.Equal(t, 1000000, got)
+	})
+
+	t.Run("Must be positive", func(t *testing.T) {
+		for _, value := range []string{"0", "-1"} {
+			loadConfig(t, "prBodyMaxBytes = "+value+"\n")
+
+			_, err := loadPRBodyMaxBytes()
+
+			require.EqualError(t, err, "invalid 'prBodyMaxBytes' ("+value+"): must be positive")
+		}
+	})
+}
+
+func TestTruncatePRBody(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	body := "## Plan\n\n```terraform\n" + strings.Repeat("  + resource \"null_resource\" \"this\" {}\n", 3000) + "```\n"
+	require.Greater(t, len(body), defaultPRBodyMaxBytes)
+
+	t.Run("GitHub's limit", func(t *testing.T) {
+		got := truncatePRBody(body, defaultPRBodyMaxBytes, genericTruncationNotice)
+
+		require.LessOrEqual(t, len(got), defaultPRBodyMaxBytes)
+		require.Greater(t, len(got), defaultPRBodyMaxBytes-1000)
+		require.Contains(t, got, "The plan was truncated to fit.")
+	})
+
+	t.Run("Overridden limit", func(t *testing.T) {
+		got := truncatePRBody(body, 4096, genericTruncationNotice)
+
+		require.LessOrEqual(t, len(got), 4096)
+		require.Contains(t, got, "The plan was truncated to fit.")
+		require.Equal(t, 2, strings.Count(got, "```"), "the code block is closed")
+	})
+
+	t.Run("Larger limit keeps the body", func(t *testing.T) {
+		require.Equal(t, body, truncatePRBody(body, 1000000, genericTruncationNotice))
+	})
+}
+
+func TestRealPRClientCreatePR(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	pr := newPR{
+		Title:     "Terraform plan (prod)",
+		Base:      "main",
+		BodyFile:  "plan.md",
+		Draft:     true,
+		Labels:    []string{"env:prod"},
+		Reviewers: []string{"acme/security"},
+	}
+	args := []string{
+		"pr", "create", "--title", "Terraform plan (prod)", "--body-file", "plan.md", "--base", "main",
+		"--draft", "--label", "env:prod", "--reviewer", "acme/security",
+	}
+
+	labelArgs := []string{"label", "create", "env:prod", "--description", "Planned by gh tp"}
+	labelExists := errors.New("gh label: exit status 1: label with name \"env:prod\" already exists; use `--force` to update its color and description")
+
+	t.Run("Success", func(t *testing.T) {
+		runner := new(MockGhRunner)
+		runner.On("Run", mock.Anything, labelArgs).Return(nil, labelExists)
+		runner.On("Run", mock.Anything, args).
+			Return([]byte("\nCreating draft pull request for feature into main in o/r\n\nhttps://github.com/o/r/pull/7\n"), nil)
+
+		url, err := (&RealPRClient{runner: runner}).CreatePR(context.Background(), pr)
+
+		require.NoError(t, err)
+		require.Equal(t, "https://github.com/o/r/pull/7", url)
+		runner.AssertExpectations(t)
+	})
+
+	t.Run("Missing label", func(t *testing.T) {
+		runner := new(MockGhRunner)
+		runner.On("Run", mock.Anything, labelArgs).Return([]byte{}, nil)
+		runner.On("Run", mock.Anything, args).Return([]byte("https://github.com/o/r/pull/7\n"), nil)
+
+		url, err := (&RealPRClient{runner: runner}).CreatePR(context.Background(), pr)
+
+		require.NoError(t, err)
+		require.Equal(t, "https://github.com/o/r/pull/7", url)
+		runner.AssertExpectations(t)
+	})
+
+	t.Run("Label can't be created", func(t *testing.T) {
+		runner := new(MockGhRunner)
+		runner.On("Run", mock.Anything, labelArgs).Return(nil, errors.New("gh label: exit status 1: HTTP 403"))
+		withoutLabel := slices.DeleteFunc(slices.Clone(args), func(arg string) bool {
+			return arg == "--label" || arg == "env:prod"
+		})
+		runner.On("Run", mock.Anything, withoutLabel).Return([]byte("https://github.com/o/r/pull/7\n"), nil)
+
+		url, err := (&RealPRClient{runner: runner}).CreatePR(context.Background(), pr)
+
+		require.NoError(t, err, "the pull request is opened without the label")
+		require.Equal(t, "https://github.com/o/r/pull/7", url)
+		runner.AssertExpectations(t)
+	})
+
+	t.Run("Already exists", func(t *testing.T) {
+		runner := new(MockGhRunner)
+		runner.On("Run", mock.Anything, labelArgs).Return(nil, labelExists)
+		runner.On("Run", mock.Anything, args).Return(nil, errors.New(
+			"gh pr: exit status 1: a pull request for branch \"feature\" into branch \"main\" already exists:\nhttps://github.com/o/r/pull/7",
+		))
+
+		url, err := (&RealPRClient{runner: runner}).CreatePR(context.Background(), pr)
+
+		require.ErrorIs(t, err, errPRExists)
+		require.Equal(t, "https://github.com/o/r/pull/7", url)
+	})
+
+	t.Run("Other errors", func(t *testing.T) {
+		runner := new(MockGhRunner)
+		runner.On("Run", mock.Anything, labelArgs).Return(nil, labelExists)
+		runner.On("Run", mock.Anything, args).Return(nil, errors.New("gh pr: exit status 1: HTTP 502"))
+
+		_, err := (&RealPRClient{runner: runner}).CreatePR(context.Background(), pr)
+
+		require.NotErrorIs(t, err, errPRExists)
+		require.ErrorContains(t, err, "failed to create the pull request")
+	})
+}
+
+func TestCreatePR(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	pr := newPR{Title: "Terraform plan", Base: "main", BodyFile: "plan.md"}
+
+	t.Run("Created", func(t *testing.T) {
+		client := new(MockPRClient)
+		client.On("CreatePR", mock.Anything, pr).Return("https://github.com/o/r/pull/7", nil)
+
+		url, err := createPR(context.Background(), client, pr)
+
+		require.NoError(t, err)
+		require.Equal(t, "https://github.com/o/r/pull/7", url)
+	})
+
+	t.Run("Already exists", func(t *testing.T) {
+		client := new(MockPRClient)
+		client.On("CreatePR", mock.Anything, pr).
+			Return("https://github.com/o/r/pull/7", fmt.Errorf("%w: gh pr: exit status 1", errPRExists))
+
+		_, err := createPR(context.Background(), client, pr)
+
+		require.ErrorIs(t, err, errPRExists)
+		require.EqualError(
+			t,
+			err,
+			"a pull request already exists for the branch: pass --update to update the plan in "+
+				"https://github.com/o/r/pull/7 instead",
+		)
+	})
+}
+
+func TestRealPRClientCurrentPR(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	args := []string{"pr", "view", "--json", "number,url,body,state"}
+
+	t.Run("Open", func(t *testing.T) {
+		runner := new(MockGhRunner)
+		runner.On("Run", mock.Anything, args).
+			Return([]byte(`{"number":7,"url":"https://github.com/o/r/pull/7","body":"Notes","state":"OPEN"}`), nil)
+
+		pr, err := (&RealPRClient{runner: runner}).CurrentPR(context.Background())
+
+		require.NoError(t, err)
+		require.Equal(t, openPR{Number: 7, URL: "https://github.com/o/r/pull/7", Body: "Notes", State: "OPEN"}, pr)
+	})
+
+	t.Run("Merged", func(t *testing.T) {
+		runner := new(MockGhRunner)
+		runner.On("Run", mock.Anything, args).
+			Return([]byte(`{"number":7,"url":"https://github.com/o/r/pull/7","body":"","state":"MERGED"}`), nil)
+
+		pr, err := (&RealPRClient{runner: runner}).CurrentPR(context.Background())
+
+		require.NoError(t, err)
+		require.Zero(t, pr.Number)
+	})
+
+	t.Run("None", func(t *testing.T) {
+		runner := new(MockGhRunner)
+		runner.On("Run", mock.Anything, args).
+			Return(nil, errors.New("gh pr: exit status 1: "+noPullRequestMessage+" \"feature\""))
+
+		pr, err := (&RealPRClient{runner: runner}).CurrentPR(context.Background())
+
+		require.NoError(t, err)
+		require.Zero(t, pr.Number)
+	})
+}
+
+func TestRealPRClientUpdatePRBody(t *testing.T) {
+	runner := new(MockGhRunner)
+	var sent string
+	runner.On("Run", mock.Anything, mock.MatchedBy(func(args []string) bool {
+		if len(args) != 6 || args[4] != "-F" || !strings.HasPrefix(args[5], "body=@") {
+			return false
+		}
+		body, err := os.ReadFile(strings.TrimPrefix(args[5], "body=@"))
+		sent = string(body)
+		return err == nil && slices.Equal(args[:4], []string{"api", "--method", "PATCH", "repos/{owner}/{repo}/pulls/7"})
+	})).Return([]byte("{}"), nil)
+
+	err := (&RealPRClient{runner: runner}).UpdatePRBody(context.Background(), 7, "Notes\n\nplan")
+
+	require.NoError(t, err)
+	require.Equal(t, "Notes\n\nplan", sent, "the body is passed in a file, not on the command line")
+	runner.AssertExpectations(t)
+}
+
+func TestUpdatePR(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	planBody := func(current string) string { return spliceBody(current, "plan", 1000, genericTruncationNotice) }
+
+	pr := func(body string) openPR {
+		return openPR{Number: 7, URL: "https://github.com/o/r/pull/7", Body: body, State: "OPEN"}
+	}
+	updated := "Notes\n\n" + planStartMarker + "\nplan\n" + planEndMarker
+
+	t.Run("Updated", func(t *testing.T) {
+		client := new(MockPRClient)
+		client.On("CurrentPR", mock.Anything).Return(pr("Notes"), nil).Once()
+		client.On("UpdatePRBody", mock.Anything, 7, updated).Return(nil).Once()
+		client.On("CurrentPR", mock.Anything).Return(pr(updated), nil).Once()
+
+		url, err := updatePR(context.Background(), client, planBody)
+
+		require.NoError(t, err)
+		require.Equal(t, "https://github.com/o/r/pull/7", url)
+		client.AssertExpectations(t)
+	})
+
+	t.Run("Up to date", func(t *testing.T) {
+		client := new(MockPRClient)
+		client.On("CurrentPR", mock.Anything).Return(pr(updated), nil).Once()
+
+		_, err := updatePR(context.Background(), client, planBody)
+
+		require.NoError(t, err)
+		client.AssertNotCalled(t, "UpdatePRBody", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Concurrent update", func(t *testing.T) {
+		client := new(MockPRClient)
+		client.On("CurrentPR", mock.Anything).Return(pr("Notes"), nil).Once()
+		client.On("UpdatePRBody", mock.Anything, 7, updated).Return(nil).Once()
+		// Another run wrote its body after ours
+		client.On("CurrentPR", mock.Anything).Return(pr("Edited"), nil).Once()
+		reapplied := "Edited\n\n" + planStartMarker + "\nplan\n" + planEndMarker
+		client.On("UpdatePRBody", mock.Anything, 7, reapplied).Return(nil).Once()
+		client.On("CurrentPR", mock.Anything).Return(pr(reapplied), nil).Once()
+
+		_, err := updatePR(context.Background(), client, planBody)
+
+		require.NoError(t, err)
+		client.AssertExpectations(t)
+	})
+
+	t.Run("Keeps changing", func(t *testing.T) {
+		client := new(MockPRClient)
+		client.On("CurrentPR", mock.Anything).Return(pr("Notes"), nil)
+		client.On("UpdatePRBody", mock.Anything, 7, updated).Return(nil)
+
+		url, err := updatePR(context.Background(), client, planBody)
+
+		require.NoError(t, err)
+		require.Equal(t, "https://github.com/o/r/pull/7", url)
+		client.AssertNumberOfCalls(t, "UpdatePRBody", maxPRUpdateAttempts)
+	})
+
+	t.Run("No pull request", func(t *testing.T) {
+		client := new(MockPRClient)
+		client.On("CurrentPR", mock.Anything).Return(openPR{}, nil)
+
+		_, err := updatePR(context.Background(), client, planBody)
+
+		require.ErrorIs(t, err, errNoPR)
+		client.AssertNotCalled(t, "UpdatePRBody", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestSpliceBody(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	block := func(plan string) string { return planStartMarker + "\n" + plan + "\n" + planEndMarker }
+
+	t.Run("New pull request", func(t *testing.T) {
+		require.Equal(t, block("plan"), spliceBody("", "plan\n", 1000, genericTruncationNotice))
+	})
+
+	t.Run("Appended the first time", func(t *testing.T) {
+		got := spliceBody("Why this change.\n", "plan", 1000, genericTruncationNotice)
+
+		require.Equal(t, "Why this change.\n\n"+block("plan"), got)
+	})
+
+	t.Run("Replaces the plan only", func(t *testing.T) {
+		current := "Why this change.\n\n" + block("old plan") + "\n\n- [ ] Reviewed"
+
+		got := spliceBody(current, "new plan", 1000, genericTruncationNotice)
+
+		require.Equal(t, "Why this change.\n\n"+block("new plan")+"\n\n- [ ] Reviewed", got)
+		require.Equal(t, "new plan", planBlock(got))
+	})
+
+	t.Run("Truncated to fit", func(t *testing.T) {
+		plan := "```terraform\n" + strings.Repeat("  + resource \"null_resource\" \"this\" {}\n", 200) + "```"
+
+		got := spliceBody("Why this change.", plan, 2000, genericTruncationNotice)
+
+		require.LessOrEqual(t, len(got), 2000)
+		require.True(t, strings.HasPrefix(got, "Why this change.\n\n"+planStartMarker))
+		require.True(t, strings.HasSuffix(got, planEndMarker))
+		require.Contains(t, got, genericTruncationNotice)
+	})
+}
+
+func TestWritePRBody(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	got, cleanup, err := writePRBody("body")
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(got)
+	require.NoError(t, err)
+	require.Equal(t, "body", string(content))
+
+	cleanup()
+	require.NoFileExists(t, got)
+}
+
+func TestCurrentBranch(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	t.Run("Pull request workflow", func(t *testing.T) {
+		t.Setenv("GITHUB_HEAD_REF", "feature/vpc")
+
+		require.Equal(t, "feature/vpc", currentBranch(context.Background(), new(MockGitRunner)))
+	})
+
+	t.Run("Normalized", func(t *testing.T) {
+		t.Setenv("GITHUB_HEAD_REF", " refs/heads/feature/vpc\n")
+
+		require.Equal(t, "feature/vpc", currentBranch(context.Background(), new(MockGitRunner)))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		t.Setenv("GITHUB_HEAD_REF", "feature..vpc")
+
+		require.Empty(t, currentBranch(context.Background(), new(MockGitRunner)))
+	})
+
+	t.Run("Checked out branch", func(t *testing.T) {
+		t.Setenv("GITHUB_HEAD_REF", "")
+		git := new(MockGitRunner)
+		git.On("Run", mock.Anything, []string{"rev-parse", "--abbrev-ref", "HEAD"}).Return([]byte("feature/vpc\n"), nil)
+
+		require.Equal(t, "feature/vpc", currentBranch(context.Background(), git))
+	})
+
+	t.Run("Detached", func(t *testing.T) {
+		t.Setenv("GITHUB_HEAD_REF", "")
+		git := new(MockGitRunner)
+		git.On("Run", mock.Anything, []string{"rev-parse", "--abbrev-ref", "HEAD"}).Return([]byte("HEAD\n"), nil)
+
+		require.Empty(t, currentBranch(context.Background(), git))
+	})
+}
+
+func TestTitleFlagAlias(t *testing.T) {
+	flags := pflag.NewFlagSet("tp", pflag.ContinueOnError)
+	flags.SetNormalizeFunc(normalizeFlagName)
+	flags.String("pr-title", "", "")
+
+	require.NoError(t, flags.Parse([]string{"--title", "Rotate the keys"}))
+
+	got, err := flags.GetString("pr-title")
+	require.NoError(t, err)
+	require.Equal(t, "Rotate the keys", got)
+}
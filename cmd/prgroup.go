@@ -0,0 +1,171 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// maxPRGroupKeyLength bounds 'prGroupKey', which is repeated in every marker
+const maxPRGroupKeyLength = 64
+
+const (
+	// prGroupStartMarker starts the section of one invocation in a grouped
+	// pull request body, e.g. "<!-- gh-tp:group nightly stacks/network -->"
+	prGroupStartMarker = "<!-- gh-tp:group %s %s -->"
+	// prGroupEndMarker ends the section started by prGroupStartMarker
+	prGroupEndMarker = "<!-- gh-tp:group-end %s %s -->"
+)
+
+var (
+	// prGroupKeyName matches a simple identifier, e.g. nightly or release-1.2
+	prGroupKeyName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	// htmlCommentUnsafe matches the runs of dashes that would end a marker early
+	htmlCommentUnsafe = regexp.MustCompile(`-{2,}|\s+`)
+)
+
+// validatePRGroupKey checks the 'prGroupKey', empty when unset.
+func validatePRGroupKey(key string) error {
+	if key == "" {
+		return nil
+	}
+	if !prGroupKeyName.MatchString(key) {
+		return fmt.Errorf(
+			"invalid 'prGroupKey' %q: use letters, digits, '-', '_' and '.', starting with a letter or digit",
+			key,
+		)
+	}
+	if len(key) > maxPRGroupKeyLength {
+		return fmt.Errorf("invalid 'prGroupKey' %q: longer than %d characters", key, maxPRGroupKeyLength)
+	}
+	return nil
+}
+
+// prGroupSection names the section an invocation owns in a grouped pull
+// request body: the environment if set, otherwise the directory planned
+// relative to the repository root, e.g. stacks/network.
+//
+// Parameters:
+//
+//	environment - The 'environment' label, may be empty.
+//	repoRoot - The repository root from resolveRepoRoot, may be empty.
+//	workingDir - The directory tp runs in.
+//
+// Returns:
+//
+//	string - The section's name, safe to use in a marker.
+func prGroupSection(environment, repoRoot, workingDir string) string {
+	section := environment
+	if section == "" {
+		section = filepath.Base(workingDir)
+		if repoRoot != "" {
+			if rel, err := filepath.Rel(repoRoot, workingDir); err == nil && !strings.HasPrefix(rel, "..") {
+				section = filepath.ToSlash(rel)
+			}
+		}
+	}
+	return htmlCommentUnsafe.ReplaceAllString(section, "-")
+}
+
+// mergePRGroupBody adds the plan Markdown of one invocation to the body
+// shared by every invocation with the same group key. A section already in
+// the body is replaced, so re-running a job doesn't duplicate its plan. New
+// sections go before the planBodyMarker of a --pr-body-file body if there's
+// one, or at the end.
+//
+// Parameters:
+//
+//	body - The current body of the group's pull request, empty for the first invocation.
+//	key - The validated 'prGroupKey'.
+//	section - The invocation's section from prGroupSection.
+//	planMd - The invocation's plan Markdown.
+//
+// Returns:
+//
+//	string - The body with the invocation's section.
+func mergePRGroupBody(body, key, section, planMd string) string {
+	start := fmt.Sprintf(prGroupStartMarker, key, section)
+	end := fmt.Sprintf(prGroupEndMarker, key, section)
+	block := start + "\n" + strings.TrimSpace(planMd) + "\n" + end
+
+	if i := strings.Index(body, start); i >= 0 {
+		if j := strings.Index(body[i:], end); j >= 0 {
+			return body[:i] + block + body[i+j+len(end):]
+		}
+	}
+	if strings.Contains(body, planBodyMarker) {
+		return strings.Replace(body, planBodyMarker, block+"\n\n"+planBodyMarker, 1)
+	}
+	if strings.TrimSpace(body) == "" {
+		return block + "\n"
+	}
+	return strings.TrimRight(body, "\n") + "\n\n" + block + "\n"
+}
+
+// fitPRGroupBody merges the plan Markdown into the group body like
+// mergePRGroupBody, truncating sections until the result fits in maxBytes.
+// The invocation's own plan is truncated first, then the largest of the
+// other sections. Sections are truncated one at a time, between their
+// markers, so the markers are never cut.
+//
+// Parameters:
+//
+//	body - The current group body, from planBlock.
+//	key - The validated 'prGroupKey'.
+//	section - The invocation's section from prGroupSection.
+//	planMd - The invocation's plan Markdown.
+//	maxBytes - The room for the group body, from planRoom.
+//	notice - The notice ending a truncated section, from truncationNotice.
+//
+// Returns:
+//
+//	string - The body with the invocation's section.
+func fitPRGroupBody(body, key, section, planMd string, maxBytes int, notice string) string {
+	merged := mergePRGroupBody(body, key, section, planMd)
+	if len(merged) <= maxBytes {
+		return merged
+	}
+
+	planMd = strings.TrimSpace(planMd)
+	planMd = truncatePRBody(planMd, max(len(planMd)-(len(merged)-maxBytes), 0), notice)
+	merged = mergePRGroupBody(body, key, section, planMd)
+	for len(merged) > maxBytes {
+		other, content := largestPRGroupSection(merged, key, section)
+		if content == "" {
+			break
+		}
+		cut := truncatePRBody(content, max(len(content)-(len(merged)-maxBytes), 0), notice)
+		if len(cut) >= len(content) {
+			break
+		}
+		merged = mergePRGroupBody(merged, key, other, cut)
+	}
+	return merged
+}
+
+// largestPRGroupSection returns the name and content of the largest section
+// of the group in body other than skip, or empty strings if there's none.
+func largestPRGroupSection(body, key, skip string) (string, string) {
+	starts := regexp.MustCompile(
+		fmt.Sprintf(regexp.QuoteMeta(prGroupStartMarker), regexp.QuoteMeta(key), `(\S+)`),
+	)
+	var largest, largestContent string
+	for _, m := range starts.FindAllStringSubmatchIndex(body, -1) {
+		name := body[m[2]:m[3]]
+		if name == skip {
+			continue
+		}
+		rest := body[m[1]:]
+		content, _, ok := strings.Cut(rest, fmt.Sprintf(prGroupEndMarker, key, name))
+		if !ok {
+			continue
+		}
+		if content = strings.TrimSpace(content); len(content) > len(largestContent) {
+			largest, largestContent = name, content
+		}
+	}
+	return largest, largestContent
+}
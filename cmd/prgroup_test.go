@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatePRGroupKey(t *testing.T) {
+	require.NoError(t, validatePRGroupKey(""))
+	require.NoError(t, validatePRGroupKey("nightly-1.2"))
+	require.ErrorContains(t, validatePRGroupKey("two words"), "use letters, digits")
+	require.ErrorContains(t, validatePRGroupKey("a-->b"), "use letters, digits")
+	require.ErrorContains(t, validatePRGroupKey(strings.Repeat("a", 65)), "longer than 64 characters")
+}
+
+func TestPRGroupSection(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator), "repo")
+
+	require.Equal(t, "prod", prGroupSection("prod", root, filepath.Join(root, "stacks", "network")))
+	require.Equal(t, "stacks/network", prGroupSection("", root, filepath.Join(root, "stacks", "network")))
+	require.Equal(t, "network", prGroupSection("", "", filepath.Join(root, "stacks", "network")))
+	require.Equal(t, "my-stack", prGroupSection("", root, filepath.Join(root, "my--stack")))
+}
+
+func TestMergePRGroupBody(t *testing.T) {
+	network := "<details><summary>Terraform plan</summary>\n\nnetwork changes\n</details>\n"
+	db := "<details><summary>Terraform plan</summary>\n\ndb changes\n</details>\n"
+
+	// The first job creates the body, the second appends its section
+	body := mergePRGroupBody("", "nightly", "stacks/network", network)
+	body = mergePRGroupBody(body, "nightly", "stacks/db", db)
+
+	require.Equal(t, "<!-- gh-tp:group nightly stacks/network -->\n"+
+		strings.TrimSpace(network)+"\n"+
+		"<!-- gh-tp:group-end nightly stacks/network -->\n\n"+
+		"<!-- gh-tp:group nightly stacks/db -->\n"+
+		strings.TrimSpace(db)+"\n"+
+		"<!-- gh-tp:group-end nightly stacks/db -->\n", body)
+
+	t.Run("Re-running a job replaces its section", func(t *testing.T) {
+		updated := strings.Replace(network, "network changes", "network changes, take two", 1)
+
+		got := mergePRGroupBody(body, "nightly", "stacks/network", updated)
+
+		require.Equal(t, 1, strings.Count(got, "<!-- gh-tp:group nightly stacks/network -->"))
+		require.Contains(t, got, "network changes, take two")
+		require.NotContains(t, got, "network changes\n")
+		require.Contains(t, got, "db changes")
+		require.Equal(t, got, mergePRGroupBody(got, "nightly", "stacks/network", updated), "merging is idempotent")
+	})
+
+	t.Run("Sections of other keys are kept", func(t *testing.T) {
+		got := mergePRGroupBody(body, "weekly", "stacks/network", db)
+
+		require.Contains(t, got, "network changes")
+		require.Contains(t, got, "<!-- gh-tp:group weekly stacks/network -->\n"+strings.TrimSpace(db))
+	})
+
+	t.Run("Sections go before the plan marker of a body file", func(t *testing.T) {
+		base := "## Nightly plans\n\n" + planBodyMarker + "\n\nReviewed by the platform team.\n"
+
+		got := mergePRGroupBody(base, "nightly", "stacks/network", network)
+		got = mergePRGroupBody(got, "nightly", "stacks/db", db)
+
+		require.Less(t, strings.Index(got, "network changes"), strings.Index(got, "db changes"))
+		require.Less(t, strings.Index(got, "db changes"), strings.Index(got, planBodyMarker))
+		require.True(t, strings.HasSuffix(got, "Reviewed by the platform team.\n"))
+	})
+}
+
+func TestFitPRGroupBody(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	plan := func(name string, lines int) string {
+		return "<details><summary>Terraform plan</summary>\n\n```terraform\n" +
+			strings.Repeat(name+" changes\n", lines) + "```\n</details>\n"
+	}
+	markers := []string{
+		"<!-- gh-tp:group nightly stacks/network -->",
+		"<!-- gh-tp:group-end nightly stacks/network -->",
+		"<!-- gh-tp:group nightly stacks/db -->",
+		"<!-- gh-tp:group-end nightly stacks/db -->",
+	}
+
+	t.Run("A body that fits is merged as is", func(t *testing.T) {
+		body := mergePRGroupBody("", "nightly", "stacks/network", plan("network", 2))
+
+		got := fitPRGroupBody(
+			body, "nightly", "stacks/db", plan("db", 2), defaultPRBodyMaxBytes, genericTruncationNotice,
+		)
+
+		require.Equal(t, mergePRGroupBody(body, "nightly", "stacks/db", plan("db", 2)), got)
+	})
+
+	t.Run("The invocation's plan is truncated first", func(t *testing.T) {
+		body := mergePRGroupBody("", "nightly", "stacks/network", plan("network", 10))
+
+		got := fitPRGroupBody(body, "nightly", "stacks/db", plan("db", 100), 1200, genericTruncationNotice)
+
+		require.LessOrEqual(t, len(got), 1200)
+		require.Equal(t, 10, strings.Count(got, "network changes"), "the other section is kept")
+		require.Contains(t, got, "db changes")
+		require.Contains(t, got, "[!WARNING]")
+		for _, marker := range markers {
+			require.Equal(t, 1, strings.Count(got, marker), marker)
+		}
+	})
+
+	t.Run("Other sections are truncated when the body exceeds prBodyMaxBytes", func(t *testing.T) {
+		body := mergePRGroupBody("", "nightly", "stacks/network", plan("network", 5000))
+		require.Greater(t, len(body), defaultPRBodyMaxBytes)
+		room := planRoom("", defaultPRBodyMaxBytes)
+
+		got := fitPRGroupBody(
+			body, "nightly", "stacks/db", plan("db", 5000), room, genericTruncationNotice,
+		)
+		pr := spliceBody("", got, defaultPRBodyMaxBytes, genericTruncationNotice)
+
+		require.LessOrEqual(t, len(pr), defaultPRBodyMaxBytes)
+		require.Equal(t, strings.TrimSpace(got), planBlock(pr), "spliceBody has nothing left to cut")
+		require.Contains(t, got, "network changes")
+		for _, marker := range markers {
+			require.Equal(t, 1, strings.Count(got, marker), marker)
+		}
+	})
+}
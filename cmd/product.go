@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-version"
+)
+
+// probeTimeout bounds the 'version' commands probeBinary runs
+const probeTimeout = 10 * time.Second
+
+// binaryInfo is what the binary reports about itself with 'version', which
+// holds even when it's named after the other product, e.g. a 'terraform'
+// symlink to tofu.
+type binaryInfo struct {
+	Product string           // "terraform" or "tofu"
+	Semver  *version.Version // The binary's version
+}
+
+// productFromBanner reads the product from the first line of 'version', e.g.
+// "OpenTofu v1.8.5" or "Terraform v1.9.8", empty when it's neither.
+func productFromBanner(data []byte) string {
+	first, _, _ := strings.Cut(strings.TrimSpace(string(data)), "\n")
+	switch {
+	case strings.HasPrefix(first, "OpenTofu "):
+		return "tofu"
+	case strings.HasPrefix(first, "Terraform "):
+		return "terraform"
+	default:
+		return ""
+	}
+}
+
+// probeBinary asks the binary which product and version it is: the version
+// from vr, whose 'version -json' tfexec caches for the version checks, and
+// the product from the banner of 'version', as OpenTofu's JSON looks like
+// Terraform's.
+//
+// Parameters:
+//
+//	ctx - The context for the commands.
+//	vr - Reads the binary's version, normally the *tfexec.Terraform of the plan.
+//	binaryPath - The binary, a name in the PATH or a path.
+//
+// Returns:
+//
+//	binaryInfo - The product and version of the binary.
+//	error - An error if the binary couldn't run or is neither product.
+func probeBinary(ctx context.Context, vr versionReader, binaryPath string) (binaryInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	v, _, err := vr.Version(ctx, false)
+	if err != nil {
+		return binaryInfo{}, err
+	}
+	out, err := runVersion(ctx, binaryPath, "version")
+	if err != nil {
+		return binaryInfo{}, err
+	}
+	product := productFromBanner(out)
+	if product == "" {
+		return binaryInfo{}, fmt.Errorf("%s is neither Terraform nor OpenTofu", binaryPath)
+	}
+	Logger.Debugf("%s reports %s %s", binaryPath, product, v)
+	return binaryInfo{Product: product, Semver: v}, nil
+}
+
+// runVersion runs binaryPath with args, returning its standard output.
+func runVersion(ctx context.Context, binaryPath string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binaryPath, args...) //nolint:gosec // the configured binary
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s %s: %w: %s", binaryPath, strings.Join(args, " "), err, msg)
+		}
+		return nil, fmt.Errorf("%s %s: %w", binaryPath, strings.Join(args, " "), err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// productName returns the product the Markdown and checks name: the one the
+// binary reports, or the one its name suggests when it couldn't be asked.
+//
+// Parameters:
+//
+//	binaryPath - The binary, a name in the PATH or a path.
+//	info - What probeBinary read, zero when it failed.
+//
+// Returns:
+//
+//	string - "terraform", "tofu", or the binary's name when unknown.
+func productName(binaryPath string, info binaryInfo) string {
+	named := binaryKind(binaryPath)
+	if info.Product == "" {
+		return named
+	}
+	if info.Product != named {
+		Logger.Infof("%s is %s, naming the plan after it", binaryPath, binaryDisplayName(info.Product))
+	}
+	return info.Product
+}
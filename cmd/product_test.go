@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductFromBanner(t *testing.T) {
+	for fixture, want := range map[string]string{"terraform.txt": "terraform", "tofu.txt": "tofu"} {
+		data, err := os.ReadFile(filepath.Join("..", "testdata", "version-output", fixture))
+		require.NoError(t, err)
+
+		require.Equal(t, want, productFromBanner(data), fixture)
+	}
+	require.Empty(t, productFromBanner([]byte("Unknown v1.0.0\n")))
+}
+
+// fakeVersionScript is a binary named terraform that is OpenTofu
+const fakeVersionScript = `#!/bin/sh
+fixtures="$VERSION_FIXTURES"
+case "$*" in
+"version -json") cat "$fixtures/tofu.json" ;;
+version) cat "$fixtures/tofu.txt" ;;
+*) exit 1 ;;
+esac
+`
+
+func TestProbeBinary(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	fixtures, err := filepath.Abs(filepath.Join("..", "testdata", "version-output"))
+	require.NoError(t, err)
+	t.Setenv("VERSION_FIXTURES", fixtures)
+	binPath := filepath.Join(t.TempDir(), "terraform")
+	require.NoError(t, os.WriteFile(binPath, []byte(fakeVersionScript), 0o700)) //nolint:gosec // an executable
+
+	tf, err := tfexec.NewTerraform(t.TempDir(), binPath)
+	require.NoError(t, err)
+
+	info, err := probeBinary(context.Background(), tf, binPath)
+
+	require.NoError(t, err)
+	require.Equal(t, "tofu", info.Product)
+	require.Equal(t, "1.8.5", info.Semver.String())
+	require.Equal(t, "tofu", productName(binPath, info), "the binary's answer wins over its name")
+	require.Equal(t, "OpenTofu plan", planTitle(productName(binPath, info)))
+
+	t.Run("The version checks reuse the version read", func(t *testing.T) {
+		// The binary can't answer anymore, the version tfexec read is cached
+		require.NoError(t, os.Remove(binPath))
+
+		require.ErrorContains(
+			t,
+			checkMinVersion(context.Background(), tf, binPath, version.Must(version.NewVersion("1.9.0"))),
+			"terraform 1.8.5 is older than the required 'minVersion' 1.9.0",
+		)
+	})
+
+	t.Run("Falls back to the name", func(t *testing.T) {
+		missing := filepath.Join(t.TempDir(), "terraform")
+		tf, err := tfexec.NewTerraform(t.TempDir(), missing)
+		require.NoError(t, err)
+
+		_, err = probeBinary(context.Background(), tf, missing)
+
+		require.Error(t, err)
+		require.Equal(t, "terraform", productName("terraform", binaryInfo{}))
+	})
+}
@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/spf13/viper"
+)
+
+// sensitivePlaceholder matches the placeholder Terraform and OpenTofu use for sensitive values
+const sensitivePlaceholder = "(sensitive value)"
+
+// compileRedactPatterns compiles the user supplied 'redactPatterns'.
+//
+// Returns:
+//
+//	[]*regexp.Regexp - The compiled patterns, in the order given.
+//	error - An error naming the first pattern that does not compile.
+func compileRedactPatterns() ([]*regexp.Regexp, error) {
+	raw := viper.GetStringSlice("redactPatterns")
+	patterns := make([]*regexp.Regexp, 0, len(raw))
+	for _, p := range raw {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", p, err)
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+// redactPlan masks sensitive values in the plan text with "(sensitive value)".
+// Values marked sensitive in the structured plan are masked wherever they
+// appear as quoted strings, and every match of the user patterns is masked.
+//
+// Parameters:
+//
+//	planStr - The human-readable plan output.
+//	plan - The structured plan providing the sensitive markings, may be nil.
+//	patterns - Additional patterns whose matches are masked.
+//
+// Returns:
+//
+//	string - The plan text with sensitive values masked.
+func redactPlan(planStr string, plan *tfjson.Plan, patterns []*regexp.Regexp) string {
+	values := sensitivePlanValues(plan)
+	// Replace longer values first so a value containing another is fully masked
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	var pairs []string
+	for _, v := range values {
+		pairs = append(pairs, `"`+v+`"`, sensitivePlaceholder)
+		if quoted := strconv.Quote(v); quoted != `"`+v+`"` {
+			pairs = append(pairs, quoted, sensitivePlaceholder)
+		}
+	}
+	if len(pairs) > 0 {
+		planStr = strings.NewReplacer(pairs...).Replace(planStr)
+	}
+
+	for _, re := range patterns {
+		planStr = re.ReplaceAllLiteralString(planStr, sensitivePlaceholder)
+	}
+
+	Logger.Debugf(
+		"Redacted %d sensitive value(s) and %d pattern(s) from the plan",
+		len(values),
+		len(patterns),
+	)
+	return planStr
+}
+
+// maskRunError returns the message of runErr for text posted publicly, e.g.
+// a pull request comment or a commit status: without color codes, and with
+// the secrets and the matches of the patterns masked, with or without --redact.
+//
+// Parameters:
+//
+//	runErr - The error the run ended with.
+//	secrets - Values masked in the message, from runSecrets.
+//	patterns - Patterns whose matches are masked, from 'redactPatterns'.
+//
+// Returns:
+//
+//	string - The masked message.
+func maskRunError(runErr error, secrets []string, patterns []*regexp.Regexp) string {
+	msg := ansiEscape.ReplaceAllString(runErr.Error(), "")
+	// Replace longer values first so a value containing another is fully masked
+	secrets = slices.Clone(secrets)
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	for _, secret := range secrets {
+		msg = strings.ReplaceAll(msg, secret, sensitivePlaceholder)
+	}
+	return redactPlan(msg, nil, patterns)
+}
+
+// sensitivePlanValues collects the distinct non-empty string values the
+// structured plan marks as sensitive, in resource and output changes.
+func sensitivePlanValues(plan *tfjson.Plan) []string {
+	if plan == nil {
+		return nil
+	}
+
+	seen := map[string]struct{}{}
+	collect := func(c *tfjson.Change) {
+		if c == nil {
+			return
+		}
+		collectSensitive(c.Before, c.BeforeSensitive, seen)
+		collectSensitive(c.After, c.AfterSensitive, seen)
+	}
+	for _, rc := range plan.ResourceChanges {
+		collect(rc.Change)
+	}
+	for _, rc := range plan.ResourceDrift {
+		collect(rc.Change)
+	}
+	for _, oc := range plan.OutputChanges {
+		collect(oc)
+	}
+
+	values := make([]string, 0, len(seen))
+	for v := range seen {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// collectSensitive walks value alongside its sensitivity marking. A marking
+// of true covers the whole value, otherwise it mirrors the value's shape.
+func collectSensitive(value, marking any, seen map[string]struct{}) {
+	switch m := marking.(type) {
+	case bool:
+		if m {
+			collectStrings(value, seen)
+		}
+	case map[string]any:
+		v, ok := value.(map[string]any)
+		if !ok {
+			return
+		}
+		for k, km := range m {
+			collectSensitive(v[k], km, seen)
+		}
+	case []any:
+		v, ok := value.([]any)
+		if !ok {
+			return
+		}
+		for i := 0; i < len(m) && i < len(v); i++ {
+			collectSensitive(v[i], m[i], seen)
+		}
+	}
+}
+
+// collectStrings adds every non-empty string within value to seen.
+func collectStrings(value any, seen map[string]struct{}) {
+	switch v := value.(type) {
+	case string:
+		if v != "" {
+			seen[v] = struct{}{}
+		}
+	case map[string]any:
+		for _, e := range v {
+			collectStrings(e, seen)
+		}
+	case []any:
+		for _, e := range v {
+			collectStrings(e, seen)
+		}
+	}
+}
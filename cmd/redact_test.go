@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactPlan(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	// A provider that does not mark the attribute sensitive in its schema
+	// still reports it in plan text, while the module marks it sensitive
+	leaky := `  # module.db.aws_db_instance.main must be replaced
+-/+ resource "aws_db_instance" "main" {
+      ~ engine_version = "15.4" -> "16.1" # forces replacement
+      ~ password       = "old-db-password" -> "new-db-password"
+      ~ connection     = "postgres://admin:new-db-password@db:5432"
+    }`
+
+	testCases := []struct {
+		name     string
+		patterns []*regexp.Regexp
+		want     []string
+		notWant  []string
+		noPlan   bool
+	}{
+		{
+			name:    "Values marked sensitive are masked",
+			want:    []string{`~ password       = (sensitive value) -> (sensitive value)`, `"15.4" -> "16.1"`},
+			notWant: []string{`"old-db-password"`, `"new-db-password"`},
+		},
+		{
+			name:     "User patterns are masked",
+			patterns: []*regexp.Regexp{regexp.MustCompile(`postgres://[^"]+`)},
+			want:     []string{`~ connection     = "(sensitive value)"`},
+			notWant:  []string{"new-db-password"},
+		},
+		{
+			name:     "Without a structured plan only patterns apply",
+			patterns: []*regexp.Regexp{regexp.MustCompile(`old-db-password`)},
+			want:     []string{`"(sensitive value)" -> "new-db-password"`},
+			noPlan:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			plan := loadPlanFixture(t, "changes.json")
+			if tc.noPlan {
+				plan = nil
+			}
+
+			got := redactPlan(leaky, plan, tc.patterns)
+
+			for _, w := range tc.want {
+				require.Contains(t, got, w)
+			}
+			for _, nw := range tc.notWant {
+				require.NotContains(t, got, nw)
+			}
+		})
+	}
+}
+
+func TestSensitivePlanValues(t *testing.T) {
+	got := sensitivePlanValues(loadPlanFixture(t, "changes.json"))
+
+	require.Equal(t, []string{"new-db-password", "old-db-password"}, got)
+	require.Nil(t, sensitivePlanValues(nil))
+}
+
+func TestCompileRedactPatterns(t *testing.T) {
+	t.Cleanup(func() { viper.Set("redactPatterns", []string{}) })
+
+	viper.Set("redactPatterns", []string{`AKIA[0-9A-Z]{16}`})
+	patterns, err := compileRedactPatterns()
+	require.NoError(t, err)
+	require.Len(t, patterns, 1)
+
+	viper.Set("redactPatterns", []string{`(unclosed`})
+	_, err = compileRedactPatterns()
+	require.ErrorContains(t, err, "(unclosed")
+}
@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// repoRelativePaths are the parameters naming files that belong to the
+// repository, resolved against the repository root when set in a config file,
+// by their flag, if any
+var repoRelativePaths = []struct{ param, flag string }{
+	{"mdTemplate", "md-template"},
+	{"prBodyFile", "pr-body-file"},
+	{"templateFile", "template-file"},
+	{"templateSmall", ""},
+	{"templateLarge", ""},
+	{"templateDestroy", ""},
+}
+
+// resolveRepoRoot returns the repository root relative paths in the config
+// file are resolved against: --repo-root when set, otherwise the root of the
+// git repository containing the current directory. Outside of a repository
+// it returns "" and paths stay relative to the current directory.
+//
+// Parameters:
+//
+//	ctx - The context for the git command.
+//	git - The GitRunner used to find the repository root.
+//	root - The 'repoRoot' parameter, may be empty.
+//
+// Returns:
+//
+//	string - The absolute repository root, or "".
+//	error - An error if 'repoRoot' isn't a directory.
+func resolveRepoRoot(ctx context.Context, git GitRunner, root string) (string, error) {
+	if root != "" {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return "", fmt.Errorf("invalid 'repo-root' (%q): %w", root, err)
+		}
+		info, err := os.Stat(abs)
+		if err != nil {
+			return "", fmt.Errorf("invalid 'repo-root' (%q): %w", root, err)
+		}
+		if !info.IsDir() {
+			return "", fmt.Errorf("invalid 'repo-root' (%q): not a directory", root)
+		}
+		return abs, nil
+	}
+
+	out, err := git.Run(ctx, "rev-parse", "--show-toplevel")
+	if err != nil {
+		Logger.Debugf("Not in a git repository, relative paths are resolved against the current directory: %v", err)
+		return "", nil
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// anchorRepoPaths resolves the relative repoRelativePaths set in the config
+// file against root, so the same config works from any subdirectory. Paths
+// passed as flags stay relative to the current directory, as typed.
+//
+// Parameters:
+//
+//	cmd - The command whose flags were passed.
+//	root - The repository root from resolveRepoRoot, nothing is resolved if "".
+func anchorRepoPaths(cmd *cobra.Command, root string) {
+	if root == "" {
+		return
+	}
+	for _, p := range repoRelativePaths {
+		value := viper.GetString(p.param)
+		if value == "" || filepath.IsAbs(value) || (p.flag != "" && cmd.Flags().Changed(p.flag)) {
+			continue
+		}
+		anchored := filepath.Join(root, value)
+		Logger.Debugf("Resolved '%s' %q to %s", p.param, value, anchored)
+		viper.Set(p.param, anchored)
+	}
+}
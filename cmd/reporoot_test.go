@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveRepoRoot(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	t.Chdir(root)
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	git := &RealGitRunner{}
+	ctx := context.Background()
+	_, err = git.Run(ctx, "init", "-q")
+	require.NoError(t, err)
+	for _, file := range []string{".github/plan.md.tmpl", ".github/body.md", "infra/net/main.tf"} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), 0o750))
+		require.NoError(t, os.WriteFile(file, []byte("{{ .Plan }}\n"), 0o600))
+	}
+	t.Chdir(filepath.Join("infra", "net"))
+
+	t.Run("Detected from a subdirectory", func(t *testing.T) {
+		got, err := resolveRepoRoot(ctx, git, "")
+
+		require.NoError(t, err)
+		require.Equal(t, root, got)
+	})
+
+	t.Run("Outside of a repository", func(t *testing.T) {
+		notRepo := new(MockGitRunner)
+		notRepo.On("Run", mock.Anything, []string{"rev-parse", "--show-toplevel"}).
+			Return([]byte(nil), errors.New("git rev-parse: exit status 128: fatal: not a git repository"))
+
+		got, err := resolveRepoRoot(ctx, notRepo, "")
+
+		require.NoError(t, err)
+		require.Empty(t, got)
+		notRepo.AssertExpectations(t)
+	})
+
+	t.Run("Explicit root", func(t *testing.T) {
+		got, err := resolveRepoRoot(ctx, git, "../..")
+		require.NoError(t, err)
+		require.Equal(t, root, got)
+
+		_, err = resolveRepoRoot(ctx, git, "main.tf")
+		require.ErrorContains(t, err, "invalid 'repo-root' (\"main.tf\"): not a directory")
+		_, err = resolveRepoRoot(ctx, git, "missing")
+		require.ErrorContains(t, err, "invalid 'repo-root' (\"missing\")")
+	})
+
+	t.Run("Config paths resolve against the root", func(t *testing.T) {
+		loadConfig(t, "mdTemplate = '.github/plan.md.tmpl'\nprBodyFile = '.github/body.md'\n")
+		cmd := &cobra.Command{}
+		cmd.Flags().String("md-template", "", "")
+		cmd.Flags().String("pr-body-file", "", "")
+		require.NoError(t, viper.BindPFlag("mdTemplate", cmd.Flags().Lookup("md-template")))
+		require.NoError(t, viper.BindPFlag("prBodyFile", cmd.Flags().Lookup("pr-body-file")))
+		// Flags stay relative to the current directory
+		require.NoError(t, cmd.Flags().Set("pr-body-file", "main.tf"))
+
+		anchorRepoPaths(cmd, root)
+
+		require.Equal(t, filepath.Join(root, ".github", "plan.md.tmpl"), viper.GetString("mdTemplate"))
+		require.Equal(t, "main.tf", viper.GetString("prBodyFile"))
+		_, err := loadMarkdownTemplate(viper.GetString("mdTemplate"))
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,210 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/spf13/viper"
+)
+
+// reportVersion is the version of the --report schema. Fields may be added
+// without changing it; it changes when one is removed or changes meaning.
+const reportVersion = 1
+
+// Rule IDs of the issues in the report
+const (
+	ruleDestroy           = "gh-tp/destroy"
+	ruleReplace           = "gh-tp/replace"
+	ruleDrift             = "gh-tp/drift"
+	ruleProtectedResource = "gh-tp/protected-resource"
+)
+
+// Severities of the issues in the report, as SARIF's levels
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+	severityNote    = "note"
+)
+
+// report is the JSON written to --report.
+type report struct {
+	Version int           `json:"version"`
+	Tool    reportTool    `json:"tool"`
+	Issues  []reportIssue `json:"issues"`
+}
+
+// reportTool names what produced the report.
+type reportTool struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// reportIssue is one issue found in a plan.
+type reportIssue struct {
+	RuleID   string `json:"ruleId"`
+	Severity string `json:"severity"`
+	Address  string `json:"address"`
+	Message  string `json:"message"`
+	Dir      string `json:"dir"`
+}
+
+// compileProtectedResources checks the 'protectedResources' address patterns.
+//
+// Returns:
+//
+//	[]string - The patterns, in the order given.
+//	error - An error naming the first malformed pattern.
+func compileProtectedResources() ([]string, error) {
+	patterns := viper.GetStringSlice("protectedResources")
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid protected resource pattern %q: %w", p, err)
+		}
+	}
+	return patterns, nil
+}
+
+// isProtected reports whether address matches one of the protected patterns.
+func isProtected(address string, patterns []string) bool {
+	for _, p := range patterns {
+		// The patterns were checked by compileProtectedResources
+		if ok, _ := path.Match(p, address); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// planIssues lists the issues of one structured plan: resources destroyed or
+// replaced, protected resources among them, and resources that drifted.
+//
+// Parameters:
+//
+//	dir - The directory of the plan, reported with each issue.
+//	plan - The structured plan from 'show -json'.
+//	protected - Address patterns of resources that must not be destroyed.
+//
+// Returns:
+//
+//	[]reportIssue - The issues, in plan order.
+func planIssues(dir string, plan *tfjson.Plan, protected []string) []reportIssue {
+	if plan == nil {
+		return nil
+	}
+
+	var issues []reportIssue
+	for _, rc := range plan.ResourceChanges {
+		if rc.Change == nil {
+			continue
+		}
+		var verb string
+		switch actions := rc.Change.Actions; {
+		case actions.Replace():
+			verb = "replaced"
+			issues = append(issues, reportIssue{
+				RuleID:   ruleReplace,
+				Severity: severityWarning,
+				Address:  rc.Address,
+				Message:  "The resource will be destroyed and recreated.",
+				Dir:      dir,
+			})
+		case actions.Delete():
+			verb = "destroyed"
+			issues = append(issues, reportIssue{
+				RuleID:   ruleDestroy,
+				Severity: severityWarning,
+				Address:  rc.Address,
+				Message:  "The resource will be destroyed.",
+				Dir:      dir,
+			})
+		default:
+			continue
+		}
+		if isProtected(rc.Address, protected) {
+			issues = append(issues, reportIssue{
+				RuleID:   ruleProtectedResource,
+				Severity: severityError,
+				Address:  rc.Address,
+				Message:  fmt.Sprintf("The resource is protected by 'protectedResources' but will be %s.", verb),
+				Dir:      dir,
+			})
+		}
+	}
+
+	for _, d := range planDrift(plan) {
+		issues = append(issues, reportIssue{
+			RuleID:   ruleDrift,
+			Severity: severityNote,
+			Address:  d.Address,
+			Message:  fmt.Sprintf("The resource was %s outside of Terraform/OpenTofu.", d.Change),
+			Dir:      dir,
+		})
+	}
+	return issues
+}
+
+// newReport builds the report of the plans. Issues are sorted by directory,
+// address and rule so the report of an unchanged plan doesn't change.
+//
+// Parameters:
+//
+//	results - The plans, a nil JSON plan is skipped with a warning.
+//	protected - Address patterns of resources that must not be destroyed.
+//	version - The version of gh-tp.
+//
+// Returns:
+//
+//	report - The report, with an empty list when there are no issues.
+func newReport(results []planResult, protected []string, version string) report {
+	r := report{
+		Version: reportVersion,
+		Tool:    reportTool{Name: "gh-tp", Version: version},
+		Issues:  []reportIssue{},
+	}
+	for _, result := range results {
+		if result.JSON == nil {
+			Logger.Warnf("The structured plan of %q could not be read, it's missing from the report.", result.Dir)
+			continue
+		}
+		r.Issues = append(r.Issues, planIssues(result.Dir, result.JSON, protected)...)
+	}
+	sort.SliceStable(r.Issues, func(i, j int) bool {
+		a, b := r.Issues[i], r.Issues[j]
+		if a.Dir != b.Dir {
+			return a.Dir < b.Dir
+		}
+		if a.Address != b.Address {
+			return a.Address < b.Address
+		}
+		return a.RuleID < b.RuleID
+	})
+	return r
+}
+
+// writeReport writes the report as indented JSON.
+//
+// Parameters:
+//
+//	reportPath - The file to write.
+//	r - The report from newReport.
+//	mode - The permissions of the file.
+//
+// Returns:
+//
+//	error - Any error encountered.
+func writeReport(reportPath string, r report, mode os.FileMode) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the report: %w", err)
+	}
+	if err = writeOutput(reportPath, string(data)+"\n", mode); err != nil {
+		return fmt.Errorf("failed to write the report %s: %w", reportPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileProtectedResources(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	viper.Set("protectedResources", []string{"module.db.*", "aws_s3_bucket.*"})
+	patterns, err := compileProtectedResources()
+	require.NoError(t, err)
+	require.Equal(t, []string{"module.db.*", "aws_s3_bucket.*"}, patterns)
+
+	viper.Set("protectedResources", []string{"module.db.[*"})
+	_, err = compileProtectedResources()
+	require.ErrorContains(t, err, `invalid protected resource pattern "module.db.[*"`)
+}
+
+func TestNewReport(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	results := []planResult{
+		{Dir: "network", JSON: loadPlanFixture(t, "changes.json")},
+		{Dir: "db", JSON: loadPlanFixture(t, "drift.json")},
+		{Dir: "unreadable"},
+	}
+
+	r := newReport(results, []string{"module.db.*"}, "1.2.3")
+
+	require.Equal(t, reportVersion, r.Version)
+	require.Equal(t, reportTool{Name: "gh-tp", Version: "1.2.3"}, r.Tool)
+	require.Equal(t, []reportIssue{
+		{
+			RuleID:   ruleDrift,
+			Severity: severityNote,
+			Address:  "aws_db_instance.main",
+			Message:  "The resource was updated outside of Terraform/OpenTofu.",
+			Dir:      "db",
+		},
+		{
+			RuleID:   ruleDrift,
+			Severity: severityNote,
+			Address:  "module.network.aws_subnet.legacy",
+			Message:  "The resource was deleted outside of Terraform/OpenTofu.",
+			Dir:      "db",
+		},
+		{
+			RuleID:   ruleDrift,
+			Severity: severityNote,
+			Address:  "aws_security_group.web",
+			Message:  "The resource was updated outside of Terraform/OpenTofu.",
+			Dir:      "network",
+		},
+		{
+			RuleID:   ruleProtectedResource,
+			Severity: severityError,
+			Address:  "module.db.aws_db_instance.main",
+			Message:  "The resource is protected by 'protectedResources' but will be replaced.",
+			Dir:      "network",
+		},
+		{
+			RuleID:   ruleReplace,
+			Severity: severityWarning,
+			Address:  "module.db.aws_db_instance.main",
+			Message:  "The resource will be destroyed and recreated.",
+			Dir:      "network",
+		},
+		{
+			RuleID:   ruleDestroy,
+			Severity: severityWarning,
+			Address:  "module.network.aws_subnet.legacy",
+			Message:  "The resource will be destroyed.",
+			Dir:      "network",
+		},
+	}, r.Issues)
+
+	t.Run("No issues is an empty list", func(t *testing.T) {
+		r := newReport([]planResult{{Dir: ".", JSON: loadPlanFixture(t, "no-changes.json")}}, nil, "")
+
+		require.NotNil(t, r.Issues)
+		require.Empty(t, r.Issues)
+	})
+}
+
+func TestWriteReport(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	r := newReport(
+		[]planResult{{Dir: ".", JSON: loadPlanFixture(t, "changes.json")}},
+		[]string{"module.network.*"},
+		"1.2.3",
+	)
+
+	require.NoError(t, writeReport(reportPath, r, 0o600))
+
+	data, err := os.ReadFile(reportPath)
+	require.NoError(t, err)
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.InDelta(t, 1, decoded["version"], 0)
+
+	issues, ok := decoded["issues"].([]any)
+	require.True(t, ok)
+	var rules []string
+	for _, raw := range issues {
+		issue, ok := raw.(map[string]any)
+		require.True(t, ok)
+		if issue["address"] == "module.network.aws_subnet.legacy" {
+			rules = append(rules, issue["ruleId"].(string)+" "+issue["severity"].(string))
+		}
+	}
+	require.Equal(t, []string{"gh-tp/destroy warning", "gh-tp/protected-resource error"}, rules)
+
+	info, err := os.Stat(reportPath)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// githubName matches a GitHub user, organization or team slug
+var githubName = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9_.]|-[A-Za-z0-9])*$`)
+
+// errReviewersNotRequestable is returned when a required reviewer can't be requested
+var errReviewersNotRequestable = errors.New("required reviewers can't be requested")
+
+// defaultReviewerClient is the ReviewerClient used outside of tests
+var defaultReviewerClient ReviewerClient = &RealReviewerClient{runner: defaultGhRunner}
+
+// ReviewerClient is an interface for checking who can be requested to review pull requests
+// This allows for dependency injection and easier testing
+type ReviewerClient interface {
+	IsCollaborator(ctx context.Context, login string) (bool, error)
+	TeamHasAccess(ctx context.Context, org, team string) (bool, error)
+}
+
+// RealReviewerClient implements the ReviewerClient interface with the REST API through 'gh api'
+type RealReviewerClient struct {
+	runner GhRunner
+}
+
+// IsCollaborator reports whether login is a collaborator on the repository,
+// which the API answers with 204, or 404 when they're not.
+func (c *RealReviewerClient) IsCollaborator(ctx context.Context, login string) (bool, error) {
+	_, err := c.runner.Run(ctx, "api", "repos/{owner}/{repo}/collaborators/"+login)
+	return apiExists(err, "unable to check collaborator "+login)
+}
+
+// TeamHasAccess reports whether the team org/team has access to the
+// repository, which the API answers with 204, or 404 when it hasn't.
+func (c *RealReviewerClient) TeamHasAccess(ctx context.Context, org, team string) (bool, error) {
+	_, err := c.runner.Run(ctx, "api", fmt.Sprintf("orgs/%s/teams/%s/repos/{owner}/{repo}", org, team))
+	return apiExists(err, fmt.Sprintf("unable to check team %s/%s", org, team))
+}
+
+// apiExists maps the error of a 'gh api' existence check to its answer: false
+// for a 404, true for success, and the error otherwise.
+func apiExists(err error, what string) (bool, error) {
+	switch {
+	case err == nil:
+		return true, nil
+	case strings.Contains(err.Error(), "HTTP 404"):
+		return false, nil
+	default:
+		return false, fmt.Errorf("%s: %w", what, err)
+	}
+}
+
+// loadRequiredReviewers reads and validates 'requiredReviewers': users, e.g.
+// alice or @alice, and teams, e.g. acme/security.
+//
+// Returns:
+//
+//	[]string - The reviewers without a leading @ or duplicates, in the order given.
+//	error - An error naming the first invalid reviewer.
+func loadRequiredReviewers() ([]string, error) {
+	var reviewers []string
+	seen := map[string]bool{}
+	for _, raw := range viper.GetStringSlice("requiredReviewers") {
+		reviewer := strings.TrimPrefix(strings.TrimSpace(raw), "@")
+		org, team, isTeam := strings.Cut(reviewer, "/")
+		if !githubName.MatchString(org) || (isTeam && !githubName.MatchString(team)) {
+			return nil, fmt.Errorf("invalid 'requiredReviewers' entry %q: expected a user or an org/team", raw)
+		}
+		if key := strings.ToLower(reviewer); !seen[key] {
+			seen[key] = true
+			reviewers = append(reviewers, reviewer)
+		}
+	}
+	return reviewers, nil
+}
+
+// verifyRequiredReviewers checks that every required reviewer can be
+// requested on the repository's pull requests, so no pull request is created
+// without the reviews a team mandates.
+//
+// Parameters:
+//
+//	ctx - The context controlling the requests
+//	client - The ReviewerClient used
+//	reviewers - The reviewers from loadRequiredReviewers
+//
+// Returns:
+//
+//	error - errReviewersNotRequestable naming every reviewer that can't be requested, or any other error encountered
+func verifyRequiredReviewers(ctx context.Context, client ReviewerClient, reviewers []string) error {
+	var invalid []string
+	for _, reviewer := range reviewers {
+		var ok bool
+		var err error
+		reason := "not a collaborator"
+		if org, team, isTeam := strings.Cut(reviewer, "/"); isTeam {
+			ok, err = client.TeamHasAccess(ctx, org, team)
+			reason = "team has no access to the repository"
+		} else {
+			ok, err = client.IsCollaborator(ctx, reviewer)
+		}
+		if err != nil {
+			return err
+		}
+		if !ok {
+			invalid = append(invalid, fmt.Sprintf("%s (%s)", reviewer, reason))
+		}
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("%w: %s", errReviewersNotRequestable, strings.Join(invalid, ", "))
+	}
+	Logger.Debugf("Required reviewers can be requested: %v", reviewers)
+	return nil
+}
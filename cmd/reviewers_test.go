@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockReviewerClient is a mock implementation of ReviewerClient
+type MockReviewerClient struct {
+	mock.Mock
+}
+
+func (m *MockReviewerClient) IsCollaborator(ctx context.Context, login string) (bool, error) {
+	called := m.Called(ctx, login)
+	return called.Bool(0), called.Error(1)
+}
+
+func (m *MockReviewerClient) TeamHasAccess(ctx context.Context, org, team string) (bool, error) {
+	called := m.Called(ctx, org, team)
+	return called.Bool(0), called.Error(1)
+}
+
+func TestRealReviewerClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Collaborator", func(t *testing.T) {
+		runner := new(MockGhRunner)
+		runner.On("Run", mock.Anything, []string{"api", "repos/{owner}/{repo}/collaborators/alice"}).Return([]byte(nil), nil)
+		runner.On("Run", mock.Anything, []string{"api", "repos/{owner}/{repo}/collaborators/mallory"}).
+			Return(nil, errors.New("gh api: exit status 1: Not Found (HTTP 404)"))
+		client := &RealReviewerClient{runner: runner}
+
+		ok, err := client.IsCollaborator(ctx, "alice")
+		require.NoError(t, err)
+		require.True(t, ok)
+		ok, err = client.IsCollaborator(ctx, "mallory")
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+
+	t.Run("Team", func(t *testing.T) {
+		runner := new(MockGhRunner)
+		runner.On("Run", mock.Anything, []string{"api", "orgs/acme/teams/security/repos/{owner}/{repo}"}).
+			Return([]byte(nil), nil)
+		client := &RealReviewerClient{runner: runner}
+
+		ok, err := client.TeamHasAccess(ctx, "acme", "security")
+
+		require.NoError(t, err)
+		require.True(t, ok)
+	})
+
+	t.Run("Other API errors", func(t *testing.T) {
+		runner := new(MockGhRunner)
+		runner.On("Run", mock.Anything, mock.Anything).Return(nil, errors.New("gh api: exit status 1: HTTP 502"))
+
+		_, err := (&RealReviewerClient{runner: runner}).IsCollaborator(ctx, "alice")
+
+		require.ErrorContains(t, err, "unable to check collaborator alice: gh api: exit status 1: HTTP 502")
+	})
+}
+
+func TestLoadRequiredReviewers(t *testing.T) {
+	t.Run("Users and teams", func(t *testing.T) {
+		loadConfig(t, "requiredReviewers = ['@alice', 'acme/security', 'Alice', 'bob-smith']\n")
+
+		reviewers, err := loadRequiredReviewers()
+
+		require.NoError(t, err)
+		require.Equal(t, []string{"alice", "acme/security", "bob-smith"}, reviewers)
+	})
+
+	for _, entry := range []string{"", "acme/", "-alice", "acme/security/leads", "alice bob"} {
+		t.Run("Invalid "+entry, func(t *testing.T) {
+			loadConfig(t, "requiredReviewers = ['"+entry+"']\n")
+
+			_, err := loadRequiredReviewers()
+
+			require.ErrorContains(t, err, "invalid 'requiredReviewers' entry")
+		})
+	}
+}
+
+func TestVerifyRequiredReviewers(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	ctx := context.Background()
+
+	t.Run("All can be requested", func(t *testing.T) {
+		client := new(MockReviewerClient)
+		client.On("IsCollaborator", mock.Anything, "alice").Return(true, nil)
+		client.On("TeamHasAccess", mock.Anything, "acme", "security").Return(true, nil)
+
+		require.NoError(t, verifyRequiredReviewers(ctx, client, []string{"alice", "acme/security"}))
+		client.AssertExpectations(t)
+	})
+
+	t.Run("Every invalid reviewer is named", func(t *testing.T) {
+		client := new(MockReviewerClient)
+		client.On("IsCollaborator", mock.Anything, "alice").Return(true, nil)
+		client.On("IsCollaborator", mock.Anything, "mallory").Return(false, nil)
+		client.On("TeamHasAccess", mock.Anything, "acme", "security").Return(false, nil)
+
+		err := verifyRequiredReviewers(ctx, client, []string{"alice", "mallory", "acme/security"})
+
+		require.ErrorIs(t, err, errReviewersNotRequestable)
+		require.EqualError(t, err, "required reviewers can't be requested: mallory (not a collaborator), "+
+			"acme/security (team has no access to the repository)")
+	})
+
+	t.Run("API errors are returned", func(t *testing.T) {
+		client := new(MockReviewerClient)
+		client.On("IsCollaborator", mock.Anything, "alice").Return(false, errors.New("HTTP 502"))
+
+		err := verifyRequiredReviewers(ctx, client, []string{"alice"})
+
+		require.EqualError(t, err, "HTTP 502")
+		require.NotErrorIs(t, err, errReviewersNotRequestable)
+	})
+}
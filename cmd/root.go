@@ -8,28 +8,81 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
 var (
-	Verbose bool
-	cfgFile string
+	Verbose  bool
+	cfgFiles []string
+	// The files readConfigFiles read, in order, for tables decoded outside of viper
+	layeredConfigFiles []string
 )
 
 // Environment variable for init-phase debugging
 const ghTpInitDebugEnv = "GH_TP_INIT_DEBUG" // Or your preferred name
 
+// noInitDebugEnvFlag disables ghTpInitDebugEnv. It's read before the flags are
+// parsed, like the environment variable.
+const noInitDebugEnvFlag = "no-init-debug-env"
+
+// flagAliases are flag names 'gh pr create' users expect, mapped to tp's
+var flagAliases = map[string]string{"title": "pr-title"}
+
+// normalizeFlagName resolves flagAliases, so --title sets --pr-title.
+func normalizeFlagName(_ *pflag.FlagSet, name string) pflag.NormalizedName {
+	if flag, ok := flagAliases[name]; ok {
+		name = flag
+	}
+	return pflag.NormalizedName(name)
+}
+
+// initDebugEnabled reports whether debug logging starts before the flags are
+// parsed: ghTpInitDebugEnv must parse as true, e.g. "1" or "true", and
+// --no-init-debug-env must not be in args.
+//
+// Parameters:
+//
+//	envValue - The value of ghTpInitDebugEnv.
+//	args - The command line arguments, without the program name.
+//
+// Returns:
+//
+//	bool - Whether to create the initial logger at debug level.
+func initDebugEnabled(envValue string, args []string) bool {
+	// If parsing fails (e.g., empty string), debug logging stays off
+	enabled, _ := strconv.ParseBool(envValue)
+	if !enabled {
+		return false
+	}
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if !strings.HasPrefix(arg, "--") || name != noInitDebugEnvFlag {
+			continue
+		}
+		if !hasValue {
+			return false
+		}
+		if ignore, err := strconv.ParseBool(value); err == nil {
+			enabled = !ignore
+		}
+	}
+	return enabled
+}
+
 func Execute() {
 	// Initial Logger -- InfoLevel
 	createLogger(false)
-	// Check ENV VAR for Initial Verbosity
-	debugEnvVal := os.Getenv(ghTpInitDebugEnv)
-	// Parse bool allows "true", "TRUE", "True", "1"
-	initialVerbose, _ := strconv.ParseBool(debugEnvVal)
-	// If parsing fails (e.g., empty string), initialVerbose remains false
+	// Check ENV VAR for Initial Verbosity, unless --no-init-debug-env is passed
+	initialVerbose := initDebugEnabled(os.Getenv(ghTpInitDebugEnv), os.Args[1:])
 
 	// Create logger based on ENV VAR
 	createLogger(initialVerbose)
@@ -40,70 +93,612 @@ func Execute() {
 		initialVerbose,
 	)
 
-	rootCmd.PersistentFlags().BoolVarP(&Verbose, "verbose", "v", false, "verbose output")
-	rootCmd.Flags().
-		StringP("binary", "b", "", "expect either 'tofu' or 'terraform'. Must exist on your $PATH.")
-	rootCmd.Flags().
+	defineFlags(rootCmd.Flags(), rootCmd.PersistentFlags())
+	bindFlags(rootCmd.Flags(), rootCmd.PersistentFlags())
+
+	Logger.Debug("[EXECUTE_DEBUG] Calling rootCmd.Execute()...")
+	executeErr := rootCmd.Execute()
+	Logger.Debugf("[EXECUTE_DEBUG] rootCmd.Execute() returned. Error: %v", executeErr)
+
+	// ensure Logger was created
+	if Logger == nil {
+		// This should ideally never happen if initConfig runs correctly
+		fmt.Fprintln(os.Stderr, "[EXECUTE_DEBUG] FATAL: Logger is nil after Execute()!")
+		// Create a fallback logger just to report the final state
+		if executeErr != nil {
+			Logger.Errorf("Command failed with error (logger was nil initially): %v", executeErr)
+			os.Exit(1)
+		} else {
+			Logger.Debug("Command finished (logger was nil initially).")
+		}
+	}
+
+	if executeErr != nil {
+		Logger.Debugf(
+			"[LOG 13] Exiting(1) because rootCmd.Execute() returned error: %v",
+			executeErr,
+		)
+		os.Exit(1)
+	}
+	Logger.Debug("[LOG 14] rootCmd.Execute() completed without error.")
+}
+
+// defineFlags defines the flags of tp on flags and its persistent flags on
+// persistent, rootCmd's outside of tests.
+func defineFlags(flags, persistent *pflag.FlagSet) {
+	persistent.BoolVarP(&Verbose, "verbose", "v", false, "verbose output")
+	persistent.
+		Bool(noInitDebugEnvFlag, false, "ignore "+ghTpInitDebugEnv+", e.g. when it's set globally in your shell.")
+	persistent.
+		Bool("ascii", false, "report created files with [OK] and [FAIL] rather than Unicode glyphs.")
+	persistent.
+		String("log-time-format", "", "timestamp format of log messages: RFC3339, RFC3339Nano, Kitchen or a Go time layout.")
+	flags.SetNormalizeFunc(normalizeFlagName)
+	flags.
+		StringP("binary", "b", "", "expect either 'tofu' or 'terraform' on your $PATH, or a path to either (e.g., /opt/tools/tofu-1.8.0/tofu).")
+	flags.
 		StringP("planFile", "o", "", "the name of the plan output file to be created by tp (e.g., plan.out).")
-	rootCmd.Flags().
+	flags.
 		StringP("mdFile", "m", "", "the name of the Markdown file to be created by tp (e.g., plan.md).")
-	rootCmd.Flags().
-		StringVarP(
-			&cfgFile,
+	flags.
+		String("generate-config-out", "", "write configuration generated for import blocks to this file (e.g., generated.tf).")
+	flags.
+		Duration("plan-cache-ttl", 0, "reuse an existing plan file younger than this duration if no sources changed (e.g., 10m).")
+	flags.
+		Bool("no-cache", false, "always run a new plan, ignoring --plan-cache-ttl.")
+	flags.
+		StringArray("env", nil, "set an environment variable for the plan process as KEY=VALUE. Can be repeated.")
+	flags.
+		Bool("skip-pr-on-no-changes", false, "do not open a pull request when the plan has no changes.")
+	flags.
+		Bool("group-by-module", false, "render one collapsible block per top-level module.")
+	flags.
+		Bool("check-fmt", false, "check formatting with 'fmt -check' before planning and warn about unformatted files.")
+	flags.
+		Bool("strict-fmt", false, "fail instead of warning when --check-fmt finds unformatted files.")
+	flags.
+		Bool("show-drift", true, "list resources changed outside of Terraform/OpenTofu in a separate section when there are any.")
+	flags.
+		Bool("show-outputs", false, "list the outputs the plan changes in a separate section when there are any.")
+	flags.
+		Bool("include-json", false, "include the JSON plan in a collapsible block after the plan, truncated if large.")
+	flags.
+		Bool("include-command", false, "include the plan command line in the Markdown so reviewers can reproduce the plan.")
+	flags.
+		Bool("allow-dangerous-dir", false, "allow planning in your home directory or the filesystem root.")
+	flags.
+		StringArray("dir", nil, "plan in this directory instead of the current one. Can be repeated to plan several directories.")
+	flags.
+		Bool("discover", false, "plan every directory below the current one containing .tf or .tofu files.")
+	flags.
+		StringArray("ignore", nil, "glob of directories --discover skips, in addition to .terraform, .git, modules and examples. Can be repeated.")
+	flags.
+		Int("concurrency", defaultConcurrency(), "maximum number of plans running at once with several --dir.")
+	flags.
+		String("run-id", "", "render the plan of an existing HCP Terraform run instead of planning locally (e.g., run-CZcmD7eagjhyX0vN).")
+	flags.
+		String("tfc-hostname", "", "hostname of HCP Terraform or Terraform Enterprise for --run-id. Default app.terraform.io.")
+	flags.
+		StringSlice("formats", []string{formatGitHub}, "output formats to write: github (the mdFile) and plain (the mdFile with a .txt extension).")
+	flags.
+		Duration("deadline", 0, "cancel the whole run, including init, plan and API calls, after this duration (e.g., 20m).")
+	flags.
+		StringArray("exclude", nil, "exclude a resource address from the plan (OpenTofu 1.9+). Can be repeated.")
+	flags.
+		String("binary-version", "", "report this binary version in the Markdown instead of the one that made the plan (e.g., 1.9.5).")
+	flags.
+		Bool("skip-if-no-tf-changes", false, "exit without planning when no .tf, .tofu or .tfvars file changed since --since-commit or the default branch.")
+	flags.
+		Bool("raw-whitespace", false, "write the Markdown as rendered, without normalizing trailing whitespace and newlines.")
+	flags.
+		Bool("plan-lock-info", true, "when the state is locked, report who holds the lock and since when instead of the raw error.")
+	flags.
+		String("status-check", "", "post a commit status with this context on HEAD, with the plan's result and change counts.")
+	flags.
+		String("repo-root", "", "resolve relative paths in the config file against this directory, the git repository's root by default.")
+	flags.
+		String("environment", "", "label the plan with this environment, e.g. prod, in the Markdown title and the pull request labels.")
+	flags.
+		String("plan-url", "", "download the plan output from this https URL and render it like stdin, instead of running the plan.")
+	flags.
+		Bool("icons", false, "prefix each resource of the plan output with the icon of its action: ➕ create, 🔄 update, ➖ destroy, ♻️ replace.")
+	flags.
+		Bool("changed-dirs-from-git", false, "plan only the stacks with files changed since the base branch, from 'git diff'.")
+	flags.
+		Bool("all", false, "plan every stack, overriding --changed-dirs-from-git.")
+	flags.
+		Bool("create-pr", false, "open a pull request for the current branch with the Markdown as its body, using 'gh pr create'.")
+	flags.
+		Bool("update", false, "replace the plan in the body of the branch's open pull request, keeping the rest of the body. With --create-pr, create it if there's none.")
+	flags.
+		Int("confirm-destroy-count", 0, "ask before opening or updating the pull request of a plan destroying more than this many resources. 0 never asks.")
+	flags.
+		BoolP("yes", "y", false, "proceed without asking for confirmation.")
+	flags.
+		StringP("template-file", "t", "", "pull request template a new pull request starts with, above the plan, e.g. .github/pull_request_template.md.")
+	flags.
+		String("remote", "", "remote whose branches --changed-dirs-from-git and --skip-if-no-tf-changes compare against. Default: the upstream's, or origin.")
+	flags.
+		String("base", "", "base branch of the pull request. Default from 'baseRules', or the repository's default branch.")
+	flags.
+		Bool("draft", false, "open the pull request as a draft.")
+	flags.
+		String("truncation-notice", "", "template of the notice ending a truncated plan, {{.ArtifactURL}} being the attached plan file's URL.")
+	flags.
+		String("format", planFormatText, "also save the structured plan with 'json', to the planFile with a .json extension. The planFile stays a saved plan.")
+	flags.
+		Bool("auto-init", false, "run 'init' and plan again when the plan fails because the directory isn't initialized.")
+	flags.
+		String("workspace", "", "select this workspace before planning. It must already exist.")
+	flags.
+		Bool("relaxed-filenames", false, "also allow +, ',', @, = and % in the names of the plan and Markdown files.")
+	flags.
+		StringArray("target", nil, "limit the plan to a resource address and its dependencies, as -target. Can be repeated.")
+	flags.
+		StringArray("var", nil, "set an input variable of the plan as -var, e.g. region=us-east-1. Can be repeated.")
+	flags.
+		String("report", "", "write the issues found in the plan, e.g. destroyed resources, as JSON to this file.")
+	flags.
+		StringArray("protected-resource", nil, "resource address pattern, e.g. 'module.db.*', reported as an error in --report when destroyed. Can be repeated.")
+	flags.
+		String("pr-group-key", "", "merge the plans of every run with this key on the branch into one pull request, each in its own section.")
+	flags.
+		String("min-version", "", "fail before planning if the binary is older than this version, e.g. 1.6.0.")
+	flags.
+		StringArray("var-file", nil, "pass a variable definitions file to the plan as -var-file. Can be repeated.")
+	flags.
+		Bool("ignore-missing-var-file", false, "skip a --var-file that doesn't exist with a warning, rather than failing.")
+	flags.
+		Bool("md-stdout", false, "also print the Markdown to stdout once every output file is written.")
+	flags.
+		Bool("dump-plan-env", false, "print the environment the plan would run with, secrets redacted, and exit.")
+	flags.
+		Bool("pr-comment-on-failure", false, "comment the plan's error on the branch's pull request when the plan fails.")
+	flags.
+		String("data-dir", "", "directory 'init' stores modules and providers in, set as TF_DATA_DIR for the plan. Default .terraform.")
+	flags.
+		String("since-commit", "", "list the planned changes stemming from files changed since this commit, e.g. origin/main.")
+	flags.
+		Bool("deterministic", false, "leave out volatile content so the same plan always produces the same Markdown.")
+	flags.
+		String("md-template", "", "Go template file rendering the whole Markdown, instead of the built-in layout.")
+	flags.
+		Bool("strict-extensions", false, "fail instead of warning when the planFile ends in .md or the mdFile doesn't.")
+	flags.
+		Bool("strict-mixed-files", false, "fail instead of warning when a directory has both .tf and .tofu files.")
+	flags.
+		String("plan-text", "", "also save the shown plan text verbatim to this file (e.g., plan.txt).")
+	flags.
+		Bool("require-template", false, "fail when no pull request template is configured or found in the repository.")
+	flags.
+		String("notify-webhook", "", "https URL to POST a JSON summary of the run to, e.g. a Slack incoming webhook.")
+	flags.
+		Bool("notify-required", false, "fail the run when the --notify-webhook request fails.")
+	flags.
+		Bool("step-summary", false, "append the Markdown to the GitHub Actions job summary. Default true when GITHUB_STEP_SUMMARY is set.")
+	flags.
+		String("gh-config-dir", "", "gh config directory, for the account used for GitHub. Default: GH_CONFIG_DIR or gh's default.")
+	flags.
+		Bool("auto-merge", false, "enable auto-merge on the pull request, merging it once its requirements are met.")
+	flags.
+		String("merge-method", "merge", "merge method of --auto-merge: merge, squash or rebase.")
+	flags.
+		String("milestone", "", "milestone to set on the pull request, by number or title.")
+	flags.
+		String("pr-title", "", "title of the pull request. Default the plan title, e.g. 'Terraform plan'.")
+	flags.
+		Int("pr-body-max-bytes", defaultPRBodyMaxBytes, "truncate the pull request body to this many bytes, for destinations with a limit other than GitHub's.")
+	flags.
+		Bool("pr-title-from-commit", false, "use the subject of the latest commit as the pull request title when --pr-title isn't set.")
+	flags.
+		String("pr-body-file", "", "existing Markdown file the plan is appended to, or inserted at '<!-- gh-tp:plan -->'.")
+	flags.
+		String("file-mode", "", "octal permission mode of the plan and Markdown files (e.g., 0640). Default 0600.")
+	flags.
+		Bool("allow-empty", false, "create a \"No changes\" Markdown file instead of failing when stdin is empty.")
+	flags.
+		Bool("attach-plan", false, "upload the binary plan file as a secret gist and link it in the Markdown.")
+	flags.
+		Bool("redact", false, "mask sensitive values in the plan before writing Markdown.")
+	flags.
+		StringArray("redact-pattern", nil, "regular expression whose matches are masked by --redact. Can be repeated.")
+	flags.
+		StringArrayVarP(
+			&cfgFiles,
 			"config",
 			"c",
-			"",
-			`config file to use not in (default lookup:
+			nil,
+			`config file to use, can be repeated with later files overriding earlier ones, not in (default lookup:
 			1. a .tp.toml file in your project's root
 			2. $XDG_CONFIG_HOME/gh-tp/.tp.toml
 			3. $HOME/.tp.toml)`,
 		)
+}
 
+// bindFlags binds the flags defineFlags defined to their parameters.
+func bindFlags(flags, persistent *pflag.FlagSet) {
 	// Local var for binding errors
 	var bindErr error
 
-	bindErr = viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
+	bindErr = viper.BindPFlag("verbose", persistent.Lookup("verbose"))
 	if bindErr != nil {
 		Logger.Fatalf("Internal error binding verbose flag: %v", bindErr)
 	}
-	bindErr = viper.BindPFlag("binary", rootCmd.Flags().Lookup("binary"))
+	bindErr = viper.BindPFlag("ascii", persistent.Lookup("ascii"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding ascii flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("logTimeFormat", persistent.Lookup("log-time-format"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding log-time-format flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("binary", flags.Lookup("binary"))
 	if bindErr != nil {
 		Logger.Fatalf("Internal error binding binary flag: %v", bindErr)
 	}
-	bindErr = viper.BindPFlag("planFile", rootCmd.Flags().Lookup("planFile"))
+	bindErr = viper.BindPFlag("planFile", flags.Lookup("planFile"))
 	if bindErr != nil {
 		Logger.Fatalf("Internal error binding planFile flag: %v", bindErr)
 	}
-	bindErr = viper.BindPFlag("mdFile", rootCmd.Flags().Lookup("mdFile"))
+	bindErr = viper.BindPFlag("mdFile", flags.Lookup("mdFile"))
 	if bindErr != nil {
 		Logger.Fatalf("Internal error binding mdFile flag: %v", bindErr)
 	}
 
-	Logger.Debug("[EXECUTE_DEBUG] Calling rootCmd.Execute()...")
-	executeErr := rootCmd.Execute()
-	Logger.Debugf("[EXECUTE_DEBUG] rootCmd.Execute() returned. Error: %v", executeErr)
+	bindErr = viper.BindPFlag("generateConfigOut", flags.Lookup("generate-config-out"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding generate-config-out flag: %v", bindErr)
+	}
 
-	// ensure Logger was created
-	if Logger == nil {
-		// This should ideally never happen if initConfig runs correctly
-		fmt.Fprintln(os.Stderr, "[EXECUTE_DEBUG] FATAL: Logger is nil after Execute()!")
-		// Create a fallback logger just to report the final state
-		if executeErr != nil {
-			Logger.Errorf("Command failed with error (logger was nil initially): %v", executeErr)
-			os.Exit(1)
-		} else {
-			Logger.Debug("Command finished (logger was nil initially).")
-		}
+	bindErr = viper.BindPFlag("planCacheTTL", flags.Lookup("plan-cache-ttl"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding plan-cache-ttl flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("noCache", flags.Lookup("no-cache"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding no-cache flag: %v", bindErr)
 	}
 
-	if executeErr != nil {
-		Logger.Debugf(
-			"[LOG 13] Exiting(1) because rootCmd.Execute() returned error: %v",
-			executeErr,
-		)
-		os.Exit(1)
+	// Not 'env', whose variable ENV POSIX shells export
+	bindErr = viper.BindPFlag("extraEnv", flags.Lookup("env"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding env flag: %v", bindErr)
+	}
+	bindErr = viper.BindEnv("extraEnv", "TP_ENV")
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding TP_ENV: %v", bindErr)
+	}
+
+	bindErr = viper.BindPFlag("skipPrOnNoChanges", flags.Lookup("skip-pr-on-no-changes"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding skip-pr-on-no-changes flag: %v", bindErr)
+	}
+
+	bindErr = viper.BindPFlag("groupByModule", flags.Lookup("group-by-module"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding group-by-module flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("checkFmt", flags.Lookup("check-fmt"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding check-fmt flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("strictFmt", flags.Lookup("strict-fmt"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding strict-fmt flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("showDrift", flags.Lookup("show-drift"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding show-drift flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("showOutputs", flags.Lookup("show-outputs"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding show-outputs flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("includeJson", flags.Lookup("include-json"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding include-json flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("includeCommand", flags.Lookup("include-command"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding include-command flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("allowDangerousDir", flags.Lookup("allow-dangerous-dir"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding allow-dangerous-dir flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("dirs", flags.Lookup("dir"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding dir flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("discover", flags.Lookup("discover"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding discover flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("ignore", flags.Lookup("ignore"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding ignore flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("concurrency", flags.Lookup("concurrency"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding concurrency flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("runId", flags.Lookup("run-id"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding run-id flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("tfcHostname", flags.Lookup("tfc-hostname"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding tfc-hostname flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("formats", flags.Lookup("formats"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding formats flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("deadline", flags.Lookup("deadline"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding deadline flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("exclude", flags.Lookup("exclude"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding exclude flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("binaryVersion", flags.Lookup("binary-version"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding binary-version flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("skipIfNoTfChanges", flags.Lookup("skip-if-no-tf-changes"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding skip-if-no-tf-changes flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("rawWhitespace", flags.Lookup("raw-whitespace"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding raw-whitespace flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("planLockInfo", flags.Lookup("plan-lock-info"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding plan-lock-info flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("statusCheck", flags.Lookup("status-check"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding status-check flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("repoRoot", flags.Lookup("repo-root"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding repo-root flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("environment", flags.Lookup("environment"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding environment flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("planUrl", flags.Lookup("plan-url"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding plan-url flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("icons", flags.Lookup("icons"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding icons flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("changedDirsFromGit", flags.Lookup("changed-dirs-from-git"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding changed-dirs-from-git flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("all", flags.Lookup("all"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding all flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("createPr", flags.Lookup("create-pr"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding create-pr flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("update", flags.Lookup("update"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding update flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("confirmDestroyCount", flags.Lookup("confirm-destroy-count"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding confirm-destroy-count flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("yes", flags.Lookup("yes"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding yes flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("templateFile", flags.Lookup("template-file"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding template-file flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("remote", flags.Lookup("remote"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding remote flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("base", flags.Lookup("base"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding base flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("draft", flags.Lookup("draft"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding draft flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("truncationNotice", flags.Lookup("truncation-notice"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding truncation-notice flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("planFormat", flags.Lookup("format"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding format flag: %v", bindErr)
+	}
+
+	bindErr = viper.BindPFlag("autoInit", flags.Lookup("auto-init"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding auto-init flag: %v", bindErr)
+	}
+
+	// Not 'workspace', whose variable WORKSPACE Jenkins sets on every job
+	bindErr = viper.BindPFlag("planWorkspace", flags.Lookup("workspace"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding workspace flag: %v", bindErr)
+	}
+	bindErr = viper.BindEnv("planWorkspace", "TP_WORKSPACE")
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding TP_WORKSPACE: %v", bindErr)
+	}
+
+	bindErr = viper.BindPFlag("relaxedFilenames", flags.Lookup("relaxed-filenames"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding relaxed-filenames flag: %v", bindErr)
+	}
+
+	bindErr = viper.BindPFlag("targets", flags.Lookup("target"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding target flag: %v", bindErr)
+	}
+
+	bindErr = viper.BindPFlag("vars", flags.Lookup("var"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding var flag: %v", bindErr)
+	}
+
+	bindErr = viper.BindPFlag("report", flags.Lookup("report"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding report flag: %v", bindErr)
+	}
+
+	bindErr = viper.BindPFlag("protectedResources", flags.Lookup("protected-resource"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding protected-resource flag: %v", bindErr)
+	}
+
+	bindErr = viper.BindPFlag("prGroupKey", flags.Lookup("pr-group-key"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding pr-group-key flag: %v", bindErr)
+	}
+
+	bindErr = viper.BindPFlag("minVersion", flags.Lookup("min-version"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding min-version flag: %v", bindErr)
+	}
+
+	bindErr = viper.BindPFlag("varFiles", flags.Lookup("var-file"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding var-file flag: %v", bindErr)
+	}
+
+	bindErr = viper.BindPFlag("ignoreMissingVarFile", flags.Lookup("ignore-missing-var-file"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding ignore-missing-var-file flag: %v", bindErr)
+	}
+
+	bindErr = viper.BindPFlag("mdStdout", flags.Lookup("md-stdout"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding md-stdout flag: %v", bindErr)
+	}
+
+	bindErr = viper.BindPFlag("dumpPlanEnv", flags.Lookup("dump-plan-env"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding dump-plan-env flag: %v", bindErr)
+	}
+
+	bindErr = viper.BindPFlag("prCommentOnFailure", flags.Lookup("pr-comment-on-failure"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding pr-comment-on-failure flag: %v", bindErr)
+	}
+
+	bindErr = viper.BindPFlag("dataDir", flags.Lookup("data-dir"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding data-dir flag: %v", bindErr)
+	}
+
+	bindErr = viper.BindPFlag("sinceCommit", flags.Lookup("since-commit"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding since-commit flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("deterministic", flags.Lookup("deterministic"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding deterministic flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("mdTemplate", flags.Lookup("md-template"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding md-template flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("strictMixedFiles", flags.Lookup("strict-mixed-files"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding strict-mixed-files flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("strictExtensions", flags.Lookup("strict-extensions"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding strict-extensions flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("planText", flags.Lookup("plan-text"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding plan-text flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("requireTemplate", flags.Lookup("require-template"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding require-template flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("notifyWebhook", flags.Lookup("notify-webhook"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding notify-webhook flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("notifyRequired", flags.Lookup("notify-required"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding notify-required flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("stepSummary", flags.Lookup("step-summary"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding step-summary flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("ghConfigDir", flags.Lookup("gh-config-dir"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding gh-config-dir flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("autoMerge", flags.Lookup("auto-merge"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding auto-merge flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("mergeMethod", flags.Lookup("merge-method"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding merge-method flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("milestone", flags.Lookup("milestone"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding milestone flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("prTitle", flags.Lookup("pr-title"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding pr-title flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("prBodyMaxBytes", flags.Lookup("pr-body-max-bytes"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding pr-body-max-bytes flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("prTitleFromCommit", flags.Lookup("pr-title-from-commit"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding pr-title-from-commit flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("prBodyFile", flags.Lookup("pr-body-file"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding pr-body-file flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("fileMode", flags.Lookup("file-mode"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding file-mode flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("allowEmpty", flags.Lookup("allow-empty"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding allow-empty flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("attachPlan", flags.Lookup("attach-plan"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding attach-plan flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("redact", flags.Lookup("redact"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding redact flag: %v", bindErr)
+	}
+	bindErr = viper.BindPFlag("redactPatterns", flags.Lookup("redact-pattern"))
+	if bindErr != nil {
+		Logger.Fatalf("Internal error binding redact-pattern flag: %v", bindErr)
 	}
-	Logger.Debug("[LOG 14] rootCmd.Execute() completed without error.")
 }
 
 // init function defines flags and sets up version
@@ -118,33 +713,21 @@ func init() {
 func initConfig() {
 	Logger.Debug("[INITCONFIG_DEBUG] Entering initConfig()...")
 
-	configFile := ConfigFile{}
+	// An empty --config keeps the default lookup, as it did before the flag could be repeated
+	cfgFiles = slices.DeleteFunc(cfgFiles, func(file string) bool { return file == "" })
 
 	// Viper config setup
-	if cfgFile != "" {
-		// Path 1: Config file specified via -c / --config flag
-		viper.SetConfigFile(cfgFile)
-		cfgFile = configFile.Path
-		Logger.Debugf(
-			"[INITCONFIG_DEBUG] Using explicit config file from flag: %s",
-			cfgFile,
-		)
-		err := viper.ReadInConfig()
+	if len(cfgFiles) > 0 {
+		// Path 1: Config files specified via -c / --config flags
 		Logger.Debugf(
-			"[INITCONFIG_DEBUG] ReadInConfig (explicit file) returned error: %v",
-			err,
+			"[INITCONFIG_DEBUG] Using explicit config files from flag: %v",
+			cfgFiles,
 		)
-		if err != nil {
-			if errors.Is(err, fs.ErrNotExist) {
-				Logger.Error(
-					"Config file specified via --config not found.")
-				os.Exit(1)
-			} else {
-				Logger.Debugf("ERROR: Error reading specified config file %s: %v", cfgFile, err)
-			}
-		} else {
-			Logger.Debugf("[INITCONFIG_DEBUG] Successfully read config file: %s", viper.ConfigFileUsed())
+		if err := readConfigFiles(cfgFiles); err != nil {
+			Logger.Error(err)
+			os.Exit(1)
 		}
+		Logger.Debugf("[INITCONFIG_DEBUG] Successfully read config file: %s", viper.ConfigFileUsed())
 	} else {
 		// Path 2: No -c / --config flag, search default locations
 		Logger.Debug("[INITCONFIG_DEBUG] Searching default locations for .tp.toml...")
@@ -189,6 +772,17 @@ func initConfig() {
 	// Set AutomaticEnv AFTER attempting to read config
 	viper.AutomaticEnv()
 
+	// Apply the log timestamp format before the final logger is created
+	if format := viper.GetString("logTimeFormat"); format != "" {
+		layout, err := parseLogTimeFormat(format)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+		logTimeFormat = layout
+		createLogger(Verbose)
+	}
+
 	// Determine final verbosity from Viper
 	v := viper.IsSet("verbose")
 	if v {
@@ -202,3 +796,43 @@ func initConfig() {
 		Logger.Debug("Exiting initConfig() function.")
 	}
 }
+
+// readConfigFiles reads the config files passed with --config in order. Each
+// file is merged over the previous ones, so a later file overrides the keys it
+// sets, tables included, and keeps the others.
+//
+// Parameters:
+//
+//	files - The config files, in the order given.
+//
+// Returns:
+//
+//	error - An error naming the first file that doesn't exist or doesn't parse.
+func readConfigFiles(files []string) error {
+	layeredConfigFiles = nil
+	for i, file := range files {
+		info, err := os.Stat(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("config file %q specified via --config not found", file)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read config file %q: %w", file, err)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("config file %q specified via --config is a directory", file)
+		}
+
+		viper.SetConfigFile(file)
+		if i == 0 {
+			err = viper.ReadInConfig()
+		} else {
+			err = viper.MergeInConfig()
+		}
+		if err != nil {
+			return fmt.Errorf("invalid config file %q: %w", file, err)
+		}
+		layeredConfigFiles = append(layeredConfigFiles, file)
+		Logger.Debugf("[INITCONFIG_DEBUG] Read config file %d of %d: %s", i+1, len(files), file)
+	}
+	return nil
+}
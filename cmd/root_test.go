@@ -4,11 +4,15 @@ package cmd
 import (
 	"bytes"
 	"errors"
+	"os"
 	"os/exec"
 	"testing"
 
 	"github.com/charmbracelet/log"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // It's possible that a person would run gh tp and no config file exists. We need to handle it.
@@ -94,3 +98,68 @@ func TestAbsentMdFile(t *testing.T) {
 		assert.Equal(t, 1, exitError.ExitCode(), "Expected exit code 1")
 	}
 }
+
+func TestInitDebugEnabled(t *testing.T) {
+	tests := []struct {
+		name     string
+		envValue string
+		args     []string
+		want     bool
+	}{
+		{name: "Unset", envValue: "", want: false},
+		{name: "Enabled", envValue: "true", args: []string{"-v"}, want: true},
+		{name: "Not a bool", envValue: "off", want: false},
+		{name: "Override", envValue: "1", args: []string{"--no-init-debug-env"}, want: false},
+		{name: "Override after other flags", envValue: "1", args: []string{"-c", "a.toml", "--no-init-debug-env"}, want: false},
+		{name: "Override with a value", envValue: "1", args: []string{"--no-init-debug-env=true"}, want: false},
+		{name: "Override set to false", envValue: "1", args: []string{"--no-init-debug-env=false"}, want: true},
+		{name: "Override after --", envValue: "1", args: []string{"--", "--no-init-debug-env"}, want: true},
+		{name: "Override on a subcommand", envValue: "1", args: []string{"init", "--no-init-debug-env"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, initDebugEnabled(tt.envValue, tt.args))
+		})
+	}
+
+	t.Run("Override suppresses the early debug logger", func(t *testing.T) {
+		originalLogger := Logger
+		defer func() {
+			Logger = originalLogger
+		}()
+
+		createLogger(initDebugEnabled("true", nil))
+		require.Equal(t, log.DebugLevel, Logger.GetLevel())
+
+		createLogger(initDebugEnabled("true", []string{"--no-init-debug-env"}))
+		require.Equal(t, log.InfoLevel, Logger.GetLevel())
+	})
+}
+
+func TestBindFlagsEnv(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	t.Cleanup(viper.Reset)
+	flags := pflag.NewFlagSet("tp", pflag.ContinueOnError)
+	persistent := pflag.NewFlagSet("tp", pflag.ContinueOnError)
+	defineFlags(flags, persistent)
+	bindFlags(flags, persistent)
+	viper.AutomaticEnv()
+
+	t.Run("Variables set by CI are ignored", func(t *testing.T) {
+		t.Setenv("WORKSPACE", "/var/lib/jenkins/workspace/infra")
+		t.Setenv("ENV", "/home/u/.shrc")
+
+		require.Empty(t, viper.GetString("planWorkspace"))
+		require.Empty(t, viper.GetStringSlice("extraEnv"))
+	})
+
+	t.Run("Namespaced variables", func(t *testing.T) {
+		t.Setenv("TP_WORKSPACE", "prod")
+		t.Setenv("TP_ENV", "AWS_PROFILE=prod")
+
+		require.Equal(t, "prod", viper.GetString("planWorkspace"))
+		require.Equal(t, []string{"AWS_PROFILE=prod"}, viper.GetStringSlice("extraEnv"))
+	})
+}
@@ -0,0 +1,364 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/hashicorp/go-version"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// selfUpdateRepo is the repository gh-tp is released from
+	selfUpdateRepo = "esacteksab/gh-tp"
+	// selfUpdateTimeout bounds the release lookup and the downloads
+	selfUpdateTimeout = 5 * time.Minute
+	// checksumsAsset is the release asset listing the SHA-256 of the others
+	checksumsAsset = "checksums.txt"
+)
+
+// errNoReleaseAsset is returned when a release has no binary for the platform
+var errNoReleaseAsset = errors.New("no release asset for this platform")
+
+// defaultReleaseClient is the ReleaseClient used outside of tests
+var defaultReleaseClient ReleaseClient = &RealReleaseClient{}
+
+// githubRelease is the subset of the releases API document we read
+type githubRelease struct {
+	TagName string         `json:"tag_name"`
+	Assets  []releaseAsset `json:"assets"`
+}
+
+// releaseAsset is a file attached to a release
+type releaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// ReleaseClient is an interface for reading gh-tp's releases
+// This allows for dependency injection and easier testing
+type ReleaseClient interface {
+	LatestRelease(ctx context.Context) (*githubRelease, error)
+	Download(ctx context.Context, url string) ([]byte, error)
+}
+
+// RealReleaseClient implements the ReleaseClient interface with the GitHub REST API
+type RealReleaseClient struct {
+	// BaseURL overrides "https://api.github.com", used in tests
+	BaseURL string
+	// HTTPClient overrides http.DefaultClient, used in tests
+	HTTPClient *http.Client
+}
+
+// LatestRelease reads the latest release of gh-tp. GH_TOKEN or GITHUB_TOKEN
+// is sent when set, raising the API's rate limit.
+func (c *RealReleaseClient) LatestRelease(ctx context.Context) (*githubRelease, error) {
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.github.com"
+	}
+	token := os.Getenv("GH_TOKEN")
+	if token == "" {
+		token = os.Getenv("GITHUB_TOKEN")
+	}
+	body, err := c.get(ctx, fmt.Sprintf("%s/repos/%s/releases/latest", baseURL, selfUpdateRepo), token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the latest release: %w", err)
+	}
+	var release githubRelease
+	if err = json.Unmarshal(body, &release); err != nil {
+		return nil, fmt.Errorf("failed to parse the latest release: %w", err)
+	}
+	return &release, nil
+}
+
+// Download fetches a release asset.
+func (c *RealReleaseClient) Download(ctx context.Context, url string) ([]byte, error) {
+	// Asset URLs redirect to a CDN and must not receive the API token
+	body, err := c.get(ctx, url, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	return body, nil
+}
+
+// get performs a GET request and returns the response body.
+func (c *RealReleaseClient) get(ctx context.Context, url, token string) ([]byte, error) {
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// selfUpdateCmd represents the self-update command
+var selfUpdateCmd = &cobra.Command{
+	Use:               "self-update",
+	Args:              cobra.NoArgs,
+	ValidArgsFunction: cobra.NoFileCompletions,
+	Short:             "Update gh-tp to the latest release.",
+	Long: heredoc.Doc(`
+		Update a standalone gh-tp binary to the latest release on GitHub. The
+		binary for this platform is downloaded, verified against the release's
+		checksums and replaces the running one. Installed as a gh extension,
+		use 'gh extension upgrade tp' instead.`),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		exePath, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("unable to locate the gh-tp binary: %w", err)
+		}
+		if exePath, err = filepath.EvalSymlinks(exePath); err != nil {
+			return fmt.Errorf("unable to locate the gh-tp binary: %w", err)
+		}
+		if isGhExtension(exePath) {
+			return errors.New("gh-tp is installed as a gh extension, run 'gh extension upgrade tp' instead")
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), selfUpdateTimeout)
+		defer cancel()
+		return selfUpdate(ctx, defaultReleaseClient, Version, exePath, runtime.GOOS, runtime.GOARCH)
+	},
+}
+
+// selfUpdate replaces the binary at exePath with the latest release when it's
+// newer than current.
+//
+// Parameters:
+//
+//	ctx - The context controlling the requests.
+//	client - The ReleaseClient used.
+//	current - The running version, Version outside of tests.
+//	exePath - The binary to replace.
+//	goos - The platform of the binary to download, runtime.GOOS outside of tests.
+//	goarch - The architecture of the binary to download, runtime.GOARCH outside of tests.
+//
+// Returns:
+//
+//	error - Any error encountered. The binary is left untouched on error.
+func selfUpdate(ctx context.Context, client ReleaseClient, current, exePath, goos, goarch string) error {
+	release, err := client.LatestRelease(ctx)
+	if err != nil {
+		return err
+	}
+	newer, err := isNewerRelease(current, release.TagName)
+	if err != nil {
+		return err
+	}
+	if !newer {
+		Logger.Infof("gh-tp %s is already the latest release", current)
+		return nil
+	}
+
+	asset, err := selectReleaseAsset(release, goos, goarch)
+	if err != nil {
+		return err
+	}
+	checksums, err := selectChecksums(release)
+	if err != nil {
+		return err
+	}
+	sums, err := client.Download(ctx, checksums.URL)
+	if err != nil {
+		return err
+	}
+	data, err := client.Download(ctx, asset.URL)
+	if err != nil {
+		return err
+	}
+	if err = verifyChecksum(data, sums, asset.Name); err != nil {
+		return err
+	}
+	if strings.HasSuffix(asset.Name, ".zip") {
+		if data, err = unzipBinary(data); err != nil {
+			return fmt.Errorf("unable to extract %s: %w", asset.Name, err)
+		}
+	}
+
+	if err = replaceExecutable(exePath, data); err != nil {
+		return err
+	}
+	Logger.Infof("gh-tp updated from %s to %s", current, release.TagName)
+	return nil
+}
+
+// isNewerRelease reports whether the release tag is newer than the current
+// version. Builds without a release version, e.g. 'go install', can't be
+// compared and are an error.
+func isNewerRelease(current, tag string) (bool, error) {
+	latest, err := version.NewVersion(tag)
+	if err != nil {
+		return false, fmt.Errorf("invalid release tag %q: %w", tag, err)
+	}
+	if current == "" {
+		return false, errors.New("unable to compare with the latest release: this build has no version")
+	}
+	running, err := version.NewVersion(current)
+	if err != nil {
+		return false, fmt.Errorf("unable to compare with the latest release: invalid version %q", current)
+	}
+	return latest.GreaterThan(running), nil
+}
+
+// selectReleaseAsset finds the binary for goos and goarch. Assets are named by
+// .goreleaser.yaml, e.g. gh-tp_v1.2.3_linux-amd64, with Windows binaries in a
+// zip archive.
+//
+// Parameters:
+//
+//	release - The release to search.
+//	goos - The platform, e.g. linux.
+//	goarch - The architecture, e.g. arm64.
+//
+// Returns:
+//
+//	releaseAsset - The asset to download.
+//	error - errNoReleaseAsset if the release has no binary for the platform.
+func selectReleaseAsset(release *githubRelease, goos, goarch string) (releaseAsset, error) {
+	name := fmt.Sprintf("gh-tp_%s_%s-%s", release.TagName, goos, goarch)
+	candidates := []string{name}
+	if goos == "windows" {
+		candidates = []string{name + ".zip", name + ".exe"}
+	}
+	for _, candidate := range candidates {
+		for _, asset := range release.Assets {
+			if asset.Name == candidate {
+				return asset, nil
+			}
+		}
+	}
+	return releaseAsset{}, fmt.Errorf("%w: %s has no %s/%s binary", errNoReleaseAsset, release.TagName, goos, goarch)
+}
+
+// selectChecksums finds the checksums asset of a release.
+func selectChecksums(release *githubRelease) (releaseAsset, error) {
+	for _, asset := range release.Assets {
+		if asset.Name == checksumsAsset {
+			return asset, nil
+		}
+	}
+	return releaseAsset{}, fmt.Errorf("%s has no %s, refusing to install an unverified binary", release.TagName, checksumsAsset)
+}
+
+// verifyChecksum checks data against the SHA-256 listed for name in sums, a
+// sha256sum formatted checksums file.
+func verifyChecksum(data, sums []byte, name string) error {
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != name {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, fields[0]) {
+			return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", name, fields[0], got)
+		}
+		return nil
+	}
+	return fmt.Errorf("no checksum for %s in %s", name, checksumsAsset)
+}
+
+// unzipBinary returns the gh-tp binary from a zip archive.
+func unzipBinary(data []byte) ([]byte, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range archive.File {
+		if !strings.HasPrefix(filepath.Base(file.Name), "gh-tp") || file.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = rc.Close()
+		}()
+		return io.ReadAll(rc) //nolint:gosec // verified against the release's checksums
+	}
+	return nil, errors.New("no gh-tp binary in the archive")
+}
+
+// replaceExecutable atomically replaces the binary at path with data: data is
+// written next to it and renamed over it, so an interrupted update leaves the
+// old binary in place.
+func replaceExecutable(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".gh-tp-update-*")
+	if err != nil {
+		return fmt.Errorf("unable to write next to %s: %w", path, err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name()) // No-op once renamed
+	}()
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("unable to write %s: %w", tmp.Name(), err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("unable to write %s: %w", tmp.Name(), err)
+	}
+	if err = os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("unable to set the mode of %s: %w", tmp.Name(), err)
+	}
+
+	if runtime.GOOS == "windows" {
+		// A running binary can't be replaced on Windows, but it can be renamed
+		old := path + ".old"
+		_ = os.Remove(old)
+		if err = os.Rename(path, old); err != nil {
+			return fmt.Errorf("unable to replace %s: %w", path, err)
+		}
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("unable to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// isGhExtension reports whether exePath was installed by 'gh extension install'.
+func isGhExtension(exePath string) bool {
+	return strings.Contains(filepath.ToSlash(exePath), "/gh/extensions/")
+}
+
+func init() {
+	rootCmd.AddCommand(selfUpdateCmd)
+}
@@ -0,0 +1,226 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsNewerRelease(t *testing.T) {
+	testCases := []struct {
+		current string
+		tag     string
+		want    bool
+	}{
+		{current: "1.2.3", tag: "v1.3.0", want: true},
+		{current: "v1.2.3", tag: "v1.2.10", want: true},
+		{current: "1.2.3", tag: "v1.2.3", want: false},
+		{current: "1.3.0", tag: "v1.2.9", want: false},
+		{current: "1.2.4-devel", tag: "v1.2.4", want: true},
+		{current: "1.2.4", tag: "v1.2.5-rc.1", want: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.current+" to "+tc.tag, func(t *testing.T) {
+			got, err := isNewerRelease(tc.current, tc.tag)
+
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+
+	t.Run("Builds without a version", func(t *testing.T) {
+		_, err := isNewerRelease("", "v1.2.3")
+		require.ErrorContains(t, err, "this build has no version")
+		_, err = isNewerRelease("(devel)", "v1.2.3")
+		require.ErrorContains(t, err, `invalid version "(devel)"`)
+	})
+
+	t.Run("Invalid tags", func(t *testing.T) {
+		_, err := isNewerRelease("1.2.3", "nightly")
+		require.ErrorContains(t, err, `invalid release tag "nightly"`)
+	})
+}
+
+func TestSelectReleaseAsset(t *testing.T) {
+	release := &githubRelease{
+		TagName: "v1.2.3",
+		Assets: []releaseAsset{
+			{Name: "checksums.txt"},
+			{Name: "gh-tp_v1.2.3_darwin-arm64"},
+			{Name: "gh-tp_v1.2.3_linux-amd64"},
+			{Name: "gh-tp_v1.2.3_linux-arm64"},
+			{Name: "gh-tp_v1.2.3_windows-amd64.zip"},
+		},
+	}
+
+	testCases := []struct {
+		goos   string
+		goarch string
+		want   string
+	}{
+		{goos: "linux", goarch: "amd64", want: "gh-tp_v1.2.3_linux-amd64"},
+		{goos: "linux", goarch: "arm64", want: "gh-tp_v1.2.3_linux-arm64"},
+		{goos: "darwin", goarch: "arm64", want: "gh-tp_v1.2.3_darwin-arm64"},
+		{goos: "windows", goarch: "amd64", want: "gh-tp_v1.2.3_windows-amd64.zip"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.goos+"/"+tc.goarch, func(t *testing.T) {
+			got, err := selectReleaseAsset(release, tc.goos, tc.goarch)
+
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got.Name)
+		})
+	}
+
+	t.Run("Unsupported platform", func(t *testing.T) {
+		_, err := selectReleaseAsset(release, "freebsd", "amd64")
+
+		require.ErrorIs(t, err, errNoReleaseAsset)
+		require.ErrorContains(t, err, "v1.2.3 has no freebsd/amd64 binary")
+	})
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	data := []byte("binary")
+	sum := sha256.Sum256(data)
+	sums := []byte(hex.EncodeToString(sum[:]) + "  gh-tp_v1.2.3_linux-amd64\n" +
+		"0000  gh-tp_v1.2.3_linux-arm64\n")
+
+	require.NoError(t, verifyChecksum(data, sums, "gh-tp_v1.2.3_linux-amd64"))
+	require.ErrorContains(t, verifyChecksum(data, sums, "gh-tp_v1.2.3_linux-arm64"), "checksum mismatch")
+	require.ErrorContains(t, verifyChecksum(data, sums, "gh-tp_v1.2.3_darwin-arm64"), "no checksum for")
+}
+
+// newReleaseServer serves a mocked releases API with a v1.3.0 release whose
+// assets are given by name, listed in checksums.txt unless sums is set.
+func newReleaseServer(t *testing.T, assets map[string][]byte, sums string) *RealReleaseClient {
+	t.Helper()
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	release := githubRelease{TagName: "v1.3.0"}
+	var checksums bytes.Buffer
+	for name, data := range assets {
+		sum := sha256.Sum256(data)
+		fmt.Fprintf(&checksums, "%s  %s\n", hex.EncodeToString(sum[:]), name)
+		release.Assets = append(release.Assets, releaseAsset{Name: name, URL: server.URL + "/download/" + name})
+		mux.HandleFunc("/download/"+name, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(data)
+		})
+	}
+	if sums == "" {
+		sums = checksums.String()
+	}
+	release.Assets = append(release.Assets, releaseAsset{Name: checksumsAsset, URL: server.URL + "/download/checksums"})
+	mux.HandleFunc("/download/checksums", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(sums))
+	})
+	mux.HandleFunc("/repos/esacteksab/gh-tp/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(release)
+	})
+	return &RealReleaseClient{BaseURL: server.URL, HTTPClient: server.Client()}
+}
+
+func TestSelfUpdate(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	newBinary := func(t *testing.T) string {
+		t.Helper()
+		exePath := filepath.Join(t.TempDir(), "gh-tp")
+		require.NoError(t, os.WriteFile(exePath, []byte("v1.2.3"), 0o700)) //nolint:gosec // an executable
+		return exePath
+	}
+
+	t.Run("Newer release replaces the binary", func(t *testing.T) {
+		client := newReleaseServer(t, map[string][]byte{
+			"gh-tp_v1.3.0_linux-amd64": []byte("v1.3.0"),
+			"gh-tp_v1.3.0_linux-arm64": []byte("v1.3.0 arm64"),
+		}, "")
+		exePath := newBinary(t)
+
+		require.NoError(t, selfUpdate(context.Background(), client, "1.2.3", exePath, "linux", "arm64"))
+
+		got, err := os.ReadFile(exePath) //nolint:gosec // test file
+		require.NoError(t, err)
+		require.Equal(t, "v1.3.0 arm64", string(got))
+		info, err := os.Stat(exePath)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+		entries, err := os.ReadDir(filepath.Dir(exePath))
+		require.NoError(t, err)
+		require.Len(t, entries, 1, "no temporary file is left behind")
+	})
+
+	t.Run("Windows binaries are extracted from the zip", func(t *testing.T) {
+		var archive bytes.Buffer
+		zw := zip.NewWriter(&archive)
+		w, err := zw.Create("gh-tp.exe")
+		require.NoError(t, err)
+		_, err = w.Write([]byte("v1.3.0 windows"))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		client := newReleaseServer(t, map[string][]byte{"gh-tp_v1.3.0_windows-amd64.zip": archive.Bytes()}, "")
+		exePath := newBinary(t)
+
+		require.NoError(t, selfUpdate(context.Background(), client, "1.2.3", exePath, "windows", "amd64"))
+
+		got, err := os.ReadFile(exePath) //nolint:gosec // test file
+		require.NoError(t, err)
+		require.Equal(t, "v1.3.0 windows", string(got))
+	})
+
+	t.Run("Already up to date", func(t *testing.T) {
+		client := newReleaseServer(t, map[string][]byte{"gh-tp_v1.3.0_linux-amd64": []byte("v1.3.0")}, "")
+		exePath := newBinary(t)
+
+		require.NoError(t, selfUpdate(context.Background(), client, "1.3.0", exePath, "linux", "amd64"))
+
+		got, err := os.ReadFile(exePath) //nolint:gosec // test file
+		require.NoError(t, err)
+		require.Equal(t, "v1.2.3", string(got))
+	})
+
+	t.Run("Checksum mismatch leaves the binary untouched", func(t *testing.T) {
+		client := newReleaseServer(
+			t,
+			map[string][]byte{"gh-tp_v1.3.0_linux-amd64": []byte("tampered")},
+			"0123456789abcdef  gh-tp_v1.3.0_linux-amd64\n",
+		)
+		exePath := newBinary(t)
+
+		err := selfUpdate(context.Background(), client, "1.2.3", exePath, "linux", "amd64")
+
+		require.ErrorContains(t, err, "checksum mismatch for gh-tp_v1.3.0_linux-amd64")
+		got, err := os.ReadFile(exePath) //nolint:gosec // test file
+		require.NoError(t, err)
+		require.Equal(t, "v1.2.3", string(got))
+	})
+
+	t.Run("No binary for the platform", func(t *testing.T) {
+		client := newReleaseServer(t, map[string][]byte{"gh-tp_v1.3.0_linux-amd64": []byte("v1.3.0")}, "")
+
+		err := selfUpdate(context.Background(), client, "1.2.3", newBinary(t), "darwin", "arm64")
+
+		require.ErrorIs(t, err, errNoReleaseAsset)
+	})
+}
+
+func TestIsGhExtension(t *testing.T) {
+	require.True(t, isGhExtension("/home/alice/.local/share/gh/extensions/gh-tp/gh-tp"))
+	require.False(t, isGhExtension("/usr/local/bin/gh-tp"))
+}
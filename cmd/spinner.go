@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/briandowns/spinner"
+	"github.com/spf13/viper"
+)
+
+// Keys of the spinner messages, which can be overridden in the [messages]
+// table of the config file
+const (
+	msgCreatingPlan    = "creatingPlan"
+	msgCreatingPlans   = "creatingPlans"
+	msgReadingStdin    = "readingStdin"
+	msgDownloadingPlan = "downloadingPlan"
+	msgInitializing    = "initializing"
+)
+
+// defaultMessages are the spinner messages used when the config doesn't
+// override them. {binary} is replaced by the binary's name and {count} by the
+// number of plans.
+var defaultMessages = map[string]string{
+	msgCreatingPlan:    "Creating {binary} plan...",
+	msgCreatingPlans:   "Creating {count} {binary} plans...",
+	msgReadingStdin:    "Reading plan from stdin and creating Markdown...",
+	msgDownloadingPlan: "Downloading plan and creating Markdown...",
+	msgInitializing:    "Initializing {binary}...",
+}
+
+// binaryDisplayName returns the product name of binaryName, e.g. "OpenTofu" for tofu.
+func binaryDisplayName(binaryName string) string {
+	switch strings.ToLower(binaryKind(binaryName)) {
+	case "tofu":
+		return "OpenTofu"
+	case "terraform":
+		return "Terraform"
+	default:
+		return binaryName
+	}
+}
+
+// spinnerMessage returns the message for key, from the config file's
+// [messages] table or the default, with its placeholders filled in.
+//
+// Parameters:
+//
+//	key - The message key, e.g. msgCreatingPlan.
+//	binaryName - The binary in use, for {binary}.
+//	count - The number of plans, for {count}.
+//
+// Returns:
+//
+//	string - The message.
+func spinnerMessage(key, binaryName string, count int) string {
+	msg := viper.GetString("messages." + key)
+	if msg == "" {
+		msg = defaultMessages[key]
+	}
+	return strings.NewReplacer(
+		"{binary}", binaryDisplayName(binaryName),
+		"{count}", strconv.Itoa(count),
+	).Replace(msg)
+}
+
+// newSpinner creates the spinner shown while tp works, with message as its suffix.
+func newSpinner(message string) *spinner.Spinner {
+	s := spinner.New(spinner.CharSets[14], spinnerDuration)
+	s.Suffix = " " + message
+	return s
+}
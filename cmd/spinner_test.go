@@ -0,0 +1,39 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpinnerMessage(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	tests := []struct {
+		name   string
+		key    string
+		binary string
+		count  int
+		want   string
+	}{
+		{"OpenTofu plan", msgCreatingPlan, "tofu", 1, "Creating OpenTofu plan..."},
+		{"Terraform plan", msgCreatingPlan, "terraform", 1, "Creating Terraform plan..."},
+		{"Several plans", msgCreatingPlans, "tofu", 3, "Creating 3 OpenTofu plans..."},
+		{"Stdin", msgReadingStdin, "terraform", 1, "Reading plan from stdin and creating Markdown..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSpinner(spinnerMessage(tt.key, tt.binary, tt.count))
+			require.Equal(t, " "+tt.want, s.Suffix)
+		})
+	}
+
+	t.Run("Config overrides the message", func(t *testing.T) {
+		loadConfig(t, "[messages]\ncreatingPlan = 'Planification {binary} en cours...'\n")
+		require.Equal(t, "Planification OpenTofu en cours...", spinnerMessage(msgCreatingPlan, "tofu", 1))
+		require.Equal(t, "Creating 2 Terraform plans...", spinnerMessage(msgCreatingPlans, "terraform", 2))
+	})
+}
@@ -0,0 +1,239 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// statusTimeout bounds posting the commit status, which also runs after a
+	// deadline or an interrupt
+	statusTimeout = 10 * time.Second
+	// maxStatusDescriptionLength is the longest description GitHub accepts
+	maxStatusDescriptionLength = 140
+	// maxStatusContextLength bounds the 'status-check' context
+	maxStatusContextLength = 255
+)
+
+// Commit status states, as the statuses API names them
+const (
+	statusSuccess = "success"
+	statusFailure = "failure"
+	statusError   = "error"
+)
+
+// errStatusForbidden is returned when the token can't create commit statuses
+var errStatusForbidden = errors.New("not allowed to create commit statuses")
+
+// statusForbiddenMessages are fragments of the errors returned when the token
+// lacks the permission to create commit statuses
+var statusForbiddenMessages = []string{
+	"HTTP 403",
+	"HTTP 404", // Returned instead of 403 for repositories the token can't see
+	"resource not accessible by integration",
+}
+
+// defaultStatusClient is the StatusClient used outside of tests
+var defaultStatusClient StatusClient = &RealStatusClient{runner: defaultGhRunner}
+
+// commitStatus is the commit status posted for --status-check.
+type commitStatus struct {
+	State       string // success, failure or error
+	Context     string // The status check's name, from --status-check
+	Description string // The change counts, or why the plan failed
+}
+
+// StatusClient is an interface for creating commit statuses
+// This allows for dependency injection and easier testing
+type StatusClient interface {
+	CreateStatus(ctx context.Context, sha string, status commitStatus) error
+}
+
+// RealStatusClient implements the StatusClient interface with the statuses API through 'gh api'
+type RealStatusClient struct {
+	runner GhRunner
+}
+
+// CreateStatus creates a commit status on sha
+//
+// Parameters:
+//
+//	ctx - The context controlling the request
+//	sha - The commit the status is set on
+//	status - The status to create
+//
+// Returns:
+//
+//	error - errStatusForbidden if the token lacks the permission, or any other error encountered
+func (c *RealStatusClient) CreateStatus(ctx context.Context, sha string, status commitStatus) error {
+	_, err := c.runner.Run(
+		ctx,
+		"api", "--method", "POST",
+		"repos/{owner}/{repo}/statuses/"+sha,
+		"-f", "state="+status.State,
+		"-f", "context="+status.Context,
+		"-f", "description="+status.Description,
+	)
+	if err != nil {
+		msg := strings.ToLower(err.Error())
+		for _, fragment := range statusForbiddenMessages {
+			if strings.Contains(msg, strings.ToLower(fragment)) {
+				return fmt.Errorf("%w: %w", errStatusForbidden, err)
+			}
+		}
+		return fmt.Errorf("failed to create commit status on %s: %w", sha, err)
+	}
+	return nil
+}
+
+// validateStatusContext checks the 'status-check' context.
+func validateStatusContext(statusContext string) error {
+	if strings.TrimSpace(statusContext) == "" {
+		return errors.New("invalid 'status-check': a context name is required")
+	}
+	if utf8.RuneCountInString(statusContext) > maxStatusContextLength {
+		return fmt.Errorf("invalid 'status-check': longer than %d characters", maxStatusContextLength)
+	}
+	return nil
+}
+
+// planStatus derives the commit status from the outcome of the run.
+//
+// Parameters:
+//
+//	statusContext - The status check's name.
+//	runErr - The error the run ended with, nil on success.
+//	noChanges - Whether the plan has no changes.
+//	changes - The change counts, nil when the plan wasn't structured.
+//	secrets - Values masked in the error, from runSecrets.
+//	patterns - Patterns whose matches are masked in the error, from 'redactPatterns'.
+//
+// Returns:
+//
+//	commitStatus - The status to post. Its description is public, so the error is masked.
+func planStatus(
+	statusContext string,
+	runErr error,
+	noChanges bool,
+	changes *changeCounts,
+	secrets []string,
+	patterns []*regexp.Regexp,
+) commitStatus {
+	status := commitStatus{State: statusSuccess, Context: statusContext}
+	switch {
+	case errors.Is(runErr, ErrInterrupted) || errors.Is(runErr, ErrDeadlineExceeded):
+		status.State = statusError
+		status.Description = "Plan did not finish: " + maskRunError(runErr, secrets, patterns)
+	case runErr != nil:
+		status.State = statusFailure
+		status.Description = "Plan failed: " + maskRunError(runErr, secrets, patterns)
+	case changes != nil:
+		status.Description = "Plan: " + changes.summary() + "."
+	case noChanges:
+		status.Description = "No changes."
+	default:
+		status.Description = "Plan succeeded."
+	}
+	status.Description = truncateDescription(status.Description)
+	return status
+}
+
+// truncateDescription shortens s to maxStatusDescriptionLength characters,
+// ending with an ellipsis when truncated.
+func truncateDescription(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= maxStatusDescriptionLength {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:maxStatusDescriptionLength-1]) + "…"
+}
+
+// postPlanStatus posts status on the commit statusCommit returns. The status
+// is a CI convenience, so a token without the permission only warns.
+//
+// Parameters:
+//
+//	ctx - The context of the run, the status is posted even once it's done.
+//	client - The StatusClient used.
+//	git - The GitRunner used to read the HEAD commit.
+//	status - The status from planStatus.
+//
+// Returns:
+//
+//	error - Any error other than the token lacking the permission.
+func postPlanStatus(ctx context.Context, client StatusClient, git GitRunner, status commitStatus) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), statusTimeout)
+	defer cancel()
+
+	sha, err := statusCommit(ctx, git)
+	if err != nil {
+		return err
+	}
+
+	err = client.CreateStatus(ctx, sha, status)
+	if errors.Is(err, errStatusForbidden) {
+		Logger.Warnf(
+			"Commit status %q wasn't posted: %v. Check that the token has the 'statuses: write' permission.",
+			status.Context,
+			err,
+		)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	Logger.Infof("Commit status %q (%s) posted on %s", status.Context, status.State, sha)
+	return nil
+}
+
+// statusCommit returns the commit the status is posted on: the head of the
+// pull request in a pull_request workflow, whose checkout is a merge commit
+// the pull request never shows, otherwise HEAD.
+func statusCommit(ctx context.Context, git GitRunner) (string, error) {
+	if sha := eventHeadSHA(os.Getenv("GITHUB_EVENT_PATH")); sha != "" {
+		return sha, nil
+	}
+	out, err := git.Run(ctx, "rev-parse", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("unable to read the HEAD commit for the status check: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// eventHeadSHA returns the pull request's head commit from the workflow event
+// at eventPath, or "" when it isn't a pull request event or can't be read.
+func eventHeadSHA(eventPath string) string {
+	if eventPath == "" {
+		return ""
+	}
+	data, err := os.ReadFile(eventPath) //nolint:gosec // the event file GitHub Actions provides
+	if err != nil {
+		Logger.Debugf("Unable to read the workflow event %s: %v", eventPath, err)
+		return ""
+	}
+	var event struct {
+		PullRequest *struct {
+			Head struct {
+				SHA string `json:"sha"`
+			} `json:"head"`
+		} `json:"pull_request"`
+	}
+	if err = json.Unmarshal(data, &event); err != nil {
+		Logger.Debugf("Unable to parse the workflow event %s: %v", eventPath, err)
+		return ""
+	}
+	if event.PullRequest == nil {
+		return ""
+	}
+	return event.PullRequest.Head.SHA
+}
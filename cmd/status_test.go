@@ -0,0 +1,249 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockStatusClient is a mock implementation of StatusClient
+type MockStatusClient struct {
+	mock.Mock
+}
+
+func (m *MockStatusClient) CreateStatus(ctx context.Context, sha string, status commitStatus) error {
+	return m.Called(ctx, sha, status).Error(0)
+}
+
+func TestRealStatusClient(t *testing.T) {
+	const sha = "0123456789abcdef0123456789abcdef01234567"
+	status := commitStatus{State: statusSuccess, Context: "tp/plan", Description: "No changes."}
+	args := []string{
+		"api", "--method", "POST",
+		"repos/{owner}/{repo}/statuses/" + sha,
+		"-f", "state=success",
+		"-f", "context=tp/plan",
+		"-f", "description=No changes.",
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		runner := new(MockGhRunner)
+		runner.On("Run", mock.Anything, args).Return([]byte(`{"id":1}`), nil)
+
+		err := (&RealStatusClient{runner: runner}).CreateStatus(context.Background(), sha, status)
+
+		require.NoError(t, err)
+		runner.AssertExpectations(t)
+	})
+
+	t.Run("Missing permission", func(t *testing.T) {
+		runner := new(MockGhRunner)
+		runner.On("Run", mock.Anything, args).Return(nil, errors.New(
+			"gh api: exit status 1: Resource not accessible by integration (HTTP 403)",
+		))
+
+		err := (&RealStatusClient{runner: runner}).CreateStatus(context.Background(), sha, status)
+
+		require.ErrorIs(t, err, errStatusForbidden)
+	})
+
+	t.Run("Other API errors", func(t *testing.T) {
+		runner := new(MockGhRunner)
+		runner.On("Run", mock.Anything, args).Return(nil, errors.New("gh api: exit status 1: HTTP 502"))
+
+		err := (&RealStatusClient{runner: runner}).CreateStatus(context.Background(), sha, status)
+
+		require.ErrorContains(t, err, "failed to create commit status on "+sha)
+		require.NotErrorIs(t, err, errStatusForbidden)
+	})
+}
+
+func TestPlanStatus(t *testing.T) {
+	testCases := []struct {
+		name      string
+		runErr    error
+		noChanges bool
+		changes   *changeCounts
+		wantState string
+		wantDesc  string
+	}{
+		{
+			name:      "Changes",
+			changes:   &changeCounts{Add: 2, Change: 1, Destroy: 3},
+			wantState: statusSuccess,
+			wantDesc:  "Plan: 2 to add, 1 to change, 3 to destroy.",
+		},
+		{
+			name:      "Imports",
+			changes:   &changeCounts{Add: 1, Import: 2},
+			wantState: statusSuccess,
+			wantDesc:  "Plan: 1 to add, 0 to change, 0 to destroy, 2 to import.",
+		},
+		{
+			name:      "No changes",
+			noChanges: true,
+			wantState: statusSuccess,
+			wantDesc:  "No changes.",
+		},
+		{
+			name:      "Plan text only",
+			wantState: statusSuccess,
+			wantDesc:  "Plan succeeded.",
+		},
+		{
+			name:      "Failed",
+			runErr:    errors.New("terraform plan failed: exit status 1\n\nError: Invalid reference"),
+			wantState: statusFailure,
+			wantDesc:  "Plan failed: terraform plan failed: exit status 1 Error: Invalid reference",
+		},
+		{
+			name:      "Interrupted",
+			runErr:    fmt.Errorf("plan in network: %w", ErrInterrupted),
+			wantState: statusError,
+			wantDesc:  "Plan did not finish: plan in network: operation interrupted by user",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := planStatus("tp/plan", tc.runErr, tc.noChanges, tc.changes, nil, nil)
+
+			require.Equal(t, commitStatus{State: tc.wantState, Context: "tp/plan", Description: tc.wantDesc}, got)
+		})
+	}
+
+	t.Run("Secrets in the error are masked", func(t *testing.T) {
+		runErr := errors.New("terraform plan failed: invalid token ghp_abcdefgh for db.internal:5432")
+
+		got := planStatus(
+			"tp/plan",
+			runErr,
+			false,
+			nil,
+			[]string{"ghp_abcdefgh"},
+			[]*regexp.Regexp{regexp.MustCompile(`db\.internal`)},
+		)
+
+		require.Equal(
+			t,
+			"Plan failed: terraform plan failed: invalid token (sensitive value) for (sensitive value):5432",
+			got.Description,
+		)
+	})
+
+	t.Run("Long errors are truncated", func(t *testing.T) {
+		got := planStatus("tp/plan", errors.New(strings.Repeat("é", 200)), false, nil, nil, nil)
+
+		require.Equal(t, maxStatusDescriptionLength, len([]rune(got.Description)))
+		require.True(t, strings.HasSuffix(got.Description, "…"))
+	})
+}
+
+func TestValidateStatusContext(t *testing.T) {
+	require.NoError(t, validateStatusContext("tp/plan"))
+	require.ErrorContains(t, validateStatusContext("  "), "a context name is required")
+	require.ErrorContains(t, validateStatusContext(strings.Repeat("a", 256)), "longer than 255 characters")
+}
+
+func TestPostPlanStatus(t *testing.T) {
+	originalLogger := Logger
+	defer func() {
+		Logger = originalLogger
+	}()
+	var buf bytes.Buffer
+	Logger = log.NewWithOptions(&buf, log.Options{Level: log.InfoLevel})
+	const sha = "0123456789abcdef0123456789abcdef01234567"
+	status := planStatus("tp/plan", nil, false, &changeCounts{Add: 1}, nil, nil)
+	// Outside of a workflow, as when tp runs locally
+	t.Setenv("GITHUB_EVENT_PATH", "")
+
+	newGit := func() *MockGitRunner {
+		git := new(MockGitRunner)
+		git.On("Run", mock.Anything, []string{"rev-parse", "HEAD"}).Return([]byte(sha+"\n"), nil)
+		return git
+	}
+
+	t.Run("Posts the plan's status on HEAD", func(t *testing.T) {
+		client := new(MockStatusClient)
+		client.On("CreateStatus", mock.Anything, sha, commitStatus{
+			State:       statusSuccess,
+			Context:     "tp/plan",
+			Description: "Plan: 1 to add, 0 to change, 0 to destroy.",
+		}).Return(nil)
+
+		require.NoError(t, postPlanStatus(context.Background(), client, newGit(), status))
+		client.AssertExpectations(t)
+	})
+
+	t.Run("Posted after the run's context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		client := new(MockStatusClient)
+		client.On("CreateStatus", mock.MatchedBy(func(ctx context.Context) bool { return ctx.Err() == nil }), sha, status).
+			Return(nil)
+
+		require.NoError(t, postPlanStatus(ctx, client, newGit(), status))
+		client.AssertExpectations(t)
+	})
+
+	t.Run("Missing permission only warns", func(t *testing.T) {
+		buf.Reset()
+		client := new(MockStatusClient)
+		client.On("CreateStatus", mock.Anything, sha, status).
+			Return(fmt.Errorf("%w: HTTP 403", errStatusForbidden))
+
+		require.NoError(t, postPlanStatus(context.Background(), client, newGit(), status))
+		require.Contains(t, buf.String(), "'statuses: write' permission")
+	})
+
+	t.Run("Other errors are returned", func(t *testing.T) {
+		client := new(MockStatusClient)
+		client.On("CreateStatus", mock.Anything, sha, status).Return(errors.New("HTTP 502"))
+
+		require.EqualError(t, postPlanStatus(context.Background(), client, newGit(), status), "HTTP 502")
+	})
+
+	t.Run("Posts on the pull request's head in a pull_request workflow", func(t *testing.T) {
+		const head = "89abcdef0123456789abcdef0123456789abcdef"
+		event := filepath.Join(t.TempDir(), "event.json")
+		require.NoError(t, os.WriteFile(event, []byte(`{"pull_request":{"head":{"sha":"`+head+`"}}}`), 0o600))
+		t.Setenv("GITHUB_EVENT_PATH", event)
+		client := new(MockStatusClient)
+		client.On("CreateStatus", mock.Anything, head, status).Return(nil)
+		git := new(MockGitRunner)
+
+		require.NoError(t, postPlanStatus(context.Background(), client, git, status))
+		client.AssertExpectations(t)
+		git.AssertNotCalled(t, "Run", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Other events post on HEAD", func(t *testing.T) {
+		event := filepath.Join(t.TempDir(), "event.json")
+		require.NoError(t, os.WriteFile(event, []byte(`{"ref":"refs/heads/main"}`), 0o600))
+		t.Setenv("GITHUB_EVENT_PATH", event)
+		client := new(MockStatusClient)
+		client.On("CreateStatus", mock.Anything, sha, status).Return(nil)
+
+		require.NoError(t, postPlanStatus(context.Background(), client, newGit(), status))
+		client.AssertExpectations(t)
+	})
+
+	t.Run("No HEAD commit", func(t *testing.T) {
+		git := new(MockGitRunner)
+		git.On("Run", mock.Anything, []string{"rev-parse", "HEAD"}).Return(nil, errors.New("not a git repository"))
+
+		err := postPlanStatus(context.Background(), new(MockStatusClient), git, status)
+
+		require.ErrorContains(t, err, "unable to read the HEAD commit")
+	})
+}
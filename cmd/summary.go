@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"github.com/spf13/viper"
+)
+
+// maxStepSummaryBytes is the largest job summary GitHub Actions accepts per step
+const maxStepSummaryBytes = 1024 * 1024
+
+// stepSummaryPath returns the job summary file to append the Markdown to, or
+// "". Writing the summary is enabled when GITHUB_STEP_SUMMARY is set, unless
+// 'stepSummary' is set to false.
+func stepSummaryPath() string {
+	path := os.Getenv("GITHUB_STEP_SUMMARY")
+	enabled := path != ""
+	if viper.IsSet("stepSummary") {
+		enabled = viper.GetBool("stepSummary")
+	}
+	if !enabled {
+		return ""
+	}
+	if path == "" {
+		Logger.Warn("'step-summary' has no effect outside GitHub Actions: GITHUB_STEP_SUMMARY is not set.")
+	}
+	return path
+}
+
+// appendStepSummary appends the Markdown file to the job summary, truncated to
+// the size GitHub Actions accepts.
+//
+// Parameters:
+//
+//	summaryPath - The job summary file, from GITHUB_STEP_SUMMARY.
+//	mdFile - The Markdown file created by tp.
+//	notice - The notice ending the summary when it's truncated, from truncationNotice.
+//
+// Returns:
+//
+//	error - Any error encountered reading the Markdown or writing the summary.
+func appendStepSummary(summaryPath, mdFile, notice string) error {
+	content, err := os.ReadFile(mdFile) //nolint:gosec // the Markdown file tp just wrote
+	if err != nil {
+		return fmt.Errorf("failed to read markdown file %s: %w", mdFile, err)
+	}
+
+	summary, err := os.OpenFile( //nolint:gosec // path provided by the Actions runner
+		summaryPath,
+		os.O_WRONLY|os.O_APPEND|os.O_CREATE,
+		defaultFileMode,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to open step summary %s: %w", summaryPath, err)
+	}
+	defer func() {
+		_ = summary.Close()
+	}()
+
+	// The summary may already hold other steps' output, keep ours a separate block
+	body := truncateMarkdownNotice(string(content), maxStepSummaryBytes-1, notice)
+	if _, err = summary.WriteString("\n" + body); err != nil {
+		return fmt.Errorf("failed to write step summary %s: %w", summaryPath, err)
+	}
+	Logger.Debugf("Appended %d bytes to step summary %s", len(body)+1, summaryPath)
+	return nil
+}
+
+// genericTruncationNotice says the plan was truncated when there's no link to
+// the full plan
+const genericTruncationNotice = "The plan was truncated to fit. See the full plan in the plan file."
+
+// defaultTruncationNotice is the 'truncationNotice' template used when none is
+// configured: it links to the attached plan file, if any.
+const defaultTruncationNotice = "The plan was truncated to fit. " +
+	"{{ if .ArtifactURL }}See the full plan in the [attached plan file]({{ .ArtifactURL }})." +
+	"{{ else }}See the full plan in the plan file.{{ end }}"
+
+// truncationNoticeData is what the 'truncationNotice' template is executed with.
+type truncationNoticeData struct {
+	ArtifactURL string // URL of the plan file attached with --attach-plan, empty otherwise
+}
+
+// loadTruncationNotice parses 'truncationNotice', the notice ending truncated
+// Markdown, and executes it once so a misspelled field fails before planning.
+//
+// Returns:
+//
+//	*template.Template - The notice template, defaultTruncationNotice if unset.
+//	error - An error if the template is malformed.
+func loadTruncationNotice() (*template.Template, error) {
+	text := viper.GetString("truncationNotice")
+	if strings.TrimSpace(text) == "" {
+		text = defaultTruncationNotice
+	}
+	tmpl, err := template.New("truncationNotice").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'truncationNotice': %w", err)
+	}
+	if _, err = truncationNotice(tmpl, ""); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// truncationNotice renders the notice ending truncated Markdown.
+//
+// Parameters:
+//
+//	tmpl - The template from loadTruncationNotice.
+//	artifactURL - The URL of the attached plan file, empty when it wasn't attached.
+//
+// Returns:
+//
+//	string - The notice, genericTruncationNotice if the template renders nothing.
+//	error - Any error executing the template.
+func truncationNotice(tmpl *template.Template, artifactURL string) (string, error) {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, truncationNoticeData{ArtifactURL: artifactURL}); err != nil {
+		return "", fmt.Errorf("invalid 'truncationNotice': %w", err)
+	}
+	if notice := strings.TrimSpace(sb.String()); notice != "" {
+		return notice, nil
+	}
+	return genericTruncationNotice, nil
+}
+
+// truncateMarkdown shortens body to at most maxBytes with the generic notice,
+// see truncateMarkdownNotice.
+func truncateMarkdown(body string, maxBytes int) string {
+	return truncateMarkdownNotice(body, maxBytes, genericTruncationNotice)
+}
+
+// truncateMarkdownNotice shortens body to at most maxBytes, cutting at a line
+// boundary. Code blocks and <details> blocks left open by the cut are closed
+// so the rest of the page still renders, and a notice says the plan was cut.
+//
+// Parameters:
+//
+//	body - The Markdown to truncate.
+//	maxBytes - The maximum size of the result.
+//	notice - The Markdown saying the plan was cut, rendered as a warning.
+//
+// Returns:
+//
+//	string - body itself if it fits, otherwise the truncated Markdown.
+func truncateMarkdownNotice(body string, maxBytes int, notice string) string {
+	if len(body) <= maxBytes {
+		return body
+	}
+
+	notice = "\n> [!WARNING]\n> " + strings.ReplaceAll(strings.TrimSpace(notice), "\n", "\n> ") + "\n"
+	// Room for the notice and the closing tags of a code block inside a
+	// <details> block, the deepest nesting tp renders
+	const closers = "```\n\n</details>\n"
+	limit := maxBytes - len(notice) - len(closers)
+	if limit <= 0 {
+		return ""
+	}
+
+	cut := body[:limit]
+	for !utf8.ValidString(cut) {
+		cut = cut[:len(cut)-1]
+	}
+	if i := strings.LastIndex(cut, "\n"); i >= 0 {
+		cut = cut[:i+1]
+	}
+
+	var sb strings.Builder
+	sb.WriteString(cut)
+	inFence := false
+	openDetails := 0
+	for line := range strings.Lines(cut) {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			inFence = !inFence
+		case inFence:
+		case strings.HasPrefix(trimmed, "<details>"):
+			openDetails++
+		case strings.HasPrefix(trimmed, "</details>") && openDetails > 0:
+			openDetails--
+		}
+	}
+	if inFence {
+		sb.WriteString("```\n")
+	}
+	for range openDetails {
+		sb.WriteString("\n</details>\n")
+	}
+	sb.WriteString(notice)
+	return sb.String()
+}
@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStepSummaryPath(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	t.Cleanup(viper.Reset)
+	summary := filepath.Join(t.TempDir(), "summary.md")
+
+	t.Setenv("GITHUB_STEP_SUMMARY", "")
+	require.Empty(t, stepSummaryPath(), "disabled outside Actions")
+
+	t.Setenv("GITHUB_STEP_SUMMARY", summary)
+	require.Equal(t, summary, stepSummaryPath(), "enabled automatically in Actions")
+
+	viper.Set("stepSummary", false)
+	require.Empty(t, stepSummaryPath(), "can be turned off")
+}
+
+func TestAppendStepSummary(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	t.Chdir(t.TempDir())
+	summary := filepath.Join(t.TempDir(), "summary.md")
+	t.Setenv("GITHUB_STEP_SUMMARY", summary)
+	require.NoError(t, os.WriteFile(summary, []byte("## Previous step\n"), 0o600))
+
+	mdFile, err := createMarkdown("plan.md", "Plan: 1 to add, 0 to change, 0 to destroy.", "terraform", markdownOptions{})
+	require.NoError(t, err)
+	require.NoError(t, appendStepSummary(stepSummaryPath(), mdFile, genericTruncationNotice))
+
+	got, err := os.ReadFile(summary)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(got), "## Previous step\n\n<details><summary>Terraform plan</summary>"), string(got))
+	require.Contains(t, string(got), "Plan: 1 to add, 0 to change, 0 to destroy.")
+}
+
+func TestTruncateMarkdown(t *testing.T) {
+	body := "<details><summary>Terraform plan</summary>\n\n```terraform\n" +
+		strings.Repeat("  + resource \"null_resource\" \"é\" {}\n", 200) +
+		"```\n\n</details>\n"
+
+	require.Equal(t, body, truncateMarkdown(body, len(body)), "fits as is")
+
+	got := truncateMarkdown(body, 2000)
+	require.LessOrEqual(t, len(got), 2000)
+	require.Contains(t, got, "The plan was truncated to fit.")
+	require.Equal(t, 2, strings.Count(got, "```"), "the code block is closed")
+	require.Equal(t, 1, strings.Count(got, "</details>"), "the details block is closed")
+}
+
+func TestTruncationNotice(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	body := "```terraform\n" + strings.Repeat("  + resource \"null_resource\" \"this\" {}\n", 200) + "```\n"
+
+	tmpl, err := loadTruncationNotice()
+	require.NoError(t, err)
+
+	t.Run("Links to the attached plan file", func(t *testing.T) {
+		notice, err := truncationNotice(tmpl, "https://gist.github.com/abc")
+		require.NoError(t, err)
+
+		got := truncateMarkdownNotice(body, 2000, notice)
+
+		require.LessOrEqual(t, len(got), 2000)
+		require.Contains(t, got, "> The plan was truncated to fit. See the full plan in the [attached plan file](https://gist.github.com/abc).")
+	})
+
+	t.Run("Generic without an artifact", func(t *testing.T) {
+		notice, err := truncationNotice(tmpl, "")
+		require.NoError(t, err)
+
+		require.Equal(t, genericTruncationNotice, notice)
+		require.Contains(t, truncateMarkdownNotice(body, 2000, notice), "> "+genericTruncationNotice)
+	})
+
+	t.Run("Configured", func(t *testing.T) {
+		viper.Set("truncationNotice", "Cut.\n{{ with .ArtifactURL }}[Full plan]({{ . }}){{ end }}")
+		tmpl, err := loadTruncationNotice()
+		require.NoError(t, err)
+
+		notice, err := truncationNotice(tmpl, "https://example.com/plan")
+		require.NoError(t, err)
+		require.Contains(t, truncateMarkdownNotice(body, 2000, notice), "> Cut.\n> [Full plan](https://example.com/plan)\n")
+
+		notice, err = truncationNotice(tmpl, "")
+		require.NoError(t, err)
+		require.Equal(t, "Cut.", notice)
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		viper.Set("truncationNotice", "{{ .ArtifactURL ")
+		_, err := loadTruncationNotice()
+		require.ErrorContains(t, err, "invalid 'truncationNotice'")
+
+		viper.Set("truncationNotice", "{{ .PlanURL }}")
+		_, err = loadTruncationNotice()
+		require.ErrorContains(t, err, "invalid 'truncationNotice'")
+	})
+}
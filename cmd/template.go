@@ -0,0 +1,345 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/huh"
+	"github.com/spf13/viper"
+)
+
+// prTemplateName is the file name GitHub looks for, in any case
+const prTemplateName = "pull_request_template.md"
+
+// prTemplateDirs are the directories GitHub searches for a pull request
+// template, relative to the repository root: a single template file in each,
+// or several in their PULL_REQUEST_TEMPLATE subdirectory.
+var prTemplateDirs = []string{".github", ".", "docs"}
+
+// findPRTemplate discovers the pull request templates GitHub would offer,
+// matching names case-insensitively as GitHub does.
+//
+// Parameters:
+//
+//	root - The repository root.
+//
+// Returns:
+//
+//	[]string - The templates found, in GitHub's search order.
+//	error - Any error encountered reading a directory other than it not existing.
+func findPRTemplate(root string) ([]string, error) {
+	var found []string
+	for _, dir := range prTemplateDirs {
+		base := filepath.Join(root, dir)
+		entries, err := os.ReadDir(base)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to search %s for pull request templates: %w", base, err)
+		}
+		for _, e := range entries {
+			switch {
+			case !e.IsDir() && strings.EqualFold(e.Name(), prTemplateName):
+				found = append(found, filepath.Join(base, e.Name()))
+			case e.IsDir() && strings.EqualFold(e.Name(), "PULL_REQUEST_TEMPLATE"):
+				templates, globErr := filepath.Glob(filepath.Join(base, e.Name(), "*.md"))
+				if globErr != nil {
+					return nil, globErr
+				}
+				sort.Strings(templates)
+				found = append(found, templates...)
+			}
+		}
+	}
+	Logger.Debugf("Found %d pull request templates: %v", len(found), found)
+	return found, nil
+}
+
+// requireTemplate fails when neither a configured templateFile nor a
+// discovered template exists, for teams that mandate a template.
+//
+// Parameters:
+//
+//	configured - The 'templateFile' parameter, may be empty.
+//	found - The templates from findPRTemplate.
+//
+// Returns:
+//
+//	error - An error listing the searched locations, or nil if a template exists.
+func requireTemplate(configured string, found []string) error {
+	if configured != "" {
+		if !doesExist(configured) {
+			return fmt.Errorf("'templateFile' %q does not exist, and --require-template is set", configured)
+		}
+		return nil
+	}
+	if len(found) > 0 {
+		return nil
+	}
+
+	searched := make([]string, 0, len(prTemplateDirs)*2) //nolint:mnd
+	for _, dir := range prTemplateDirs {
+		searched = append(
+			searched,
+			filepath.Join(dir, prTemplateName),
+			filepath.Join(dir, "PULL_REQUEST_TEMPLATE", "*.md"),
+		)
+	}
+	return fmt.Errorf(
+		"no pull request template found and --require-template is set. Set 'templateFile' or add one of: %s",
+		strings.Join(searched, ", "),
+	)
+}
+
+// HuhSelectRunner implements the FormRunner interface with a huh select,
+// choosing one of several files
+type HuhSelectRunner struct {
+	title      string   // Title displayed in the form
+	root       string   // Directory the options are shown relative to
+	options    []string // Paths to choose from
+	chosen     *string  // Pointer to store user's selection
+	accessible bool     // Whether to enable accessibility features
+}
+
+// Run displays the select to the user and captures their choice
+func (h *HuhSelectRunner) Run() error {
+	options := make([]huh.Option[string], 0, len(h.options))
+	for _, path := range h.options {
+		label := path
+		if rel, err := filepath.Rel(h.root, path); err == nil {
+			label = rel
+		}
+		options = append(options, huh.NewOption(label, path))
+	}
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title(h.title).
+				Options(options...).
+				Value(h.chosen),
+		),
+	).WithTheme(huh.ThemeBase16()).
+		WithAccessible(h.accessible)
+	return form.Run()
+}
+
+// Factory function for creating the pull request template picker
+// Makes it easier to mock during testing
+var templatePickerFactory = func(root string, templates []string, chosen *string, accessible bool) FormRunner {
+	return &HuhSelectRunner{
+		title:      "Which pull request template should the plan use?",
+		root:       root,
+		options:    templates,
+		chosen:     chosen,
+		accessible: accessible,
+	}
+}
+
+// pickPRTemplate chooses the pull request template among those findPRTemplate
+// found when 'templateFile' isn't set: the only one, or the one the user picks
+// when there are several. Without a terminal to ask in, none is used.
+//
+// Parameters:
+//
+//	root - The repository root the templates were found in.
+//	found - The templates from findPRTemplate.
+//
+// Returns:
+//
+//	string - The chosen template, empty when none was found.
+//	error - ErrInterrupted if the user left the picker, or any error encountered asking.
+func pickPRTemplate(root string, found []string) (string, error) {
+	switch len(found) {
+	case 0:
+		return "", nil
+	case 1:
+		Logger.Debugf("Using the only pull request template found: %s", found[0])
+		return found[0], nil
+	}
+
+	if !stdinIsTerminal() {
+		Logger.Debugf("Found %d pull request templates and no terminal to choose in, set 'templateFile' to use one", len(found))
+		return "", nil
+	}
+
+	accessible, _ := strconv.ParseBool(os.Getenv("ACCESSIBLE"))
+	chosen := found[0]
+	err := templatePickerFactory(root, found, &chosen, accessible).Run()
+	if isUserAbort(err) {
+		return "", ErrInterrupted
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to choose among %d pull request templates: %w", len(found), err)
+	}
+	Logger.Debugf("Using the chosen pull request template: %s", chosen)
+	return chosen, nil
+}
+
+// stdinIsTerminal reports whether stdin is a terminal the user can answer a
+// prompt in, rather than a pipe or CI's closed stdin. Tests replace it.
+var stdinIsTerminal = func() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// defaultTemplateLargeThreshold is the number of changes from which
+// 'templateLarge' is used
+const defaultTemplateLargeThreshold = 10
+
+// prTemplates are the pull request templates to choose from by the size of
+// the plan's changes.
+type prTemplates struct {
+	Default        string // 'templateFile', used when no other template applies
+	Small          string // 'templateSmall', for fewer changes than LargeThreshold
+	Large          string // 'templateLarge', for at least LargeThreshold changes
+	Destroy        string // 'templateDestroy', for plans destroying resources
+	LargeThreshold int    // 'templateLargeThreshold'
+}
+
+// loadPRTemplates reads and validates the templates and the threshold. The
+// templates set must exist.
+func loadPRTemplates() (prTemplates, error) {
+	templates := prTemplates{
+		Default:        viper.GetString("templateFile"),
+		Small:          viper.GetString("templateSmall"),
+		Large:          viper.GetString("templateLarge"),
+		Destroy:        viper.GetString("templateDestroy"),
+		LargeThreshold: defaultTemplateLargeThreshold,
+	}
+	if viper.IsSet("templateLargeThreshold") {
+		templates.LargeThreshold = viper.GetInt("templateLargeThreshold")
+		if templates.LargeThreshold <= 0 {
+			return prTemplates{}, fmt.Errorf(
+				"invalid 'templateLargeThreshold' (%d): must be positive",
+				templates.LargeThreshold,
+			)
+		}
+	}
+	for _, t := range []struct{ param, path string }{
+		{"templateFile", templates.Default},
+		{"templateSmall", templates.Small},
+		{"templateLarge", templates.Large},
+		{"templateDestroy", templates.Destroy},
+	} {
+		if t.path != "" && !doesExist(t.path) {
+			return prTemplates{}, fmt.Errorf("'%s' %q does not exist", t.param, t.path)
+		}
+	}
+	return templates, nil
+}
+
+// selectFor picks the template for the plan's changes: templateDestroy when
+// resources are destroyed, then templateLarge or templateSmall by the number
+// of changes, falling back to templateFile when the matching one isn't set.
+//
+// Parameters:
+//
+//	noChanges - Whether the plan has no changes.
+//	changes - The change counts, nil when the plan wasn't structured.
+//
+// Returns:
+//
+//	string - The template's path, empty when none is configured.
+func (t prTemplates) selectFor(noChanges bool, changes *changeCounts) string {
+	total := 0
+	switch {
+	case changes != nil:
+		if changes.Destroy > 0 && t.Destroy != "" {
+			return t.Destroy
+		}
+		total = changes.Add + changes.Change + changes.Destroy + changes.Import
+	case !noChanges:
+		// The plan text alone doesn't tell the size of the changes
+		return t.Default
+	}
+	if total >= t.LargeThreshold && t.Large != "" {
+		return t.Large
+	}
+	if total < t.LargeThreshold && t.Small != "" {
+		return t.Small
+	}
+	return t.Default
+}
+
+// readTemplateFile reads a pull request template with the checks of
+// readBodyFile and loadMarkdownTemplate.
+//
+// Parameters:
+//
+//	param - The parameter naming the template, for errors.
+//	path - The path of the template.
+//
+// Returns:
+//
+//	string - The template's content.
+//	error - An error if the file is missing, not a regular file, too large or not valid UTF-8.
+func readTemplateFile(param, path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid '%s' (%q): %w", param, path, err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("invalid '%s' (%q): not a regular file", param, path)
+	}
+	if info.Size() > maxTemplateBytes {
+		return "", fmt.Errorf("invalid '%s' (%q): larger than %d bytes", param, path, maxTemplateBytes)
+	}
+	data, err := os.ReadFile(path) //nolint:gosec // explicitly provided by the user
+	if err != nil {
+		return "", fmt.Errorf("invalid '%s' (%q): %w", param, path, err)
+	}
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("invalid '%s' (%q): file is not valid UTF-8", param, path)
+	}
+	return string(data), nil
+}
+
+// getTemplateFromConfig reads the configured pull request template for the
+// plan's changes, see selectFor.
+//
+// Parameters:
+//
+//	templates - The templates from loadPRTemplates.
+//	noChanges - Whether the plan has no changes.
+//	changes - The change counts, nil when the plan wasn't structured.
+//
+// Returns:
+//
+//	string - The template's content, empty when none is configured.
+//	error - Any error encountered reading the template.
+func getTemplateFromConfig(templates prTemplates, noChanges bool, changes *changeCounts) (string, error) {
+	path := templates.selectFor(noChanges, changes)
+	if path == "" {
+		return "", nil
+	}
+	param := "templateFile"
+	switch path {
+	case templates.Destroy:
+		param = "templateDestroy"
+	case templates.Large:
+		param = "templateLarge"
+	case templates.Small:
+		param = "templateSmall"
+	}
+	Logger.Debugf("Using pull request template: %s", path)
+	return readTemplateFile(param, path)
+}
+
+// prBodyTemplate returns the pull request template a new pull request's body
+// starts with, normalized like the Markdown tp writes. spliceBody puts the
+// plan after it, outside of the template.
+func prBodyTemplate(tmpl string) string {
+	if strings.TrimSpace(tmpl) == "" {
+		return ""
+	}
+	return normalizeMarkdown(tmpl)
+}
@@ -0,0 +1,328 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindPRTemplate(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	root := t.TempDir()
+	write := func(path string) {
+		t.Helper()
+		full := filepath.Join(root, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0o750))
+		require.NoError(t, os.WriteFile(full, []byte("## Summary\n"), 0o600))
+	}
+
+	found, err := findPRTemplate(root)
+	require.NoError(t, err)
+	require.Empty(t, found)
+
+	write(".github/PULL_REQUEST_TEMPLATE.md")
+	write("docs/PULL_REQUEST_TEMPLATE/infra.md")
+	write("docs/PULL_REQUEST_TEMPLATE/app.md")
+	write("docs/notes.md")
+
+	found, err = findPRTemplate(root)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(root, ".github", "PULL_REQUEST_TEMPLATE.md"),
+		filepath.Join(root, "docs", "PULL_REQUEST_TEMPLATE", "app.md"),
+		filepath.Join(root, "docs", "PULL_REQUEST_TEMPLATE", "infra.md"),
+	}, found)
+}
+
+func TestRequireTemplate(t *testing.T) {
+	dir := t.TempDir()
+	configured := filepath.Join(dir, "template.md")
+	require.NoError(t, os.WriteFile(configured, []byte("## Summary\n"), 0o600))
+
+	tests := []struct {
+		name       string
+		configured string
+		found      []string
+		wantErr    string
+	}{
+		{name: "Configured", configured: configured},
+		{name: "Discovered", found: []string{".github/pull_request_template.md"}},
+		{
+			name:    "Missing",
+			wantErr: "no pull request template found and --require-template is set. Set 'templateFile' or add one of: .github/pull_request_template.md, .github/PULL_REQUEST_TEMPLATE/*.md, pull_request_template.md",
+		},
+		{
+			name:       "Configured but missing",
+			configured: filepath.Join(dir, "missing.md"),
+			found:      []string{".github/pull_request_template.md"},
+			wantErr:    "does not exist",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := requireTemplate(tt.configured, tt.found)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestSelectPRTemplate(t *testing.T) {
+	templates := prTemplates{
+		Default:        "default.md",
+		Small:          "small.md",
+		Large:          "large.md",
+		Destroy:        "destroy.md",
+		LargeThreshold: defaultTemplateLargeThreshold,
+	}
+	counts := func(name string) *changeCounts {
+		c := countChanges(loadPlanFixture(t, name))
+		return &c
+	}
+
+	t.Run("No changes", func(t *testing.T) {
+		require.Equal(t, "small.md", templates.selectFor(true, counts("no-changes.json")))
+		require.Equal(t, "small.md", templates.selectFor(true, nil), "from the plan text")
+	})
+
+	t.Run("Large change", func(t *testing.T) {
+		require.Equal(t, "large.md", templates.selectFor(false, counts("large.json")))
+	})
+
+	t.Run("Destroy present", func(t *testing.T) {
+		require.Equal(t, "destroy.md", templates.selectFor(false, counts("changes.json")))
+	})
+
+	t.Run("Threshold", func(t *testing.T) {
+		lower := templates
+		lower.LargeThreshold = 3
+		lower.Destroy = ""
+
+		require.Equal(t, "large.md", lower.selectFor(false, &changeCounts{Add: 2, Import: 1}))
+		require.Equal(t, "small.md", lower.selectFor(false, &changeCounts{Add: 2}))
+	})
+
+	t.Run("Falls back to the single template", func(t *testing.T) {
+		single := prTemplates{Default: "default.md", LargeThreshold: defaultTemplateLargeThreshold}
+
+		require.Equal(t, "default.md", single.selectFor(true, counts("no-changes.json")))
+		require.Equal(t, "default.md", single.selectFor(false, counts("large.json")))
+		require.Equal(t, "default.md", single.selectFor(false, counts("changes.json")))
+		require.Equal(t, "default.md", templates.selectFor(false, nil), "unknown size from the plan text")
+	})
+}
+
+func TestLoadPRTemplates(t *testing.T) {
+	dir := t.TempDir()
+	destroy := filepath.Join(dir, "destroy.md")
+	require.NoError(t, os.WriteFile(destroy, []byte("Resources will be destroyed.\n"), 0o600))
+
+	t.Run("Defaults", func(t *testing.T) {
+		loadConfig(t, "templateFile = '"+destroy+"'\n")
+
+		got, err := loadPRTemplates()
+
+		require.NoError(t, err)
+		require.Equal(t, prTemplates{Default: destroy, LargeThreshold: defaultTemplateLargeThreshold}, got)
+	})
+
+	t.Run("Templates per magnitude", func(t *testing.T) {
+		loadConfig(t, "templateDestroy = '"+destroy+"'\ntemplateLargeThreshold = 25\n")
+
+		got, err := loadPRTemplates()
+
+		require.NoError(t, err)
+		require.Equal(t, prTemplates{Destroy: destroy, LargeThreshold: 25}, got)
+	})
+
+	t.Run("Missing template", func(t *testing.T) {
+		loadConfig(t, "templateLarge = 'missing.md'\n")
+
+		_, err := loadPRTemplates()
+
+		require.EqualError(t, err, `'templateLarge' "missing.md" does not exist`)
+
+		loadConfig(t, "templateFile = 'missing.md'\n")
+
+		_, err = loadPRTemplates()
+
+		require.EqualError(t, err, `'templateFile' "missing.md" does not exist`)
+	})
+
+	t.Run("Threshold must be positive", func(t *testing.T) {
+		loadConfig(t, "templateLargeThreshold = 0\n")
+
+		_, err := loadPRTemplates()
+
+		require.EqualError(t, err, "invalid 'templateLargeThreshold' (0): must be positive")
+	})
+}
+
+func TestGetTemplateFromConfig(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+	templates := prTemplates{
+		Default:        write("default.md", "## Summary\n"),
+		Destroy:        write("destroy.md", "## Resources will be destroyed\n"),
+		LargeThreshold: defaultTemplateLargeThreshold,
+	}
+	changes := countChanges(loadPlanFixture(t, "changes.json"))
+
+	t.Run("Selected by the changes", func(t *testing.T) {
+		got, err := getTemplateFromConfig(templates, false, &changes)
+
+		require.NoError(t, err)
+		require.Equal(t, "## Resources will be destroyed\n", got)
+	})
+
+	t.Run("templateFile", func(t *testing.T) {
+		got, err := getTemplateFromConfig(templates, false, nil)
+
+		require.NoError(t, err)
+		require.Equal(t, "## Summary\n", got)
+	})
+
+	t.Run("None configured", func(t *testing.T) {
+		got, err := getTemplateFromConfig(prTemplates{LargeThreshold: defaultTemplateLargeThreshold}, false, &changes)
+
+		require.NoError(t, err)
+		require.Empty(t, got)
+	})
+
+	t.Run("Invalid template", func(t *testing.T) {
+		invalid := prTemplates{Default: write("binary.md", "\xff\xfe"), LargeThreshold: defaultTemplateLargeThreshold}
+
+		_, err := getTemplateFromConfig(invalid, false, nil)
+		require.ErrorContains(t, err, "invalid 'templateFile'")
+		require.ErrorContains(t, err, "not valid UTF-8")
+
+		_, err = getTemplateFromConfig(prTemplates{Default: dir, LargeThreshold: defaultTemplateLargeThreshold}, false, nil)
+		require.ErrorContains(t, err, "not a regular file")
+	})
+}
+
+func TestPRBodyTemplate(t *testing.T) {
+	tmpl := prBodyTemplate("## Summary  \r\n\n\n")
+	require.Equal(t, "## Summary\n", tmpl)
+	require.Empty(t, prBodyTemplate(" \n"))
+
+	body := spliceBody(tmpl, "```terraform\nNo changes.\n```\n", defaultPRBodyMaxBytes, genericTruncationNotice)
+
+	require.Equal(
+		t,
+		"## Summary\n\n"+planStartMarker+"\n```terraform\nNo changes.\n```\n"+planEndMarker,
+		body,
+		"the template stays outside of the plan markers",
+	)
+	require.Equal(t, body, spliceBody(body, "```terraform\nNo changes.\n```\n", defaultPRBodyMaxBytes, genericTruncationNotice),
+		"updating the plan keeps a single template")
+}
+
+func TestPickPRTemplate(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	root := t.TempDir()
+	bug := filepath.Join(root, ".github", "PULL_REQUEST_TEMPLATE", "bug.md")
+	feature := filepath.Join(root, ".github", "PULL_REQUEST_TEMPLATE", "feature.md")
+	originalFactory := templatePickerFactory
+	originalIsTerminal := stdinIsTerminal
+	t.Cleanup(func() {
+		templatePickerFactory = originalFactory
+		stdinIsTerminal = originalIsTerminal
+	})
+	stdinIsTerminal = func() bool { return true }
+	prompted := false
+	answer := func(choice string, err error) {
+		prompted = false
+		templatePickerFactory = func(r string, templates []string, chosen *string, accessible bool) FormRunner {
+			prompted = true
+			require.Equal(t, root, r)
+			require.Equal(t, []string{bug, feature}, templates)
+			require.Equal(t, bug, *chosen, "GitHub's first template is preselected")
+			*chosen = choice
+			return &MockFormRunner{err: err}
+		}
+	}
+
+	t.Run("None", func(t *testing.T) {
+		answer("", nil)
+
+		got, err := pickPRTemplate(root, nil)
+
+		require.NoError(t, err)
+		require.Empty(t, got)
+		require.False(t, prompted)
+	})
+
+	t.Run("One", func(t *testing.T) {
+		answer("", nil)
+
+		got, err := pickPRTemplate(root, []string{feature})
+
+		require.NoError(t, err)
+		require.Equal(t, feature, got)
+		require.False(t, prompted)
+	})
+
+	t.Run("Several", func(t *testing.T) {
+		answer(feature, nil)
+
+		got, err := pickPRTemplate(root, []string{bug, feature})
+
+		require.NoError(t, err)
+		require.Equal(t, feature, got)
+		require.True(t, prompted)
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		answer(feature, huh.ErrUserAborted)
+
+		_, err := pickPRTemplate(root, []string{bug, feature})
+
+		require.ErrorIs(t, err, ErrInterrupted)
+	})
+
+	t.Run("Unable to ask", func(t *testing.T) {
+		answer(feature, errors.New("could not open a new TTY"))
+
+		_, err := pickPRTemplate(root, []string{bug, feature})
+
+		require.ErrorContains(t, err, "unable to choose among 2 pull request templates")
+		require.NotErrorIs(t, err, ErrInterrupted)
+	})
+
+	t.Run("No terminal", func(t *testing.T) {
+		stdinIsTerminal = func() bool { return false }
+		t.Cleanup(func() {
+			stdinIsTerminal = func() bool { return true }
+		})
+		answer(feature, nil)
+
+		got, err := pickPRTemplate(root, []string{bug, feature})
+
+		require.NoError(t, err, "CI proceeds without a template")
+		require.Empty(t, got)
+		require.False(t, prompted)
+	})
+}
@@ -6,75 +6,120 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"os/exec"
 	"os/signal"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
 
-	"github.com/briandowns/spinner"
+	"github.com/go-playground/validator/v10"
+	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
 	"github.com/spf13/viper"
 )
 
-func createPlan() (planStr string, err error) {
+// planResult is the outcome of a plan in one working directory.
+type planResult struct {
+	Dir      string       // Working directory of the plan
+	PlanPath string       // Path of the plan file, relative to the current directory
+	Text     string       // Human-readable plan output
+	JSON     *tfjson.Plan // Structured plan, nil when it could not be read
+	Command  string       // Command line of the plan, for --include-command
+}
+
+// createPlan runs the plan in workingDir and returns both its human-readable
+// output and, when it can be read, the structured JSON plan. A nil JSON plan
+// is not an error.
+//
+// Parameters:
+//
+//	ctx - The context controlling the plan, cancelling it stops the binary.
+//	workingDir - The directory to plan in, "." for the current directory.
+//	quiet - Whether to hide the spinner, used when several plans run at once.
+//
+// Returns:
+//
+//	planResult - The plan outputs.
+//	error - ErrInterrupted on a signal, or any error encountered planning.
+func createPlan(ctx context.Context, workingDir string, quiet bool) (planResult, error) {
 	// --- Parameter Validation & Setup ---
-	workingDir := "."
+	result := planResult{Dir: workingDir}
 	tfBinaryPath := viper.GetString("binary")
 	if tfBinaryPath == "" { // Primary source (Viper) is empty
 		if binary == "" { // Check fallback source BEFORE assigning
-			return "", errors.New("binary not configured: No path provided via config or default")
+			return result, errors.New("binary not configured: No path provided via config or default")
 		}
 		tfBinaryPath = binary
 	}
 	pf := viper.GetString("planFile")
-	planPath, err := validateFilePath(pf)
+	// planName is passed to the binary, which runs in workingDir
+	planName, err := validateOutputName(pf)
 	if err != nil {
-		return "", fmt.Errorf("invalid 'planFile' (%q): %w", pf, err)
+		return result, fmt.Errorf("invalid 'planFile' (%q): %w", pf, err)
 	}
+	planPath := filepath.Join(workingDir, planName)
+	result.PlanPath = planPath
 
 	tf, err := tfexec.NewTerraform(workingDir, tfBinaryPath)
 	if err != nil {
-		return "", fmt.Errorf("tfexec init failed: %w", err)
+		return result, fmt.Errorf("tfexec init failed: %w", err)
 	}
 	// _ = tf.SetWaitDelay(60 * time.Second)
-	planOpts := []tfexec.PlanOption{tfexec.Out(planPath)}
 
-	// --- Signal Handling & Atomic Flag ---
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-	var interrupted atomic.Bool
-
-	cleanupSignalResources := func() {
-		Logger.Debug("Attempting signal resource cleanup...")
-		signal.Stop(sigChan)
-		select {
-		case <-sigChan:
-			Logger.Debug("Drained signal during cleanup.")
-		default:
-		}
-		func() {
-			defer func() {
-				if r := recover(); r != nil {
-					Logger.Debugf("Recovered from closing already closed sigChan: %v", r)
-				}
-			}()
-			close(sigChan)
-			Logger.Debug("Signal channel closed.")
-		}()
-		Logger.Debug("Signal handler resources cleanup finished.")
+	planEnv, err := buildPlanEnv()
+	if err != nil {
+		return result, err
+	}
+	env := planProcessEnv(os.Environ(), planEnv)
+	addExcludeArgs(env, viper.GetStringSlice("exclude"))
+	if err = applyPlanEnv(tf, env); err != nil {
+		return result, err
 	}
 
-	go func() {
-		defer Logger.Debug("Signal listener goroutine finished.")
-		sig, ok := <-sigChan
-		if ok {
-			Logger.Warnf("Signal %v received by Go process. Setting interruption flag.", sig)
-			interrupted.Store(true)
-		} else {
-			Logger.Debug("Signal channel closed while listener goroutine was active.")
+	warnPinnedVersion(ctx, tf, tfBinaryPath, workingDir)
+
+	// --- Select the Workspace ---
+	// Before the cache, whose key includes the workspace
+	if ws := viper.GetString("planWorkspace"); ws != "" {
+		if err = selectWorkspace(ctx, tf, workingDir, ws); err != nil {
+			return result, err
 		}
-	}()
+	}
+
+	// --- Check Formatting ---
+	if viper.GetBool("checkFmt") {
+		err = checkFormat(ctx, tf, tfBinaryPath, viper.GetBool("strictFmt"))
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// Before the cache, whose key includes the arguments, so they're checked either way
+	planOpts, planArgs, err := buildPlanOptions(planName)
+	if err != nil {
+		return result, err
+	}
+	result.Command = formatPlanCommand(tfBinaryPath, currentWorkspace(workingDir), planArgs)
+
+	// --- Reuse a Recent Plan ---
+	cacheKey := planCacheKey(tfBinaryPath, currentWorkspace(workingDir), planArgs)
+	if usePlanCache(planPath, workingDir, cacheKey) {
+		Logger.Infof("Reusing cached plan %s (use --no-cache to re-plan)", planPath)
+		return showPlanResult(ctx, tf, planName, result)
+	}
+
+	// --- Signal Handling ---
+	signals := watchSignals()
+	// Stopped as soon as the plan returns, this covers the other returns
+	defer signals.Stop()
 
 	// --- Execute Terraform Plan ---
 	Logger.Debugf(
@@ -82,61 +127,708 @@ func createPlan() (planStr string, err error) {
 		tfBinaryPath,
 		planPath,
 	)
-	s := spinner.New(spinner.CharSets[14], spinnerDuration)
-	s.Suffix = " Creating Plan..."
-	s.Start()
+	s := newSpinner(spinnerMessage(msgCreatingPlan, filepath.Base(tfBinaryPath), 1))
+	if !quiet {
+		s.Start()
+	}
 
-	planCtx := context.Background()
-	_, err = tf.Plan(planCtx, planOpts...)
+	_, err = tf.Plan(ctx, planOpts...)
+	var initErr error
+	if err != nil && !signals.Interrupted() && viper.GetBool("autoInit") && needsInit(err, workingDir) {
+		s.Stop()
+		Logger.Infof("%s isn't initialized, running init (--auto-init)", workingDir)
+		if initErr = runInit(ctx, tf, tfBinaryPath, quiet); initErr == nil && !signals.Interrupted() {
+			if !quiet {
+				s.Start()
+			}
+			_, err = tf.Plan(ctx, planOpts...)
+		}
+	}
+	// A signal received during the plan is relayed before Stop returns
+	signals.Stop()
 
 	// --- Handle Plan Result ---
-	if interrupted.Load() {
+	if signals.Interrupted() {
 		s.Stop()
 		Logger.Warnf("Interruption flag set. Terraform process likely interrupted.")
+		return result, ErrInterrupted // Return the specific error
+	}
 
-		cleanupSignalResources()
-
-		Logger.Debugf("[DIAG] Skipping signal cleanup call for test.")
-		Logger.Debugf("[DIAG] About to return ErrInterrupted from createPlan.")
-
-		return "", ErrInterrupted // Return the specific error
+	if initErr != nil {
+		_ = os.Remove(planPath)
+		return result, initErr
 	}
 
 	// Handle other errors
 	if err != nil {
 		s.Stop()
 		Logger.Errorf("tf.Plan finished with non-interruption error. Type: %T, Value: %v", err, err)
-		cleanupSignalResources()
 		// Presumably an unusable plan, so let's clean things up -- we may not want this long-term or maybe make this a parameter
 		_ = os.Remove(planPath) // Attempt cleanup for other errors
-		return "", fmt.Errorf("terraform plan failed: %w", err)
+		if !isInitialized(workingDir) {
+			Logger.Warnf("%s not found, has 'init' been run in %s?", dataDirPath(workingDir), workingDir)
+		}
+		planErr := explainExecError(err, tfBinaryPath)
+		if viper.GetBool("planLockInfo") {
+			planErr = explainLockError(planErr)
+		}
+		return result, fmt.Errorf("terraform plan failed: %w", planErr)
 	}
 
 	// --- Plan Successful ---
 	s.Stop()
-	cleanupSignalResources()
 	Logger.Debug("Terraform plan completed successfully.")
+	writePlanCacheKey(planPath, cacheKey)
+
+	return showPlanResult(ctx, tf, planName, result)
+}
+
+// uninitializedErrors are parts of the errors a plan fails with when 'init'
+// hasn't been run, or needs to run again after a module, provider or backend
+// change
+var uninitializedErrors = []string{
+	"Module not installed",
+	"Required plugins are not installed",
+	"Backend initialization required",
+	"Inconsistent dependency lock file",
+	"missing or corrupted provider plugins",
+}
+
+// needsInit reports whether the plan of dir failed with planErr because
+// 'init' must run first.
+func needsInit(planErr error, dir string) bool {
+	if !isInitialized(dir) {
+		return true
+	}
+	msg := planErr.Error()
+	for _, part := range uninitializedErrors {
+		if strings.Contains(msg, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// initializer is the subset of *tfexec.Terraform used to run 'init'.
+type initializer interface {
+	Init(ctx context.Context, opts ...tfexec.InitOption) error
+}
+
+// runInit runs 'init' for --auto-init, with its own spinner.
+//
+// Parameters:
+//
+//	ctx - The context controlling 'init'.
+//	in - The initializer, normally the *tfexec.Terraform used for the plan.
+//	binaryPath - The binary used, for the spinner and errors.
+//	quiet - Whether to hide the spinner, used when several plans run at once.
+//
+// Returns:
+//
+//	error - Any error encountered initializing.
+func runInit(ctx context.Context, in initializer, binaryPath string, quiet bool) error {
+	s := newSpinner(spinnerMessage(msgInitializing, filepath.Base(binaryPath), 1))
+	if !quiet {
+		s.Start()
+	}
+	err := in.Init(ctx)
+	s.Stop()
+	if err != nil {
+		return fmt.Errorf("%s init failed: %w", filepath.Base(binaryPath), explainExecError(err, binaryPath))
+	}
+	Logger.Debug("Init completed, planning again.")
+	return nil
+}
+
+// signalWatcher records whether SIGINT or SIGTERM was received while a plan
+// runs. The binary receives the signal too and stops on its own, the watcher
+// tells createPlan to report ErrInterrupted rather than a plan failure.
+type signalWatcher struct {
+	sigChan     chan os.Signal
+	done        chan struct{} // Closed once the listener goroutine returns
+	interrupted atomic.Bool
+	stopOnce    sync.Once
+}
 
-	planStr, err = showPlan(tf, planPath)
+// watchSignals starts watching for SIGINT and SIGTERM. Stop must be called to
+// end the listener goroutine.
+func watchSignals() *signalWatcher {
+	w := &signalWatcher{sigChan: make(chan os.Signal, 1), done: make(chan struct{})}
+	signal.Notify(w.sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer close(w.done)
+		if sig, ok := <-w.sigChan; ok {
+			Logger.Warnf("Signal %v received by Go process. Setting interruption flag.", sig)
+			w.interrupted.Store(true)
+		}
+	}()
+	return w
+}
+
+// Interrupted reports whether a signal was received. It's final once Stop
+// has returned.
+func (w *signalWatcher) Interrupted() bool {
+	return w.interrupted.Load()
+}
+
+// Stop stops watching for signals and waits for the listener goroutine to
+// return, so repeated plans don't accumulate goroutines. It can be called more
+// than once.
+func (w *signalWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		// No signal is sent to sigChan once signal.Stop returns, so it can be closed
+		signal.Stop(w.sigChan)
+		close(w.sigChan)
+	})
+	<-w.done
+}
+
+// explainExecError adds guidance to the cryptic errors returned when the
+// binary can't be executed at all: a build for another architecture fails with
+// ENOEXEC ("exec format error") and a file without the execute bit with
+// EACCES ("permission denied"). Only errors starting the process are
+// explained, other errors are returned as is.
+//
+// Parameters:
+//
+//	err - The error returned running the binary.
+//	binaryPath - The binary that was run.
+//
+// Returns:
+//
+//	error - err, wrapped with guidance when the binary couldn't be executed.
+func explainExecError(err error, binaryPath string) error {
+	var pathErr *fs.PathError
+	var execErr *exec.Error
+	// os.StartProcess reports a binary it couldn't start as a "fork/exec" PathError
+	started := errors.As(err, &pathErr) && pathErr.Op == "fork/exec"
+	if !started && !errors.As(err, &execErr) {
+		return err
+	}
+	switch {
+	case errors.Is(err, syscall.ENOEXEC):
+		return fmt.Errorf(
+			"unable to execute %s, is it built for this architecture (%s/%s)? %w",
+			binaryPath, runtime.GOOS, runtime.GOARCH, err,
+		)
+	case errors.Is(err, syscall.EACCES) || errors.Is(err, fs.ErrPermission):
+		return fmt.Errorf("unable to execute %s, is it executable (chmod +x)? %w", binaryPath, err)
+	}
+	return err
+}
+
+// showPlanResult fills result with the text and JSON of the plan file.
+func showPlanResult(ctx context.Context, tf *tfexec.Terraform, planName string, result planResult) (planResult, error) {
+	text, planJSON, err := showPlans(ctx, tf, planName)
+	if err != nil {
+		return result, err
+	}
+	result.Text = text
+	result.JSON = planJSON
+	return result, nil
+}
+
+// defaultWorkspace is the workspace used when none has been selected
+const defaultWorkspace = "default"
+
+// currentWorkspace returns the workspace a plan in dir would use, following
+// the same precedence as terraform and tofu: TF_WORKSPACE, then the workspace
+// recorded by 'workspace select', then "default".
+func currentWorkspace(dir string) string {
+	if ws := os.Getenv("TF_WORKSPACE"); ws != "" {
+		return ws
+	}
+	data, err := os.ReadFile(filepath.Join(dataDirPath(dir), "environment")) //nolint:gosec // fixed name in the data dir
+	if err != nil {
+		return defaultWorkspace
+	}
+	if ws := strings.TrimSpace(string(data)); ws != "" {
+		return ws
+	}
+	return defaultWorkspace
+}
+
+// dataDirPath returns the data directory 'init' creates for a plan in dir:
+// TF_DATA_DIR, as the plan process sees it, relative to dir, or .terraform.
+func dataDirPath(dir string) string {
+	dataDir := planDataDir()
+	if dataDir == "" {
+		dataDir = ".terraform"
+	}
+	if !filepath.IsAbs(dataDir) {
+		dataDir = filepath.Join(dir, dataDir)
+	}
+	return dataDir
+}
+
+// planDataDir returns TF_DATA_DIR as applyPlanEnv sets it for the plan
+// process: from --data-dir, 'planEnv' or --env, otherwise inherited.
+func planDataDir() string {
+	if env, err := buildPlanEnv(); err == nil {
+		if dataDir, ok := env["TF_DATA_DIR"]; ok {
+			return dataDir
+		}
+	}
+	return os.Getenv("TF_DATA_DIR")
+}
+
+// isInitialized reports whether 'init' has created the data directory for dir.
+func isInitialized(dir string) bool {
+	info, err := os.Stat(dataDirPath(dir))
+	return err == nil && info.IsDir()
+}
+
+// versionReader is the subset of *tfexec.Terraform used to read the version.
+type versionReader interface {
+	Version(ctx context.Context, skipCache bool) (*version.Version, map[string]*version.Version, error)
+}
+
+// warnPinnedVersion warns when the binary's version differs from the one
+// pinned by a version manager file for workingDir. It is best-effort and
+// only runs the binary when a pin exists.
+func warnPinnedVersion(ctx context.Context, vr versionReader, binaryPath, workingDir string) {
+	pins := readVersionPins(workingDir)
+	if len(pins) == 0 {
+		return
+	}
+	installed, _, err := vr.Version(ctx, false)
+	if err != nil {
+		Logger.Debugf("Unable to read the %s version: %v", binaryPath, err)
+		return
+	}
+	if warning := pinnedVersionWarning(binaryKind(binaryPath), installed.String(), pins); warning != "" {
+		Logger.Warn(warning)
+	}
+}
+
+// parseMinVersion validates 'minVersion', the oldest binary version allowed
+// to plan, with the semver rule of ConfigParams' tag so the flag and the
+// config file accept the same versions.
+func parseMinVersion(v string) (*version.Version, error) {
+	trimmed := strings.TrimSpace(v)
+	if err := validator.New().Var(trimmed, "semver"); err != nil {
+		return nil, fmt.Errorf("invalid 'minVersion' (%q): expected a version such as 1.6.0", v)
+	}
+	minVersion, err := version.NewSemver(trimmed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'minVersion' (%q): expected a version such as 1.6.0", v)
+	}
+	return minVersion, nil
+}
+
+// checkMinVersion fails when the binary is older than minVersion, whose plan
+// output may render differently.
+//
+// Parameters:
+//
+//	ctx - The context for reading the version.
+//	vr - Reads the binary's version, normally a *tfexec.Terraform.
+//	binaryPath - The binary used for the plan.
+//	minVersion - The version from parseMinVersion.
+//
+// Returns:
+//
+//	error - An error naming both versions if the binary is older, or if its version can't be read.
+func checkMinVersion(ctx context.Context, vr versionReader, binaryPath string, minVersion *version.Version) error {
+	installed, _, err := vr.Version(ctx, false)
+	if err != nil {
+		return fmt.Errorf("unable to check that %s is at least %s: %w", binaryPath, minVersion, err)
+	}
+	if installed.LessThan(minVersion) {
+		return fmt.Errorf(
+			"%s %s is older than the required 'minVersion' %s, upgrade it to plan",
+			filepath.Base(binaryPath),
+			installed,
+			minVersion,
+		)
+	}
+	Logger.Debugf("%s %s satisfies 'minVersion' %s", binaryPath, installed, minVersion)
+	return nil
+}
+
+// minExcludeVersion is the first OpenTofu release with 'plan -exclude'
+var minExcludeVersion = version.Must(version.NewVersion("1.9.0"))
+
+// planArgsEnvVar passes extra arguments to 'plan'. tfexec has no option for
+// -exclude, and the binary reads this variable for every 'plan' it runs.
+const planArgsEnvVar = "TF_CLI_ARGS_plan"
+
+// checkExcludes checks that the binary supports -exclude, which
+// addExcludeArgs passes to each plan.
+//
+// Parameters:
+//
+//	ctx - The context for reading the version.
+//	vr - Reads the binary's version, normally a *tfexec.Terraform.
+//	binaryPath - The binary used for the plan.
+//
+// Returns:
+//
+//	error - An error if the binary is terraform or an OpenTofu older than 1.9.
+func checkExcludes(ctx context.Context, vr versionReader, binaryPath string) error {
+	if binaryKind(binaryPath) != "tofu" {
+		return fmt.Errorf(
+			"'exclude' requires OpenTofu %s or later, %s has no -exclude option. Use -b tofu",
+			minExcludeVersion,
+			filepath.Base(binaryPath),
+		)
+	}
+	installed, _, err := vr.Version(ctx, false)
+	if err != nil {
+		return fmt.Errorf("unable to check that %s supports 'exclude': %w", binaryPath, err)
+	}
+	if installed.LessThan(minExcludeVersion) {
+		return fmt.Errorf("'exclude' requires OpenTofu %s or later, found %s", minExcludeVersion, installed)
+	}
+	return nil
+}
+
+// addExcludeArgs passes the exclusions to the plan process through
+// TF_CLI_ARGS_plan in env, its environment from planProcessEnv, keeping any
+// arguments the user already set there.
+func addExcludeArgs(env map[string]string, excludes []string) {
+	if len(excludes) == 0 {
+		return
+	}
+	args := excludeArgs(excludes)
+	if existing := env[planArgsEnvVar]; existing != "" {
+		args = append([]string{existing}, args...)
+	}
+	env[planArgsEnvVar] = strings.Join(args, " ")
+	Logger.Debugf("Excluding from the plan: %s", strings.Join(excludes, ", "))
+}
+
+// excludeArgs returns the -exclude arguments for the addresses, quoted for
+// TF_CLI_ARGS, which is split like a shell command line.
+func excludeArgs(excludes []string) []string {
+	args := make([]string, 0, len(excludes))
+	for _, addr := range excludes {
+		args = append(args, shellQuote("-exclude="+addr))
+	}
+	return args
+}
+
+// workspaceSelector is the subset of *tfexec.Terraform used to select a workspace.
+type workspaceSelector interface {
+	WorkspaceSelect(ctx context.Context, workspace string, opts ...tfexec.WorkspaceSelectOption) error
+}
+
+// selectWorkspace selects workspace in dir with 'workspace select', unless it
+// already is. TF_WORKSPACE overrides the selected workspace, so it must name
+// the same workspace if set.
+//
+// Parameters:
+//
+//	ctx - The context for 'workspace select'.
+//	ws - The workspace selector, normally the *tfexec.Terraform used for the plan.
+//	dir - The directory of the plan.
+//	workspace - The workspace to plan in, from 'workspace'.
+//
+// Returns:
+//
+//	error - An error naming the workspace if it could not be selected.
+func selectWorkspace(ctx context.Context, ws workspaceSelector, dir, workspace string) error {
+	if env := os.Getenv("TF_WORKSPACE"); env != "" && env != workspace {
+		return fmt.Errorf("unable to select workspace %q: TF_WORKSPACE selects %q", workspace, env)
+	}
+	if currentWorkspace(dir) == workspace {
+		Logger.Debugf("Workspace %s is already selected in %s", workspace, dir)
+		return nil
+	}
+	if err := ws.WorkspaceSelect(ctx, workspace); err != nil {
+		return fmt.Errorf("unable to select workspace %q in %s: %w", workspace, dir, err)
+	}
+	Logger.Debugf("Selected workspace %s in %s", workspace, dir)
+	return nil
+}
+
+// formatChecker is the subset of *tfexec.Terraform used to check formatting.
+type formatChecker interface {
+	FormatCheck(ctx context.Context, opts ...tfexec.FormatOption) (bool, []string, error)
+}
+
+// checkFormat runs '<binary> fmt -check' in the working directory and reports
+// unformatted files. They are logged as a warning unless strict is set, in
+// which case an error listing them is returned so the plan is not created.
+//
+// Parameters:
+//
+//	ctx - The context for the format check.
+//	fc - The format checker, normally the *tfexec.Terraform used for the plan.
+//	binaryPath - The binary used, for the suggested fix in messages.
+//	strict - Whether unformatted files are an error.
+//
+// Returns:
+//
+//	error - An error if the check could not run, or strict is set and files are unformatted.
+func checkFormat(ctx context.Context, fc formatChecker, binaryPath string, strict bool) error {
+	Logger.Debug("Checking formatting before planning...")
+	formatted, files, err := fc.FormatCheck(ctx)
+	if err != nil {
+		return fmt.Errorf("format check failed: %w", explainExecError(err, binaryPath))
+	}
+	if formatted {
+		Logger.Debug("All files are formatted.")
+		return nil
+	}
+
+	fix := fmt.Sprintf("run '%s fmt' to fix", filepath.Base(binaryPath))
+	if strict {
+		return fmt.Errorf("unformatted files (%s): %s", fix, strings.Join(files, ", "))
+	}
+	Logger.Warnf("Unformatted files (%s): %s", fix, strings.Join(files, ", "))
+	return nil
+}
+
+// buildPlanOptions assembles the tfexec plan options from flags and config,
+// along with the equivalent command line arguments for --include-command.
+//
+// Parameters:
+//
+//	planPath - The validated path of the plan file to be written.
+//
+// Returns:
+//
+//	[]tfexec.PlanOption - The options to pass to tf.Plan.
+//	[]string - The 'plan' arguments matching the options.
+//	error - Any error encountered validating an option, or nil on success.
+func buildPlanOptions(planPath string) ([]tfexec.PlanOption, []string, error) {
+	planOpts := []tfexec.PlanOption{tfexec.Out(planPath)}
+	planArgs := []string{"-out=" + planPath}
+
+	if gco := viper.GetString("generateConfigOut"); gco != "" {
+		generatedPath, err := validateGenerateConfigOut(gco)
+		if err != nil {
+			return nil, nil, err
+		}
+		Logger.Debugf("Generating configuration for import blocks to %s", generatedPath)
+		planOpts = append(planOpts, tfexec.GenerateConfigOut(generatedPath))
+		planArgs = append(planArgs, "-generate-config-out="+generatedPath)
+	}
+	varFiles, err := resolveVarFiles(viper.GetStringSlice("varFiles"), viper.GetBool("ignoreMissingVarFile"))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, varFile := range varFiles {
+		planOpts = append(planOpts, tfexec.VarFile(varFile))
+		planArgs = append(planArgs, "-var-file="+varFile)
+	}
+	vars, err := parsePlanVars(viper.GetStringSlice("vars"))
+	if err != nil {
+		return nil, nil, err
+	}
+	// After the var files, so a --var overrides them as on the command line
+	for _, v := range vars {
+		planOpts = append(planOpts, tfexec.Var(v))
+		planArgs = append(planArgs, "-var="+v)
+	}
+	targets, err := parseTargets(viper.GetStringSlice("targets"))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, addr := range targets {
+		planOpts = append(planOpts, tfexec.Target(addr))
+		planArgs = append(planArgs, "-target="+addr)
+	}
+	// Passed through TF_CLI_ARGS_plan by addExcludeArgs, listed for --include-command
+	for _, addr := range viper.GetStringSlice("exclude") {
+		planArgs = append(planArgs, "-exclude="+addr)
+	}
+
+	return planOpts, planArgs, nil
+}
+
+// resolveVarFiles checks that every --var-file can be read before planning.
+//
+// Parameters:
+//
+//	varFiles - The var files, relative to the current directory.
+//	ignoreMissing - Whether a var file that doesn't exist is skipped with a
+//	warning, for optional files. Unreadable files are an error regardless.
+//
+// Returns:
+//
+//	[]string - The absolute paths of the var files, so every directory planned finds them.
+//	error - An error naming the first var file missing or unreadable.
+func resolveVarFiles(varFiles []string, ignoreMissing bool) ([]string, error) {
+	resolved := make([]string, 0, len(varFiles))
+	for _, varFile := range varFiles {
+		absPath, err := filepath.Abs(varFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid var file %q: %w", varFile, err)
+		}
+		f, err := os.Open(absPath) //nolint:gosec // read by the binary anyway
+		switch {
+		case errors.Is(err, os.ErrNotExist) && ignoreMissing:
+			Logger.Warnf("Skipping var file %s: it doesn't exist", varFile)
+			continue
+		case errors.Is(err, os.ErrNotExist):
+			return nil, fmt.Errorf(
+				"var file %q not found, use --ignore-missing-var-file to skip missing var files",
+				varFile,
+			)
+		case err != nil:
+			return nil, fmt.Errorf("unable to read var file %q: %w", varFile, err)
+		}
+		info, err := f.Stat()
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read var file %q: %w", varFile, err)
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("var file %q is a directory", varFile)
+		}
+		resolved = append(resolved, absPath)
+	}
+	return resolved, nil
+}
+
+// parsePlanVars checks that every --var is a key=value assignment. Only the
+// first '=' separates the key, so values may contain '='.
+//
+// Parameters:
+//
+//	vars - The variables, e.g. "region=us-east-1".
+//
+// Returns:
+//
+//	[]string - The variables, unchanged.
+//	error - An error naming the first malformed variable.
+func parsePlanVars(vars []string) ([]string, error) {
+	for _, v := range vars {
+		key, _, ok := strings.Cut(v, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid var %q: expected key=value", v)
+		}
+		if strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid var %q: the variable name is empty", v)
+		}
+	}
+	return vars, nil
+}
+
+// parseTargets checks that no --target is empty.
+//
+// Parameters:
+//
+//	targets - The resource addresses, e.g. "module.db.aws_db_instance.main".
+//
+// Returns:
+//
+//	[]string - The addresses, trimmed of surrounding whitespace.
+//	error - An error for the first empty address.
+func parseTargets(targets []string) ([]string, error) {
+	trimmed := make([]string, 0, len(targets))
+	for i, addr := range targets {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			return nil, fmt.Errorf("invalid target #%d: the address is empty", i+1)
+		}
+		trimmed = append(trimmed, addr)
+	}
+	return trimmed, nil
+}
+
+// Matches arguments that never need shell quoting
+var shellSafeArg = regexp.MustCompile(`^[A-Za-z0-9_\-./=:,@+%]+$`)
+
+// formatPlanCommand renders a plan invocation a reviewer can copy to reproduce
+// the plan. Values of -var arguments whose names look like secrets are redacted.
+//
+// Parameters:
+//
+//	binaryPath - The binary used for the plan.
+//	workspace - The selected workspace, added as TF_WORKSPACE unless "default".
+//	planArgs - The 'plan' arguments from buildPlanOptions.
+//
+// Returns:
+//
+//	string - The command line.
+func formatPlanCommand(binaryPath, workspace string, planArgs []string) string {
+	parts := []string{}
+	if workspace != "" && workspace != defaultWorkspace {
+		parts = append(parts, shellQuote("TF_WORKSPACE="+workspace))
+	}
+	parts = append(parts, filepath.Base(binaryPath), "plan")
+	for _, arg := range planArgs {
+		if assignment, ok := strings.CutPrefix(arg, "-var="); ok {
+			if name, value, found := strings.Cut(assignment, "="); found {
+				arg = "-var=" + name + "=" + redactEnvValue(name, value)
+			}
+		}
+		parts = append(parts, shellQuote(arg))
+	}
+	return strings.Join(parts, " ")
+}
+
+// shellQuote single-quotes arg for POSIX shells when needed.
+func shellQuote(arg string) string {
+	if shellSafeArg.MatchString(arg) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// validateGenerateConfigOut checks the path passed to -generate-config-out.
+// Terraform refuses to write generated configuration over an existing file, so
+// we fail early rather than after a potentially long plan.
+func validateGenerateConfigOut(path string) (string, error) {
+	validated, err := validateFilePath(path)
+	if err != nil {
+		return path, fmt.Errorf("invalid 'generate-config-out' (%q): %w", path, err)
+	}
+	if doesExist(validated) {
+		return path, fmt.Errorf(
+			"invalid 'generate-config-out' (%q): file already exists, remove it or choose another name",
+			path,
+		)
+	}
+	return validated, nil
+}
+
+// showPlans reads the plan file back as text and, best-effort, as JSON.
+func showPlans(ctx context.Context, tf *tfexec.Terraform, planPath string) (string, *tfjson.Plan, error) {
+	planStr, err := showPlan(ctx, tf, planPath)
 	if err != nil {
 		Logger.Debug(err)
-		return "", err
+		return "", nil, err
+	}
+
+	planJSON, err := showPlanJSON(ctx, tf, planPath)
+	if err != nil {
+		// The text output is enough to render Markdown, so carry on without JSON
+		Logger.Debugf("Continuing without structured plan: %v", err)
+		return planStr, nil, nil
 	}
 
-	return planStr, err
+	return planStr, planJSON, nil
 }
 
-func showPlan(tf *tfexec.Terraform, planPath string) (planStr string, err error) {
+func showPlan(ctx context.Context, tf *tfexec.Terraform, planPath string) (planStr string, err error) {
 	// --- Show Plan Output ---
 	Logger.Debug("Generating plan output...")
-	showCtx, showCancel := context.WithTimeout(context.Background(), 30*time.Second) //nolint:mnd
+	showCtx, showCancel := context.WithTimeout(ctx, 30*time.Second) //nolint:mnd
 	defer showCancel()
 	planStr, err = tf.ShowPlanFileRaw(showCtx, planPath)
 	if err != nil {
 		Logger.Errorf("Plan created, but failed to read/show plan file %q: %v", planPath, err)
-		return "", fmt.Errorf("failed to show plan file %q: %w", planPath, err)
+		return "", fmt.Errorf("failed to show plan file %q: %w", planPath, explainExecError(err, tf.ExecPath()))
 	}
 
 	Logger.Debug("Plan output generated successfully.")
 	return planStr, nil
 }
+
+// showPlanJSON reads the plan file back as a structured plan.
+func showPlanJSON(ctx context.Context, tf *tfexec.Terraform, planPath string) (*tfjson.Plan, error) {
+	Logger.Debug("Generating structured plan output...")
+	showCtx, showCancel := context.WithTimeout(ctx, 30*time.Second) //nolint:mnd
+	defer showCancel()
+	planJSON, err := tf.ShowPlanFile(showCtx, planPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to show plan file %q as JSON: %w", planPath, err)
+	}
+
+	Logger.Debug("Structured plan output generated successfully.")
+	return planJSON, nil
+}
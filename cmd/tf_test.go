@@ -0,0 +1,781 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+func TestBuildPlanOptions(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	t.Chdir(t.TempDir())
+
+	t.Run("Defaults to only the plan output file", func(t *testing.T) {
+		viper.Set("generateConfigOut", "")
+
+		opts, args, err := buildPlanOptions("plan.out")
+
+		require.NoError(t, err)
+		require.Equal(t, []tfexec.PlanOption{tfexec.Out("plan.out")}, opts)
+		require.Equal(t, []string{"-out=plan.out"}, args)
+	})
+
+	t.Run("Generate config out is assembled when set", func(t *testing.T) {
+		viper.Set("generateConfigOut", "generated.tf")
+		t.Cleanup(func() { viper.Set("generateConfigOut", "") })
+
+		opts, args, err := buildPlanOptions("plan.out")
+
+		require.NoError(t, err)
+		require.Contains(t, opts, tfexec.GenerateConfigOut("generated.tf"))
+		require.Equal(t, []string{"-out=plan.out", "-generate-config-out=generated.tf"}, args)
+	})
+
+	t.Run("Generate config out rejects directory separators", func(t *testing.T) {
+		viper.Set("generateConfigOut", "../generated.tf")
+		t.Cleanup(func() { viper.Set("generateConfigOut", "") })
+
+		_, _, err := buildPlanOptions("plan.out")
+
+		require.ErrorContains(t, err, "must be a filename only")
+	})
+
+	t.Run("Generate config out rejects an existing file", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(".", "existing.tf"), nil, 0o600))
+		viper.Set("generateConfigOut", "existing.tf")
+		t.Cleanup(func() { viper.Set("generateConfigOut", "") })
+
+		_, _, err := buildPlanOptions("plan.out")
+
+		require.ErrorContains(t, err, "file already exists")
+	})
+}
+
+func TestResolveVarFiles(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	t.Chdir(t.TempDir())
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile("prod.tfvars", []byte("region = \"us-east-1\"\n"), 0o600))
+
+	t.Run("Resolved to absolute paths", func(t *testing.T) {
+		got, err := resolveVarFiles([]string{"prod.tfvars"}, false)
+
+		require.NoError(t, err)
+		require.Equal(t, []string{filepath.Join(wd, "prod.tfvars")}, got)
+	})
+
+	t.Run("Missing var files fail", func(t *testing.T) {
+		_, err := resolveVarFiles([]string{"prod.tfvars", "local.tfvars"}, false)
+
+		require.ErrorContains(t, err, `var file "local.tfvars" not found, use --ignore-missing-var-file`)
+	})
+
+	t.Run("Missing var files are skipped when ignored", func(t *testing.T) {
+		got, err := resolveVarFiles([]string{"local.tfvars", "prod.tfvars"}, true)
+
+		require.NoError(t, err)
+		require.Equal(t, []string{filepath.Join(wd, "prod.tfvars")}, got)
+	})
+
+	t.Run("Unreadable var files fail even when missing ones are ignored", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("Skipping permission test when running as root")
+		}
+		require.NoError(t, os.WriteFile("secret.tfvars", nil, 0o200))
+
+		_, err := resolveVarFiles([]string{"secret.tfvars"}, true)
+
+		require.ErrorContains(t, err, `unable to read var file "secret.tfvars"`)
+		require.ErrorIs(t, err, os.ErrPermission)
+	})
+
+	t.Run("Passed to the plan", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		viper.Set("varFiles", []string{"prod.tfvars"})
+
+		opts, args, err := buildPlanOptions("plan.out")
+
+		require.NoError(t, err)
+		require.Contains(t, opts, tfexec.VarFile(filepath.Join(wd, "prod.tfvars")))
+		require.Equal(t, []string{"-out=plan.out", "-var-file=" + filepath.Join(wd, "prod.tfvars")}, args)
+	})
+}
+
+func TestPlanVars(t *testing.T) {
+	t.Run("Values keep their '='", func(t *testing.T) {
+		vars, err := parsePlanVars([]string{"region=us-east-1", "tags={Name=web}", "empty="})
+
+		require.NoError(t, err)
+		require.Equal(t, []string{"region=us-east-1", "tags={Name=web}", "empty="}, vars)
+	})
+
+	t.Run("Malformed vars fail", func(t *testing.T) {
+		_, err := parsePlanVars([]string{"region"})
+		require.EqualError(t, err, `invalid var "region": expected key=value`)
+
+		_, err = parsePlanVars([]string{"=us-east-1"})
+		require.EqualError(t, err, `invalid var "=us-east-1": the variable name is empty`)
+	})
+
+	t.Run("Passed to the plan after the var files", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		dir := t.TempDir()
+		varFile := filepath.Join(dir, "prod.tfvars")
+		require.NoError(t, os.WriteFile(varFile, nil, 0o600))
+		viper.Set("varFiles", []string{varFile})
+		viper.Set("vars", []string{"region=us-east-1", "tags={Name=web}"})
+
+		opts, args, err := buildPlanOptions("plan.out")
+
+		require.NoError(t, err)
+		require.Contains(t, opts, tfexec.Var("region=us-east-1"))
+		require.Contains(t, opts, tfexec.Var("tags={Name=web}"))
+		require.Equal(t, []string{
+			"-out=plan.out", "-var-file=" + varFile, "-var=region=us-east-1", "-var=tags={Name=web}",
+		}, args)
+	})
+}
+
+func TestTargets(t *testing.T) {
+	t.Run("Empty targets fail", func(t *testing.T) {
+		_, err := parseTargets([]string{"aws_instance.web", " "})
+
+		require.EqualError(t, err, "invalid target #2: the address is empty")
+	})
+
+	t.Run("Each target is a plan option", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		viper.Set("targets", []string{"aws_instance.web", ` module.db["primary"] `})
+
+		opts, args, err := buildPlanOptions("plan.out")
+
+		require.NoError(t, err)
+		require.Contains(t, opts, tfexec.Target("aws_instance.web"))
+		require.Contains(t, opts, tfexec.Target(`module.db["primary"]`))
+		require.Equal(t, []string{"-out=plan.out", "-target=aws_instance.web", `-target=module.db["primary"]`}, args)
+	})
+
+	t.Run("The Markdown notes the plan is targeted", func(t *testing.T) {
+		require.Equal(t,
+			"This plan is targeted, only `aws_instance.web` and their dependencies were planned. It is not a full plan.",
+			targetNote([]string{"aws_instance.web"}),
+		)
+	})
+}
+
+// fakeFormatChecker returns a canned 'fmt -check' result.
+type fakeFormatChecker struct {
+	formatted bool
+	files     []string
+	err       error
+}
+
+func (f fakeFormatChecker) FormatCheck(
+	_ context.Context,
+	_ ...tfexec.FormatOption,
+) (bool, []string, error) {
+	return f.formatted, f.files, f.err
+}
+
+func TestCheckFormat(t *testing.T) {
+	originalLogger := Logger
+	defer func() {
+		Logger = originalLogger
+	}()
+	var buf bytes.Buffer
+	Logger = log.NewWithOptions(&buf, log.Options{Level: log.InfoLevel})
+
+	unformatted := fakeFormatChecker{files: []string{"main.tf", "vars.tf"}}
+
+	testCases := []struct {
+		name     string
+		fc       fakeFormatChecker
+		strict   bool
+		wantErr  string
+		wantWarn bool
+	}{
+		{name: "Formatted", fc: fakeFormatChecker{formatted: true}},
+		{name: "Unformatted warns", fc: unformatted, wantWarn: true},
+		{
+			name:    "Unformatted fails when strict",
+			fc:      unformatted,
+			strict:  true,
+			wantErr: "unformatted files (run 'terraform fmt' to fix): main.tf, vars.tf",
+		},
+		{
+			name:    "Check errors are returned",
+			fc:      fakeFormatChecker{err: errors.New("exit status 1")},
+			wantErr: "format check failed: exit status 1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buf.Reset()
+
+			err := checkFormat(context.Background(), tc.fc, "/usr/bin/terraform", tc.strict)
+
+			if tc.wantErr != "" {
+				require.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			if tc.wantWarn {
+				require.Contains(t, buf.String(), "main.tf, vars.tf")
+			} else {
+				require.Empty(t, buf.String())
+			}
+		})
+	}
+}
+
+func TestCheckFormatFixtures(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	var binaryPath string
+	for _, name := range []string{"terraform", "tofu"} {
+		if p, err := exec.LookPath(name); err == nil {
+			binaryPath = p
+			break
+		}
+	}
+	if binaryPath == "" {
+		t.Skip("neither terraform nor tofu found in PATH")
+	}
+
+	fixture := func(t *testing.T, name string) formatChecker {
+		t.Helper()
+		tf, err := tfexec.NewTerraform(filepath.Join("..", "testdata", "fmt", name), binaryPath)
+		require.NoError(t, err)
+		return tf
+	}
+
+	t.Run("Formatted directory passes", func(t *testing.T) {
+		require.NoError(t, checkFormat(context.Background(), fixture(t, "formatted"), binaryPath, true))
+	})
+
+	t.Run("Unformatted directory lists only the offending files", func(t *testing.T) {
+		err := checkFormat(context.Background(), fixture(t, "unformatted"), binaryPath, true)
+
+		require.ErrorContains(t, err, "main.tf")
+		require.NotContains(t, err.Error(), "outputs.tf")
+	})
+}
+
+func TestCurrentWorkspace(t *testing.T) {
+	t.Run("Defaults without a selected workspace", func(t *testing.T) {
+		t.Setenv("TF_WORKSPACE", "")
+		t.Setenv("TF_DATA_DIR", "")
+
+		require.Equal(t, "default", currentWorkspace(t.TempDir()))
+	})
+
+	t.Run("Reads the selected workspace", func(t *testing.T) {
+		t.Setenv("TF_WORKSPACE", "")
+		t.Setenv("TF_DATA_DIR", "")
+		dir := t.TempDir()
+		require.NoError(t, os.Mkdir(filepath.Join(dir, ".terraform"), 0o750))
+		require.NoError(
+			t,
+			os.WriteFile(filepath.Join(dir, ".terraform", "environment"), []byte("staging"), 0o600),
+		)
+
+		require.Equal(t, "staging", currentWorkspace(dir))
+	})
+
+	t.Run("TF_WORKSPACE takes precedence", func(t *testing.T) {
+		t.Setenv("TF_WORKSPACE", "prod")
+
+		require.Equal(t, "prod", currentWorkspace(t.TempDir()))
+	})
+}
+
+// fakeWorkspaceSelector records the workspaces selected.
+type fakeWorkspaceSelector struct {
+	selected []string
+	err      error
+}
+
+func (f *fakeWorkspaceSelector) WorkspaceSelect(
+	_ context.Context,
+	workspace string,
+	_ ...tfexec.WorkspaceSelectOption,
+) error {
+	f.selected = append(f.selected, workspace)
+	return f.err
+}
+
+func TestSelectWorkspace(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	t.Setenv("TF_DATA_DIR", "")
+
+	t.Run("Selects the workspace", func(t *testing.T) {
+		t.Setenv("TF_WORKSPACE", "")
+		ws := &fakeWorkspaceSelector{}
+
+		require.NoError(t, selectWorkspace(context.Background(), ws, t.TempDir(), "prod"))
+		require.Equal(t, []string{"prod"}, ws.selected)
+	})
+
+	t.Run("Skips the selected workspace", func(t *testing.T) {
+		t.Setenv("TF_WORKSPACE", "")
+		dir := t.TempDir()
+		require.NoError(t, os.Mkdir(filepath.Join(dir, ".terraform"), 0o750))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ".terraform", "environment"), []byte("prod"), 0o600))
+		ws := &fakeWorkspaceSelector{}
+
+		require.NoError(t, selectWorkspace(context.Background(), ws, dir, "prod"))
+		require.Empty(t, ws.selected)
+	})
+
+	t.Run("Errors name the workspace", func(t *testing.T) {
+		t.Setenv("TF_WORKSPACE", "")
+		dir := t.TempDir()
+		ws := &fakeWorkspaceSelector{err: errors.New("workspace \"prod\" doesn't exist")}
+
+		err := selectWorkspace(context.Background(), ws, dir, "prod")
+
+		require.EqualError(t, err, fmt.Sprintf(`unable to select workspace "prod" in %s: workspace "prod" doesn't exist`, dir))
+	})
+
+	t.Run("TF_WORKSPACE must agree", func(t *testing.T) {
+		t.Setenv("TF_WORKSPACE", "staging")
+		ws := &fakeWorkspaceSelector{}
+
+		err := selectWorkspace(context.Background(), ws, t.TempDir(), "prod")
+
+		require.EqualError(t, err, `unable to select workspace "prod": TF_WORKSPACE selects "staging"`)
+		require.Empty(t, ws.selected)
+	})
+}
+
+func TestIsInitialized(t *testing.T) {
+	t.Run(".terraform by default", func(t *testing.T) {
+		t.Setenv("TF_DATA_DIR", "")
+		dir := t.TempDir()
+		require.False(t, isInitialized(dir))
+
+		require.NoError(t, os.Mkdir(filepath.Join(dir, ".terraform"), 0o750))
+
+		require.True(t, isInitialized(dir))
+	})
+
+	t.Run("Relative TF_DATA_DIR is in the directory planned", func(t *testing.T) {
+		t.Setenv("TF_DATA_DIR", ".tfdata")
+		dir := t.TempDir()
+		require.NoError(t, os.Mkdir(filepath.Join(dir, ".terraform"), 0o750))
+		require.False(t, isInitialized(dir))
+
+		require.NoError(t, os.Mkdir(filepath.Join(dir, ".tfdata"), 0o750))
+
+		require.True(t, isInitialized(dir))
+		require.Equal(t, filepath.Join(dir, ".tfdata"), dataDirPath(dir))
+	})
+
+	t.Run("Absolute TF_DATA_DIR", func(t *testing.T) {
+		dataDir := t.TempDir()
+		t.Setenv("TF_DATA_DIR", dataDir)
+
+		require.True(t, isInitialized(t.TempDir()))
+		require.Equal(t, dataDir, dataDirPath("."))
+	})
+
+	t.Run("--data-dir overrides the inherited TF_DATA_DIR", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		t.Setenv("TF_DATA_DIR", ".tfdata")
+		viper.Set("dataDir", ".cache")
+		dir := t.TempDir()
+
+		require.Equal(t, filepath.Join(dir, ".cache"), dataDirPath(dir))
+		require.Equal(t, ".tfdata", os.Getenv("TF_DATA_DIR"), "the plan process alone sees --data-dir")
+	})
+}
+
+func TestFormatPlanCommand(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	t.Run("Command line matches the assembled options", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		viper.Set("generateConfigOut", "generated.tf")
+		t.Cleanup(func() { viper.Set("generateConfigOut", "") })
+
+		_, args, err := buildPlanOptions("plan.out")
+		require.NoError(t, err)
+
+		require.Equal(
+			t,
+			"terraform plan -out=plan.out -generate-config-out=generated.tf",
+			formatPlanCommand("/usr/local/bin/terraform", "default", args),
+		)
+	})
+
+	testCases := []struct {
+		name      string
+		workspace string
+		args      []string
+		want      string
+	}{
+		{
+			name:      "Workspace is set for non-default workspaces",
+			workspace: "staging",
+			args:      []string{"-out=plan.out"},
+			want:      "TF_WORKSPACE=staging tofu plan -out=plan.out",
+		},
+		{
+			name: "Secret variables are redacted",
+			args: []string{"-var=region=us-east-1", "-var=db_password=hunter2"},
+			want: "tofu plan -var=region=us-east-1 '-var=db_password=<redacted>'",
+		},
+		{
+			name: "Arguments are quoted for the shell",
+			args: []string{"-var=tags={team=\"infra\"}", "-var=name=it's"},
+			want: `tofu plan '-var=tags={team="infra"}' '-var=name=it'\''s'`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, formatPlanCommand("tofu", tc.workspace, tc.args))
+		})
+	}
+}
+
+// fakeVersionReader returns a canned 'version' result.
+type fakeVersionReader struct {
+	version string
+	err     error
+}
+
+func (f fakeVersionReader) Version(
+	_ context.Context,
+	_ bool,
+) (*version.Version, map[string]*version.Version, error) {
+	if f.err != nil {
+		return nil, nil, f.err
+	}
+	return version.Must(version.NewVersion(f.version)), nil, nil
+}
+
+func TestParseMinVersion(t *testing.T) {
+	got, err := parseMinVersion(" 1.6.0 ")
+	require.NoError(t, err)
+	require.Equal(t, "1.6.0", got.String())
+
+	_, err = parseMinVersion("latest")
+	require.EqualError(t, err, `invalid 'minVersion' ("latest"): expected a version such as 1.6.0`)
+
+	// Rejected in a config file too
+	for _, v := range []string{"1.6", "v1.6.0"} {
+		_, err = parseMinVersion(v)
+		require.Error(t, err, v)
+		conf := ConfigParams{Binary: "terraform", PlanFile: "plan.out", MdFile: "plan.md", MinVersion: v}
+		require.Error(t, validateParams(conf), v)
+	}
+}
+
+func TestCheckMinVersion(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	minVersion := version.Must(version.NewVersion("1.6.0"))
+
+	t.Run("Same or newer", func(t *testing.T) {
+		require.NoError(t, checkMinVersion(context.Background(), fakeVersionReader{version: "1.6.0"}, "terraform", minVersion))
+		require.NoError(t, checkMinVersion(context.Background(), fakeVersionReader{version: "1.9.8"}, "terraform", minVersion))
+	})
+
+	t.Run("Older", func(t *testing.T) {
+		err := checkMinVersion(context.Background(), fakeVersionReader{version: "1.5.7"}, "/usr/bin/terraform", minVersion)
+
+		require.EqualError(t, err, "terraform 1.5.7 is older than the required 'minVersion' 1.6.0, upgrade it to plan")
+	})
+
+	t.Run("Version can't be read", func(t *testing.T) {
+		err := checkMinVersion(
+			context.Background(),
+			fakeVersionReader{err: errors.New("exit status 1")},
+			"tofu",
+			minVersion,
+		)
+
+		require.ErrorContains(t, err, "unable to check that tofu is at least 1.6.0: exit status 1")
+	})
+}
+
+func TestCheckExcludes(t *testing.T) {
+	require.NoError(t, checkExcludes(context.Background(), fakeVersionReader{version: "1.9.0"}, "/usr/bin/tofu"))
+
+	testCases := []struct {
+		name    string
+		binary  string
+		vr      fakeVersionReader
+		wantErr string
+	}{
+		{"Terraform has no exclude", "/usr/bin/terraform", fakeVersionReader{version: "1.9.0"}, "terraform has no -exclude"},
+		{"OpenTofu before 1.9", "tofu", fakeVersionReader{version: "1.8.5"}, "found 1.8.5"},
+		{"Version unavailable", "tofu", fakeVersionReader{err: errors.New("exit status 1")}, "unable to check"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.ErrorContains(t, checkExcludes(context.Background(), tc.vr, tc.binary), tc.wantErr)
+		})
+	}
+}
+
+func TestAddExcludeArgs(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	excludes := []string{"aws_instance.web", `module.db["primary"]`}
+
+	t.Run("Exclusions are passed to plan", func(t *testing.T) {
+		t.Setenv(planArgsEnvVar, "")
+		env := map[string]string{"PATH": "/usr/bin"}
+
+		addExcludeArgs(env, excludes)
+
+		require.Equal(t, `-exclude=aws_instance.web '-exclude=module.db["primary"]'`, env[planArgsEnvVar])
+		require.Empty(t, os.Getenv(planArgsEnvVar), "our own environment is untouched")
+	})
+
+	t.Run("Existing plan arguments are kept", func(t *testing.T) {
+		env := map[string]string{planArgsEnvVar: "-refresh=false"}
+
+		addExcludeArgs(env, excludes[:1])
+
+		require.Equal(t, "-refresh=false -exclude=aws_instance.web", env[planArgsEnvVar])
+	})
+
+	t.Run("No exclusions", func(t *testing.T) {
+		env := map[string]string{}
+
+		addExcludeArgs(env, nil)
+
+		require.NotContains(t, env, planArgsEnvVar)
+	})
+}
+
+func TestExplainExecError(t *testing.T) {
+	const bin = "/opt/bin/terraform"
+	wrongArch := &fs.PathError{Op: "fork/exec", Path: bin, Err: syscall.ENOEXEC}
+	notExecutable := &fs.PathError{Op: "fork/exec", Path: bin, Err: syscall.EACCES}
+
+	testCases := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"Wrong architecture", wrongArch, "is it built for this architecture"},
+		{"Wrapped by tfexec", fmt.Errorf("running plan: %w", wrongArch), "is it built for this architecture"},
+		{"Not executable", notExecutable, "is it executable (chmod +x)?"},
+		{"Not executable in PATH", &exec.Error{Name: "terraform", Err: fs.ErrPermission}, "is it executable (chmod +x)?"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := explainExecError(tc.err, bin)
+
+			require.ErrorContains(t, err, "unable to execute "+bin+", "+tc.want)
+			require.ErrorIs(t, err, tc.err)
+		})
+	}
+
+	t.Run("Other errors are unchanged", func(t *testing.T) {
+		err := errors.New("exit status 1")
+		require.Equal(t, err, explainExecError(err, bin))
+		require.NoError(t, explainExecError(nil, bin))
+
+		// e.g. the plan file, once the binary is running
+		unreadable := fmt.Errorf("failed to read plan: %w", &fs.PathError{Op: "open", Path: "plan.out", Err: syscall.EACCES})
+		require.Equal(t, unreadable, explainExecError(unreadable, bin))
+		message := errors.New("Error: exec format error in a provider's output")
+		require.Equal(t, message, explainExecError(message, bin))
+	})
+
+	t.Run("Format check", func(t *testing.T) {
+		fc := fakeFormatChecker{err: wrongArch}
+
+		err := checkFormat(context.Background(), fc, bin, false)
+
+		require.ErrorContains(t, err, "format check failed: unable to execute "+bin+", is it built for this architecture")
+		require.ErrorIs(t, err, syscall.ENOEXEC)
+	})
+}
+
+// fakeTerraformScript stands in for the binary: it reports its version, writes
+// the plan file and shows the plan in testdata/plans/changes.json.
+const fakeTerraformScript = `#!/bin/sh
+case "$1" in
+version) echo '{"terraform_version":"1.9.8","platform":"linux_amd64","provider_selections":{}}' ;;
+plan)
+	for arg in "$@"; do
+		case "$arg" in -out=*) : > "${arg#-out=}" ;; esac
+	done
+	exit 2 ;;
+show)
+	case "$*" in
+	*-json*) cat "$PLAN_JSON" ;;
+	*) echo "Plan: 1 to add, 0 to change, 0 to destroy." ;;
+	esac ;;
+esac
+`
+
+func TestCreatePlanSignalCleanup(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	planJSON, err := filepath.Abs("../testdata/plans/changes.json")
+	require.NoError(t, err)
+	t.Setenv("PLAN_JSON", planJSON)
+	binPath := filepath.Join(t.TempDir(), "terraform")
+	require.NoError(t, os.WriteFile(binPath, []byte(fakeTerraformScript), 0o700)) //nolint:gosec // an executable
+	t.Chdir(t.TempDir())
+	viper.Set("binary", binPath)
+	viper.Set("planFile", "plan.out")
+	t.Cleanup(viper.Reset)
+
+	// Goroutines started by earlier tests are not this test's concern
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	for range 5 {
+		result, err := createPlan(context.Background(), ".", true)
+
+		require.NoError(t, err)
+		require.Equal(t, "plan.out", result.PlanPath)
+	}
+}
+
+// fakeUninitializedScript stands in for a binary in a fresh clone: the plan
+// fails until 'init' has created .terraform.
+const fakeUninitializedScript = `#!/bin/sh
+case "$1" in
+version) echo '{"terraform_version":"1.9.8","platform":"linux_amd64","provider_selections":{}}' ;;
+init) mkdir -p .terraform; echo init >> "$INIT_LOG" ;;
+plan)
+	if [ ! -d .terraform ]; then
+		echo 'Error: Module not installed' >&2
+		exit 1
+	fi
+	for arg in "$@"; do
+		case "$arg" in -out=*) : > "${arg#-out=}" ;; esac
+	done
+	exit 2 ;;
+show)
+	case "$*" in
+	*-json*) cat "$PLAN_JSON" ;;
+	*) echo "Plan: 1 to add, 0 to change, 0 to destroy." ;;
+	esac ;;
+esac
+`
+
+func TestCreatePlanAutoInit(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	planJSON, err := filepath.Abs("../testdata/plans/changes.json")
+	require.NoError(t, err)
+	t.Setenv("PLAN_JSON", planJSON)
+	t.Setenv("TF_DATA_DIR", "")
+	binPath := filepath.Join(t.TempDir(), "terraform")
+	require.NoError(t, os.WriteFile(binPath, []byte(fakeUninitializedScript), 0o700)) //nolint:gosec // an executable
+	initLog := filepath.Join(t.TempDir(), "init.log")
+	t.Setenv("INIT_LOG", initLog)
+
+	setup := func(t *testing.T) {
+		t.Helper()
+		t.Chdir(t.TempDir())
+		viper.Set("binary", binPath)
+		viper.Set("planFile", "plan.out")
+		t.Cleanup(viper.Reset)
+		_ = os.Remove(initLog)
+	}
+
+	t.Run("Fails without --auto-init", func(t *testing.T) {
+		setup(t)
+
+		_, err := createPlan(context.Background(), ".", true)
+
+		require.ErrorContains(t, err, "Module not installed")
+		require.NoFileExists(t, initLog, "init doesn't run unless asked")
+	})
+
+	t.Run("Initializes once and plans again", func(t *testing.T) {
+		setup(t)
+		viper.Set("autoInit", true)
+
+		result, err := createPlan(context.Background(), ".", true)
+
+		require.NoError(t, err)
+		require.FileExists(t, result.PlanPath)
+		initRuns, err := os.ReadFile(initLog)
+		require.NoError(t, err)
+		require.Equal(t, "init\n", string(initRuns))
+
+		// Now initialized, the next plan doesn't init again
+		_, err = createPlan(context.Background(), ".", true)
+		require.NoError(t, err)
+		initRuns, err = os.ReadFile(initLog)
+		require.NoError(t, err)
+		require.Equal(t, "init\n", string(initRuns))
+	})
+}
+
+func TestNeedsInit(t *testing.T) {
+	t.Setenv("TF_DATA_DIR", "")
+	dir := t.TempDir()
+	require.True(t, needsInit(errors.New("exit status 1"), dir), "no .terraform")
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, ".terraform"), 0o750))
+	require.False(t, needsInit(errors.New("Error: Invalid reference"), dir))
+	require.True(t, needsInit(errors.New("Error: Required plugins are not installed"), dir))
+}
+
+func TestSignalWatcher(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	t.Run("Stop is idempotent", func(t *testing.T) {
+		w := watchSignals()
+		w.Stop()
+		w.Stop()
+
+		require.False(t, w.Interrupted())
+	})
+
+	t.Run("A signal sets the flag", func(t *testing.T) {
+		w := watchSignals()
+		defer w.Stop()
+
+		require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+		require.Eventually(t, w.Interrupted, time.Second, 10*time.Millisecond)
+	})
+}
@@ -0,0 +1,253 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// defaultTFCHostname is the hostname of HCP Terraform (formerly Terraform Cloud)
+const defaultTFCHostname = "app.terraform.io"
+
+// tfcTimeout bounds each request to the HCP Terraform API
+const tfcTimeout = 30 * time.Second
+
+var (
+	// Matches an HCP Terraform run ID, e.g. run-CZcmD7eagjhyX0vN
+	tfcRunID = regexp.MustCompile(`^run-[A-Za-z0-9]+$`)
+	// Matches a 'cloud' block in a terraform block
+	cloudBlock = regexp.MustCompile(`(?m)^\s*cloud\s*\{`)
+	// Matches ANSI escape sequences in run logs
+	ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+)
+
+// defaultTFCClient is the TFCClient used outside of tests
+var defaultTFCClient TFCClient = &RealTFCClient{}
+
+// TFCClient is an interface for reading remote runs from HCP Terraform
+// This allows for dependency injection and easier testing
+type TFCClient interface {
+	PlanLog(ctx context.Context, runID string) (string, error)
+}
+
+// RealTFCClient implements the TFCClient interface with the HCP Terraform API
+type RealTFCClient struct {
+	// BaseURL overrides "https://<hostname>", used in tests
+	BaseURL string
+	// HTTPClient overrides http.DefaultClient, used in tests
+	HTTPClient *http.Client
+}
+
+// tfcRun is the subset of the JSON:API run document we read
+type tfcRun struct {
+	Included []struct {
+		Type       string `json:"type"`
+		Attributes struct {
+			LogReadURL string `json:"log-read-url"`
+		} `json:"attributes"`
+	} `json:"included"`
+}
+
+// PlanLog fetches the plan log of a remote run
+//
+// Parameters:
+//
+//	ctx - The context controlling the requests
+//	runID - The ID of the run, e.g. run-CZcmD7eagjhyX0vN
+//
+// Returns:
+//
+//	string - The plan output, without color codes
+//	error - Any error encountered talking to the API
+func (c *RealTFCClient) PlanLog(ctx context.Context, runID string) (string, error) {
+	hostname := tfcHostname()
+	token, err := tfcToken(hostname)
+	if err != nil {
+		return "", err
+	}
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = "https://" + hostname
+	}
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, tfcTimeout)
+	defer cancel()
+
+	runURL := fmt.Sprintf("%s/api/v2/runs/%s?include=plan", baseURL, runID)
+	body, err := tfcGet(ctx, client, runURL, token)
+	if err != nil {
+		return "", fmt.Errorf("failed to read run %s: %w", runID, err)
+	}
+	var run tfcRun
+	if err = json.Unmarshal(body, &run); err != nil {
+		return "", fmt.Errorf("failed to parse run %s: %w", runID, err)
+	}
+	logURL := ""
+	for _, inc := range run.Included {
+		if inc.Type == "plans" {
+			logURL = inc.Attributes.LogReadURL
+		}
+	}
+	if logURL == "" {
+		return "", fmt.Errorf("run %s has no plan log", runID)
+	}
+
+	// The log URL is pre-signed and must not receive the API token
+	body, err = tfcGet(ctx, client, logURL, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to read plan log for run %s: %w", runID, err)
+	}
+	return cleanRunLog(string(body)), nil
+}
+
+// tfcGet performs an authenticated GET request and returns the response body.
+func tfcGet(ctx context.Context, client *http.Client, url, token string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/vnd.api+json")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// tfcHostname returns the HCP Terraform or Terraform Enterprise hostname.
+func tfcHostname() string {
+	if h := viper.GetString("tfcHostname"); h != "" {
+		return h
+	}
+	if h := os.Getenv("TF_CLOUD_HOSTNAME"); h != "" {
+		return h
+	}
+	return defaultTFCHostname
+}
+
+// tfcToken finds an API token for hostname the way terraform does: the
+// TF_TOKEN_<hostname> variable, then the CLI credentials file. TFE_TOKEN is
+// also accepted as it is common in CI.
+func tfcToken(hostname string) (string, error) {
+	envName := "TF_TOKEN_" + strings.NewReplacer(".", "_", "-", "__").Replace(hostname)
+	if token := os.Getenv(envName); token != "" {
+		return token, nil
+	}
+	if token := os.Getenv("TFE_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err == nil {
+		credsPath := filepath.Join(homeDir, ".terraform.d", "credentials.tfrc.json")
+		data, readErr := os.ReadFile(credsPath) //nolint:gosec // terraform's credentials file
+		if readErr == nil {
+			var creds struct {
+				Credentials map[string]struct {
+					Token string `json:"token"`
+				} `json:"credentials"`
+			}
+			if jsonErr := json.Unmarshal(data, &creds); jsonErr == nil {
+				if token := creds.Credentials[hostname].Token; token != "" {
+					return token, nil
+				}
+			}
+		}
+	}
+
+	return "", fmt.Errorf(
+		"no API token found for %s: set %s or run 'terraform login %s'",
+		hostname,
+		envName,
+		hostname,
+	)
+}
+
+// cleanRunLog turns a run log into plain plan output. Color codes are removed
+// and, for runs that log structured JSON lines, only the messages are kept.
+func cleanRunLog(raw string) string {
+	var sb strings.Builder
+	scanner := bufio.NewScanner(strings.NewReader(ansiEscape.ReplaceAllString(raw, "")))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024) //nolint:mnd
+	for scanner.Scan() {
+		line := scanner.Text()
+		var entry struct {
+			Message *string `json:"@message"`
+		}
+		if strings.HasPrefix(line, "{") && json.Unmarshal([]byte(line), &entry) == nil &&
+			entry.Message != nil {
+			line = *entry.Message
+		}
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// fetchRemotePlan returns the plan output of an existing remote run.
+//
+// Parameters:
+//
+//	ctx - The context controlling the requests
+//	client - The TFCClient used to read the run
+//	runID - The ID of the run passed to --run-id
+//
+// Returns:
+//
+//	string - The plan output
+//	error - An error if the run ID is invalid or the plan could not be read
+func fetchRemotePlan(ctx context.Context, client TFCClient, runID string) (string, error) {
+	if !tfcRunID.MatchString(runID) {
+		return "", fmt.Errorf("invalid 'run-id' %q: expected an ID such as run-CZcmD7eagjhyX0vN", runID)
+	}
+	Logger.Debugf("Fetching plan for remote run %s from %s", runID, tfcHostname())
+	planOutput, err := client.PlanLog(ctx, runID)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(planOutput) == "" {
+		return "", fmt.Errorf("remote run %s has an empty plan log", runID)
+	}
+	return planOutput, nil
+}
+
+// usesCloudBlock reports whether a .tf file in dir configures a 'cloud' block,
+// in which case plans run remotely.
+func usesCloudBlock(dir string) bool {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return false
+	}
+	for _, m := range matches {
+		data, readErr := os.ReadFile(m) //nolint:gosec // configuration file in dir
+		if readErr == nil && cloudBlock.Match(data) {
+			return true
+		}
+	}
+	return false
+}
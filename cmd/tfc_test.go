@@ -0,0 +1,142 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockTFCClient struct {
+	mock.Mock
+}
+
+func (m *MockTFCClient) PlanLog(ctx context.Context, runID string) (string, error) {
+	args := m.Called(ctx, runID)
+	return args.String(0), args.Error(1)
+}
+
+func TestFetchRemotePlan(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	t.Run("Plan output of the run is returned", func(t *testing.T) {
+		client := new(MockTFCClient)
+		client.On("PlanLog", mock.Anything, "run-CZcmD7eagjhyX0vN").
+			Return("Plan: 1 to add, 0 to change, 0 to destroy.", nil)
+
+		got, err := fetchRemotePlan(context.Background(), client, "run-CZcmD7eagjhyX0vN")
+
+		require.NoError(t, err)
+		require.Equal(t, "Plan: 1 to add, 0 to change, 0 to destroy.", got)
+		client.AssertExpectations(t)
+	})
+
+	t.Run("Invalid run IDs are rejected before calling the API", func(t *testing.T) {
+		client := new(MockTFCClient)
+
+		_, err := fetchRemotePlan(context.Background(), client, "ws-abc")
+
+		require.ErrorContains(t, err, "invalid 'run-id'")
+		client.AssertNotCalled(t, "PlanLog", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Client errors are returned", func(t *testing.T) {
+		client := new(MockTFCClient)
+		client.On("PlanLog", mock.Anything, "run-abc").Return("", errors.New("unexpected status 404"))
+
+		_, err := fetchRemotePlan(context.Background(), client, "run-abc")
+
+		require.ErrorContains(t, err, "404")
+	})
+
+	t.Run("Empty plan logs are an error", func(t *testing.T) {
+		client := new(MockTFCClient)
+		client.On("PlanLog", mock.Anything, "run-abc").Return("\n", nil)
+
+		_, err := fetchRemotePlan(context.Background(), client, "run-abc")
+
+		require.ErrorContains(t, err, "empty plan log")
+	})
+}
+
+func TestRealTFCClientPlanLog(t *testing.T) {
+	t.Cleanup(func() { viper.Set("tfcHostname", "") })
+	viper.Set("tfcHostname", "tfe.example.com")
+	t.Setenv("TF_TOKEN_tfe_example_com", "secret-token")
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	mux.HandleFunc("/api/v2/runs/run-abc", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `{"data":{"id":"run-abc"},"included":[`+
+			`{"type":"plans","attributes":{"log-read-url":"%s/logs/plan"}}]}`, server.URL)
+	})
+	mux.HandleFunc("/logs/plan", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, "\x1b[1mTerraform will perform the following actions:\x1b[0m\n"+
+			"\x1b[1mPlan:\x1b[0m 1 to add, 0 to change, 0 to destroy.\n")
+	})
+
+	client := &RealTFCClient{BaseURL: server.URL, HTTPClient: server.Client()}
+
+	got, err := client.PlanLog(context.Background(), "run-abc")
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		"Terraform will perform the following actions:\nPlan: 1 to add, 0 to change, 0 to destroy.",
+		got,
+	)
+
+	_, err = client.PlanLog(context.Background(), "run-missing")
+	require.ErrorContains(t, err, "404")
+}
+
+func TestCleanRunLog(t *testing.T) {
+	raw := `{"@level":"info","@message":"Terraform 1.9.0","type":"version"}
+{"@level":"info","@message":"null_resource.a: Plan to create","type":"planned_change"}
+{"@level":"info","@message":"Plan: 1 to add, 0 to change, 0 to destroy.","type":"change_summary"}`
+
+	require.Equal(
+		t,
+		"Terraform 1.9.0\nnull_resource.a: Plan to create\nPlan: 1 to add, 0 to change, 0 to destroy.",
+		cleanRunLog(raw),
+	)
+}
+
+func TestUsesCloudBlock(t *testing.T) {
+	dir := t.TempDir()
+	require.False(t, usesCloudBlock(dir))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte(`resource "null_resource" "a" {}`), 0o600))
+	require.False(t, usesCloudBlock(dir))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "backend.tf"), []byte(`terraform {
+  cloud {
+    organization = "example"
+    workspaces {
+      name = "app"
+    }
+  }
+}
+`), 0o600))
+	require.True(t, usesCloudBlock(dir))
+}
@@ -10,13 +10,18 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
 	"github.com/cli/safeexec"
 	"github.com/fatih/color"
+	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
@@ -25,31 +30,85 @@ const (
 	maxFilenameLength = 255
 )
 
-// determineBinary finds the IaC binary to use based on flags, config, or PATH discovery.
-func determineBinary() (string, error) {
+// Sources of the binary in a BinaryResolution
+const (
+	binarySourceFlag   = "flag"   // --binary
+	binarySourceConfig = "config" // binary in the config file or environment
+	binarySourceAuto   = "auto"   // Found in PATH
+)
+
+// BinaryResolution records how determineBinary chose the binary, so the
+// decision can be logged or shown without re-running it.
+type BinaryResolution struct {
+	Source     string   // Where the binary came from: flag, config or auto
+	Candidates []string // Binaries found in PATH, when auto-detecting
+	Pinned     string   // Binary pinned by a version manager file, when auto-detecting
+	Binary     string   // The binary chosen, empty if none could be
+}
+
+// String describes the resolution, e.g. "tofu (auto: found tofu, terraform; pinned tofu)".
+func (r BinaryResolution) String() string {
+	binary := r.Binary
+	if binary == "" {
+		binary = "none"
+	}
+	if r.Source != binarySourceAuto {
+		return fmt.Sprintf("%s (%s)", binary, r.Source)
+	}
+	found := "found none"
+	if len(r.Candidates) > 0 {
+		found = "found " + strings.Join(r.Candidates, ", ")
+	}
+	if r.Pinned != "" {
+		found += "; pinned " + r.Pinned
+	}
+	return fmt.Sprintf("%s (%s: %s)", binary, r.Source, found)
+}
+
+// determineBinary finds the IaC binary to use based on flags, config, or PATH
+// discovery. cmd tells a --binary flag from the config, and may be nil.
+func determineBinary(cmd *cobra.Command) (string, error) {
+	resolution, err := resolveBinary(cmd != nil && cmd.Flags().Changed("binary"))
+	Logger.Debugf("Binary resolution: %s", resolution)
+	return resolution.Binary, err
+}
+
+// resolveBinary chooses the binary as determineBinary does, recording how.
+//
+// Parameters:
+//
+//	flagChanged - Whether --binary was passed, rather than set in the config.
+//
+// Returns:
+//
+//	BinaryResolution - How the binary was chosen, as far as it got.
+//	error - An error if the configured binary is invalid or none can be chosen.
+func resolveBinary(flagChanged bool) (BinaryResolution, error) {
 	// 1. Check Viper (which checks flags first, then config)
 	binaryFromConfig, err := getBinaryFromConfig()
-	if err != nil {
-		return "", err
-	}
-	if binaryFromConfig != "" {
-		return binaryFromConfig, nil
+	if binaryFromConfig != "" || err != nil {
+		source := binarySourceConfig
+		if flagChanged {
+			source = binarySourceFlag
+		}
+		return BinaryResolution{Source: source, Binary: binaryFromConfig}, err
 	}
 
 	// 2. Auto-detect if not specified
-	detectedBinary, err := autoDetectBinary()
+	resolution, err := autoDetectBinary()
 	if err != nil {
-		return "", err
+		return resolution, err
 	}
-	if detectedBinary != "" {
-		return detectedBinary, nil
+	if resolution.Binary != "" {
+		return resolution, nil
 	}
 
 	// 3. Handle the case where no binary is found
-	return "", buildNoBinaryFoundError()
+	return resolution, buildNoBinaryFoundError()
 }
 
-// getBinaryFromConfig checks for a binary specified via flag or config.
+// getBinaryFromConfig checks for a binary specified via flag or config:
+// 'terraform' or 'tofu' found in PATH, or a path to either.
 func getBinaryFromConfig() (string, error) {
 	v := viper.IsSet("binary")
 	Logger.Debugf("Binary is set: %v", v)
@@ -58,8 +117,23 @@ func getBinaryFromConfig() (string, error) {
 		return "", nil // Not set
 	}
 
+	// A path is used as is, e.g. for a build kept outside of PATH
+	if isBinaryPath(viperBinary) {
+		binaryPath, err := binaryFromPath(viperBinary)
+		if err != nil {
+			return "", err
+		}
+		if !knownBinary(viperBinary) {
+			return "", fmt.Errorf(
+				"invalid binary specified ('%s'): the file must be named 'terraform' or 'tofu'",
+				viperBinary,
+			)
+		}
+		return binaryPath, nil
+	}
+
 	// Validate if specified
-	if viperBinary != "terraform" && viperBinary != "tofu" {
+	if !knownBinary(viperBinary) {
 		return "", fmt.Errorf(
 			"invalid binary specified ('%s'): must be 'terraform' or 'tofu'",
 			viperBinary,
@@ -80,15 +154,75 @@ func getBinaryFromConfig() (string, error) {
 	return viperBinary, nil
 }
 
+// binaryFromPath checks that path is an executable file.
+//
+// Parameters:
+//
+//	path - The binary's path, absolute or relative to the current directory.
+//
+// Returns:
+//
+//	string - The binary's absolute path, so it's found when planning in another directory.
+//	error - An error if path isn't an executable file.
+func binaryFromPath(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid binary path '%s': %w", path, err)
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return "", fmt.Errorf("binary '%s' specified but not found: %w", path, err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("binary '%s' specified is a directory", path)
+	}
+	// Windows has no execute bit, the extension decides
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o111 == 0 {
+		return "", fmt.Errorf("binary '%s' specified is not executable (chmod +x)", path)
+	}
+
+	Logger.Debugf("Using binary path specified via flag or config: %s", absPath)
+	return absPath, nil
+}
+
+// isBinaryPath reports whether binary is a path rather than a name to look
+// up in PATH.
+func isBinaryPath(binary string) bool {
+	return strings.ContainsRune(binary, '/') || strings.ContainsRune(binary, filepath.Separator)
+}
+
+// knownBinary reports whether binary is 'terraform' or 'tofu', or a path to
+// a file binaryKind recognizes as either, e.g. /opt/tools/tofu-1.8.0/tofu.
+func knownBinary(binary string) bool {
+	if isBinaryPath(binary) {
+		kind := binaryKind(binary)
+		return kind == "terraform" || kind == "tofu"
+	}
+	return binary == "terraform" || binary == "tofu"
+}
+
+// binaryKind returns "terraform" or "tofu" for a binary given by name or
+// path, e.g. tofu for /opt/tools/tofu-1.8.0/tofu or tofu_1.8.0.exe, and the
+// file's name otherwise.
+func binaryKind(binary string) string {
+	name := strings.TrimSuffix(filepath.Base(binary), ".exe")
+	for _, known := range []string{"terraform", "tofu"} {
+		if name == known || strings.HasPrefix(name, known+"-") || strings.HasPrefix(name, known+"_") {
+			return known
+		}
+	}
+	return name
+}
+
 // autoDetectBinary attempts to find 'tofu' or 'terraform' in the PATH.
-func autoDetectBinary() (string, error) {
+func autoDetectBinary() (BinaryResolution, error) {
 	Logger.Debug("Binary not specified, attempting auto-detection...")
+	resolution := BinaryResolution{Source: binarySourceAuto}
 	binariesToFind := []string{"tofu", "terraform"}
-	var foundBinaries []string
 	for _, binName := range binariesToFind {
 		binPath, lookupErr := safeexec.LookPath(binName)
 		if lookupErr == nil && len(binPath) > 0 {
-			foundBinaries = append(foundBinaries, binName)
+			resolution.Candidates = append(resolution.Candidates, binName)
 			Logger.Debugf("Found '%s' in PATH at '%s'", binName, binPath)
 		} else {
 			Logger.Debugf("Did not find '%s' in PATH: %v", binName, lookupErr)
@@ -96,29 +230,53 @@ func autoDetectBinary() (string, error) {
 	}
 
 	// Evaluate auto-detection results
-	if len(foundBinaries) == 0 {
-		return "", nil // No binaries found, handle in the main function
+	if len(resolution.Candidates) == 0 {
+		return resolution, nil // No binaries found, handle in the main function
 	}
 
-	if len(foundBinaries) > 1 {
-		return "", buildMultipleBinariesFoundError(foundBinaries)
+	// Version manager files can tell which binary the project uses
+	resolution.Pinned = pinnedBinary(readVersionPins("."))
+	if len(resolution.Candidates) > 1 {
+		if resolution.Pinned != "" {
+			Logger.Debugf("Both binaries found, using %s pinned by a version file", resolution.Pinned)
+			resolution.Binary = resolution.Pinned
+			return resolution, nil
+		}
+		return resolution, buildMultipleBinariesFoundError(resolution.Candidates)
 	}
 
 	// Exactly one binary found
-	detectedBinary := foundBinaries[0]
-	Logger.Debugf("Auto-detected binary: %s", detectedBinary)
-	return detectedBinary, nil
+	resolution.Binary = resolution.Candidates[0]
+	if resolution.Pinned != "" && resolution.Pinned != resolution.Binary {
+		Logger.Warnf(
+			"A version file pins %s, but only %s was found in your PATH. Using %s.",
+			resolution.Pinned,
+			resolution.Binary,
+			resolution.Binary,
+		)
+	}
+	Logger.Debugf("Auto-detected binary: %s", resolution.Binary)
+	return resolution, nil
 }
 
 // Regex for allowed filename characters
 var validFilenameChars = regexp.MustCompile(`^[a-zA-Z0-9_\-\.]+$`)
 
+// Regex for the filename characters allowed with --relaxed-filenames. It adds
+// characters the shell doesn't interpret, but not whitespace, quotes, globs or
+// anything that expands or chains commands.
+var relaxedFilenameChars = regexp.MustCompile(`^[a-zA-Z0-9_\-\.+,@=%]+$`)
+
+// dirEntryBatch is the number of names checkFilesByExtension reads at once
+const dirEntryBatch = 256
+
 // checkFilesByExtension checks if files with any of the specified extensions exist in a directory
 //
-// This function iterates through a list of file extensions and uses filepath.Glob to find
-// any files matching those extensions in the given directory. It returns true as soon as it
-// finds at least one file with any of the specified extensions, and false if no matching
-// files are found or if an error occurs.
+// This function reads the directory's entry names in batches, without sorting
+// or stat'ing them, and returns true as soon as a name ends with one of the
+// extensions. A single pass is much faster than a glob per extension in
+// directories with thousands of files. Like a glob, it matches any entry,
+// including directories, and returns false if the directory can't be read.
 //
 // Parameters:
 //
@@ -130,19 +288,30 @@ var validFilenameChars = regexp.MustCompile(`^[a-zA-Z0-9_\-\.]+$`)
 //	bool - true if at least one file with any of the specified extensions exists,
 //	       false if no matching files are found or if an error occurs
 func checkFilesByExtension(dir string, exts []string) bool {
-	var exists bool
-	for _, v := range exts {
-		files, err := filepath.Glob(filepath.Join(dir, "*"+v))
-		if err != nil {
-			exists = false
-			return exists
+	if len(exts) == 0 {
+		return false
+	}
+	d, err := os.Open(dir) //nolint:gosec // a directory being planned
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = d.Close()
+	}()
+
+	for {
+		names, readErr := d.Readdirnames(dirEntryBatch)
+		for _, name := range names {
+			for _, ext := range exts {
+				if strings.HasSuffix(name, ext) {
+					return true
+				}
+			}
 		}
-		if len(files) > 0 {
-			exists = true
-			return exists
+		if readErr != nil { // io.EOF once every name was read
+			return false
 		}
 	}
-	return exists
 }
 
 // existsOrCreated checks if specified files exist or were created and reports their status.
@@ -154,6 +323,7 @@ func checkFilesByExtension(dir string, exts []string) bool {
 // Returns:
 //   - error: Returns nil if status reporting completes, or an error if writing to output fails
 func existsOrCreated(files []tpFile) error {
+	g := statusGlyphs(os.Getenv, runtime.GOOS)
 	for _, v := range files {
 		// First check if the file exists
 		exists := doesExist(v.Name)
@@ -163,12 +333,12 @@ func existsOrCreated(files []tpFile) error {
 			// File doesn't exist - log debug info and display failure status
 			Logger.Debugf("%s file %s was not created", v.Purpose, v.Name)
 			_, err = fmt.Fprintf(color.Output, "%s  %s%s",
-				bold(red("✕")), v.Purpose, " Failed to Create\n")
+				bold(red(g.Failure)), v.Purpose, " Failed to Create\n")
 		} else {
 			// File exists - log debug info and display success status
 			Logger.Debugf("%s file %s was created", v.Purpose, v.Name)
 			_, err = fmt.Fprintf(color.Output, "%s  %s%s",
-				bold(green("✔")), v.Purpose, " Created...\n")
+				bold(green(g.Success)), v.Purpose, " Created...\n")
 		}
 		if err != nil {
 			return fmt.Errorf("failed to display status: %w", err)
@@ -222,7 +392,123 @@ func getDirectories() (homeDir, configDir, cwd string, err error) {
 	return homeDir, configDir, cwd, nil
 }
 
-// BackupFile copies a file from source to destination.
+// checkDangerousDir refuses to plan in the filesystem root or the user's home
+// directory, where a plan is almost never intended.
+//
+// Parameters:
+//
+//	cwd - The directory the plan would run in.
+//	homeDir - The user's home directory.
+//
+// Returns:
+//
+//	error - An error explaining how to proceed if cwd is a dangerous directory.
+func checkDangerousDir(cwd, homeDir string) error {
+	resolve := func(dir string) string {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = resolved
+		}
+		return filepath.Clean(dir)
+	}
+	dir := resolve(cwd)
+
+	var which string
+	switch {
+	case filepath.Dir(dir) == dir:
+		which = "the filesystem root"
+	case homeDir != "" && dir == resolve(homeDir):
+		which = "your home directory"
+	default:
+		return nil
+	}
+	return fmt.Errorf(
+		"refusing to plan in %s (%s). Run tp in your project's directory, or pass --allow-dangerous-dir if this is intended",
+		which,
+		cwd,
+	)
+}
+
+// checkWritable verifies that a file can be created in dir. If dir does not
+// exist yet, its nearest existing ancestor is checked instead, since the
+// missing directories will be created alongside the file.
+//
+// Parameters:
+//
+//	dir - The directory the file will be written to.
+//
+// Returns:
+//
+//	error - nil if dir is writable, or an error describing why it is not.
+func checkWritable(dir string) error {
+	target := filepath.Clean(dir)
+	for {
+		info, err := os.Stat(target)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", target)
+			}
+			break
+		}
+		// ENOTDIR means a parent is a file, which the next iteration reports
+		if !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, syscall.ENOTDIR) {
+			return fmt.Errorf("cannot access %s: %w", target, err)
+		}
+		parent := filepath.Dir(target)
+		if parent == target {
+			return fmt.Errorf("no existing parent directory for %s", dir)
+		}
+		target = parent
+	}
+
+	probe, err := os.CreateTemp(target, ".tp-write-check-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", target, err)
+	}
+	probeName := probe.Name()
+	if err = probe.Close(); err != nil {
+		Logger.Debugf("Error closing write check file %s: %v", probeName, err)
+	}
+	if err = os.Remove(probeName); err != nil {
+		Logger.Debugf("Error removing write check file %s: %v", probeName, err)
+	}
+	return nil
+}
+
+// maxBackupsPerSecond bounds the numbered backups newBackup tries in one second
+const maxBackupsPerSecond = 100
+
+// newBackup backs source up next to it, named after now, e.g.
+// .tp.toml-20250102150405. When a backup of the same second exists, a number
+// is appended, e.g. .tp.toml-20250102150405-2, so no backup is overwritten.
+//
+// Parameters:
+//   - source: Path to the file to back up.
+//   - now: The time of the backup.
+//
+// Returns:
+//   - string: The path of the backup.
+//   - error: nil on success, or an error describing what went wrong.
+func newBackup(source string, now time.Time) (string, error) {
+	base := source + "-" + now.Local().Format(backupTimeFormat)
+	for n := 1; n <= maxBackupsPerSecond; n++ {
+		dest := base
+		if n > 1 {
+			dest = fmt.Sprintf("%s-%d", base, n)
+		}
+		err := BackupFile(source, dest)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return dest, nil
+	}
+	return "", fmt.Errorf("unable to back up %q: %d backups already exist for %s", source, maxBackupsPerSecond, base)
+}
+
+// BackupFile copies a file from source to destination. An existing
+// destination is never overwritten, the error then wraps os.ErrExist.
 // It relies on os package functions for path handling and permissions.
 //
 // Parameters:
@@ -232,6 +518,13 @@ func getDirectories() (homeDir, configDir, cwd string, err error) {
 // Returns:
 //   - error: nil on success, or an error describing what went wrong (file ops).
 func BackupFile(source, dest string) error {
+	return copyFile(source, dest, os.O_EXCL)
+}
+
+// copyFile copies a file from source to destination, opening the destination
+// with os.O_WRONLY|os.O_CREATE and flag: os.O_EXCL to refuse an existing
+// destination, os.O_TRUNC to replace it.
+func copyFile(source, dest string, flag int) error {
 	// Check if source exists using os.Stat
 	sourceInfo, statErr := os.Stat(source)
 	if statErr != nil {
@@ -262,9 +555,14 @@ func BackupFile(source, dest string) error {
 	}()
 
 	// Create destination file
-	destFile, err := os.Create( //nolint:gosec // dest path provided by trusted caller context (e.g., config backup)
+	destFile, err := os.OpenFile( //nolint:gosec // dest path provided by trusted caller context (e.g., config backup)
 		dest,
+		os.O_WRONLY|os.O_CREATE|flag,
+		0o666, //nolint:mnd // as os.Create, before the umask
 	)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("backup %q already exists: %w", dest, err)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create destination file %q: %w", dest, err)
 	}
@@ -291,8 +589,45 @@ func BackupFile(source, dest string) error {
 	return nil // Success
 }
 
+// logTimeFormat is the timestamp layout from 'logTimeFormat', empty for the defaults
+var logTimeFormat string
+
+// namedLogTimeFormats are the layouts 'logTimeFormat' accepts by name
+var namedLogTimeFormats = map[string]string{
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"kitchen":     time.Kitchen,
+}
+
+// parseLogTimeFormat validates 'logTimeFormat', either a name such as RFC3339
+// or a Go time layout such as "2006-01-02 15:04:05". A layout that formats
+// time.Now() as itself has no time elements and is rejected.
+//
+// Parameters:
+//
+//	format - The format as configured.
+//
+// Returns:
+//
+//	string - The Go time layout.
+//	error - An error if format isn't a usable layout.
+func parseLogTimeFormat(format string) (string, error) {
+	if layout, ok := namedLogTimeFormats[strings.ToLower(strings.TrimSpace(format))]; ok {
+		return layout, nil
+	}
+	if strings.TrimSpace(format) == "" || time.Now().Format(format) == format {
+		return "", fmt.Errorf(
+			"invalid 'logTimeFormat' (%q): expected RFC3339, RFC3339Nano, Kitchen or a Go time layout such as %q",
+			format,
+			time.DateTime,
+		)
+	}
+	return format, nil
+}
+
 // createLogger creates and configures the package-level Logger instance
-// based on the desired verbosity.
+// based on the desired verbosity. A custom logTimeFormat also turns on
+// timestamps when not verbose.
 func createLogger(verbose bool) {
 	var level log.Level
 	var reportCaller, reportTimestamp bool
@@ -310,6 +645,10 @@ func createLogger(verbose bool) {
 		timeFormat = time.Kitchen
 		level = log.InfoLevel
 	}
+	if logTimeFormat != "" {
+		reportTimestamp = true
+		timeFormat = logTimeFormat
+	}
 
 	var instanceToUse *log.Logger // Use a local variable first
 
@@ -356,6 +695,79 @@ func createLogger(verbose bool) {
 	}
 }
 
+// defaultFileMode is the permission mode of the plan and Markdown files
+const defaultFileMode os.FileMode = 0o600
+
+// parseFileMode parses an octal permission mode such as "0640" for the
+// generated plan and Markdown files. The owner must be able to read and write
+// the files, and world-writable modes are rejected.
+//
+// Parameters:
+//
+//	mode - The octal mode string, with or without a leading "0" or "0o".
+//
+// Returns:
+//
+//	os.FileMode - The parsed mode.
+//	error - An error if the mode is not octal or is not a sane file mode.
+func parseFileMode(mode string) (os.FileMode, error) {
+	trimmed := strings.TrimPrefix(strings.TrimSpace(mode), "0o")
+	parsed, err := strconv.ParseUint(trimmed, 8, 32) //nolint:mnd
+	if err != nil {
+		return 0, fmt.Errorf("invalid 'fileMode' %q: expected an octal mode such as 0640", mode)
+	}
+	return checkFileMode(os.FileMode(parsed), mode)
+}
+
+// checkFileMode rejects modes that aren't sane for the generated files.
+func checkFileMode(fm os.FileMode, raw string) (os.FileMode, error) {
+	switch {
+	case fm&^os.ModePerm != 0:
+		return 0, fmt.Errorf("invalid 'fileMode' %q: only permission bits (0000-0777) are allowed", raw)
+	case fm&0o002 != 0: //nolint:mnd
+		return 0, fmt.Errorf("invalid 'fileMode' %q: files must not be world-writable", raw)
+	case fm&defaultFileMode != defaultFileMode:
+		return 0, fmt.Errorf("invalid 'fileMode' %q: the owner must be able to read and write files", raw)
+	}
+	return fm, nil
+}
+
+// outputFileMode returns the configured 'fileMode', or defaultFileMode if unset.
+// The mode is normally a string, but a TOML octal integer (0o640) is accepted too.
+func outputFileMode() (os.FileMode, error) {
+	switch v := viper.Get("fileMode").(type) {
+	case nil:
+		return defaultFileMode, nil
+	case int64:
+		return checkFileMode(os.FileMode(v), fmt.Sprintf("%#o", v)) //nolint:gosec // range checked
+	case string:
+		if v == "" {
+			return defaultFileMode, nil
+		}
+		return parseFileMode(v)
+	default:
+		return parseFileMode(viper.GetString("fileMode"))
+	}
+}
+
+// runLogger returns a child of base that adds the run's context to every log
+// line, so lines can be traced back to a run in aggregated logs. The fields are
+// key/value pairs, which every log formatter includes.
+//
+// Parameters:
+//
+//	base - The configured package Logger.
+//	workingDir - The directory the plan runs in.
+//	binaryName - The resolved binary, "terraform" or "tofu".
+//	workspace - The selected workspace.
+//
+// Returns:
+//
+//	*log.Logger - The child logger.
+func runLogger(base *log.Logger, workingDir, binaryName, workspace string) *log.Logger {
+	return base.With("dir", workingDir, "binary", binaryName, "workspace", workspace)
+}
+
 // validateFilePath checks if a given path string represents a simple, safe filename
 // intended for use within the current directory.
 // It performs checks for:
@@ -375,6 +787,22 @@ func createLogger(verbose bool) {
 //	error - An error detailing the validation failure if any check fails. On failure,
 //	        the returned string is the original input path.
 func validateFilePath(path string) (string, error) {
+	return checkFilename(path, validFilenameChars, "a-z, A-Z, 0-9, _, -, .")
+}
+
+// validateOutputName validates the name of a plan or Markdown file like
+// validateFilePath. With --relaxed-filenames it also allows the characters of
+// relaxedFilenameChars, e.g. "plan+prod.out".
+func validateOutputName(path string) (string, error) {
+	if viper.GetBool("relaxedFilenames") {
+		return checkFilename(path, relaxedFilenameChars, "a-z, A-Z, 0-9, _, -, ., +, ,, @, =, %")
+	}
+	return validateFilePath(path)
+}
+
+// checkFilename implements validateFilePath, allowing the characters matched
+// by allowed, described by allowedDesc in errors.
+func checkFilename(path string, allowed *regexp.Regexp, allowedDesc string) (string, error) {
 	// --- Validate the filename parameter ---
 	if path == "" {
 		err := errors.New("invalid file path: filename cannot be empty")
@@ -398,10 +826,11 @@ func validateFilePath(path string) (string, error) {
 	}
 
 	// 3. Check for allowed characters using regex
-	if !validFilenameChars.MatchString(validatedFilename) {
+	if !allowed.MatchString(validatedFilename) {
 		err := fmt.Errorf(
-			"invalid file path: filename %q contains invalid characters (allowed: a-z, A-Z, 0-9, _, -, .)",
+			"invalid file path: filename %q contains invalid characters (allowed: %s)",
 			validatedFilename, // Use validated filename here as it's the one checked
+			allowedDesc,
 		)
 		// Return original path and error
 		return path, err
@@ -428,3 +857,70 @@ func validateFilePath(path string) (string, error) {
 	// If all checks pass, return the validated filename (which is just the base name) and nil error
 	return validatedFilename, nil
 }
+
+// checkMixedExtensions catches a directory with both .tf and .tofu files,
+// often a half-done migration. Terraform ignores .tofu files while OpenTofu
+// prefers them over .tf files of the same name, so the two tools may plan
+// different configurations. This only warns unless strict.
+//
+// Parameters:
+//
+//	dir - The directory planned.
+//	strict - Whether --strict-mixed-files was passed.
+//
+// Returns:
+//
+//	error - An error describing the conflict when strict, otherwise nil.
+func checkMixedExtensions(dir string, strict bool) error {
+	if !checkFilesByExtension(dir, []string{".tf"}) || !checkFilesByExtension(dir, []string{".tofu"}) {
+		return nil
+	}
+	problem := fmt.Sprintf("%q has both .tf and .tofu files", dir)
+	if strict {
+		return fmt.Errorf("%s (--strict-mixed-files is set)", problem)
+	}
+	Logger.Warnf(
+		"%s. Terraform ignores the .tofu files and OpenTofu prefers them, so the two may plan different configurations.",
+		problem,
+	)
+	return nil
+}
+
+// markdownExts are the extensions expected of the mdFile
+var markdownExts = []string{".md", ".markdown"}
+
+// checkFileExtensions catches a planFile and mdFile that look swapped: the
+// plan file shouldn't end in a Markdown extension and the Markdown file
+// should. Unusual names can be deliberate, so this only warns unless strict.
+//
+// Parameters:
+//
+//	planFile - The validated planFile.
+//	mdFile - The validated mdFile.
+//	strict - Whether --strict-extensions was passed.
+//
+// Returns:
+//
+//	error - An error describing the problems when strict, otherwise nil.
+func checkFileExtensions(planFile, mdFile string, strict bool) error {
+	var problems []string
+	if slices.Contains(markdownExts, strings.ToLower(filepath.Ext(planFile))) {
+		problems = append(problems, fmt.Sprintf("'planFile' %q has a Markdown extension", planFile))
+	}
+	if !slices.Contains(markdownExts, strings.ToLower(filepath.Ext(mdFile))) {
+		problems = append(
+			problems,
+			fmt.Sprintf("'mdFile' %q doesn't end in %s", mdFile, strings.Join(markdownExts, " or ")),
+		)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	if strict {
+		return fmt.Errorf("%s (--strict-extensions is set)", strings.Join(problems, "; "))
+	}
+	for _, problem := range problems {
+		Logger.Warnf("%s. Are 'planFile' and 'mdFile' swapped?", problem)
+	}
+	return nil
+}
@@ -4,13 +4,17 @@ package cmd
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/fatih/color"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -76,6 +80,52 @@ func TestBackupFile(t *testing.T) {
 	})
 }
 
+func TestNewBackup(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	dir := t.TempDir()
+	source := filepath.Join(dir, ConfigName)
+	now := time.Date(2025, 1, 2, 15, 4, 5, 0, time.Local)
+
+	require.NoError(t, os.WriteFile(source, []byte("planFile = 'first.out'\n"), 0o600))
+	first, err := newBackup(source, now)
+	require.NoError(t, err)
+	require.Equal(t, source+"-20250102150405", first)
+
+	t.Run("Backups in the same minute both survive", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(source, []byte("planFile = 'second.out'\n"), 0o600))
+		second, err := newBackup(source, now.Add(30*time.Second))
+		require.NoError(t, err)
+		require.Equal(t, source+"-20250102150435", second)
+
+		require.NoError(t, os.WriteFile(source, []byte("planFile = 'third.out'\n"), 0o600))
+		third, err := newBackup(source, now.Add(30*time.Second))
+		require.NoError(t, err)
+		require.Equal(t, source+"-20250102150435-2", third)
+
+		for path, want := range map[string]string{first: "first.out", second: "second.out", third: "third.out"} {
+			content, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.Contains(t, string(content), want)
+		}
+
+		backups, err := listConfigBackups(source)
+		require.NoError(t, err)
+		require.Len(t, backups, 3)
+		require.Equal(t, []string{first, second, third}, []string{backups[0].Path, backups[1].Path, backups[2].Path})
+	})
+
+	t.Run("BackupFile refuses to overwrite a backup", func(t *testing.T) {
+		err := BackupFile(source, first)
+
+		require.ErrorIs(t, err, os.ErrExist)
+		content, readErr := os.ReadFile(first)
+		require.NoError(t, readErr)
+		require.Contains(t, string(content), "first.out")
+	})
+}
+
 func TestCheckFilesByExtensionExist(t *testing.T) {
 	fileExts := []string{".tofu", ".tf"}
 
@@ -106,8 +156,89 @@ func TestCheckFilesByExtensionDoNotExist(t *testing.T) {
 	assert.False(t, files)
 }
 
+// globFilesByExtension is the glob per extension checkFilesByExtension
+// replaced, kept to check they agree and to compare their speed.
+func globFilesByExtension(dir string, exts []string) bool {
+	for _, v := range exts {
+		files, err := filepath.Glob(filepath.Join(dir, "*"+v))
+		if err != nil {
+			return false
+		}
+		if len(files) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCheckFilesByExtensionMatchesGlob(t *testing.T) {
+	exts := []string{".tf", ".tofu"}
+	testCases := []struct {
+		name  string
+		files []string
+		dirs  []string
+		want  bool
+	}{
+		{name: "Empty"},
+		{name: "Configuration", files: []string{"README.md", "main.tf"}, want: true},
+		{name: "OpenTofu", files: []string{"main.tofu"}, want: true},
+		{name: "Hidden", files: []string{".tf"}, want: true},
+		{name: "Other extensions", files: []string{"main.tf.json", "terraform.tfvars", "main.tfstate"}},
+		{name: "Directory", dirs: []string{"network.tf"}, want: true},
+		{name: "Nested only", dirs: []string{"modules"}, files: []string{filepath.Join("modules", "main.tf")}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, d := range tc.dirs {
+				require.NoError(t, os.MkdirAll(filepath.Join(dir, d), 0o750))
+			}
+			for _, f := range tc.files {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, f), nil, 0o600))
+			}
+
+			require.Equal(t, tc.want, checkFilesByExtension(dir, exts))
+			require.Equal(t, globFilesByExtension(dir, exts), checkFilesByExtension(dir, exts))
+		})
+	}
+
+	t.Run("Missing directory", func(t *testing.T) {
+		missing := filepath.Join(t.TempDir(), "missing")
+		require.False(t, checkFilesByExtension(missing, exts))
+	})
+}
+
+// benchmarkDir creates a directory of n files without a .tf or .tofu file.
+func benchmarkDir(b *testing.B, n int) string {
+	b.Helper()
+	dir := b.TempDir()
+	for i := range n {
+		require.NoError(b, os.WriteFile(filepath.Join(dir, fmt.Sprintf("file-%05d.json", i)), nil, 0o600))
+	}
+	return dir
+}
+
+func BenchmarkCheckFilesByExtension(b *testing.B) {
+	exts := []string{".tf", ".tofu"}
+	dir := benchmarkDir(b, 10000)
+
+	b.Run("Glob", func(b *testing.B) {
+		for b.Loop() {
+			globFilesByExtension(dir, exts)
+		}
+	})
+	b.Run("ReadDir", func(b *testing.B) {
+		for b.Loop() {
+			checkFilesByExtension(dir, exts)
+		}
+	})
+}
+
 func TestExistsOrCreatedExists(t *testing.T) {
 	createLogger(false)
+	// The glyphs depend on the terminal's Unicode support
+	t.Setenv("LC_ALL", "en_US.UTF-8")
+	t.Setenv("TERM", "xterm")
 	plan, err := os.CreateTemp("", "plan.out")
 	if err != nil {
 		log.Fatal(err)
@@ -153,6 +284,9 @@ func TestExistsOrCreatedDoesNotExists(t *testing.T) {
 	if Logger == nil {
 		createLogger(false)
 	}
+	// The glyphs depend on the terminal's Unicode support
+	t.Setenv("LC_ALL", "en_US.UTF-8")
+	t.Setenv("TERM", "xterm")
 
 	files := []tpFile{
 		{Name: "plan.out", Purpose: "Plan"},
@@ -315,6 +449,59 @@ func Test_ValidateFilePath(t *testing.T) {
 	}
 }
 
+func TestValidateOutputName(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	t.Run("Strict by default", func(t *testing.T) {
+		_, err := validateOutputName("plan+prod.out")
+
+		require.ErrorContains(t, err, "contains invalid characters (allowed: a-z, A-Z, 0-9, _, -, .)")
+	})
+
+	viper.Set("relaxedFilenames", true)
+
+	for _, name := range []string{"plan+prod.out", "plan,v2.md", "plan@main.out", "env=prod.md", "100%.md"} {
+		t.Run("Allows "+name, func(t *testing.T) {
+			got, err := validateOutputName(name)
+
+			require.NoError(t, err)
+			require.Equal(t, name, got)
+		})
+	}
+
+	blocked := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{"Separator", "plans/plan+prod.out", "must be a filename only"},
+		{"Traversal", "../plan+prod.out", "must be a filename only"},
+		{"Null byte", "plan+prod\x00.out", "contains invalid characters"},
+		{"Space", "plan prod.out", "contains invalid characters"},
+		{"Command substitution", "$(id).out", "contains invalid characters"},
+		{"Backticks", "`id`.out", "contains invalid characters"},
+		{"Command chaining", "plan;id.out", "contains invalid characters"},
+		{"Glob", "plan*.out", "contains invalid characters"},
+		{"Quote", "plan'.out", "contains invalid characters"},
+		{"Redirection", "plan>out", "contains invalid characters"},
+		{"Home expansion", "~plan.out", "contains invalid characters"},
+	}
+	for _, tt := range blocked {
+		t.Run("Blocks "+tt.name, func(t *testing.T) {
+			got, err := validateOutputName(tt.path)
+
+			require.ErrorContains(t, err, tt.wantErr)
+			require.Equal(t, tt.path, got)
+		})
+	}
+
+	t.Run("Other file names stay strict", func(t *testing.T) {
+		_, err := validateFilePath("plan+prod.out")
+
+		require.ErrorContains(t, err, "contains invalid characters")
+	})
+}
+
 func Test_createLogger(t *testing.T) {
 	type args struct {
 		verbose bool
@@ -476,3 +663,478 @@ func Test_getDirectories(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckWritable(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	t.Run("Writable directory", func(t *testing.T) {
+		require.NoError(t, checkWritable(t.TempDir()))
+	})
+
+	t.Run("Missing directory with writable parent", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "gh-tp")
+
+		require.NoError(t, checkWritable(dir))
+		require.NoDirExists(t, dir, "checkWritable should not create the directory")
+	})
+
+	t.Run("Parent is a file", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "not-a-dir")
+		require.NoError(t, os.WriteFile(file, nil, 0o600))
+
+		err := checkWritable(filepath.Join(file, "gh-tp"))
+
+		require.ErrorContains(t, err, "is not a directory")
+	})
+
+	t.Run("Read-only directory", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("root can write to read-only directories")
+		}
+		dir := t.TempDir()
+		require.NoError(t, os.Chmod(dir, 0o500))
+		t.Cleanup(func() { os.Chmod(dir, 0o700) })
+
+		err := checkWritable(dir)
+
+		require.ErrorContains(t, err, "is not writable")
+	})
+}
+
+func TestRunLogger(t *testing.T) {
+	testCases := []struct {
+		name      string
+		formatter log.Formatter
+		want      []string
+	}{
+		{
+			name:      "Text",
+			formatter: log.TextFormatter,
+			want:      []string{"dir=/work/infra", "binary=tofu", "workspace=staging"},
+		},
+		{
+			name:      "JSON",
+			formatter: log.JSONFormatter,
+			want: []string{
+				`"dir":"/work/infra"`,
+				`"binary":"tofu"`,
+				`"workspace":"staging"`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			base := log.NewWithOptions(&buf, log.Options{Level: log.InfoLevel, Formatter: tc.formatter})
+
+			runLogger(base, "/work/infra", "tofu", "staging").Info("Plan created")
+			base.Info("Outside the run")
+
+			lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+			require.Len(t, lines, 2)
+			for _, w := range tc.want {
+				require.Contains(t, string(lines[0]), w)
+			}
+			require.NotContains(t, string(lines[1]), "workspace", "base logger must be unchanged")
+		})
+	}
+}
+
+func TestParseFileMode(t *testing.T) {
+	testCases := []struct {
+		mode    string
+		want    os.FileMode
+		wantErr string
+	}{
+		{mode: "0640", want: 0o640},
+		{mode: "600", want: 0o600},
+		{mode: "0o660", want: 0o660},
+		{mode: "0644", want: 0o644},
+		{mode: "0666", wantErr: "world-writable"},
+		{mode: "0440", wantErr: "owner must be able to read and write"},
+		{mode: "1640", wantErr: "only permission bits"},
+		{mode: "0948", wantErr: "expected an octal mode"},
+		{mode: "rw-r-----", wantErr: "expected an octal mode"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.mode, func(t *testing.T) {
+			got, err := parseFileMode(tc.mode)
+
+			if tc.wantErr != "" {
+				require.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestOutputFileMode(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	t.Run("Defaults to 0600", func(t *testing.T) {
+		viper.Reset()
+
+		got, err := outputFileMode()
+
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0o600), got)
+	})
+
+	t.Run("Config string and TOML octal integer", func(t *testing.T) {
+		for _, content := range []string{`fileMode = '0640'`, `fileMode = 0o640`} {
+			viper.Reset()
+			cfg := filepath.Join(t.TempDir(), ".tp.toml")
+			require.NoError(t, os.WriteFile(cfg, []byte(content), 0o600))
+			viper.SetConfigFile(cfg)
+			require.NoError(t, viper.ReadInConfig())
+
+			got, err := outputFileMode()
+
+			require.NoError(t, err, content)
+			require.Equal(t, os.FileMode(0o640), got, content)
+		}
+	})
+}
+
+func TestCreateMarkdownFileMode(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	t.Chdir(t.TempDir())
+
+	mode, err := parseFileMode("0640")
+	require.NoError(t, err)
+	// An existing file keeps its mode unless it is changed explicitly
+	require.NoError(t, os.WriteFile("plan.md", nil, 0o600))
+
+	mdFile, err := createMarkdown("plan.md", "No changes.", "terraform", markdownOptions{FileMode: mode})
+	require.NoError(t, err)
+
+	info, err := os.Stat(mdFile)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+
+	mdFile, err = createMarkdown("default.md", "No changes.", "terraform", markdownOptions{})
+	require.NoError(t, err)
+	info, err = os.Stat(mdFile)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestCheckDangerousDir(t *testing.T) {
+	homeDir := t.TempDir()
+	projectDir := filepath.Join(homeDir, "src", "infra")
+	require.NoError(t, os.MkdirAll(projectDir, 0o750))
+	linkToHome := filepath.Join(t.TempDir(), "home-link")
+	require.NoError(t, os.Symlink(homeDir, linkToHome))
+
+	testCases := []struct {
+		name    string
+		cwd     string
+		wantErr string
+	}{
+		{name: "Home directory", cwd: homeDir, wantErr: "refusing to plan in your home directory"},
+		{name: "Home directory via symlink", cwd: linkToHome, wantErr: "your home directory"},
+		{name: "Home directory with trailing separator", cwd: homeDir + string(filepath.Separator), wantErr: "your home directory"},
+		{name: "Filesystem root", cwd: string(filepath.Separator), wantErr: "refusing to plan in the filesystem root"},
+		{name: "Project directory", cwd: projectDir},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkDangerousDir(tc.cwd, homeDir)
+
+			if tc.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tc.wantErr)
+			require.ErrorContains(t, err, "--allow-dangerous-dir")
+		})
+	}
+}
+
+func TestCheckFileExtensions(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	tests := []struct {
+		name     string
+		planFile string
+		mdFile   string
+		wantErr  []string
+	}{
+		{name: "Correct", planFile: "plan.out", mdFile: "plan.md"},
+		{name: "Long Markdown extension", planFile: "tfplan", mdFile: "plan.markdown"},
+		{name: "Extensions are case-insensitive", planFile: "plan.OUT", mdFile: "PLAN.MD"},
+		{
+			name:     "Swapped",
+			planFile: "plan.md",
+			mdFile:   "plan.out",
+			wantErr:  []string{`'planFile' "plan.md" has a Markdown extension`, `'mdFile' "plan.out" doesn't end in .md or .markdown`},
+		},
+		{name: "Plan file in Markdown", planFile: "plan.Markdown", mdFile: "plan.md", wantErr: []string{"'planFile'"}},
+		{name: "Markdown without an extension", planFile: "plan.out", mdFile: "plan", wantErr: []string{"'mdFile'"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, checkFileExtensions(tt.planFile, tt.mdFile, false), "only warns by default")
+
+			err := checkFileExtensions(tt.planFile, tt.mdFile, true)
+			if len(tt.wantErr) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			for _, want := range tt.wantErr {
+				require.ErrorContains(t, err, want)
+			}
+			require.ErrorContains(t, err, "--strict-extensions")
+		})
+	}
+}
+
+func TestCheckMixedExtensions(t *testing.T) {
+	originalLogger := Logger
+	defer func() {
+		Logger = originalLogger
+	}()
+	var buf bytes.Buffer
+	Logger = log.NewWithOptions(&buf, log.Options{Level: log.InfoLevel})
+
+	tests := []struct {
+		name  string
+		files []string
+		mixed bool
+	}{
+		{name: "Terraform only", files: []string{"main.tf", "variables.tf"}},
+		{name: "OpenTofu only", files: []string{"main.tofu", "variables.tofu"}},
+		{name: "Other files alongside", files: []string{"main.tf", "terraform.tfvars", "README.md"}},
+		{name: "Both", files: []string{"main.tf", "main.tofu"}, mixed: true},
+		{name: "Both in different files", files: []string{"main.tf", "providers.tofu"}, mixed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, file := range tt.files {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, file), nil, 0o600))
+			}
+			buf.Reset()
+
+			require.NoError(t, checkMixedExtensions(dir, false), "only warns by default")
+			err := checkMixedExtensions(dir, true)
+
+			if !tt.mixed {
+				require.Empty(t, buf.String())
+				require.NoError(t, err)
+				return
+			}
+			require.Contains(t, buf.String(), "has both .tf and .tofu files")
+			require.Contains(t, buf.String(), "may plan different configurations")
+			require.EqualError(t, err, fmt.Sprintf("%q has both .tf and .tofu files (--strict-mixed-files is set)", dir))
+		})
+	}
+}
+
+func TestParseLogTimeFormat(t *testing.T) {
+	tests := []struct {
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{format: "RFC3339", want: time.RFC3339},
+		{format: "rfc3339nano", want: time.RFC3339Nano},
+		{format: "2006-01-02 15:04", want: "2006-01-02 15:04"},
+		{format: "", wantErr: true},
+		{format: "timestamp", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got, err := parseLogTimeFormat(tt.format)
+			if tt.wantErr {
+				require.ErrorContains(t, err, "invalid 'logTimeFormat'")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCreateLoggerTimeFormat(t *testing.T) {
+	t.Cleanup(func() {
+		logTimeFormat = ""
+		createLogger(false)
+		Logger.SetOutput(os.Stderr)
+	})
+	logTimeFormat = "2006-01-02T15:04"
+	createLogger(false)
+	var buf bytes.Buffer
+	Logger.SetOutput(&buf)
+
+	Logger.Info("planned")
+
+	require.Regexp(t, `^\d{4}-\d{2}-\d{2}T\d{2}:\d{2} INFO planned\n$`, buf.String())
+}
+
+func TestResolveBinary(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	// path creates a PATH containing fake binaries
+	path := func(t *testing.T, binaries ...string) string {
+		t.Helper()
+		dir := t.TempDir()
+		for _, b := range binaries {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, b), []byte("#!/bin/sh\n"), 0o700)) //nolint:gosec // must be executable
+		}
+		return dir
+	}
+
+	tests := []struct {
+		name        string
+		path        []string
+		binary      string
+		flagChanged bool
+		pin         string
+		want        BinaryResolution
+		wantErr     string
+	}{
+		{
+			name:        "Flag",
+			path:        []string{"tofu", "terraform"},
+			binary:      "tofu",
+			flagChanged: true,
+			want:        BinaryResolution{Source: binarySourceFlag, Binary: "tofu"},
+		},
+		{
+			name:   "Config",
+			path:   []string{"terraform"},
+			binary: "terraform",
+			want:   BinaryResolution{Source: binarySourceConfig, Binary: "terraform"},
+		},
+		{
+			name:    "Invalid config",
+			path:    []string{"terraform"},
+			binary:  "tf",
+			want:    BinaryResolution{Source: binarySourceConfig},
+			wantErr: "invalid binary specified",
+		},
+		{
+			name: "Auto-detected",
+			path: []string{"terraform"},
+			want: BinaryResolution{Source: binarySourceAuto, Candidates: []string{"terraform"}, Binary: "terraform"},
+		},
+		{
+			name: "Auto-detected with a pin",
+			path: []string{"tofu", "terraform"},
+			pin:  ".opentofu-version",
+			want: BinaryResolution{
+				Source:     binarySourceAuto,
+				Candidates: []string{"tofu", "terraform"},
+				Pinned:     "tofu",
+				Binary:     "tofu",
+			},
+		},
+		{
+			name:    "Both found",
+			path:    []string{"tofu", "terraform"},
+			want:    BinaryResolution{Source: binarySourceAuto, Candidates: []string{"tofu", "terraform"}},
+			wantErr: "found both",
+		},
+		{
+			name:    "None found",
+			want:    BinaryResolution{Source: binarySourceAuto},
+			wantErr: "could not find",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(viper.Reset)
+			t.Setenv("PATH", path(t, tt.path...))
+			t.Chdir(t.TempDir())
+			if tt.pin != "" {
+				require.NoError(t, os.WriteFile(tt.pin, []byte("1.9.0\n"), 0o600))
+			}
+			if tt.binary != "" {
+				viper.Set("binary", tt.binary)
+			}
+
+			got, err := resolveBinary(tt.flagChanged)
+
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, strings.ToLower(err.Error()), tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("Path to a binary outside of PATH", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		t.Setenv("PATH", path(t))
+		t.Chdir(path(t, "tofu-1.8.0"))
+		wd, err := os.Getwd()
+		require.NoError(t, err)
+		viper.Set("binary", "./tofu-1.8.0")
+
+		got, err := resolveBinary(true)
+
+		require.NoError(t, err)
+		require.Equal(t, BinaryResolution{Source: binarySourceFlag, Binary: filepath.Join(wd, "tofu-1.8.0")}, got)
+	})
+
+	t.Run("Invalid paths", func(t *testing.T) {
+		dir := path(t, "tofu")
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "terraform"), []byte("#!/bin/sh\n"), 0o600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "tf"), []byte("#!/bin/sh\n"), 0o700)) //nolint:gosec // an executable
+		for name, tc := range map[string]struct{ path, wantErr string }{
+			"Missing":        {filepath.Join(dir, "missing"), "not found"},
+			"Directory":      {dir + string(filepath.Separator), "is a directory"},
+			"Not executable": {filepath.Join(dir, "terraform"), "is not executable"},
+			"Another binary": {filepath.Join(dir, "tf"), "must be named 'terraform' or 'tofu'"},
+		} {
+			t.Run(name, func(t *testing.T) {
+				t.Cleanup(viper.Reset)
+				viper.Set("binary", tc.path)
+
+				_, err := resolveBinary(false)
+
+				require.ErrorContains(t, err, tc.wantErr)
+			})
+		}
+	})
+
+	t.Run("Rendered for logs", func(t *testing.T) {
+		require.Equal(t, "tofu (flag)", BinaryResolution{Source: binarySourceFlag, Binary: "tofu"}.String())
+		require.Equal(
+			t,
+			"tofu (auto: found tofu, terraform; pinned tofu)",
+			BinaryResolution{
+				Source:     binarySourceAuto,
+				Candidates: []string{"tofu", "terraform"},
+				Pinned:     "tofu",
+				Binary:     "tofu",
+			}.String(),
+		)
+		require.Equal(t, "none (auto: found none)", BinaryResolution{Source: binarySourceAuto}.String())
+	})
+}
+
+func TestBinaryKind(t *testing.T) {
+	require.Equal(t, "tofu", binaryKind("tofu"))
+	require.Equal(t, "terraform", binaryKind("terraform"))
+	require.Equal(t, "tofu", binaryKind("/opt/tools/tofu-1.8.0/tofu"))
+	require.Equal(t, "tofu", binaryKind("/opt/tools/tofu_1.8.0"))
+	require.Equal(t, "terraform", binaryKind("terraform.exe"))
+	require.Equal(t, "tf", binaryKind("/usr/local/bin/tf"))
+	require.Equal(t, "tofutils", binaryKind("tofutils"))
+}
@@ -4,16 +4,25 @@ package cmd
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+	"text/template"
 	"time"
 
 	"github.com/MakeNowJust/heredoc"
-	"github.com/briandowns/spinner"
 	"github.com/charmbracelet/log"
 	"github.com/fatih/color"
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform-exec/tfexec"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"golang.org/x/text/cases"
@@ -37,6 +46,9 @@ var (
 	planStr         string // Contents of the plan output
 )
 
+// noChangesPlan is the plan text rendered for an empty stdin with --allow-empty
+const noChangesPlan = "No changes. Your infrastructure matches the configuration."
+
 // A struct representing the files created by tp
 type tpFile struct {
 	Name    string
@@ -45,7 +57,8 @@ type tpFile struct {
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	Use:          "tp [-o <planfile>] [-m <mdfile>] [-b <binary>] | tp -",
+	Use:          "tp [-o <planfile>] [-m <mdfile>] [-b <binary>] [<planfile> [<mdfile>]] | tp -",
+	Args:         cobra.ArbitraryArgs,
 	SilenceUsage: true,
 	Short:        "A GitHub CLI extension to submit a pull request with Terraform or OpenTofu plan output.",
 	Long: heredoc.Doc(`
@@ -62,24 +75,57 @@ var rootCmd = &cobra.Command{
 
 	Use 'tp -' to read plan output directly from stdin.
 
+	The plan and Markdown files can also be passed as arguments, e.g.
+	'tp plan.out plan.md'. They override the config file, but not -o or -m.
+
 	View the README at https://github.com/esacteksab/gh-tp or run
 	'gh tp init' to create your .tp.toml config file now.
 	`),
 
-	RunE: func(cmd *cobra.Command, args []string) error {
+	RunE: func(cmd *cobra.Command, args []string) (runErr error) {
 		var err error
 		var planFileRaw string
 		var mdFileRaw string
 		var planFileValidated string
 		var mdFileValidated string
 
+		// --- Positional Plan & Markdown Files ---
+		args, err = applyPositionalFiles(cmd, args)
+		if err != nil {
+			Logger.Debugf("Error: %s", err)
+			return err
+		}
+
+		// --- Dump the Plan Environment ---
+		if viper.GetBool("dumpPlanEnv") {
+			planEnv, envErr := buildPlanEnv()
+			if envErr != nil {
+				return envErr
+			}
+			return dumpPlanEnv(cmd.OutOrStdout(), os.Environ(), planEnv)
+		}
+
 		// --- Determine Binary ---
-		binary, err = determineBinary()
+		binary, err = determineBinary(cmd)
 		if err != nil {
 			return err
 		}
 		Logger.Debugf("Using binary: %s", binary)
 
+		// --- Add Run Context to Log Lines ---
+		workingDir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to determine working directory: %w", err)
+		}
+		baseLogger := Logger
+		Logger = runLogger(baseLogger, workingDir, binary, currentWorkspace(workingDir))
+		log.SetDefault(Logger)
+		defer func() {
+			// Restore the base logger so later runs don't stack fields
+			Logger = baseLogger
+			log.SetDefault(baseLogger)
+		}()
+
 		// --- Get Config File Path (if loaded) ---
 		loadedConfigFile := viper.ConfigFileUsed() // Get path Viper actually used, if any
 		Logger.Debugf("loadedConfigFile in RunE is: %s", loadedConfigFile)
@@ -99,7 +145,7 @@ var rootCmd = &cobra.Command{
 			}
 		}
 		planFileRaw = viper.GetString("planFile")
-		planFileValidated, err = validateFilePath(planFileRaw)
+		planFileValidated, err = validateOutputName(planFileRaw)
 		if err != nil {
 			Logger.Debugf("planFile validation failed: %s", planFileRaw)
 			return fmt.Errorf("invalid 'planFile' configuration/flag (%q): %w", planFileRaw, err)
@@ -121,12 +167,319 @@ var rootCmd = &cobra.Command{
 			}
 		}
 		mdFileRaw = viper.GetString("mdFile")
-		mdFileValidated, err = validateFilePath(mdFileRaw)
+		mdFileValidated, err = validateOutputName(mdFileRaw)
 		if err != nil {
 			Logger.Debugf("mdFile validation failed: %s", mdFileRaw)
 			return fmt.Errorf("invalid 'mdFile' configuration/flag (%q): %w", mdFileRaw, err)
 		}
 		Logger.Debugf("Using markdown file: %s", mdFileValidated)
+		if err = checkFileExtensions(planFileValidated, mdFileValidated, viper.GetBool("strictExtensions")); err != nil {
+			return err
+		}
+
+		// --- Determine Output Formats ---
+		formats, err := parseFormats(viper.GetStringSlice("formats"))
+		if err != nil {
+			return err
+		}
+		if slices.Contains(formats, formatPlain) {
+			plainPath := formatPath(mdFileValidated, formatPlain)
+			if plainPath == mdFileValidated || plainPath == planFileValidated {
+				return fmt.Errorf(
+					"the plain format is written to %q, which must differ from 'planFile' and 'mdFile'",
+					plainPath,
+				)
+			}
+		}
+
+		// --- Apply [markdown] Config ---
+		mdConfig, err := applyMarkdownConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		// --- Resolve Paths Against the Repository Root ---
+		repoRoot, err := resolveRepoRoot(context.Background(), defaultGitRunner, viper.GetString("repoRoot"))
+		if err != nil {
+			return err
+		}
+		anchorRepoPaths(cmd, repoRoot)
+
+		// --- Load Markdown Template ---
+		var mdTemplate *template.Template
+		if mdTemplatePath := viper.GetString("mdTemplate"); mdTemplatePath != "" {
+			mdTemplate, err = loadMarkdownTemplate(mdTemplatePath)
+			if err != nil {
+				return err
+			}
+		}
+
+		// --- Determine Plan Text File Path ---
+		planTextValidated := ""
+		if planTextRaw := viper.GetString("planText"); planTextRaw != "" {
+			planTextValidated, err = validateOutputName(planTextRaw)
+			if err != nil {
+				return fmt.Errorf("invalid 'planText' configuration/flag (%q): %w", planTextRaw, err)
+			}
+			if planTextValidated == planFileValidated || planTextValidated == mdFileValidated ||
+				(slices.Contains(formats, formatPlain) && planTextValidated == formatPath(mdFileValidated, formatPlain)) {
+				return fmt.Errorf(
+					"invalid 'planText' (%q): must differ from 'planFile', 'mdFile' and the plain format's file",
+					planTextRaw,
+				)
+			}
+			Logger.Debugf("Using plan text file: %s", planTextValidated)
+		}
+
+		// --- Determine Plan Format ---
+		planFormat, err := parsePlanFormat(viper.GetString("planFormat"))
+		if err != nil {
+			return err
+		}
+		planJSONValidated := ""
+		if planFormat == planFormatJSON {
+			if len(args) > 0 || viper.GetString("runId") != "" || viper.GetString("planUrl") != "" {
+				Logger.Warn("'format json' only has an effect when tp runs the plan.")
+			} else {
+				planJSONValidated = planJSONPath(planFileValidated)
+				if planJSONValidated == planFileValidated || planJSONValidated == mdFileValidated ||
+					planJSONValidated == planTextValidated ||
+					(slices.Contains(formats, formatPlain) && planJSONValidated == formatPath(mdFileValidated, formatPlain)) {
+					return fmt.Errorf(
+						"the JSON plan is written to %q, which must differ from 'planFile', 'mdFile', 'planText' and the plain format's file",
+						planJSONValidated,
+					)
+				}
+				Logger.Debugf("Using plan JSON file: %s", planJSONValidated)
+			}
+		}
+
+		// --- Determine Output File Mode ---
+		fileMode, err := outputFileMode()
+		if err != nil {
+			return err
+		}
+		Logger.Debugf("Using file mode: %04o", fileMode)
+		// Printed once every output file is written
+		var mdStdout io.Writer
+		if viper.GetBool("mdStdout") {
+			mdStdout = cmd.OutOrStdout()
+		}
+
+		// --- Read PR Body Base ---
+		var bodyBase string
+		if bodyFile := viper.GetString("prBodyFile"); bodyFile != "" {
+			bodyBase, err = readBodyFile(bodyFile)
+			if err != nil {
+				return err
+			}
+			Logger.Debugf("Using PR body file: %s", bodyFile)
+		}
+
+		// --- Start the Deadline ---
+		deadline := viper.GetDuration("deadline")
+		if deadline < 0 {
+			return fmt.Errorf("invalid 'deadline' (%s): must not be negative", deadline)
+		}
+		ctx, cancel := runContext(context.Background(), deadline)
+		defer cancel()
+		defer func() { runErr = deadlineError(ctx, runErr) }()
+
+		// --- Ask the Binary What It Is ---
+		// Only when tp runs the plan, a plan from stdin, a run or a URL is named
+		// after the binary. A 'terraform' symlink to tofu is OpenTofu, whatever
+		// its name.
+		var tf *tfexec.Terraform // Caches the version for the checks below, nil when no plan runs
+		var info binaryInfo
+		if runsPlan(args) {
+			if tf, err = tfexec.NewTerraform(".", binary); err != nil {
+				return fmt.Errorf("tfexec init failed: %w", err)
+			}
+			var probeErr error
+			if info, probeErr = probeBinary(ctx, tf, binary); probeErr != nil {
+				Logger.Debugf("Unable to ask %s for its version, going by its name: %v", binary, probeErr)
+			}
+		}
+		product := productName(binary, info)
+
+		// --- Select the gh Account ---
+		if ghConfigDir := viper.GetString("ghConfigDir"); ghConfigDir != "" {
+			if err = useGhConfigDir(realGhRunner, ghConfigDir); err != nil {
+				return err
+			}
+		}
+
+		// --- Validate the Reported Binary Version ---
+		binaryVersion := ""
+		if v := viper.GetString("binaryVersion"); v != "" {
+			if binaryVersion, err = parseBinaryVersion(v); err != nil {
+				return err
+			}
+			Logger.Debugf("Reporting %s version %s", binary, binaryVersion)
+		}
+
+		// --- Validate the Minimum Binary Version ---
+		var minVersion *version.Version
+		if v := viper.GetString("minVersion"); v != "" {
+			if minVersion, err = parseMinVersion(v); err != nil {
+				return err
+			}
+		}
+
+		// --- Validate the Report ---
+		reportPath := viper.GetString("report")
+		protectedResources, err := compileProtectedResources()
+		if err != nil {
+			return err
+		}
+		if len(protectedResources) > 0 && reportPath == "" {
+			Logger.Warn("'protectedResources' has no effect without --report.")
+		}
+
+		// --- Validate the Environment ---
+		environment := viper.GetString("environment")
+		if err = validateEnvironment(environment); err != nil {
+			return err
+		}
+		if environment != "" {
+			Logger.Debugf(
+				"Labeling the plan with environment %s, pull request labels %v",
+				environment,
+				environmentLabels(environment),
+			)
+		}
+
+		// --- Validate Pull Request Settings ---
+		createPRFlag := viper.GetBool("createPr")
+		updatePRFlag := viper.GetBool("update")
+		baseRules, err := loadBaseRules()
+		if err != nil {
+			return err
+		}
+		// Shared so the default branch is looked up at most once per run
+		defaultBranch := newDefaultBranchCache(defaultGhRunner)
+		prBase := viper.GetString("base")
+		if prBase != "" {
+			if prBase, err = normalizeBranchName(prBase); err != nil {
+				return fmt.Errorf("invalid 'base': %w", err)
+			}
+		}
+		if viper.GetBool("draft") && !createPRFlag {
+			Logger.Warn("'draft' only has an effect with --create-pr.")
+		}
+		if prBase != "" && !createPRFlag && !viper.GetBool("changedDirsFromGit") && !viper.GetBool("skipIfNoTfChanges") {
+			Logger.Warn("'base' only has an effect with --create-pr, --changed-dirs-from-git or --skip-if-no-tf-changes.")
+		}
+		prTitle := resolvePRTitle(
+			ctx,
+			viper.GetString("prTitle"),
+			viper.GetBool("prTitleFromCommit"),
+			defaultGitRunner,
+			environmentTitle(planTitle(product), environment),
+		)
+		Logger.Debugf("Using pull request title: %q", prTitle)
+		prBodyMaxBytes, err := loadPRBodyMaxBytes()
+		if err != nil {
+			return err
+		}
+		Logger.Debugf("Pull request body is limited to %d bytes", prBodyMaxBytes)
+		noticeTmpl, err := loadTruncationNotice()
+		if err != nil {
+			return err
+		}
+		mergeMethod, err := parseMergeMethod(viper.GetString("mergeMethod"))
+		if err != nil {
+			return err
+		}
+		if viper.GetBool("autoMerge") {
+			Logger.Debugf("Auto-merge (%s) will be enabled on the pull request", strings.ToLower(mergeMethod))
+		}
+		if milestone := viper.GetString("milestone"); milestone != "" {
+			if strings.TrimSpace(milestone) == "" {
+				return errors.New("invalid 'milestone': a milestone number or title is required")
+			}
+			Logger.Debugf("Milestone %q will be set on the pull request", milestone)
+		}
+		destroyThreshold, err := loadConfirmDestroyCount()
+		if err != nil {
+			return err
+		}
+		if destroyThreshold > 0 && !createPRFlag && !updatePRFlag {
+			Logger.Warn("'confirmDestroyCount' only has an effect with --create-pr or --update.")
+		}
+		requiredReviewers, err := loadRequiredReviewers()
+		if err != nil {
+			return err
+		}
+		if len(requiredReviewers) > 0 && createPRFlag {
+			// Checked before planning, the pull request can't be created otherwise
+			if err = verifyRequiredReviewers(ctx, defaultReviewerClient, requiredReviewers); err != nil {
+				return err
+			}
+		}
+		if prGroupKey := viper.GetString("prGroupKey"); prGroupKey != "" {
+			if err = validatePRGroupKey(prGroupKey); err != nil {
+				return err
+			}
+			Logger.Debugf(
+				"The plan will be merged into the pull request of group %q as section %q",
+				prGroupKey,
+				prGroupSection(environment, repoRoot, workingDir),
+			)
+		}
+		prTemplates, err := loadPRTemplates()
+		if err != nil {
+			return err
+		}
+		if prTemplates.Small != "" || prTemplates.Large != "" || prTemplates.Destroy != "" {
+			Logger.Debugf("Pull request template will be chosen by the size of the changes: %+v", prTemplates)
+		}
+		// Only for a pull request body, and never while stdin holds the plan
+		pickTemplate := prTemplates.Default == "" && (createPRFlag || updatePRFlag) &&
+			(len(args) == 0 || args[0] != "-")
+		if viper.GetBool("requireTemplate") || pickTemplate {
+			templateRoot := repoRoot
+			if templateRoot == "" {
+				templateRoot = "."
+			}
+			templates, templateErr := findPRTemplate(templateRoot)
+			if templateErr != nil {
+				return templateErr
+			}
+			if viper.GetBool("requireTemplate") {
+				if err = requireTemplate(prTemplates.Default, templates); err != nil {
+					return err
+				}
+			}
+			if pickTemplate {
+				picked, pickErr := pickPRTemplate(templateRoot, templates)
+				switch {
+				case errors.Is(pickErr, ErrInterrupted):
+					Logger.Info("Operation cancelled by user.")
+					return nil
+				case pickErr != nil:
+					Logger.Warnf("Proceeding without a pull request template, set 'templateFile' to choose one: %v", pickErr)
+				default:
+					prTemplates.Default = picked
+				}
+			}
+		}
+
+		// --- Validate Notification Settings ---
+		notifyWebhook := viper.GetString("notifyWebhook")
+		if notifyWebhook != "" {
+			if err = validateWebhookURL(notifyWebhook); err != nil {
+				return err
+			}
+		} else if viper.GetBool("notifyRequired") {
+			Logger.Warn("'notify-required' has no effect without --notify-webhook.")
+		}
+		statusContext := viper.GetString("statusCheck")
+		if cmd.Flags().Changed("status-check") || statusContext != "" {
+			if err = validateStatusContext(statusContext); err != nil {
+				return err
+			}
+		}
 
 		// --- Logging & File Checks ---
 		if loadedConfigFile != "" {
@@ -137,25 +490,426 @@ var rootCmd = &cobra.Command{
 			Logger.Debug("No config file loaded; using flags and/or auto-detection for parameters.")
 		}
 
-		// Check for existence of .tf or .tofu files (only if not reading from stdin)
-		if len(args) == 0 {
+		// --- Validate the Plan URL ---
+		planURL := viper.GetString("planUrl")
+		if planURL != "" {
+			if len(args) > 0 {
+				return errors.New("'plan-url' can't be used with a plan argument")
+			}
+			if viper.GetString("runId") != "" {
+				return errors.New("'plan-url' can't be used with 'run-id'")
+			}
+			if err = validatePlanURL(planURL); err != nil {
+				return err
+			}
+		}
+
+		// --- Validate Plan Directories ---
+		dirs := viper.GetStringSlice("dirs")
+		if viper.GetBool("discover") {
+			if len(dirs) > 0 {
+				return errors.New("'discover' can't be used with 'dir'")
+			}
+			if runsPlan(args) {
+				patterns, patternErr := ignorePatterns()
+				if patternErr != nil {
+					return patternErr
+				}
+				dirs, err = discoverPlanDirs(".", patterns)
+				if err != nil {
+					return err
+				}
+				if len(dirs) == 0 {
+					return errors.New("no directories with .tf or .tofu files found to plan")
+				}
+			}
+		} else if len(dirs) == 0 && runsPlan(args) {
+			// --dir overrides the stacks of the config file
+			dirs, err = configuredStacks()
+			if err != nil {
+				return err
+			}
+		}
+
+		// --- Plan Only the Stacks Changed on the Branch ---
+		if viper.GetBool("changedDirsFromGit") {
+			switch {
+			case !runsPlan(args):
+				Logger.Warn("'changed-dirs-from-git' only has an effect when tp runs the plan.")
+			case viper.GetBool("all"):
+				Logger.Debug("Planning every stack, --all overrides --changed-dirs-from-git")
+			default:
+				if len(dirs) == 0 {
+					patterns, patternErr := ignorePatterns()
+					if patternErr != nil {
+						return patternErr
+					}
+					if dirs, err = discoverPlanDirs(".", patterns); err != nil {
+						return err
+					}
+					if len(dirs) == 0 {
+						return errors.New("no directories with .tf or .tofu files found to plan")
+					}
+				}
+				base := comparisonBase(ctx, prBase, baseRules, defaultGitRunner, defaultBranch)
+				files, filesErr := changedFiles(ctx, defaultGitRunner, base)
+				if filesErr != nil {
+					// Skipping stacks only saves time, so plan them all when unsure
+					Logger.Warnf("Unable to list the changed files, planning every stack: %v", filesErr)
+					break
+				}
+				changed, unowned := changedStacks(files, dirs)
+				if len(unowned) > 0 {
+					// e.g. a shared module, which any stack may call
+					Logger.Infof("%s changed outside of the stacks; planning every stack.", strings.Join(unowned, ", "))
+					break
+				}
+				if len(changed) == 0 {
+					Logger.Infof("No stack changed since %s; skipping the plan.", base)
+					return nil
+				}
+				Logger.Infof("Planning the %d of %d stacks changed since %s: %v", len(changed), len(dirs), base, changed)
+				dirs = changed
+			}
+		} else if viper.GetBool("all") {
+			Logger.Warn("'all' only has an effect with --changed-dirs-from-git.")
+		}
+		concurrency := 1
+		if len(dirs) > 0 && runsPlan(args) {
+			dirs, err = validatePlanDirs(dirs, viper.GetBool("allowDangerousDir"))
+			if err != nil {
+				return err
+			}
+			concurrency, err = planConcurrency()
+			if err != nil {
+				return err
+			}
+			Logger.Debugf("Planning %d directories, %d at a time", len(dirs), concurrency)
+		}
+
+		// Refuse to plan somewhere a plan is almost never intended
+		if len(args) == 0 && len(dirs) == 0 && planURL == "" && !viper.GetBool("allowDangerousDir") {
+			homeDir, _, cwd, dirErr := getDirectories()
+			if dirErr != nil {
+				return dirErr
+			}
+			if err = checkDangerousDir(cwd, homeDir); err != nil {
+				return err
+			}
+		}
+
+		// Check for existence of .tf or .tofu files (only if not reading from stdin or a URL)
+		if len(args) == 0 && len(dirs) == 0 && planURL == "" {
 			fileExts := []string{".tf", ".tofu"}
 			files := checkFilesByExtension(".", fileExts)
 			if !files {
 				titleCaser := cases.Title(language.English)
 				return fmt.Errorf(
 					"no %s files found in current directory. Please run this in a directory with %s files",
-					titleCaser.String(binary),
-					titleCaser.String(binary),
+					titleCaser.String(binaryKind(product)),
+					titleCaser.String(binaryKind(product)),
 				)
 			}
 		}
+		if runsPlan(args) {
+			planDirs := dirs
+			if len(planDirs) == 0 {
+				planDirs = []string{"."}
+			}
+			for _, dir := range planDirs {
+				if err = checkMixedExtensions(dir, viper.GetBool("strictMixedFiles")); err != nil {
+					return err
+				}
+			}
+		}
+
+		// --- Skip When No Terraform Files Changed ---
+		if viper.GetBool("skipIfNoTfChanges") {
+			if !runsPlan(args) {
+				Logger.Warn("'skip-if-no-tf-changes' only has an effect when tp runs the plan.")
+			} else {
+				base := comparisonBase(ctx, prBase, baseRules, defaultGitRunner, defaultBranch)
+				changed, changesErr := hasTFChanges(ctx, defaultGitRunner, base)
+				if changesErr != nil {
+					// Skipping only saves time, so plan when unsure
+					Logger.Warnf("Unable to check for Terraform changes, planning anyway: %v", changesErr)
+				} else if !changed {
+					Logger.Infof("No Terraform files changed since %s; skipping the plan.", base)
+					return nil
+				}
+			}
+		}
+
+		// --- Check the Minimum Binary Version ---
+		if minVersion != nil {
+			if runsPlan(args) {
+				if err = checkMinVersion(ctx, tf, binary, minVersion); err != nil {
+					return err
+				}
+			} else {
+				Logger.Warn("'minVersion' only has an effect when tp runs the plan.")
+			}
+		}
+
+		// --- Apply Resource Exclusions ---
+		excludes := viper.GetStringSlice("exclude")
+		if len(excludes) > 0 {
+			if runsPlan(args) {
+				if err = checkExcludes(ctx, tf, product); err != nil {
+					return err
+				}
+			} else {
+				Logger.Warn("'exclude' only has an effect when tp runs the plan.")
+			}
+		}
+
+		// --- Validate the Targets and Workspace ---
+		targets, err := parseTargets(viper.GetStringSlice("targets"))
+		if err != nil {
+			return err
+		}
+		if len(targets) > 0 && !runsPlan(args) {
+			Logger.Warn("'target' only has an effect when tp runs the plan.")
+			targets = nil
+		}
+		workspace := viper.GetString("planWorkspace")
+		if workspace != "" && !runsPlan(args) {
+			Logger.Warn("'workspace' only has an effect when tp runs the plan.")
+			workspace = ""
+		}
+
+		// --- List Files Changed on the Branch ---
+		sinceCommit := viper.GetString("sinceCommit")
+		var branchFiles []string
+		if sinceCommit != "" {
+			if !runsPlan(args) {
+				Logger.Warn("'since-commit' only has an effect when tp runs the plan.")
+				sinceCommit = ""
+			} else if branchFiles, err = changedFiles(ctx, defaultGitRunner, sinceCommit); err != nil {
+				return err
+			}
+		}
 
 		// --- Execution Logic ---
 		Logger.Debug("[LOG 1] Starting RunE execution...")
 
-		if len(args) == 0 { // Run plan mode
-			planStr, err = createPlan()
+		redactPatterns, err := compileRedactPatterns()
+		if err != nil {
+			return err
+		}
+		if len(redactPatterns) > 0 && !viper.GetBool("redact") && !viper.GetBool("prCommentOnFailure") {
+			Logger.Warn("'redactPatterns' has no effect without --redact or --pr-comment-on-failure.")
+		}
+
+		runID := viper.GetString("runId")
+		noChanges := false
+		var planResults []planResult
+		var outputFiles []tpFile
+		// Set when the plan is structured
+		var changes *changeCounts
+		var reportResults []planResult // The plans in the report, nil when tp didn't run them
+		var artifactURL string         // The attached plan file, linked from truncation notices
+
+		// --- Post the Commit Status Once the Run Ends ---
+		if statusContext != "" {
+			defer func() {
+				status := planStatus(
+					statusContext,
+					deadlineError(ctx, runErr),
+					noChanges,
+					changes,
+					runSecrets(),
+					redactPatterns,
+				)
+				if statusErr := postPlanStatus(ctx, defaultStatusClient, defaultGitRunner, status); statusErr != nil {
+					Logger.Warnf("Unable to post the commit status: %v", statusErr)
+				}
+			}()
+		}
+		// --- Comment a Failed Plan on the Pull Request ---
+		if viper.GetBool("prCommentOnFailure") {
+			defer func() {
+				failErr := deadlineError(ctx, runErr)
+				if !shouldCommentFailure(failErr) {
+					return
+				}
+				title := environmentTitle(planTitle(product), environment)
+				body := failureComment(failErr, title, environment, runSecrets(), redactPatterns, prBodyMaxBytes)
+				if commentErr := postFailureComment(ctx, defaultCommentClient, environment, body); commentErr != nil {
+					Logger.Warnf("Unable to comment the plan failure: %v", commentErr)
+				}
+			}()
+		}
+		// --- Markdown Options of Every Mode ---
+		baseOpts := markdownOptions{
+			GroupByModule:  viper.GetBool("groupByModule"),
+			Icons:          viper.GetBool("icons"),
+			Redact:         viper.GetBool("redact"),
+			RedactPatterns: redactPatterns,
+			FileMode:       fileMode,
+			Stdout:         mdStdout,
+			BodyBase:       bodyBase,
+			Deterministic:  viper.GetBool("deterministic"),
+			Template:       mdTemplate,
+			Syntax:         SyntaxHighlight(mdConfig.Syntax),
+			RawWhitespace:  viper.GetBool("rawWhitespace"),
+			BinaryVersion:  binaryVersion,
+			Environment:    environment,
+		}
+		// The options a plan tp runs adds
+		planOpts := baseOpts
+		planOpts.ShowDrift = viper.GetBool("showDrift")
+		planOpts.ShowOutputs = viper.GetBool("showOutputs")
+		planOpts.IncludeJSON = viper.GetBool("includeJson")
+		planOpts.Workspace = workspace
+
+		if len(args) == 0 && planURL != "" { // Plan URL mode
+			s := newSpinner(spinnerMessage(msgDownloadingPlan, product, 1))
+			s.Start()
+			content, fetchErr := fetchPlanURL(ctx, nil, planURL)
+			if fetchErr == nil {
+				content, fetchErr = decompressPlan("plan-url", content, maxPlanBytes)
+			}
+			s.Stop()
+			if fetchErr != nil {
+				return fetchErr
+			}
+			planStr, err = inputPlan(string(content), "plan-url", viper.GetBool("allowEmpty"))
+			if err != nil {
+				return err
+			}
+			noChanges = len(content) == 0
+			warnPlanOnlyFlags("plan-url", len(dirs) > 0, "")
+			if planTextValidated != "" {
+				if err = writePlanText(planTextValidated, planStr, fileMode); err != nil {
+					return err
+				}
+			}
+
+			// --- Generate Markdown ---
+			var mdErr error
+			mdParam, outputFiles, mdErr = createOutputs(formats, mdFileValidated, planStr, product, baseOpts)
+			if mdErr != nil {
+				return fmt.Errorf("markdown creation failed for '%s': %w", mdFileValidated, mdErr)
+			}
+			Logger.Debugf("Markdown file '%s' created from 'plan-url'.", mdParam)
+			Logger.Info(green(statusGlyphs(os.Getenv, runtime.GOOS).Success+" ") + " Markdown Created from plan URL...") // User feedback
+		} else if len(args) == 0 && runID != "" { // Remote run mode
+			if len(dirs) > 0 {
+				Logger.Warn("'dir' has no effect with --run-id.")
+			}
+			planStr, err = fetchRemotePlan(ctx, defaultTFCClient, runID)
+			if err != nil {
+				return err
+			}
+			if planTextValidated != "" {
+				if err = writePlanText(planTextValidated, planStr, fileMode); err != nil {
+					return err
+				}
+			}
+
+			// --- Generate Markdown ---
+			var mdErr error
+			mdParam, outputFiles, mdErr = createOutputs(formats, mdFileValidated, planStr, product, baseOpts)
+			if mdErr != nil {
+				return fmt.Errorf("markdown creation failed for '%s': %w", mdFileValidated, mdErr)
+			}
+			Logger.Debugf("Markdown file '%s' created from remote run %s.", mdParam, runID)
+		} else if len(args) == 0 && len(dirs) > 0 { // Multi-directory plan mode
+			sigCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			s := newSpinner(spinnerMessage(msgCreatingPlans, product, len(dirs)))
+			s.Start()
+			planResults, err = runPlans(sigCtx, dirs, concurrency, func(ctx context.Context, dir string) (planResult, error) {
+				return createPlan(ctx, dir, true)
+			})
+			s.Stop()
+			if errors.Is(err, ErrInterrupted) || errors.Is(err, ErrDeadlineExceeded) {
+				for _, dir := range dirs {
+					removeErr := os.Remove(filepath.Join(dir, planFileValidated))
+					if removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) {
+						Logger.Warnf("Cleanup failed in %q: %v", dir, removeErr)
+					}
+				}
+				if errors.Is(err, ErrDeadlineExceeded) {
+					return err
+				}
+				Logger.Info("Operation cancelled by user.")
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			reportResults = planResults
+			noChanges = true
+			total := changeCounts{}
+			changes = &total
+			sections := make([]planSection, 0, len(planResults))
+			for _, result := range planResults {
+				if err = os.Chmod(result.PlanPath, fileMode); err != nil {
+					return fmt.Errorf("failed to set permissions on plan file %s: %w", result.PlanPath, err)
+				}
+				// Only skip the PR when every directory is known to have no changes
+				if result.JSON == nil || planHasChanges(result.JSON) {
+					noChanges = false
+				}
+				if result.JSON == nil {
+					changes = nil
+				} else if changes != nil {
+					total = total.plus(countChanges(result.JSON))
+				}
+				if planTextValidated != "" {
+					if err = writePlanText(filepath.Join(result.Dir, planTextValidated), result.Text, fileMode); err != nil {
+						return err
+					}
+				}
+				if planJSONValidated != "" {
+					if err = writePlanJSON(filepath.Join(result.Dir, planJSONValidated), result.JSON, baseOpts); err != nil {
+						return err
+					}
+				}
+				section := planSection{
+					Dir:         result.Dir,
+					Text:        result.Text,
+					Plan:        result.JSON,
+					Attribution: branchAttribution(result.Dir, result.JSON, branchFiles, sinceCommit),
+				}
+				if viper.GetBool("includeCommand") {
+					section.Command = result.Command
+				}
+				sections = append(sections, section)
+			}
+
+			var notes []string
+			if len(excludes) > 0 {
+				notes = append(notes, excludeNote(excludes))
+			}
+			if len(targets) > 0 {
+				notes = append(notes, targetNote(targets))
+			}
+
+			// --- Generate Markdown ---
+			var mdErr error
+			mdOpts := planOpts
+			mdOpts.Notes = notes
+			mdOpts.Sections = sections
+			mdParam, outputFiles, mdErr = createOutputs(formats, mdFileValidated, "", product, mdOpts)
+			if mdErr != nil {
+				return fmt.Errorf("markdown creation failed for '%s': %w", mdFileValidated, mdErr)
+			}
+			Logger.Debugf("Markdown file '%s' created for %d directories.", mdParam, len(dirs))
+		} else if len(args) == 0 { // Run plan mode
+			if usesCloudBlock(".") {
+				Logger.Info(
+					"This configuration uses a 'cloud' block. To render the plan of an existing remote run, use --run-id.",
+				)
+			}
+			var result planResult
+			result, err = createPlan(ctx, ".", false)
+			planStr = result.Text
+			planJSON := result.JSON
 			Logger.Debugf("[LOG 2] createPlan returned. err: %v (type: %T)", err, err)
 
 			if err != nil {
@@ -189,13 +943,62 @@ var rootCmd = &cobra.Command{
 			}
 
 			Logger.Debug("[LOG 9] createPlan returned nil error. Proceeding.")
+			if err = os.Chmod(planFileValidated, fileMode); err != nil {
+				return fmt.Errorf("failed to set permissions on plan file %s: %w", planFileValidated, err)
+			}
+			if planTextValidated != "" {
+				if err = writePlanText(planTextValidated, planStr, fileMode); err != nil {
+					return err
+				}
+			}
+			if planJSONValidated != "" {
+				if err = writePlanJSON(planJSONValidated, planJSON, baseOpts); err != nil {
+					return err
+				}
+			}
+			reportResults = []planResult{{Dir: ".", JSON: planJSON}}
+			if planJSON != nil {
+				counts := countChanges(planJSON)
+				changes = &counts
+				noChanges = !planHasChanges(planJSON)
+				Logger.Debugf("Structured plan reports no changes: %t", noChanges)
+			}
 			// Logger.Info(green("✔ ") + " Plan Created...") // User feedback
 
 			// --- Generate Markdown ---
 			Logger.Debugf("Generating Markdown file '%s'...", mdFileValidated)
 			var mdErr error
+			mdOpts := planOpts
+			mdOpts.Plan = planJSON
+			mdOpts.Attribution = branchAttribution(".", planJSON, branchFiles, sinceCommit)
+			if viper.GetBool("includeCommand") {
+				mdOpts.Command = result.Command
+			}
+			if len(excludes) > 0 {
+				mdOpts.Notes = append(mdOpts.Notes, excludeNote(excludes))
+			}
+			if len(targets) > 0 {
+				mdOpts.Notes = append(mdOpts.Notes, targetNote(targets))
+			}
+			if gco := viper.GetString("generateConfigOut"); gco != "" {
+				mdOpts.Notes = append(mdOpts.Notes, fmt.Sprintf(
+					"Configuration for imported resources was generated to `%s`.", gco,
+				))
+			}
+			if viper.GetBool("attachPlan") && viper.GetBool("deterministic") {
+				Logger.Warn("'attach-plan' is skipped with --deterministic: the gist link changes on every run.")
+			} else if viper.GetBool("attachPlan") {
+				note, url, attachErr := attachPlan(ctx, defaultGistClient, planFileValidated)
+				if attachErr != nil {
+					// The plan text is still embedded, so don't fail the run
+					Logger.Warnf("Unable to attach the plan file: %v", attachErr)
+				} else {
+					mdOpts.Notes = append(mdOpts.Notes, note)
+					artifactURL = url
+				}
+			}
 			// Use mdFileValidated for the target path
-			mdParam, mdErr = createMarkdown(mdFileValidated, planStr, binary)
+			mdParam, outputFiles, mdErr = createOutputs(formats, mdFileValidated, planStr, product, mdOpts)
 			if mdErr != nil {
 				Logger.Debugf("Error: Markdown creation failed: %s", mdErr)
 				return fmt.Errorf("markdown creation failed for '%s': %w", mdFileValidated, mdErr)
@@ -204,8 +1007,7 @@ var rootCmd = &cobra.Command{
 			// Logger.Info(green("✔ ") + " Markdown Created...") // User feedback
 
 		} else if args[0] == "-" { // Stdin mode
-			s := spinner.New(spinner.CharSets[14], spinnerDuration)
-			s.Suffix = " Reading plan from stdin and creating Markdown..."
+			s := newSpinner(spinnerMessage(msgReadingStdin, product, 1))
 			s.Start()
 
 			Logger.Debugf("Reading plan from stdin...")
@@ -231,14 +1033,25 @@ var rootCmd = &cobra.Command{
 				Logger.Debugf("Error: %s", err)
 				return err
 			}
+			content, err = decompressPlan("stdin", content, maxPlanBytes)
+			if err != nil {
+				s.Stop() // Stop spinner before returning error
+				Logger.Debugf("Error: %s", err)
+				return err
+			}
 			s.Stop() // Stop spinner after reading
 
-			planStr = string(content)
-			if planStr == "" {
-				err = errors.New("received empty plan from stdin")
+			planStr, err = stdinPlan(string(content), viper.GetBool("allowEmpty"))
+			if err != nil {
 				Logger.Debugf("Error: %s", err)
 				return err
 			}
+			noChanges = len(content) == 0
+
+			if runID != "" {
+				Logger.Warn("'run-id' has no effect when reading the plan from stdin.")
+			}
+			warnPlanOnlyFlags("stdin", len(dirs) > 0, planTextValidated)
 
 			// Use mdFileValidated determined earlier
 			currentMdParam := mdFileValidated
@@ -246,14 +1059,14 @@ var rootCmd = &cobra.Command{
 
 			// --- Generate Markdown ---
 			var mdErr error
-			mdParam, mdErr = createMarkdown(currentMdParam, planStr, binary)
+			mdParam, outputFiles, mdErr = createOutputs(formats, currentMdParam, planStr, product, baseOpts)
 			if mdErr != nil {
 				err = fmt.Errorf("markdown creation failed for '%s': %w", currentMdParam, mdErr)
 				Logger.Debugf("Error: %s", err)
 				return err
 			}
 			Logger.Debugf("Markdown file '%s' created successfully from stdin.", mdParam)
-			Logger.Info(green("✔ ") + " Markdown Created from stdin...") // User feedback
+			Logger.Info(green(statusGlyphs(os.Getenv, runtime.GOOS).Success+" ") + " Markdown Created from stdin...") // User feedback
 
 		} else { // Handle unexpected arguments
 			err = fmt.Errorf("unexpected argument: %s. Use '-' to read from stdin or no arguments to run plan", args[0])
@@ -264,10 +1077,44 @@ var rootCmd = &cobra.Command{
 		// --- Final Check (adjusted based on mode) ---
 		Logger.Debug("[LOG 10] Reached final check.")
 		var filesToCheck []tpFile
-		if len(args) == 0 { // Ran plan mode
-			filesToCheck = []tpFile{{planFileValidated, "Plan"}, {mdParam, "Markdown"}}
+		if len(args) == 0 && (runID != "" || planURL != "") { // Ran remote run or plan URL mode
+			if planTextValidated != "" {
+				filesToCheck = append(filesToCheck, tpFile{planTextValidated, "Plan Text"})
+			}
+			filesToCheck = append(filesToCheck, outputFiles...)
+		} else if len(args) == 0 && len(dirs) > 0 { // Ran multi-directory plan mode
+			for _, result := range planResults {
+				filesToCheck = append(filesToCheck, tpFile{result.PlanPath, "Plan"})
+				if planTextValidated != "" {
+					filesToCheck = append(filesToCheck, tpFile{filepath.Join(result.Dir, planTextValidated), "Plan Text"})
+				}
+				if planJSONValidated != "" {
+					filesToCheck = append(filesToCheck, tpFile{filepath.Join(result.Dir, planJSONValidated), "Plan JSON"})
+				}
+			}
+			filesToCheck = append(filesToCheck, outputFiles...)
+		} else if len(args) == 0 { // Ran plan mode
+			filesToCheck = []tpFile{{planFileValidated, "Plan"}}
+			if planTextValidated != "" {
+				filesToCheck = append(filesToCheck, tpFile{planTextValidated, "Plan Text"})
+			}
+			if planJSONValidated != "" {
+				filesToCheck = append(filesToCheck, tpFile{planJSONValidated, "Plan JSON"})
+			}
+			filesToCheck = append(filesToCheck, outputFiles...)
 		} else if args[0] == "-" { // Stdin mode
-			filesToCheck = []tpFile{{mdParam, "Markdown"}}
+			filesToCheck = outputFiles
+		}
+
+		if reportPath != "" {
+			if reportResults == nil {
+				Logger.Warn("'report' only has an effect when tp runs the plan.")
+			} else {
+				if err = writeReport(reportPath, newReport(reportResults, protectedResources, Version), fileMode); err != nil {
+					return err
+				}
+				filesToCheck = append(filesToCheck, tpFile{reportPath, "Report"})
+			}
 		}
 
 		// Perform the check only if there are files expected
@@ -280,8 +1127,278 @@ var rootCmd = &cobra.Command{
 			}
 		}
 
+		if summaryPath := stepSummaryPath(); summaryPath != "" && doesExist(mdParam) {
+			notice, noticeErr := truncationNotice(noticeTmpl, artifactURL)
+			if noticeErr != nil {
+				Logger.Warnf("Using the generic truncation notice: %v", noticeErr)
+				notice = genericTruncationNotice
+			}
+			if err = appendStepSummary(summaryPath, mdParam, notice); err != nil {
+				// The job summary is a convenience, the Markdown file is what matters
+				Logger.Warnf("Unable to write the job summary: %v", err)
+			}
+		}
+
+		// --- Open or Update the Pull Request ---
+		var prURL string
+		switch {
+		case noChanges && viper.GetBool("skipPrOnNoChanges"):
+			Logger.Info("No changes; skipping PR.")
+		case (createPRFlag || updatePRFlag) && !doesExist(mdParam):
+			Logger.Warn("No Markdown was created; skipping PR.")
+		case createPRFlag || updatePRFlag:
+			if destroyThreshold > 0 && reportResults == nil {
+				Logger.Warn("'confirmDestroyCount' only has an effect when tp runs the plan.")
+			}
+			if err = confirmDestroy(reportResults, destroyThreshold, viper.GetBool("yes")); err != nil {
+				if !errors.Is(err, ErrInterrupted) {
+					return err
+				}
+				Logger.Info("Pull request cancelled by user.")
+				break
+			}
+			content, readErr := os.ReadFile(mdParam) //nolint:gosec // the Markdown file tp just wrote
+			if readErr != nil {
+				return fmt.Errorf("failed to read markdown file %s: %w", mdParam, readErr)
+			}
+			notice, noticeErr := truncationNotice(noticeTmpl, artifactURL)
+			if noticeErr != nil {
+				Logger.Warnf("Using the generic truncation notice: %v", noticeErr)
+				notice = genericTruncationNotice
+			}
+			// The body with this run's plan, given the pull request's current body
+			planBody := func(current string) string {
+				planMd := string(content)
+				if key := viper.GetString("prGroupKey"); key != "" {
+					section := prGroupSection(environment, repoRoot, workingDir)
+					room := planRoom(current, prBodyMaxBytes)
+					planMd = fitPRGroupBody(planBlock(current), key, section, planMd, room, notice)
+				}
+				return spliceBody(current, planMd, prBodyMaxBytes, notice)
+			}
+
+			if updatePRFlag {
+				prURL, err = updatePR(ctx, defaultPRClient, planBody)
+				switch {
+				case errors.Is(err, errNoPR) && createPRFlag:
+					Logger.Info("The branch has no open pull request, creating one.")
+				case errors.Is(err, errNoPR):
+					return fmt.Errorf("%w: pass --create-pr too to create it", err)
+				case err != nil:
+					return err
+				}
+			}
+			if prURL == "" {
+				// A new pull request's body starts with the template, once
+				prTemplate, templateErr := getTemplateFromConfig(prTemplates, noChanges, changes)
+				if templateErr != nil {
+					return templateErr
+				}
+				bodyFile, cleanup, bodyErr := writePRBody(planBody(prBodyTemplate(prTemplate)))
+				if bodyErr != nil {
+					return bodyErr
+				}
+				defer cleanup()
+				base := prBase
+				if base == "" {
+					branch := currentBranch(ctx, defaultGitRunner)
+					base = resolvePRBase("", branch, baseRules, defaultBranch.Get(ctx))
+				}
+				if prURL, err = createPR(ctx, defaultPRClient, newPR{
+					Title:     prTitle,
+					Base:      base,
+					BodyFile:  bodyFile,
+					Draft:     viper.GetBool("draft"),
+					Labels:    environmentLabels(environment),
+					Reviewers: requiredReviewers,
+				}); err != nil {
+					return err
+				}
+			}
+			if milestone := viper.GetString("milestone"); milestone != "" {
+				if err = setMilestone(ctx, defaultMilestoneClient, prURL, milestone); err != nil {
+					return err
+				}
+			}
+			if viper.GetBool("autoMerge") {
+				if err = enableAutoMerge(ctx, defaultAutoMergeClient, prURL, mergeMethod); err != nil {
+					return err
+				}
+			}
+		}
+
+		if notifyWebhook != "" {
+			payload := newNotifyPayload(ctx, defaultGitRunner, defaultGhRunner, changes, prURL)
+			if err = sendNotification(ctx, nil, notifyWebhook, payload); err != nil {
+				if viper.GetBool("notifyRequired") {
+					return err
+				}
+				Logger.Warnf("Unable to send the notification: %v", err)
+			}
+		}
+
 		Logger.Debug("✔ Processing complete.")
 		Logger.Debug("[LOG 11] RunE finished successfully.")
 		return nil // Success!
 	},
 }
+
+// runsPlan reports whether tp runs the plan itself, rather than reading it
+// from stdin, a remote run or a URL.
+func runsPlan(args []string) bool {
+	return len(args) == 0 && viper.GetString("runId") == "" && viper.GetString("planUrl") == ""
+}
+
+// excludeNote lists the resources excluded from the plan, for the Markdown.
+func excludeNote(excludes []string) string {
+	quoted := make([]string, 0, len(excludes))
+	for _, addr := range excludes {
+		quoted = append(quoted, "`"+addr+"`")
+	}
+	return "Excluded from this plan: " + strings.Join(quoted, ", ") + "."
+}
+
+// targetNote warns that the plan was limited to the targeted resources, for
+// the Markdown.
+func targetNote(targets []string) string {
+	quoted := make([]string, 0, len(targets))
+	for _, addr := range targets {
+		quoted = append(quoted, "`"+addr+"`")
+	}
+	return "This plan is targeted, only " + strings.Join(quoted, ", ") +
+		" and their dependencies were planned. It is not a full plan."
+}
+
+// writePlanText saves the shown plan text verbatim, for diffing or archival.
+//
+// Parameters:
+//
+//	path - The validated path of the plan text file.
+//	text - The plan output, as rendered in the Markdown.
+//	mode - The permission mode of the file.
+//
+// Returns:
+//
+//	error - Any error encountered writing the file.
+func writePlanText(path, text string, mode os.FileMode) error {
+	if err := os.WriteFile(path, []byte(text), mode); err != nil {
+		return fmt.Errorf("failed to write plan text file %s: %w", path, err)
+	}
+	// WriteFile applies the umask and keeps the mode of an existing file
+	if err := os.Chmod(path, mode); err != nil {
+		return fmt.Errorf("failed to set permissions on plan text file %s: %w", path, err)
+	}
+	Logger.Debugf("Wrote %d bytes of plan text to %s", len(text), path)
+	return nil
+}
+
+// stdinPlan returns the plan text read from stdin. Empty input is an error
+// unless allowEmpty is set, in which case a "No changes" plan is used so the
+// Markdown is still created.
+func stdinPlan(content string, allowEmpty bool) (string, error) {
+	return inputPlan(content, "stdin", allowEmpty)
+}
+
+// inputPlan returns the plan text read from source, e.g. stdin, as stdinPlan
+// does.
+func inputPlan(content, source string, allowEmpty bool) (string, error) {
+	if content != "" {
+		return content, nil
+	}
+	if !allowEmpty {
+		return "", fmt.Errorf("received empty plan from %s", source)
+	}
+	Logger.Infof("Received empty plan from %s, treating it as no changes.", source)
+	return noChangesPlan, nil
+}
+
+// warnPlanOnlyFlags warns about the flags that only have an effect when tp
+// runs the plan, when the plan is read from source instead.
+//
+// Parameters:
+//
+//	source - Where the plan is read from, e.g. stdin.
+//	hasDirs - Whether directories to plan were given.
+//	planText - The validated --plan-text, empty when not set or when source supports it.
+func warnPlanOnlyFlags(source string, hasDirs bool, planText string) {
+	for _, f := range []struct {
+		set  bool
+		name string
+	}{
+		{viper.GetString("generateConfigOut") != "", "generate-config-out"},
+		{viper.GetBool("checkFmt"), "check-fmt"},
+		{viper.GetBool("attachPlan"), "attach-plan"},
+		{hasDirs, "dir"},
+		{planText != "", "plan-text"},
+		{viper.GetBool("includeCommand"), "include-command"},
+	} {
+		if f.set {
+			Logger.Warnf("'%s' has no effect when reading the plan from %s.", f.name, source)
+		}
+	}
+}
+
+// applyPositionalFiles maps 'tp <planfile> [<mdfile>]' onto the planFile and
+// mdFile parameters. Positional files override the config file but not an
+// explicit -o or -m flag.
+//
+// Parameters:
+//
+//	cmd - The root command, used to check which flags were set.
+//	args - The positional arguments.
+//
+// Returns:
+//
+//	[]string - The arguments left for mode selection: nil once files are applied.
+//	error - An "unexpected argument" error for arguments that can't be files.
+func applyPositionalFiles(cmd *cobra.Command, args []string) ([]string, error) {
+	if len(args) == 0 || (len(args) == 1 && args[0] == "-") {
+		return args, nil
+	}
+
+	unexpected := func(arg string, reason error) error {
+		msg := fmt.Sprintf(
+			"unexpected argument: %s. Use '-' to read from stdin, no arguments to run plan or '<planfile> [<mdfile>]'",
+			arg,
+		)
+		if reason != nil {
+			return fmt.Errorf("%s: %w", msg, reason)
+		}
+		return errors.New(msg)
+	}
+
+	if len(args) > 2 { //nolint:mnd
+		return nil, unexpected(args[2], nil)
+	}
+	if cmd.SuggestionsMinimumDistance <= 0 {
+		// Cobra only sets its default while handling an unknown command
+		cmd.SuggestionsMinimumDistance = 2
+	}
+	for _, arg := range args {
+		if arg == "-" {
+			return nil, unexpected(arg, errors.New("'-' can't be combined with file arguments"))
+		}
+		// A mistyped subcommand is more likely than a plan file named like one
+		if suggestions := cmd.SuggestionsFor(arg); len(suggestions) > 0 {
+			return nil, unexpected(arg, fmt.Errorf("did you mean %q?", suggestions[0]))
+		}
+		if _, err := validateOutputName(arg); err != nil {
+			return nil, unexpected(arg, err)
+		}
+	}
+
+	params := []struct{ key, flag, short string }{
+		{"planFile", "planFile", "-o"},
+		{"mdFile", "mdFile", "-m"},
+	}
+	for i, arg := range args {
+		p := params[i]
+		if cmd.Flags().Changed(p.flag) {
+			Logger.Warnf("Ignoring argument %q, %s was given", arg, p.short)
+			continue
+		}
+		Logger.Debugf("Using %s %q from arguments", p.key, arg)
+		viper.Set(p.key, arg)
+	}
+	return nil, nil
+}
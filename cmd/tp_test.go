@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStdinPlan(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	t.Run("Empty stdin is an error by default", func(t *testing.T) {
+		_, err := stdinPlan("", false)
+
+		require.EqualError(t, err, "received empty plan from stdin")
+	})
+
+	t.Run("Empty stdin creates a no changes Markdown with allow empty", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+
+		plan, err := stdinPlan("", true)
+		require.NoError(t, err)
+		mdFile, err := createMarkdown("plan.md", plan, "terraform", markdownOptions{})
+		require.NoError(t, err)
+
+		got, err := os.ReadFile(mdFile)
+		require.NoError(t, err)
+		require.Contains(t, string(got), noChangesPlan)
+	})
+
+	t.Run("Plan text is returned unchanged", func(t *testing.T) {
+		plan, err := stdinPlan("Plan: 1 to add, 0 to change, 0 to destroy.", true)
+
+		require.NoError(t, err)
+		require.Equal(t, "Plan: 1 to add, 0 to change, 0 to destroy.", plan)
+	})
+}
+
+func TestApplyPositionalFiles(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	// newCmd returns a command with the root's file flags and an 'init' subcommand
+	newCmd := func(t *testing.T, flagArgs ...string) *cobra.Command {
+		t.Helper()
+		cmd := &cobra.Command{Use: "tp"}
+		cmd.Flags().StringP("planFile", "o", "", "")
+		cmd.Flags().StringP("mdFile", "m", "", "")
+		cmd.AddCommand(&cobra.Command{Use: "init", Run: func(*cobra.Command, []string) {}})
+		require.NoError(t, cmd.Flags().Parse(flagArgs))
+		return cmd
+	}
+	reset := func() {
+		viper.Set("planFile", "")
+		viper.Set("mdFile", "")
+	}
+
+	t.Run("No arguments and stdin are left alone", func(t *testing.T) {
+		for _, args := range [][]string{nil, {"-"}} {
+			got, err := applyPositionalFiles(newCmd(t), args)
+
+			require.NoError(t, err)
+			require.Equal(t, args, got)
+		}
+	})
+
+	t.Run("Two positional files set plan and markdown files", func(t *testing.T) {
+		t.Cleanup(reset)
+		viper.Set("planFile", "config.out")
+		viper.Set("mdFile", "config.md")
+
+		got, err := applyPositionalFiles(newCmd(t), []string{"plan.out", "plan.md"})
+
+		require.NoError(t, err)
+		require.Empty(t, got)
+		require.Equal(t, "plan.out", viper.GetString("planFile"))
+		require.Equal(t, "plan.md", viper.GetString("mdFile"))
+	})
+
+	t.Run("One positional file sets only the plan file", func(t *testing.T) {
+		t.Cleanup(reset)
+		viper.Set("mdFile", "config.md")
+
+		_, err := applyPositionalFiles(newCmd(t), []string{"plan.out"})
+
+		require.NoError(t, err)
+		require.Equal(t, "plan.out", viper.GetString("planFile"))
+		require.Equal(t, "config.md", viper.GetString("mdFile"))
+	})
+
+	t.Run("Explicit flags win over positional files", func(t *testing.T) {
+		t.Cleanup(reset)
+		cmd := newCmd(t, "-o", "flag.out")
+		viper.Set("planFile", "flag.out")
+
+		_, err := applyPositionalFiles(cmd, []string{"plan.out", "plan.md"})
+
+		require.NoError(t, err)
+		require.Equal(t, "flag.out", viper.GetString("planFile"))
+		require.Equal(t, "plan.md", viper.GetString("mdFile"))
+	})
+
+	testCases := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "Too many arguments",
+			args:    []string{"plan.out", "plan.md", "extra"},
+			wantErr: "unexpected argument: extra.",
+		},
+		{
+			name:    "Stdin combined with files",
+			args:    []string{"-", "plan.md"},
+			wantErr: "unexpected argument: -.",
+		},
+		{
+			name:    "Mistyped subcommand",
+			args:    []string{"inti"},
+			wantErr: `did you mean "init"?`,
+		},
+		{
+			name:    "Invalid filename",
+			args:    []string{"../plan.out"},
+			wantErr: "must be a filename only",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Cleanup(reset)
+
+			_, err := applyPositionalFiles(newCmd(t), tc.args)
+
+			require.ErrorContains(t, err, tc.wantErr)
+			require.Empty(t, viper.GetString("planFile"))
+		})
+	}
+}
+
+func TestWritePlanText(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+	t.Chdir(t.TempDir())
+	planText := "\nTerraform will perform the following actions:\n\n  # null_resource.a will be created\n\nPlan: 1 to add, 0 to change, 0 to destroy.\n"
+
+	require.NoError(t, os.WriteFile("plan.txt", []byte("stale"), 0o644)) //nolint:gosec // checks the mode is reset
+	require.NoError(t, writePlanText("plan.txt", planText, 0o600))
+
+	got, err := os.ReadFile("plan.txt")
+	require.NoError(t, err)
+	require.Equal(t, planText, string(got), "the plan text is written verbatim")
+	info, err := os.Stat("plan.txt")
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestRunsPlan(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	require.True(t, runsPlan(nil))
+	require.False(t, runsPlan([]string{"-"}), "stdin")
+
+	viper.Set("runId", "run-abc123")
+	require.False(t, runsPlan(nil), "remote run")
+
+	viper.Set("runId", "")
+	viper.Set("planUrl", "https://example.com/plan.txt")
+	require.False(t, runsPlan(nil), "plan URL")
+}
@@ -0,0 +1,163 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/cobra"
+)
+
+// upgradeConfigCmd represents the upgrade-config command
+var upgradeConfigCmd = &cobra.Command{
+	Use:               "upgrade-config",
+	Args:              cobra.NoArgs,
+	ValidArgsFunction: cobra.NoFileCompletions,
+	Short:             "Reformat the config file and restore its documentation comments.",
+	Long: heredoc.Doc(`
+		Reformat the .tp.toml config file tp loaded, validating it and writing
+		it back the way 'gh tp init' does, with a comment documenting each
+		parameter. Parameters 'gh tp init' doesn't write are kept as they are.
+		The config file is backed up first, see 'gh tp config backups'.`),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgPath, err := resolvedConfigPath()
+		if err != nil {
+			return err
+		}
+		return upgradeConfigFile(cfgPath)
+	},
+}
+
+// upgradeConfigFile reformats cfgPath with upgradeConfig, backing it up first.
+// A file that is already formatted is left alone.
+//
+// Parameters:
+//
+//	cfgPath - The path of the config file.
+//
+// Returns:
+//
+//	error - Any error encountered reading, validating or writing the file.
+func upgradeConfigFile(cfgPath string) error {
+	info, err := os.Stat(cfgPath)
+	if err != nil {
+		return fmt.Errorf("unable to read config file %s: %w", cfgPath, err)
+	}
+	data, err := os.ReadFile(cfgPath) //nolint:gosec // the config file viper loaded
+	if err != nil {
+		return fmt.Errorf("unable to read config file %s: %w", cfgPath, err)
+	}
+	upgraded, err := upgradeConfig(data)
+	if err != nil {
+		return fmt.Errorf("unable to upgrade %s: %w", cfgPath, err)
+	}
+	if bytes.Equal(upgraded, data) {
+		Logger.Infof("%s is already up to date", cfgPath)
+		return nil
+	}
+
+	backup, err := newBackup(cfgPath, time.Now())
+	if err != nil {
+		return err
+	}
+	Logger.Infof("Backup file %s created", backup)
+	if err = os.WriteFile(cfgPath, upgraded, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", cfgPath, err)
+	}
+	Logger.Infof("Config file %s upgraded", cfgPath)
+	return nil
+}
+
+// upgradeConfig reformats a config file: the ConfigParams are validated and
+// marshalled by genConfig, with their comments, and any other parameters are
+// marshalled after them so that nothing is lost.
+//
+// Parameters:
+//
+//	data - The contents of the config file.
+//
+// Returns:
+//
+//	[]byte - The reformatted config file.
+//	error - An error if the file isn't valid TOML or fails validation.
+func upgradeConfig(data []byte) ([]byte, error) {
+	var params ConfigParams
+	if err := toml.Unmarshal(data, &params); err != nil {
+		return nil, err
+	}
+	if err := validateConfig(params); err != nil {
+		return nil, err
+	}
+	known, err := genConfig(params)
+	if err != nil {
+		return nil, err
+	}
+
+	var all map[string]any
+	if err = toml.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	for _, key := range configParamKeys() {
+		delete(all, key)
+	}
+	if len(all) == 0 {
+		return known, nil
+	}
+	rest, err := toml.Marshal(all)
+	if err != nil {
+		return nil, fmt.Errorf("failed marshalling TOML: %w", err)
+	}
+
+	// Top-level keys must come before the first table, or they'd belong to it
+	restKeys, restTables := splitTables(string(rest))
+	knownKeys, knownTables := splitTables(string(known))
+	upgraded := knownKeys
+	if restKeys != "" {
+		upgraded = strings.TrimRight(upgraded, "\n") + "\n\n" + restKeys
+	}
+	for _, tables := range []string{knownTables, restTables} {
+		if tables != "" {
+			upgraded = strings.TrimRight(upgraded, "\n") + "\n\n" + tables
+		}
+	}
+	return []byte(upgraded), nil
+}
+
+// splitTables splits a TOML document before its first table header, keeping
+// the comment lines right above it with the table.
+func splitTables(doc string) (keys, tables string) {
+	lines := strings.SplitAfter(doc, "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(line), "[") {
+			continue
+		}
+		start := i
+		for start > 0 && strings.HasPrefix(strings.TrimSpace(lines[start-1]), "#") {
+			start--
+		}
+		return strings.Join(lines[:start], ""), strings.Join(lines[start:], "")
+	}
+	return doc, ""
+}
+
+// configParamKeys returns the TOML keys of ConfigParams.
+func configParamKeys() []string {
+	t := reflect.TypeFor[ConfigParams]()
+	keys := make([]string, 0, t.NumField())
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("toml"), ",")
+		keys = append(keys, name)
+	}
+	return keys
+}
+
+func init() {
+	rootCmd.AddCommand(upgradeConfigCmd)
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpgradeConfig(t *testing.T) {
+	if Logger == nil {
+		Logger = log.NewWithOptions(os.Stderr, log.Options{Level: log.InfoLevel})
+	}
+
+	t.Run("Comments are restored", func(t *testing.T) {
+		got, err := upgradeConfig([]byte("binary='tofu'\nplanFile =  'plan.out'\n  mdFile='plan.md'\n"))
+
+		require.NoError(t, err)
+		require.Contains(t, string(got), "# binary: (type: string) The name of the binary")
+		require.Contains(t, string(got), "# planFile: (type: string) The name of the plan file created by 'gh tp'.")
+		require.Contains(t, string(got), "# mdFile: (type: string) The name of the Markdown file created by 'gh tp'.")
+		require.Contains(t, string(got), "binary = 'tofu'\n")
+		require.Contains(t, string(got), "mdFile = 'plan.md'\n")
+
+		again, err := upgradeConfig(got)
+		require.NoError(t, err)
+		require.Equal(t, string(got), string(again), "upgrading is idempotent")
+	})
+
+	t.Run("Other parameters are kept", func(t *testing.T) {
+		cfg := `redact = true
+binary = 'terraform'
+planFile = 'plan.out'
+exclude = ['module.legacy']
+mdFile = 'plan.md'
+
+[planEnv]
+AWS_PROFILE = 'staging'
+
+[notify]
+url = 'https://hooks.example.com/tp'
+`
+		got, err := upgradeConfig([]byte(cfg))
+		require.NoError(t, err)
+
+		var want, upgraded map[string]any
+		require.NoError(t, toml.Unmarshal([]byte(cfg), &want))
+		require.NoError(t, toml.Unmarshal(got, &upgraded))
+		want["verbose"] = false
+		require.Equal(t, want, upgraded, string(got))
+		require.Contains(t, string(got), "# planEnv: (type: table)")
+	})
+
+	t.Run("Binary given as a path", func(t *testing.T) {
+		got, err := upgradeConfig([]byte("binary = '/opt/tools/tofu-1.8.0/tofu'\nplanFile = 'plan.out'\nmdFile = 'plan.md'\n"))
+
+		require.NoError(t, err)
+		require.Contains(t, string(got), "binary = '/opt/tools/tofu-1.8.0/tofu'\n")
+	})
+
+	t.Run("Invalid configs are rejected", func(t *testing.T) {
+		_, err := upgradeConfig([]byte("binary = 'tf'\nplanFile = 'plan.out'\nmdFile = 'plan.md'\n"))
+		require.ErrorContains(t, err, "Field: Binary")
+
+		_, err = upgradeConfig([]byte("binary = '/usr/local/bin/tf'\nplanFile = 'plan.out'\nmdFile = 'plan.md'\n"))
+		require.ErrorContains(t, err, "Field: Binary")
+
+		_, err = upgradeConfig([]byte("planFile = 'plan.out\n"))
+		require.Error(t, err)
+	})
+
+	t.Run("The config file is backed up", func(t *testing.T) {
+		cfgPath := filepath.Join(t.TempDir(), ConfigName)
+		original := "binary='terraform'\nplanFile='plan.out'\nmdFile='plan.md'\n"
+		require.NoError(t, os.WriteFile(cfgPath, []byte(original), 0o600))
+
+		require.NoError(t, upgradeConfigFile(cfgPath))
+
+		backups, err := listConfigBackups(cfgPath)
+		require.NoError(t, err)
+		require.Len(t, backups, 1)
+		backup, err := os.ReadFile(backups[0].Path)
+		require.NoError(t, err)
+		require.Equal(t, original, string(backup))
+		got, err := os.ReadFile(cfgPath)
+		require.NoError(t, err)
+		require.Contains(t, string(got), "# planFile:")
+	})
+}
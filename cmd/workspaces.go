@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: MIT
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/spf13/cobra"
+)
+
+// workspacesCmd represents the workspaces command
+var workspacesCmd = &cobra.Command{
+	Use:               "workspaces",
+	Args:              cobra.NoArgs,
+	ValidArgsFunction: cobra.NoFileCompletions,
+	Short:             "List the workspaces of the current directory.",
+	Long: heredoc.Doc(`
+		List the workspaces of the current directory with 'workspace list', the
+		selected one marked with '*', to choose one for --workspace. The binary
+		is chosen as for a plan: 'binary' from the config file, or the one
+		found in your PATH.`),
+	Example: heredoc.Doc(`
+		gh tp workspaces
+		gh tp workspaces --json`),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bin, err := determineBinary(nil)
+		if err != nil {
+			return err
+		}
+		tf, err := tfexec.NewTerraform(".", bin)
+		if err != nil {
+			return fmt.Errorf("tfexec init failed: %w", err)
+		}
+		workspaces, err := listWorkspaces(cmd.Context(), tf, bin)
+		if err != nil {
+			return err
+		}
+		asJSON, _ := cmd.Flags().GetBool("json")
+		return printWorkspaces(cmd.OutOrStdout(), workspaces, asJSON)
+	},
+}
+
+// workspace is a workspace listed by 'gh tp workspaces'.
+type workspace struct {
+	Name    string `json:"name"`
+	Current bool   `json:"current"`
+}
+
+// workspaceLister is the subset of *tfexec.Terraform used to list workspaces.
+type workspaceLister interface {
+	WorkspaceList(ctx context.Context, opts ...tfexec.WorkspaceListOption) ([]string, string, error)
+}
+
+// listWorkspaces lists the workspaces with 'workspace list', in its order.
+//
+// Parameters:
+//
+//	ctx - The context for 'workspace list'.
+//	wl - The workspace lister, normally a *tfexec.Terraform.
+//	binaryPath - The binary used, for errors.
+//
+// Returns:
+//
+//	[]workspace - The workspaces, the selected one marked current.
+//	error - Any error encountered listing them.
+func listWorkspaces(ctx context.Context, wl workspaceLister, binaryPath string) ([]workspace, error) {
+	names, current, err := wl.WorkspaceList(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list workspaces: %w", explainExecError(err, binaryPath))
+	}
+	workspaces := make([]workspace, 0, len(names))
+	for _, name := range names {
+		workspaces = append(workspaces, workspace{Name: name, Current: name == current})
+	}
+	return workspaces, nil
+}
+
+// printWorkspaces writes the workspaces one per line, the current one marked
+// with '*' as 'workspace list' does, or as a JSON array.
+func printWorkspaces(w io.Writer, workspaces []workspace, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(workspaces)
+	}
+	for _, ws := range workspaces {
+		marker := " "
+		if ws.Current {
+			marker = "*"
+		}
+		if _, err := fmt.Fprintf(w, "%s %s\n", marker, ws.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	workspacesCmd.Flags().Bool("json", false, "print the workspaces as a JSON array.")
+	rootCmd.AddCommand(workspacesCmd)
+}
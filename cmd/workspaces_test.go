@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: MIT
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWorkspaceLister returns canned workspaces.
+type fakeWorkspaceLister struct {
+	names   []string
+	current string
+	err     error
+}
+
+func (f fakeWorkspaceLister) WorkspaceList(
+	_ context.Context,
+	_ ...tfexec.WorkspaceListOption,
+) ([]string, string, error) {
+	return f.names, f.current, f.err
+}
+
+func TestListWorkspaces(t *testing.T) {
+	wl := fakeWorkspaceLister{names: []string{"default", "dev", "prod", "staging"}, current: "prod"}
+
+	workspaces, err := listWorkspaces(context.Background(), wl, "terraform")
+	require.NoError(t, err)
+
+	t.Run("The current workspace is marked", func(t *testing.T) {
+		var out bytes.Buffer
+
+		require.NoError(t, printWorkspaces(&out, workspaces, false))
+		require.Equal(t, "  default\n  dev\n* prod\n  staging\n", out.String())
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var out bytes.Buffer
+
+		require.NoError(t, printWorkspaces(&out, workspaces, true))
+		require.JSONEq(t, `[
+			{"name": "default", "current": false},
+			{"name": "dev", "current": false},
+			{"name": "prod", "current": true},
+			{"name": "staging", "current": false}
+		]`, out.String())
+	})
+
+	t.Run("No workspaces is an empty array", func(t *testing.T) {
+		var out bytes.Buffer
+		none, err := listWorkspaces(context.Background(), fakeWorkspaceLister{}, "terraform")
+		require.NoError(t, err)
+
+		require.NoError(t, printWorkspaces(&out, none, true))
+		require.JSONEq(t, `[]`, out.String())
+	})
+
+	t.Run("Errors are returned", func(t *testing.T) {
+		_, err := listWorkspaces(
+			context.Background(),
+			fakeWorkspaceLister{err: errors.New("exit status 1")},
+			"terraform",
+		)
+
+		require.EqualError(t, err, "unable to list workspaces: exit status 1")
+	})
+}
@@ -0,0 +1,5 @@
+module "db" {
+  source = "./modules/db"
+
+  password = random_password.db.result
+}
@@ -0,0 +1,8 @@
+resource "random_password" "db" {
+  length  = 32
+  special = false
+}
+
+resource "aws_s3_bucket" "logs" {
+  bucket = "example-logs"
+}
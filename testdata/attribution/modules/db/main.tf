@@ -0,0 +1,9 @@
+variable "password" {
+  type      = string
+  sensitive = true
+}
+
+resource "aws_db_instance" "main" {
+  engine   = "postgres"
+  password = var.password
+}
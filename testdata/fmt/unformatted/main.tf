@@ -0,0 +1,5 @@
+resource "null_resource" "example" {
+triggers = {
+    name  =   "example"
+  }
+}